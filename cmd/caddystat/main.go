@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"flag"
 	"fmt"
 	"log/slog"
@@ -40,8 +42,14 @@ func main() {
 	logging.Setup(cfg.LogLevel)
 
 	if *healthcheckFlag {
-		url := fmt.Sprintf("http://localhost%s/health", cfg.ListenAddr)
-		resp, err := http.Get(url)
+		scheme, client := "http", http.DefaultClient
+		if cfg.TLSEnabled() {
+			// The certificate is issued for the public name, not localhost
+			scheme = "https"
+			client = &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+		}
+		url := fmt.Sprintf("%s://localhost%s/health", scheme, cfg.ListenAddr)
+		resp, err := client.Get(url)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "health check failed: %v\n", err)
 			os.Exit(1)
@@ -54,12 +62,29 @@ func main() {
 		os.Exit(0)
 	}
 
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		os.Exit(1)
+	}
+
 	// Load bot signatures if configured (supports multiple files for community lists)
 	if len(cfg.BotSignaturesPaths) > 0 {
 		if err := useragent.LoadBotSignaturesList(cfg.BotSignaturesPaths); err != nil {
 			slog.Warn("failed to load bot signatures, using defaults", "paths", cfg.BotSignaturesPaths, "error", err)
 		}
 	}
+	if err := useragent.LoadBotRanges(cfg.BotRangesPaths); err != nil {
+		slog.Warn("failed to load bot ranges, using built-in ranges", "paths", cfg.BotRangesPaths, "error", err)
+	}
+	if err := useragent.LoadDeviceRules(cfg.DeviceRulesPath); err != nil {
+		slog.Warn("failed to load device rules, using built-in rules", "path", cfg.DeviceRulesPath, "error", err)
+	}
+	if strictness, ok := useragent.LookupStrictness(cfg.BotDetection); ok {
+		useragent.SetStrictness(strictness)
+	} else {
+		slog.Warn("unknown BOT_DETECTION value, using loose", "value", cfg.BotDetection)
+	}
+	storage.SetMillisDecimals(cfg.ResponseTimeDecimals)
 
 	// Load alerting configuration
 	alertCfg := alerts.LoadConfig()
@@ -68,8 +93,19 @@ func main() {
 	printStartupBanner(cfg, alertCfg)
 
 	store, err := storage.NewWithOptions(cfg.DBPath, storage.Options{
-		MaxConnections: cfg.DBMaxConnections,
-		QueryTimeout:   cfg.DBQueryTimeout,
+		MaxConnections:   cfg.DBMaxConnections,
+		QueryTimeout:     cfg.DBQueryTimeout,
+		StripQueryParams: cfg.StripQueryParams,
+		AssetExtensions:  cfg.AssetExtensions,
+		BusyTimeout:      cfg.DBBusyTimeout,
+		JournalMode:      cfg.DBJournalMode,
+		Synchronous:      cfg.DBSynchronous,
+
+		CountEstimateThreshold: cfg.CountEstimateThreshold,
+		MaxRecentRequests:      cfg.MaxRecentRequests,
+		UnknownLabel:           cfg.UnknownLabel,
+		DirectLabel:            cfg.DirectLabel,
+		PercentileMinSamples:   cfg.PercentileMinSamples,
 	})
 	if err != nil {
 		slog.Error("failed to initialize database", "error", err)
@@ -87,7 +123,7 @@ func main() {
 		slog.Debug("geo lookups disabled", "reason", "MAXMIND_DB_PATH not set")
 	}
 
-	hub := sse.NewHub(sse.WithBufferSize(cfg.SSEBufferSize))
+	hub := sse.NewHub(sse.WithBufferSize(cfg.SSEBufferSize), sse.WithRecentSize(cfg.RecentBufferSize))
 
 	// Initialize Prometheus metrics
 	m := metrics.New(
@@ -153,6 +189,9 @@ func main() {
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
+	// Pause ingest while the database volume is low on space
+	store.MonitorDiskSpace(ctx, cfg.MinFreeDiskBytes, time.Minute)
+
 	if err := ingestor.Start(ctx); err != nil {
 		slog.Error("failed to start ingestor", "error", err)
 		os.Exit(1)
@@ -163,6 +202,11 @@ func main() {
 		alertManager.Start(ctx)
 	}
 
+	// Expose per-host traffic totals as Prometheus gauges
+	if cfg.TrafficMetricsInterval > 0 {
+		go m.RunTrafficUpdater(ctx, storage.NewMetricsTrafficAdapter(store), cfg.TrafficMetricsHosts, cfg.TrafficMetricsInterval)
+	}
+
 	go func() {
 		dataTicker := time.NewTicker(12 * time.Hour)
 		sessionTicker := time.NewTicker(1 * time.Hour)
@@ -173,29 +217,37 @@ func main() {
 			case <-ctx.Done():
 				return
 			case <-dataTicker.C:
+				if cfg.CompactRawAfterDays > 0 {
+					if deleted, err := store.CompactRawData(context.Background(), cfg.CompactRawAfterDays); err != nil {
+						slog.Warn("raw data compaction failed", "error", err, "deleted", deleted)
+					} else if deleted > 0 {
+						slog.Info("compacted raw data", "deleted", deleted, "older_than_days", cfg.CompactRawAfterDays)
+					}
+				}
 				slog.Debug("running data cleanup", "default_retention_days", cfg.DataRetentionDays)
-				result, err := store.CleanupWithPerSiteRetention(context.Background(), cfg.DataRetentionDays)
-				if err != nil {
+				result, err := store.RunMaintenance(context.Background(), cfg.DataRetentionDays)
+				if errors.Is(err, storage.ErrMaintenanceRunning) {
+					slog.Debug("skipping data cleanup", "reason", "maintenance already running")
+				} else if result == nil {
 					slog.Warn("data cleanup failed", "error", err)
 				} else {
-					if result.TotalDeleted > 0 {
+					cleanup := result.Cleanup
+					if cleanup.TotalDeleted > 0 {
 						slog.Info("data cleanup completed",
-							"total_deleted", result.TotalDeleted,
-							"global_deleted", result.GlobalDeleted,
-							"sites_with_custom_retention", result.SitesProcessed,
+							"total_deleted", cleanup.TotalDeleted,
+							"global_deleted", cleanup.GlobalDeleted,
+							"sites_with_custom_retention", cleanup.SitesProcessed,
 						)
-						for host, deleted := range result.PerSiteDeleted {
+						for host, deleted := range cleanup.PerSiteDeleted {
 							slog.Debug("per-site cleanup", "host", host, "deleted", deleted)
 						}
 					} else {
 						slog.Debug("data cleanup completed", "total_deleted", 0)
 					}
-					// Run VACUUM after cleanup to reclaim disk space
-					slog.Debug("running database vacuum")
-					if bytesFreed, err := store.Vacuum(context.Background()); err != nil {
+					if err != nil {
 						slog.Warn("database vacuum failed", "error", err)
-					} else if bytesFreed > 0 {
-						slog.Info("database vacuum completed", "bytes_freed", bytesFreed)
+					} else if result.BytesFreed > 0 {
+						slog.Info("database vacuum completed", "bytes_freed", result.BytesFreed)
 					} else {
 						slog.Debug("database vacuum completed", "bytes_freed", 0)
 					}
@@ -211,14 +263,17 @@ func main() {
 		}
 	}()
 
-	srv := &http.Server{
-		Addr:    cfg.ListenAddr,
-		Handler: server.New(store, hub, cfg, m),
+	handler := server.New(store, hub, cfg, m)
+	handler.SetIngester(ingestor)
+	srv, err := server.NewHTTPServer(cfg, handler)
+	if err != nil {
+		slog.Error("failed to configure HTTP server", "error", err)
+		os.Exit(1)
 	}
 
 	go func() {
-		slog.Info("server started", "addr", cfg.ListenAddr, "version", version.Version)
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		slog.Info("server started", "addr", cfg.ListenAddr, "tls", cfg.TLSEnabled(), "version", version.Version)
+		if err := server.ListenAndServe(srv); err != nil && err != http.ErrServerClosed {
 			slog.Error("server failed", "error", err)
 			os.Exit(1)
 		}
@@ -227,16 +282,12 @@ func main() {
 	<-ctx.Done()
 	slog.Info("received shutdown signal, starting graceful shutdown...")
 
-	// Create a timeout context for the entire shutdown sequence
-	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancelShutdown()
-
 	// 1. Stop accepting new SSE connections and close existing ones
 	sseClients := hub.Close()
 	slog.Debug("closed SSE connections", "clients", sseClients)
 
 	// 2. Shutdown HTTP server (stops accepting new requests, waits for in-flight)
-	if err := srv.Shutdown(shutdownCtx); err != nil {
+	if err := shutdownServer(srv, cfg.ShutdownTimeout); err != nil {
 		slog.Warn("HTTP server shutdown error", "error", err)
 	} else {
 		slog.Debug("HTTP server stopped")
@@ -266,6 +317,15 @@ func main() {
 	slog.Info("shutdown complete")
 }
 
+// shutdownServer stops srv from accepting connections and waits up to
+// timeout for in-flight requests, such as long exports, to finish. It
+// returns context.DeadlineExceeded if they are still running at the deadline.
+func shutdownServer(srv *http.Server, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return srv.Shutdown(ctx)
+}
+
 func printStartupBanner(cfg config.Config, alertCfg alerts.Config) {
 	fmt.Println()
 	fmt.Println("  ╔═══════════════════════════════════════════════╗")
@@ -281,11 +341,19 @@ func printStartupBanner(cfg config.Config, alertCfg alerts.Config) {
 		fmt.Printf("  Built:          %s\n", version.BuildTime)
 	}
 	fmt.Println()
-	fmt.Printf("  Listen:         %s\n", cfg.ListenAddr)
+	if cfg.TLSEnabled() {
+		fmt.Printf("  Listen:         %s (TLS)\n", cfg.ListenAddr)
+	} else {
+		fmt.Printf("  Listen:         %s\n", cfg.ListenAddr)
+	}
 	fmt.Printf("  Database:       %s\n", cfg.DBPath)
+	fmt.Printf("  Data Dir:       %s\n", cfg.DataDir)
 	fmt.Printf("  Log Paths:      %s\n", strings.Join(cfg.LogPaths, ", "))
 	fmt.Printf("  Log Level:      %s\n", cfg.LogLevel.String())
 	fmt.Printf("  Retention:      %d days\n", cfg.DataRetentionDays)
+	if cfg.CompactRawAfterDays > 0 {
+		fmt.Printf("  Compact Raw:    after %d days\n", cfg.CompactRawAfterDays)
+	}
 	if cfg.MaxMindDBPath != "" {
 		fmt.Printf("  GeoIP:          %s\n", cfg.MaxMindDBPath)
 	}
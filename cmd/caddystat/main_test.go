@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// startBlockingServer serves a handler that blocks until release is closed
+// and returns once one request is in flight.
+func startBlockingServer(t *testing.T, release <-chan struct{}) *http.Server {
+	t.Helper()
+	entered := make(chan struct{})
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+	})}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go srv.Serve(ln)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String())
+		if err == nil {
+			resp.Body.Close()
+		}
+	}()
+	select {
+	case <-entered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("request never reached the handler")
+	}
+	return srv
+}
+
+func TestShutdownServer_RespectsTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	srv := startBlockingServer(t, release)
+	defer srv.Close()
+
+	const timeout = 200 * time.Millisecond
+	start := time.Now()
+	err := shutdownServer(srv, timeout)
+	elapsed := time.Since(start)
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("shutdownServer() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed < timeout || elapsed > timeout+2*time.Second {
+		t.Errorf("shutdownServer() took %v, want about %v", elapsed, timeout)
+	}
+}
+
+func TestShutdownServer_WaitsForInFlight(t *testing.T) {
+	release := make(chan struct{})
+	srv := startBlockingServer(t, release)
+	defer srv.Close()
+
+	time.AfterFunc(100*time.Millisecond, func() { close(release) })
+	if err := shutdownServer(srv, 5*time.Second); err != nil {
+		t.Errorf("shutdownServer() error = %v, want nil once the request finishes", err)
+	}
+}
@@ -1,8 +1,14 @@
 package config
 
 import (
+	"crypto/tls"
+	"errors"
+	"fmt"
 	"log/slog"
+	"net/url"
 	"os"
+	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
 	"time"
@@ -11,14 +17,43 @@ import (
 )
 
 type Config struct {
-	LogPaths                []string
+	LogPaths                []string // Files or glob patterns; patterns are re-expanded to find new files
+	LogDiscoveryInterval    time.Duration
+	QuarantineThreshold     int // Consecutive parse errors before a log file is quarantined (0 = never)
 	ListenAddr              string
+	ShutdownTimeout         time.Duration // How long shutdown waits for in-flight requests to finish
+	TLSCertFile             string        // With TLSKeyFile, serve HTTPS (and HTTP/2) instead of plain HTTP
+	TLSKeyFile              string
+	StaticDir               string // Dashboard files served at /; empty serves the assets embedded at build time
+	ServeDashboard          bool   // Serve the dashboard at /; false answers / with API info JSON (headless)
+	ContentSecurityPolicy   string // Content-Security-Policy header value (empty = built-in policy)
 	DBPath                  string
+	DataDir                 string // Backups, temp snapshots and other auxiliary files (default: DB_PATH's directory)
 	DataRetentionDays       int
+	CompactRawAfterDays     int // Delete raw rows older than this but keep their rollups (0 = off)
 	MaxMindDBPath           string
 	PrivacyHashIPs          bool
 	PrivacyHashSalt         string
-	PrivacyAnonymizeOctet   bool
+	PrivacyHashDaily        bool     // Hash IPs with an in-memory salt rotated at UTC midnight
+	AnonymizeIP             bool     // Truncate IPs (IPv4 /24, IPv6 /48) after geo lookup, before storing
+	ExcludePaths            []string // Path prefixes or glob patterns never recorded
+	ExcludeIPs              []string // IPs or CIDRs never recorded
+	InternalCIDRs           []string // IPs or CIDRs recorded but flagged as internal traffic
+	HonorDNT                bool     // Drop requests carrying "DNT: 1"
+	ReferrerSpamPath        string   // File of referrer spam domains, one per line
+	CaddyMetricsURL         string   // Caddy Prometheus metrics endpoint to poll for rollups (empty = disabled)
+	CaddyMetricsInterval    time.Duration
+	IngestAPIKey            string // Enables POST /api/ingest when set
+	IngestRatePerMinute     int    // Max push-ingest batches per minute per IP (0 = unlimited)
+	IngestDedup             bool   // Skip requests identical to one already stored (host, path, IP, time, status)
+	IngestSampleRate        int    // Store 1 in N requests, weighted by N (1 = store all)
+	IngestWorkers           int    // Goroutines parsing and enriching lines during import (1 = serial)
+	NormalizeHosts          bool   // Lower-case hosts and drop ports before storing
+	NormalizeHostsStripWWW  bool   // With NormalizeHosts, also drop a leading "www."
+	NormalizePaths          bool   // Collapse duplicate slashes in paths before storing
+	NormalizePathsLowercase bool   // With NormalizePaths, also lower-case the path (query string untouched)
+	NormalizePathsTrimSlash bool   // With NormalizePaths, also drop a trailing slash ("/" is kept)
+	MaxHeaderLength         int    // User-Agent and Referer values are cut to this many bytes at ingest (0 = unlimited)
 	RawRetentionHours       int
 	AggregationInterval     time.Duration
 	AggregationFlushSeconds int
@@ -27,33 +62,114 @@ type Config struct {
 	LogLevel                logging.Level
 	RateLimitPerMinute      int
 	MaxRequestBodyBytes     int64
+	AccessLogSampleRate     int      // Log 1 in N API requests at debug level (0 = disabled)
+	TrustedHosts            []string // Allowed Host header values (empty = allow all)
+	ScannerPatterns         []string // Path substrings that mark a 404 as a scanner probe
+	StripQueryParams        []string // Query params dropped before ranking top paths ("*" = whole query)
+	AssetExtensions         []string // File extensions not counted as page views (nil = built-in list)
 	DBMaxConnections        int
 	DBQueryTimeout          time.Duration
+	DBBusyTimeout           time.Duration
+	DBJournalMode           string
+	DBSynchronous           string
+	CountEstimateThreshold  int64    // Estimate the requests count from this many rows (0 = always exact)
+	MinFreeDiskBytes        int64    // Pause ingest when the DB volume has less free space (0 = disabled)
 	BotSignaturesPaths      []string // Comma-separated list of bot signature files (community lists)
+	BotRangesPaths          []string // BotName=path pairs of published crawler IP range files
+	DeviceRulesPath         string   // JSON file of extra device brand/model rules
+	BotDetection            string   // Bot detection strictness: strict, balanced or loose
 	SSEBufferSize           int      // Channel buffer size for SSE clients
+	RecentBufferSize        int      // Recent requests kept in memory for new SSE clients (0 = always query)
+	OnlinePushInterval      time.Duration
+	PollIntervalMin         time.Duration // Shortest X-Poll-Interval suggested to clients (busiest traffic)
+	PollIntervalMax         time.Duration // Longest X-Poll-Interval suggested to clients (no traffic)
+	TrafficMetricsInterval  time.Duration // How often per-host traffic gauges are refreshed (0 = disabled)
+	TrafficMetricsHosts     int           // Hosts with their own traffic gauges; the rest are summed as "other"
+	EnableProfiling         bool          // Serve net/http/pprof at /debug/pprof/ to admin sessions
+
+	// API defaults for requests without "range" or "limit" params
+	DefaultRange       time.Duration
+	DefaultTopLimit    int // Top-N lists such as visitors, referrers and robots
+	DefaultRecentLimit int // /api/stats/recent
+	MaxRecentRequests  int // Largest accepted /api/stats/recent limit
+	// Decimals kept for response times and percentiles in JSON (negative = full precision)
+	ResponseTimeDecimals int
+	UnknownLabel         string  // Reported for empty browser, OS, country, region and city values
+	DirectLabel          string  // Reported for requests without a referrer
+	SLOObjective         float64 // /api/stats/slo success target in percent, e.g. 99.9
+	SLOLatencyTargetMs   int     // Slower requests count as bad in /api/stats/slo (0 = status only)
+	PercentileMinSamples int     // Timed requests needed before /api/stats/performance reports percentiles
 
 	// Report configuration
-	ReportsEnabled        bool
-	ReportsStoragePath    string        // Directory to store generated reports
-	ReportsRetentionDays  int           // How long to keep generated reports
-	ReportsCheckInterval  time.Duration // How often to check for due reports
-	ReportsSMTPHost       string
-	ReportsSMTPPort       int
-	ReportsSMTPUsername   string
-	ReportsSMTPPassword   string
-	ReportsSMTPFrom       string
+	ReportsEnabled       bool
+	ReportsStoragePath   string        // Directory to store generated reports
+	ReportsRetentionDays int           // How long to keep generated reports
+	ReportsCheckInterval time.Duration // How often to check for due reports
+	ReportsSMTPHost      string
+	ReportsSMTPPort      int
+	ReportsSMTPUsername  string
+	ReportsSMTPPassword  string
+	ReportsSMTPFrom      string
+}
+
+// DefaultScannerPatterns are path fragments commonly probed by vulnerability
+// scanners, used by the scans report when SCANNER_PATTERNS is unset.
+var DefaultScannerPatterns = []string{
+	"/wp-login.php",
+	"/wp-admin",
+	"/xmlrpc.php",
+	"/.env",
+	"/.git",
+	"/.aws",
+	"/admin",
+	"/phpmyadmin",
+	"/cgi-bin",
+	"/vendor/phpunit",
+	"/actuator",
+	"/server-status",
+	"/config.php",
+	".sql",
+	".bak",
 }
 
 func Load() Config {
 	cfg := Config{
 		LogPaths:                splitEnv("LOG_PATH", []string{"./caddy.log"}),
+		LogDiscoveryInterval:    getEnvDuration("LOG_DISCOVERY_INTERVAL", 30*time.Second),
+		QuarantineThreshold:     getEnvInt("LOG_QUARANTINE_THRESHOLD", 1000),
 		ListenAddr:              getEnv("LISTEN_ADDR", ":8404"),
+		ShutdownTimeout:         getEnvDuration("SHUTDOWN_TIMEOUT", 10*time.Second),
+		TLSCertFile:             os.Getenv("TLS_CERT_FILE"),
+		TLSKeyFile:              os.Getenv("TLS_KEY_FILE"),
+		StaticDir:               os.Getenv("STATIC_DIR"),
+		ServeDashboard:          getEnvBool("SERVE_DASHBOARD", true),
+		ContentSecurityPolicy:   strings.TrimSpace(os.Getenv("CONTENT_SECURITY_POLICY")),
 		DBPath:                  getEnv("DB_PATH", "./data/caddystat.db"),
 		DataRetentionDays:       getEnvInt("DATA_RETENTION_DAYS", 7),
+		CompactRawAfterDays:     getEnvInt("COMPACT_RAW_AFTER_DAYS", 0),
 		MaxMindDBPath:           os.Getenv("MAXMIND_DB_PATH"),
 		PrivacyHashIPs:          getEnvBool("PRIVACY_HASH_IPS", false),
 		PrivacyHashSalt:         getEnv("PRIVACY_HASH_SALT", "caddystat"),
-		PrivacyAnonymizeOctet:   getEnvBool("PRIVACY_ANONYMIZE_LAST_OCTET", false),
+		PrivacyHashDaily:        getEnvBool("PRIVACY_HASH_DAILY_SALT", false),
+		AnonymizeIP:             getEnvBool("PRIVACY_ANONYMIZE_IP", getEnvBool("PRIVACY_ANONYMIZE_LAST_OCTET", false)),
+		ExcludePaths:            splitEnv("EXCLUDE_PATHS", nil),
+		ExcludeIPs:              splitEnv("EXCLUDE_IPS", nil),
+		InternalCIDRs:           splitEnv("INTERNAL_CIDRS", nil),
+		HonorDNT:                getEnvBool("HONOR_DNT", false),
+		ReferrerSpamPath:        getEnv("REFERRER_SPAM_PATH", ""),
+		CaddyMetricsURL:         getEnv("CADDY_METRICS_URL", ""),
+		CaddyMetricsInterval:    getEnvDuration("CADDY_METRICS_INTERVAL", 30*time.Second),
+		IngestAPIKey:            os.Getenv("INGEST_API_KEY"),
+		IngestRatePerMinute:     getEnvInt("INGEST_RATE_LIMIT_PER_MINUTE", 120),
+		IngestDedup:             getEnvBool("INGEST_DEDUP", false),
+		IngestSampleRate:        getEnvInt("INGEST_SAMPLE_RATE", 1),
+		IngestWorkers:           getEnvInt("INGEST_WORKERS", runtime.NumCPU()),
+		NormalizeHosts:          getEnvBool("NORMALIZE_HOSTS", false),
+		NormalizeHostsStripWWW:  getEnvBool("NORMALIZE_HOSTS_STRIP_WWW", false),
+		NormalizePaths:          getEnvBool("NORMALIZE_PATHS", false),
+		NormalizePathsLowercase: getEnvBool("NORMALIZE_PATHS_LOWERCASE", false),
+		NormalizePathsTrimSlash: getEnvBool("NORMALIZE_PATHS_TRIM_SLASH", false),
+		MaxHeaderLength:         getEnvInt("MAX_HEADER_LENGTH", 512),
 		RawRetentionHours:       getEnvInt("RAW_RETENTION_HOURS", 48),
 		AggregationInterval:     getEnvDuration("AGGREGATION_INTERVAL", time.Hour),
 		AggregationFlushSeconds: getEnvInt("AGGREGATION_FLUSH_SECONDS", 10),
@@ -62,10 +178,41 @@ func Load() Config {
 		LogLevel:                logging.ParseLevel(getEnv("LOG_LEVEL", "INFO")),
 		RateLimitPerMinute:      getEnvInt("RATE_LIMIT_PER_MINUTE", 0),
 		MaxRequestBodyBytes:     getEnvInt64("MAX_REQUEST_BODY_BYTES", 1<<20), // 1MB default
+		AccessLogSampleRate:     getEnvInt("ACCESS_LOG_SAMPLE_RATE", 1),
+		TrustedHosts:            splitEnv("TRUSTED_HOSTS", nil),
+		ScannerPatterns:         splitEnv("SCANNER_PATTERNS", DefaultScannerPatterns),
+		StripQueryParams:        splitEnv("TOP_PATHS_STRIP_QUERY", nil),
+		AssetExtensions:         splitEnv("ASSET_EXTENSIONS", nil),
 		DBMaxConnections:        getEnvInt("DB_MAX_CONNECTIONS", 1),
 		DBQueryTimeout:          getEnvDuration("DB_QUERY_TIMEOUT", 30*time.Second),
+		DBBusyTimeout:           getEnvDuration("DB_BUSY_TIMEOUT", 30*time.Second),
+		DBJournalMode:           getEnv("DB_JOURNAL_MODE", "WAL"),
+		DBSynchronous:           getEnv("DB_SYNCHRONOUS", "NORMAL"),
+		CountEstimateThreshold:  getEnvInt64("COUNT_ESTIMATE_THRESHOLD", 100_000),
+		MinFreeDiskBytes:        getEnvInt64("MIN_FREE_DISK_BYTES", 0),
 		BotSignaturesPaths:      splitEnv("BOT_SIGNATURES_PATH", nil),
+		BotRangesPaths:          splitEnv("BOT_RANGES_PATH", nil),
+		DeviceRulesPath:         os.Getenv("DEVICE_RULES_PATH"),
+		BotDetection:            getEnv("BOT_DETECTION", "loose"),
 		SSEBufferSize:           getEnvInt("SSE_BUFFER_SIZE", 32),
+		RecentBufferSize:        getEnvInt("RECENT_BUFFER_SIZE", 100),
+		OnlinePushInterval:      getEnvDuration("ONLINE_PUSH_INTERVAL", 10*time.Second),
+		PollIntervalMin:         getEnvDuration("POLL_INTERVAL_MIN", 5*time.Second),
+		PollIntervalMax:         getEnvDuration("POLL_INTERVAL_MAX", time.Minute),
+		TrafficMetricsInterval:  getEnvDuration("TRAFFIC_METRICS_INTERVAL", time.Minute),
+		TrafficMetricsHosts:     getEnvInt("TRAFFIC_METRICS_TOP_HOSTS", 20),
+		EnableProfiling:         getEnvBool("ENABLE_PROFILING", false),
+		// API defaults
+		DefaultRange:         getEnvDuration("DEFAULT_RANGE", 24*time.Hour),
+		DefaultTopLimit:      getEnvInt("DEFAULT_TOP_LIMIT", 20),
+		DefaultRecentLimit:   getEnvInt("DEFAULT_RECENT_LIMIT", 20),
+		MaxRecentRequests:    getEnvInt("MAX_RECENT_REQUESTS", 100),
+		ResponseTimeDecimals: getEnvInt("RESPONSE_TIME_DECIMALS", 2),
+		UnknownLabel:         getEnv("UNKNOWN_LABEL", "Unknown"),
+		DirectLabel:          getEnv("DIRECT_LABEL", "Direct / Bookmark"),
+		SLOObjective:         getEnvFloat("SLO_OBJECTIVE", 99.9),
+		SLOLatencyTargetMs:   getEnvInt("SLO_LATENCY_TARGET_MS", 500),
+		PercentileMinSamples: getEnvInt("PERCENTILE_MIN_SAMPLES", 20),
 		// Report configuration
 		ReportsEnabled:       getEnvBool("REPORTS_ENABLED", false),
 		ReportsStoragePath:   getEnv("REPORTS_STORAGE_PATH", "./data/reports"),
@@ -77,6 +224,7 @@ func Load() Config {
 		ReportsSMTPPassword:  os.Getenv("REPORTS_SMTP_PASSWORD"),
 		ReportsSMTPFrom:      getEnv("REPORTS_SMTP_FROM", "caddystat@localhost"),
 	}
+	cfg.DataDir = getEnv("DATA_DIR", filepath.Dir(cfg.DBPath))
 
 	return cfg
 }
@@ -94,6 +242,337 @@ func getEnvInt64(key string, def int64) int64 {
 	return parsed
 }
 
+// Errors reported by Validate; match them with errors.Is.
+var (
+	ErrLogPathMissing     = errors.New("log path does not exist")
+	ErrDBDirNotWritable   = errors.New("database directory is not writable")
+	ErrDataDirNotWritable = errors.New("data directory is not writable")
+	ErrAuthIncomplete     = errors.New("AUTH_USERNAME and AUTH_PASSWORD must be set together")
+	ErrTLSIncomplete      = errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	ErrTLSKeyPair         = errors.New("TLS certificate and key could not be loaded")
+	ErrInvalidCSP         = errors.New("CONTENT_SECURITY_POLICY is not a valid policy")
+	ErrProfilingNoAuth    = errors.New("ENABLE_PROFILING requires AUTH_USERNAME and AUTH_PASSWORD")
+	ErrPollIntervalRange  = errors.New("POLL_INTERVAL_MIN must not exceed POLL_INTERVAL_MAX")
+	ErrSLOObjectiveRange  = errors.New("SLO_OBJECTIVE must be above 0 and at most 100")
+)
+
+// Validate checks settings that Load cannot catch by falling back to a
+// default. It returns every problem found, joined, or nil.
+//
+// A log path passes if the file exists or its directory does, since Caddy
+// creates the file on first write. The database and data directories may
+// not exist yet (they are created on demand) but their nearest existing
+// parents must be writable.
+func (c Config) Validate() error {
+	var errs []error
+	for _, p := range c.LogPaths {
+		if _, err := os.Stat(p); err == nil {
+			continue
+		}
+		if fi, err := os.Stat(filepath.Dir(p)); err != nil || !fi.IsDir() {
+			errs = append(errs, fmt.Errorf("%w: LOG_PATH %q (directory %q not found)", ErrLogPathMissing, p, filepath.Dir(p)))
+		}
+	}
+	if err := checkWritableDir(filepath.Dir(c.DBPath)); err != nil {
+		errs = append(errs, fmt.Errorf("%w: DB_PATH %q: %v", ErrDBDirNotWritable, c.DBPath, err))
+	}
+	if c.DataDir != "" {
+		if err := checkWritableDir(c.DataDir); err != nil {
+			errs = append(errs, fmt.Errorf("%w: DATA_DIR %q: %v", ErrDataDirNotWritable, c.DataDir, err))
+		}
+	}
+	if (c.AuthUsername == "") != (c.AuthPassword == "") {
+		errs = append(errs, ErrAuthIncomplete)
+	}
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		errs = append(errs, ErrTLSIncomplete)
+	} else if c.TLSEnabled() {
+		if _, err := tls.LoadX509KeyPair(c.TLSCertFile, c.TLSKeyFile); err != nil {
+			errs = append(errs, fmt.Errorf("%w: %v", ErrTLSKeyPair, err))
+		}
+	}
+	if c.EnableProfiling && !c.AuthEnabled() {
+		errs = append(errs, ErrProfilingNoAuth)
+	}
+	if c.PollIntervalMin > 0 && c.PollIntervalMax > 0 && c.PollIntervalMin > c.PollIntervalMax {
+		errs = append(errs, ErrPollIntervalRange)
+	}
+	if c.SLOObjective <= 0 || c.SLOObjective > 100 {
+		errs = append(errs, ErrSLOObjectiveRange)
+	}
+	if c.ContentSecurityPolicy != "" {
+		if err := validateCSP(c.ContentSecurityPolicy); err != nil {
+			errs = append(errs, fmt.Errorf("%w: %v", ErrInvalidCSP, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateCSP checks that policy is a list of ";"-separated directives, each
+// a lower-case name such as "script-src" followed by optional sources, and
+// that it holds nothing that cannot go in a header value or would split it
+// into a second policy.
+func validateCSP(policy string) error {
+	for _, r := range policy {
+		if (r < 0x20 && r != '\t') || r > 0x7e || r == ',' {
+			return fmt.Errorf("invalid character %q", r)
+		}
+	}
+	seen := make(map[string]bool)
+	for _, directive := range strings.Split(policy, ";") {
+		fields := strings.Fields(directive)
+		if len(fields) == 0 {
+			continue
+		}
+		name := fields[0]
+		if strings.Trim(name, "abcdefghijklmnopqrstuvwxyz-") != "" {
+			return fmt.Errorf("invalid directive name %q", name)
+		}
+		if seen[name] {
+			return fmt.Errorf("duplicate directive %q", name)
+		}
+		seen[name] = true
+	}
+	if len(seen) == 0 {
+		return errors.New("no directives")
+	}
+	return nil
+}
+
+// TLSEnabled reports whether both TLS_CERT_FILE and TLS_KEY_FILE are set.
+func (c Config) TLSEnabled() bool {
+	return c.TLSCertFile != "" && c.TLSKeyFile != ""
+}
+
+// checkWritableDir creates and removes a temp file in dir, or in its nearest
+// existing ancestor when dir has not been created yet.
+func checkWritableDir(dir string) error {
+	for {
+		fi, err := os.Stat(dir)
+		if err == nil {
+			if !fi.IsDir() {
+				return fmt.Errorf("%s is not a directory", dir)
+			}
+			break
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return err
+		}
+		dir = parent
+	}
+	f, err := os.CreateTemp(dir, ".caddystat-write-check-*")
+	if err != nil {
+		return err
+	}
+	name := f.Name()
+	f.Close()
+	return os.Remove(name)
+}
+
+// Sanitized is the running configuration with secrets replaced by "set" or
+// "unset", safe to show to an administrator. Durations are Go duration
+// strings.
+type Sanitized struct {
+	LogPaths                []string `json:"log_paths"`
+	LogDiscoveryInterval    string   `json:"log_discovery_interval"`
+	QuarantineThreshold     int      `json:"log_quarantine_threshold"`
+	ListenAddr              string   `json:"listen_addr"`
+	ShutdownTimeout         string   `json:"shutdown_timeout"`
+	TLSCertFile             string   `json:"tls_cert_file"`
+	TLSKeyFile              string   `json:"tls_key_file"`
+	StaticDir               string   `json:"static_dir"`
+	ServeDashboard          bool     `json:"serve_dashboard"`
+	ContentSecurityPolicy   string   `json:"content_security_policy"`
+	DBPath                  string   `json:"db_path"`
+	DataDir                 string   `json:"data_dir"`
+	DataRetentionDays       int      `json:"data_retention_days"`
+	CompactRawAfterDays     int      `json:"compact_raw_after_days"`
+	RawRetentionHours       int      `json:"raw_retention_hours"`
+	MaxMindDBPath           string   `json:"maxmind_db_path"`
+	BotSignaturesPaths      []string `json:"bot_signatures_paths"`
+	BotRangesPaths          []string `json:"bot_ranges_paths"`
+	DeviceRulesPath         string   `json:"device_rules_path"`
+	BotDetection            string   `json:"bot_detection"`
+	ReferrerSpamPath        string   `json:"referrer_spam_path"`
+	PrivacyHashIPs          bool     `json:"privacy_hash_ips"`
+	PrivacyHashSalt         string   `json:"privacy_hash_salt"`
+	PrivacyHashDaily        bool     `json:"privacy_hash_daily_salt"`
+	AnonymizeIP             bool     `json:"privacy_anonymize_ip"`
+	ExcludePaths            []string `json:"exclude_paths"`
+	ExcludeIPs              []string `json:"exclude_ips"`
+	InternalCIDRs           []string `json:"internal_cidrs"`
+	HonorDNT                bool     `json:"honor_dnt"`
+	CaddyMetricsURL         string   `json:"caddy_metrics_url"`
+	CaddyMetricsInterval    string   `json:"caddy_metrics_interval"`
+	IngestAPIKey            string   `json:"ingest_api_key"`
+	IngestRatePerMinute     int      `json:"ingest_rate_limit_per_minute"`
+	IngestDedup             bool     `json:"ingest_dedup"`
+	IngestSampleRate        int      `json:"ingest_sample_rate"`
+	IngestWorkers           int      `json:"ingest_workers"`
+	NormalizeHosts          bool     `json:"normalize_hosts"`
+	NormalizeHostsStripWWW  bool     `json:"normalize_hosts_strip_www"`
+	NormalizePaths          bool     `json:"normalize_paths"`
+	NormalizePathsLowercase bool     `json:"normalize_paths_lowercase"`
+	NormalizePathsTrimSlash bool     `json:"normalize_paths_trim_slash"`
+	MaxHeaderLength         int      `json:"max_header_length"`
+	AggregationInterval     string   `json:"aggregation_interval"`
+	PollIntervalMin         string   `json:"poll_interval_min"`
+	PollIntervalMax         string   `json:"poll_interval_max"`
+	AggregationFlushSeconds int      `json:"aggregation_flush_seconds"`
+	AuthUsername            string   `json:"auth_username"`
+	AuthPassword            string   `json:"auth_password"`
+	LogLevel                string   `json:"log_level"`
+	RateLimitPerMinute      int      `json:"rate_limit_per_minute"`
+	MaxRequestBodyBytes     int64    `json:"max_request_body_bytes"`
+	AccessLogSampleRate     int      `json:"access_log_sample_rate"`
+	TrustedHosts            []string `json:"trusted_hosts"`
+	ScannerPatterns         []string `json:"scanner_patterns"`
+	StripQueryParams        []string `json:"top_paths_strip_query"`
+	AssetExtensions         []string `json:"asset_extensions"`
+	DBMaxConnections        int      `json:"db_max_connections"`
+	DBQueryTimeout          string   `json:"db_query_timeout"`
+	DBBusyTimeout           string   `json:"db_busy_timeout"`
+	DBJournalMode           string   `json:"db_journal_mode"`
+	DBSynchronous           string   `json:"db_synchronous"`
+	CountEstimateThreshold  int64    `json:"count_estimate_threshold"`
+	MinFreeDiskBytes        int64    `json:"min_free_disk_bytes"`
+	SSEBufferSize           int      `json:"sse_buffer_size"`
+	RecentBufferSize        int      `json:"recent_buffer_size"`
+	OnlinePushInterval      string   `json:"online_push_interval"`
+	TrafficMetricsInterval  string   `json:"traffic_metrics_interval"`
+	TrafficMetricsHosts     int      `json:"traffic_metrics_top_hosts"`
+	EnableProfiling         bool     `json:"enable_profiling"`
+	DefaultRange            string   `json:"default_range"`
+	DefaultTopLimit         int      `json:"default_top_limit"`
+	DefaultRecentLimit      int      `json:"default_recent_limit"`
+	MaxRecentRequests       int      `json:"max_recent_requests"`
+	ResponseTimeDecimals    int      `json:"response_time_decimals"`
+	UnknownLabel            string   `json:"unknown_label"`
+	DirectLabel             string   `json:"direct_label"`
+	SLOObjective            float64  `json:"slo_objective"`
+	SLOLatencyTargetMs      int      `json:"slo_latency_target_ms"`
+	PercentileMinSamples    int      `json:"percentile_min_samples"`
+	ReportsEnabled          bool     `json:"reports_enabled"`
+	ReportsStoragePath      string   `json:"reports_storage_path"`
+	ReportsRetentionDays    int      `json:"reports_retention_days"`
+	ReportsCheckInterval    string   `json:"reports_check_interval"`
+	ReportsSMTPHost         string   `json:"reports_smtp_host"`
+	ReportsSMTPPort         int      `json:"reports_smtp_port"`
+	ReportsSMTPUsername     string   `json:"reports_smtp_username"`
+	ReportsSMTPPassword     string   `json:"reports_smtp_password"`
+	ReportsSMTPFrom         string   `json:"reports_smtp_from"`
+}
+
+// Sanitized returns c with secrets redacted. Credentials embedded in
+// CADDY_METRICS_URL are masked as well.
+func (c Config) Sanitized() Sanitized {
+	metricsURL := c.CaddyMetricsURL
+	if u, err := url.Parse(metricsURL); err == nil {
+		metricsURL = u.Redacted()
+	}
+	return Sanitized{
+		LogPaths:                c.LogPaths,
+		LogDiscoveryInterval:    c.LogDiscoveryInterval.String(),
+		QuarantineThreshold:     c.QuarantineThreshold,
+		ListenAddr:              c.ListenAddr,
+		ShutdownTimeout:         c.ShutdownTimeout.String(),
+		TLSCertFile:             c.TLSCertFile,
+		TLSKeyFile:              c.TLSKeyFile,
+		StaticDir:               c.StaticDir,
+		ServeDashboard:          c.ServeDashboard,
+		ContentSecurityPolicy:   c.ContentSecurityPolicy,
+		DBPath:                  c.DBPath,
+		DataDir:                 c.DataDir,
+		DataRetentionDays:       c.DataRetentionDays,
+		CompactRawAfterDays:     c.CompactRawAfterDays,
+		RawRetentionHours:       c.RawRetentionHours,
+		MaxMindDBPath:           c.MaxMindDBPath,
+		BotSignaturesPaths:      c.BotSignaturesPaths,
+		BotRangesPaths:          c.BotRangesPaths,
+		DeviceRulesPath:         c.DeviceRulesPath,
+		BotDetection:            c.BotDetection,
+		ReferrerSpamPath:        c.ReferrerSpamPath,
+		PrivacyHashIPs:          c.PrivacyHashIPs,
+		PrivacyHashSalt:         redacted(c.PrivacyHashSalt),
+		PrivacyHashDaily:        c.PrivacyHashDaily,
+		AnonymizeIP:             c.AnonymizeIP,
+		ExcludePaths:            c.ExcludePaths,
+		ExcludeIPs:              c.ExcludeIPs,
+		InternalCIDRs:           c.InternalCIDRs,
+		HonorDNT:                c.HonorDNT,
+		CaddyMetricsURL:         metricsURL,
+		CaddyMetricsInterval:    c.CaddyMetricsInterval.String(),
+		IngestAPIKey:            redacted(c.IngestAPIKey),
+		IngestRatePerMinute:     c.IngestRatePerMinute,
+		IngestDedup:             c.IngestDedup,
+		IngestSampleRate:        c.IngestSampleRate,
+		IngestWorkers:           c.IngestWorkers,
+		NormalizeHosts:          c.NormalizeHosts,
+		NormalizeHostsStripWWW:  c.NormalizeHostsStripWWW,
+		NormalizePaths:          c.NormalizePaths,
+		NormalizePathsLowercase: c.NormalizePathsLowercase,
+		NormalizePathsTrimSlash: c.NormalizePathsTrimSlash,
+		MaxHeaderLength:         c.MaxHeaderLength,
+		AggregationInterval:     c.AggregationInterval.String(),
+		PollIntervalMin:         c.PollIntervalMin.String(),
+		PollIntervalMax:         c.PollIntervalMax.String(),
+		AggregationFlushSeconds: c.AggregationFlushSeconds,
+		AuthUsername:            c.AuthUsername,
+		AuthPassword:            redacted(c.AuthPassword),
+		LogLevel:                c.LogLevel.String(),
+		RateLimitPerMinute:      c.RateLimitPerMinute,
+		MaxRequestBodyBytes:     c.MaxRequestBodyBytes,
+		AccessLogSampleRate:     c.AccessLogSampleRate,
+		TrustedHosts:            c.TrustedHosts,
+		ScannerPatterns:         c.ScannerPatterns,
+		StripQueryParams:        c.StripQueryParams,
+		AssetExtensions:         c.AssetExtensions,
+		DBMaxConnections:        c.DBMaxConnections,
+		DBQueryTimeout:          c.DBQueryTimeout.String(),
+		DBBusyTimeout:           c.DBBusyTimeout.String(),
+		DBJournalMode:           c.DBJournalMode,
+		DBSynchronous:           c.DBSynchronous,
+		CountEstimateThreshold:  c.CountEstimateThreshold,
+		MinFreeDiskBytes:        c.MinFreeDiskBytes,
+		SSEBufferSize:           c.SSEBufferSize,
+		RecentBufferSize:        c.RecentBufferSize,
+		OnlinePushInterval:      c.OnlinePushInterval.String(),
+		TrafficMetricsInterval:  c.TrafficMetricsInterval.String(),
+		TrafficMetricsHosts:     c.TrafficMetricsHosts,
+		EnableProfiling:         c.EnableProfiling,
+		DefaultRange:            c.DefaultRange.String(),
+		DefaultTopLimit:         c.DefaultTopLimit,
+		DefaultRecentLimit:      c.DefaultRecentLimit,
+		MaxRecentRequests:       c.MaxRecentRequests,
+		ResponseTimeDecimals:    c.ResponseTimeDecimals,
+		UnknownLabel:            c.UnknownLabel,
+		DirectLabel:             c.DirectLabel,
+		SLOObjective:            c.SLOObjective,
+		SLOLatencyTargetMs:      c.SLOLatencyTargetMs,
+		PercentileMinSamples:    c.PercentileMinSamples,
+		ReportsEnabled:          c.ReportsEnabled,
+		ReportsStoragePath:      c.ReportsStoragePath,
+		ReportsRetentionDays:    c.ReportsRetentionDays,
+		ReportsCheckInterval:    c.ReportsCheckInterval.String(),
+		ReportsSMTPHost:         c.ReportsSMTPHost,
+		ReportsSMTPPort:         c.ReportsSMTPPort,
+		ReportsSMTPUsername:     c.ReportsSMTPUsername,
+		ReportsSMTPPassword:     redacted(c.ReportsSMTPPassword),
+		ReportsSMTPFrom:         c.ReportsSMTPFrom,
+	}
+}
+
+func redacted(secret string) string {
+	if secret == "" {
+		return "unset"
+	}
+	return "set"
+}
+
 // AuthEnabled returns true if both AUTH_USERNAME and AUTH_PASSWORD are set.
 func (c Config) AuthEnabled() bool {
 	return c.AuthUsername != "" && c.AuthPassword != ""
@@ -149,6 +628,19 @@ func getEnvInt(key string, def int) int {
 	return parsed
 }
 
+func getEnvFloat(key string, def float64) float64 {
+	val := os.Getenv(key)
+	if val == "" {
+		return def
+	}
+	parsed, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		slog.Warn("invalid float environment variable", "key", key, "value", val, "error", err)
+		return def
+	}
+	return parsed
+}
+
 func getEnvDuration(key string, def time.Duration) time.Duration {
 	val := os.Getenv(key)
 	if val == "" {
@@ -0,0 +1,58 @@
+package ingest
+
+import "strings"
+
+// cacheStatusHeaders are response headers that report whether a cache in
+// front of (or inside) Caddy served the request, in order of preference.
+var cacheStatusHeaders = []string{"Cache-Status", "X-Cache-Status", "Cf-Cache-Status", "X-Cache"}
+
+// cacheStatus returns HIT, MISS or BYPASS for the first cache header present
+// in the logged response headers, the raw (upper-cased) value if it is not
+// recognised, or "" if no cache header was logged.
+func cacheStatus(respHeaders map[string][]string) string {
+	for _, name := range cacheStatusHeaders {
+		v := strings.TrimSpace(firstHeader(respHeaders, name))
+		if v == "" {
+			continue
+		}
+		if name == "Cache-Status" {
+			return structuredCacheStatus(v)
+		}
+		// "MISS from proxy", "HIT, HIT" -> first token
+		token, _, _ := strings.Cut(strings.ToUpper(v), " ")
+		token = strings.TrimSuffix(token, ",")
+		switch token {
+		case "HIT", "STALE", "UPDATING", "REVALIDATED":
+			return "HIT"
+		case "MISS", "EXPIRED":
+			return "MISS"
+		case "BYPASS", "DYNAMIC":
+			return "BYPASS"
+		}
+		return token
+	}
+	return ""
+}
+
+// structuredCacheStatus interprets an RFC 9211 Cache-Status value such as
+// "Souin; hit" or "Caddy; fwd=uri-miss; stored". Only the last (closest to
+// the client) cache in the list is considered.
+func structuredCacheStatus(v string) string {
+	if i := strings.LastIndex(v, ","); i >= 0 {
+		v = v[i+1:]
+	}
+	params := strings.Split(strings.ToLower(v), ";")
+	for _, p := range params[1:] {
+		key, val, _ := strings.Cut(strings.TrimSpace(p), "=")
+		switch key {
+		case "hit":
+			return "HIT"
+		case "fwd":
+			if val == "bypass" {
+				return "BYPASS"
+			}
+			return "MISS"
+		}
+	}
+	return "MISS"
+}
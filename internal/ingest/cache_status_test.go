@@ -0,0 +1,30 @@
+package ingest
+
+import "testing"
+
+func TestCacheStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string][]string
+		want    string
+	}{
+		{"absent", nil, ""},
+		{"x-cache hit", map[string][]string{"X-Cache": {"HIT"}}, "HIT"},
+		{"x-cache squid miss", map[string][]string{"X-Cache": {"MISS from proxy.local"}}, "MISS"},
+		{"nginx expired", map[string][]string{"X-Cache-Status": {"EXPIRED"}}, "MISS"},
+		{"cloudflare dynamic", map[string][]string{"Cf-Cache-Status": {"DYNAMIC"}}, "BYPASS"},
+		{"lowercase header and value", map[string][]string{"x-cache": {"hit"}}, "HIT"},
+		{"rfc9211 hit", map[string][]string{"Cache-Status": {"Souin; hit"}}, "HIT"},
+		{"rfc9211 miss", map[string][]string{"Cache-Status": {"Caddy; fwd=uri-miss; stored"}}, "MISS"},
+		{"rfc9211 bypass", map[string][]string{"Cache-Status": {"Caddy; fwd=bypass"}}, "BYPASS"},
+		{"rfc9211 last cache wins", map[string][]string{"Cache-Status": {"Origin; hit, Caddy; fwd=miss"}}, "MISS"},
+		{"unrecognised", map[string][]string{"X-Cache": {"Error"}}, "ERROR"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cacheStatus(tt.headers); got != tt.want {
+				t.Errorf("cacheStatus() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
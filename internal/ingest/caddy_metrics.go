@@ -0,0 +1,195 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+
+	"github.com/dustin/Caddystat/internal/storage"
+)
+
+const (
+	// Caddy histograms labelled with the response code. Their sample count
+	// gives requests; the response size sum gives bytes.
+	caddyDurationMetric = "caddy_http_request_duration_seconds"
+	caddySizeMetric     = "caddy_http_response_size_bytes"
+
+	caddyMetricsTimeout = 10 * time.Second
+)
+
+// caddySeriesKey identifies one counter after summing over handlers and methods.
+type caddySeriesKey struct {
+	host string
+	code int
+}
+
+type caddySeries struct {
+	requests float64
+	bytes    float64
+}
+
+// CaddyMetricsPoller records request counts from Caddy's Prometheus metrics
+// endpoint into the rollup tables. It is meant for deployments without
+// access log files: only hourly/daily totals per host and status class are
+// available, with no paths, visitors or geo data.
+type CaddyMetricsPoller struct {
+	url      string
+	interval time.Duration
+	store    *storage.Storage
+	client   *http.Client
+
+	// Counters from the previous scrape; nil until the first successful one
+	last map[caddySeriesKey]caddySeries
+}
+
+// NewCaddyMetricsPoller creates a poller for the given metrics URL.
+func NewCaddyMetricsPoller(url string, interval time.Duration, store *storage.Storage) *CaddyMetricsPoller {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	return &CaddyMetricsPoller{
+		url:      url,
+		interval: interval,
+		store:    store,
+		client:   &http.Client{Timeout: caddyMetricsTimeout},
+	}
+}
+
+// Run polls until ctx is cancelled. The first scrape only establishes a
+// baseline, so traffic from before Caddystat started is not counted.
+func (p *CaddyMetricsPoller) Run(ctx context.Context) {
+	slog.Info("polling Caddy metrics", "url", p.url, "interval", p.interval)
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		if err := p.poll(ctx); err != nil && ctx.Err() == nil {
+			slog.Warn("failed to poll Caddy metrics", "url", p.url, "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll scrapes the endpoint once and records the increase since the
+// previous scrape.
+func (p *CaddyMetricsPoller) poll(ctx context.Context) error {
+	current, err := p.scrape(ctx)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+
+	previous := p.last
+	p.last = current
+	if previous == nil {
+		return nil
+	}
+
+	byHost := make(map[string]*storage.RollupCounts)
+	for key, cur := range current {
+		prev := previous[key]
+		requests, bytes := cur.requests-prev.requests, cur.bytes-prev.bytes
+		if requests < 0 || bytes < 0 {
+			// Counter reset (Caddy restarted): everything since is new
+			requests, bytes = cur.requests, cur.bytes
+		}
+		if requests == 0 && bytes == 0 {
+			continue
+		}
+		c, ok := byHost[key.host]
+		if !ok {
+			c = &storage.RollupCounts{Host: key.host}
+			byHost[key.host] = c
+		}
+		c.Requests += int64(requests)
+		c.Bytes += int64(bytes)
+		c.AddStatus(key.code, int64(requests))
+	}
+
+	counts := make([]storage.RollupCounts, 0, len(byHost))
+	for _, c := range byHost {
+		counts = append(counts, *c)
+	}
+	sort.Slice(counts, func(i, j int) bool { return counts[i].Host < counts[j].Host })
+	return p.store.AddRollupCounts(ctx, now, counts)
+}
+
+func (p *CaddyMetricsPoller) scrape(ctx context.Context) (map[caddySeriesKey]caddySeries, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/plain")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	parser := expfmt.NewTextParser(model.UTF8Validation)
+	families, err := parser.TextToMetricFamilies(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("parse metrics: %w", err)
+	}
+
+	series := make(map[caddySeriesKey]caddySeries)
+	if mf := families[caddyDurationMetric]; mf != nil {
+		for _, m := range mf.GetMetric() {
+			if key, ok := caddyKey(m); ok && m.GetHistogram() != nil {
+				s := series[key]
+				s.requests += float64(m.GetHistogram().GetSampleCount())
+				series[key] = s
+			}
+		}
+	}
+	if mf := families[caddySizeMetric]; mf != nil {
+		for _, m := range mf.GetMetric() {
+			if key, ok := caddyKey(m); ok && m.GetHistogram() != nil {
+				s := series[key]
+				s.bytes += m.GetHistogram().GetSampleSum()
+				series[key] = s
+			}
+		}
+	}
+	return series, nil
+}
+
+// caddyKey extracts the host and status code from a Caddy metric. Caddy only
+// adds a host label when per-host metrics are enabled; otherwise the server
+// name (e.g. "srv0") is used.
+func caddyKey(m *dto.Metric) (caddySeriesKey, bool) {
+	var key caddySeriesKey
+	var server string
+	for _, lp := range m.GetLabel() {
+		switch lp.GetName() {
+		case "host":
+			key.host = lp.GetValue()
+		case "server":
+			server = lp.GetValue()
+		case "code":
+			code, err := strconv.Atoi(lp.GetValue())
+			if err != nil {
+				return key, false
+			}
+			key.code = code
+		}
+	}
+	if key.host == "" {
+		key.host = server
+	}
+	return key, key.code != 0
+}
@@ -0,0 +1,163 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dustin/Caddystat/internal/config"
+)
+
+// fakeCaddyMetrics serves Caddy-style histogram samples whose counts can be
+// changed between scrapes.
+type fakeCaddyMetrics struct {
+	mu     sync.Mutex
+	counts map[string]int // "host code" -> requests
+}
+
+func (f *fakeCaddyMetrics) set(host string, code, n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.counts[fmt.Sprintf("%s %d", host, code)] = n
+}
+
+func (f *fakeCaddyMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fmt.Fprintln(w, "# TYPE caddy_http_request_duration_seconds histogram")
+	for key, n := range f.counts {
+		var host string
+		var code int
+		fmt.Sscanf(key, "%s %d", &host, &code)
+		labels := fmt.Sprintf(`code="%d",handler="reverse_proxy",host="%s",method="GET",server="srv0"`, code, host)
+		fmt.Fprintf(w, "caddy_http_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, n)
+		fmt.Fprintf(w, "caddy_http_request_duration_seconds_sum{%s} %f\n", labels, float64(n)*0.01)
+		fmt.Fprintf(w, "caddy_http_request_duration_seconds_count{%s} %d\n", labels, n)
+	}
+	fmt.Fprintln(w, "# TYPE caddy_http_response_size_bytes histogram")
+	for key, n := range f.counts {
+		var host string
+		var code int
+		fmt.Sscanf(key, "%s %d", &host, &code)
+		labels := fmt.Sprintf(`code="%d",handler="reverse_proxy",host="%s",method="GET",server="srv0"`, code, host)
+		fmt.Fprintf(w, "caddy_http_response_size_bytes_bucket{%s,le=\"+Inf\"} %d\n", labels, n)
+		fmt.Fprintf(w, "caddy_http_response_size_bytes_sum{%s} %d\n", labels, n*100)
+		fmt.Fprintf(w, "caddy_http_response_size_bytes_count{%s} %d\n", labels, n)
+	}
+}
+
+func TestCaddyMetricsPoller_RecordsDeltas(t *testing.T) {
+	_, store := setupTestIngestor(t, config.Config{}, nil)
+	ctx := context.Background()
+
+	fake := &fakeCaddyMetrics{counts: map[string]int{}}
+	fake.set("example.com", 200, 50)
+	fake.set("example.com", 404, 5)
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	p := NewCaddyMetricsPoller(ts.URL, time.Minute, store)
+
+	// First scrape is only a baseline
+	if err := p.poll(ctx); err != nil {
+		t.Fatalf("poll() error = %v", err)
+	}
+	totals, err := store.HourlyRollupTotals(ctx, time.Now().Add(-time.Hour), time.Now().Add(time.Hour), "")
+	if err != nil {
+		t.Fatalf("HourlyRollupTotals() error = %v", err)
+	}
+	if totals.Requests != 0 {
+		t.Fatalf("baseline scrape should not record requests, got %d", totals.Requests)
+	}
+
+	fake.set("example.com", 200, 60)
+	fake.set("example.com", 404, 7)
+	fake.set("other.com", 502, 1)
+	if err := p.poll(ctx); err != nil {
+		t.Fatalf("poll() error = %v", err)
+	}
+
+	totals, err = store.HourlyRollupTotals(ctx, time.Now().Add(-time.Hour), time.Now().Add(time.Hour), "example.com")
+	if err != nil {
+		t.Fatalf("HourlyRollupTotals() error = %v", err)
+	}
+	if totals.Requests != 12 || totals.Status2xx != 10 || totals.Status4xx != 2 {
+		t.Errorf("example.com totals = %+v, want 12 requests (10 2xx, 2 4xx)", totals)
+	}
+	if totals.Bytes != 1200 {
+		t.Errorf("example.com bytes = %d, want 1200", totals.Bytes)
+	}
+
+	other, err := store.HourlyRollupTotals(ctx, time.Now().Add(-time.Hour), time.Now().Add(time.Hour), "other.com")
+	if err != nil {
+		t.Fatalf("HourlyRollupTotals() error = %v", err)
+	}
+	if other.Requests != 1 || other.Status5xx != 1 {
+		t.Errorf("other.com totals = %+v, want 1 5xx request", other)
+	}
+
+	// Caddy restart resets counters; the new values are all fresh traffic
+	fake.set("example.com", 200, 3)
+	fake.set("example.com", 404, 0)
+	fake.set("other.com", 502, 0)
+	if err := p.poll(ctx); err != nil {
+		t.Fatalf("poll() error = %v", err)
+	}
+	totals, err = store.HourlyRollupTotals(ctx, time.Now().Add(-time.Hour), time.Now().Add(time.Hour), "example.com")
+	if err != nil {
+		t.Fatalf("HourlyRollupTotals() error = %v", err)
+	}
+	if totals.Requests != 15 {
+		t.Errorf("after counter reset requests = %d, want 15", totals.Requests)
+	}
+}
+
+func TestCaddyMetricsPoller_ServerLabelFallback(t *testing.T) {
+	_, store := setupTestIngestor(t, config.Config{}, nil)
+	ctx := context.Background()
+
+	var count atomic.Int64
+	count.Store(1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "# TYPE caddy_http_request_duration_seconds histogram")
+		fmt.Fprintf(w, "caddy_http_request_duration_seconds_bucket{code=\"200\",server=\"srv0\",le=\"+Inf\"} %d\n", count.Load())
+		fmt.Fprintf(w, "caddy_http_request_duration_seconds_sum{code=\"200\",server=\"srv0\"} 0.1\n")
+		fmt.Fprintf(w, "caddy_http_request_duration_seconds_count{code=\"200\",server=\"srv0\"} %d\n", count.Load())
+	}))
+	defer ts.Close()
+
+	p := NewCaddyMetricsPoller(ts.URL, time.Minute, store)
+	if err := p.poll(ctx); err != nil {
+		t.Fatalf("poll() error = %v", err)
+	}
+	count.Store(4)
+	if err := p.poll(ctx); err != nil {
+		t.Fatalf("poll() error = %v", err)
+	}
+
+	totals, err := store.HourlyRollupTotals(ctx, time.Now().Add(-time.Hour), time.Now().Add(time.Hour), "srv0")
+	if err != nil {
+		t.Fatalf("HourlyRollupTotals() error = %v", err)
+	}
+	if totals.Requests != 3 {
+		t.Errorf("srv0 requests = %d, want 3", totals.Requests)
+	}
+}
+
+func TestCaddyMetricsPoller_BadStatus(t *testing.T) {
+	_, store := setupTestIngestor(t, config.Config{}, nil)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusForbidden)
+	}))
+	defer ts.Close()
+
+	p := NewCaddyMetricsPoller(ts.URL, time.Minute, store)
+	if err := p.poll(context.Background()); err == nil {
+		t.Error("expected error for non-200 response")
+	}
+}
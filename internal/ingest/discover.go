@@ -0,0 +1,85 @@
+package ingest
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// isGlobPattern reports whether a LOG_PATH entry is a filepath.Match
+// pattern rather than a single file.
+func isGlobPattern(p string) bool {
+	return strings.ContainsAny(p, "*?[")
+}
+
+// startTail begins tailing path unless it is already being tailed. Gzipped
+// files are only imported, never tailed. It reports whether path was new.
+func (i *Ingestor) startTail(ctx context.Context, path string) bool {
+	i.tailMu.Lock()
+	defer i.tailMu.Unlock()
+	if i.tailing == nil {
+		i.tailing = make(map[string]struct{})
+	}
+	if _, ok := i.tailing[path]; ok {
+		return false
+	}
+	i.tailing[path] = struct{}{}
+	if strings.HasSuffix(path, ".gz") {
+		return true
+	}
+	i.wg.Add(1)
+	go func() {
+		defer i.wg.Done()
+		i.tailFile(ctx, path)
+	}()
+	return true
+}
+
+// isTailing reports whether path has already been picked up.
+func (i *Ingestor) isTailing(path string) bool {
+	i.tailMu.Lock()
+	defer i.tailMu.Unlock()
+	_, ok := i.tailing[path]
+	return ok
+}
+
+// discoverLogs expands the glob patterns and, for each file not seen
+// before, imports its existing contents and starts tailing it. Each file
+// tracks its own import progress, so a restart resumes where it left off.
+func (i *Ingestor) discoverLogs(ctx context.Context, patterns []string) {
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			slog.Warn("invalid log path pattern", "pattern", pattern, "error", err)
+			continue
+		}
+		for _, path := range matches {
+			if i.isTailing(path) {
+				continue
+			}
+			if err := i.importHistoricalLogs(ctx, path); err != nil {
+				slog.Warn("failed to import historical logs", "path", path, "error", err)
+			}
+			if i.startTail(ctx, path) {
+				slog.Info("discovered log file", "path", path, "pattern", pattern)
+			}
+		}
+	}
+}
+
+// watchLogGlobs re-expands the patterns every interval until ctx is done so
+// log files created after startup are picked up.
+func (i *Ingestor) watchLogGlobs(ctx context.Context, patterns []string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			i.discoverLogs(ctx, patterns)
+		}
+	}
+}
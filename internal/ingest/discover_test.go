@@ -0,0 +1,93 @@
+package ingest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dustin/Caddystat/internal/config"
+	"github.com/dustin/Caddystat/internal/storage"
+)
+
+func TestIsGlobPattern(t *testing.T) {
+	for p, want := range map[string]bool{
+		"/var/log/caddy/access.log":   false,
+		"/var/log/caddy/*.access.log": true,
+		"/var/log/site?.log":          true,
+		"/var/log/[ab].log":           true,
+	} {
+		if got := isGlobPattern(p); got != want {
+			t.Errorf("isGlobPattern(%q) = %v, want %v", p, got, want)
+		}
+	}
+}
+
+func TestIngestor_GlobDiscovery(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, path string) string {
+		t.Helper()
+		file := filepath.Join(dir, name)
+		if err := os.WriteFile(file, []byte(testLogLine(path, "10.0.0.1")+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+	files := []string{write("a.access.log", "/a"), write("b.access.log", "/b")}
+	write("ignored.txt", "/ignored")
+
+	cfg := config.Config{
+		LogPaths:             []string{filepath.Join(dir, "*.access.log")},
+		LogDiscoveryInterval: 20 * time.Millisecond,
+	}
+	ingestor, store := setupTestIngestor(t, cfg, nil)
+	ctx := context.Background()
+	if err := ingestor.Start(ctx); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer ingestor.Stop()
+
+	waitForRequests := func(want int) []storage.RecentRequest {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			recent, err := store.RecentRequests(ctx, 10, "")
+			if err != nil {
+				t.Fatalf("RecentRequests() error = %v", err)
+			}
+			if len(recent) >= want || time.Now().After(deadline) {
+				return recent
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	if got := waitForRequests(2); len(got) != 2 {
+		t.Fatalf("expected 2 requests from the initial matches, got %d", len(got))
+	}
+
+	files = append(files, write("c.access.log", "/c"))
+	recent := waitForRequests(3)
+	if len(recent) != 3 {
+		t.Fatalf("expected the new file to be picked up, got %d requests", len(recent))
+	}
+	for _, r := range recent {
+		if r.Path == "/ignored" {
+			t.Error("file outside the pattern was ingested")
+		}
+	}
+
+	for _, f := range files {
+		progress, err := store.GetImportProgress(ctx, f)
+		if err != nil {
+			t.Fatalf("GetImportProgress(%s) error = %v", f, err)
+		}
+		if progress == nil {
+			t.Errorf("no import progress recorded for %s", f)
+		}
+		if !ingestor.isTailing(f) {
+			t.Errorf("%s is not being tailed", f)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package ingest
+
+import (
+	"log/slog"
+	"net"
+	"path"
+	"strings"
+)
+
+// Exclusion reasons reported to metrics.
+const (
+	excludeReasonPath = "path"
+	excludeReasonIP   = "ip"
+	excludeReasonDNT  = "dnt"
+)
+
+// excludeFilter decides which requests are dropped before they reach storage.
+type excludeFilter struct {
+	paths    []string
+	networks []*net.IPNet
+	honorDNT bool
+}
+
+// newExcludeFilter builds a filter from path patterns, IP/CIDR strings and the
+// DNT setting. Returns nil if nothing would ever be excluded.
+func newExcludeFilter(paths, ips []string, honorDNT bool) *excludeFilter {
+	f := &excludeFilter{honorDNT: honorDNT}
+	for _, p := range paths {
+		if p = strings.TrimSpace(p); p != "" {
+			f.paths = append(f.paths, p)
+		}
+	}
+	f.networks = parseNetworks(ips, "excluded IP")
+	if len(f.paths) == 0 && len(f.networks) == 0 && !f.honorDNT {
+		return nil
+	}
+	return f
+}
+
+// match returns the reason the entry should be excluded, or "" to keep it.
+// ip must be the normalized client address (before anonymization/hashing).
+func (f *excludeFilter) match(entry parsedEntry, ip string) string {
+	if f == nil {
+		return ""
+	}
+	if f.honorDNT && entry.DNT {
+		return excludeReasonDNT
+	}
+	if len(f.paths) > 0 && matchesPathPattern(entry.Path, f.paths) {
+		return excludeReasonPath
+	}
+	if containsIP(f.networks, ip) {
+		return excludeReasonIP
+	}
+	return ""
+}
+
+// parseNetworks parses IP and CIDR strings into networks. A bare IP becomes a
+// single-host network; invalid entries are logged (as what) and skipped.
+func parseNetworks(values []string, what string) []*net.IPNet {
+	var networks []*net.IPNet
+	for _, raw := range values {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		if !strings.Contains(raw, "/") {
+			if ip := net.ParseIP(raw); ip != nil && ip.To4() != nil {
+				raw += "/32"
+			} else {
+				raw += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(raw)
+		if err != nil {
+			slog.Warn("ignoring invalid "+what, "value", raw, "error", err)
+			continue
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+// containsIP reports whether ip falls inside any of the networks.
+func containsIP(networks []*net.IPNet, ip string) bool {
+	if len(networks) == 0 {
+		return false
+	}
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, n := range networks {
+		if n.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesPathPattern reports whether the request path (query string ignored)
+// matches any pattern. Patterns containing glob metacharacters are matched with
+// path.Match; anything else is treated as a prefix.
+func matchesPathPattern(requestPath string, patterns []string) bool {
+	if idx := strings.IndexByte(requestPath, '?'); idx != -1 {
+		requestPath = requestPath[:idx]
+	}
+	for _, p := range patterns {
+		if strings.ContainsAny(p, "*?[") {
+			if ok, _ := path.Match(p, requestPath); ok {
+				return true
+			}
+			continue
+		}
+		if strings.HasPrefix(requestPath, p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dustin/Caddystat/internal/config"
+	"github.com/dustin/Caddystat/internal/storage"
+)
+
+func TestNewExcludeFilter_Empty(t *testing.T) {
+	if f := newExcludeFilter(nil, []string{" ", "not-an-ip"}, false); f != nil {
+		t.Errorf("expected nil filter when nothing is configured, got %+v", f)
+	}
+}
+
+func TestExcludeFilter_Match(t *testing.T) {
+	f := newExcludeFilter(
+		[]string{"/health", "/admin/*", "/*.php"},
+		[]string{"10.0.0.0/8", "192.168.1.5", "2001:db8::/32"},
+		true,
+	)
+
+	tests := []struct {
+		name  string
+		entry parsedEntry
+		ip    string
+		want  string
+	}{
+		{"prefix", parsedEntry{Path: "/health"}, "1.2.3.4", excludeReasonPath},
+		{"prefix with query", parsedEntry{Path: "/health?check=1"}, "1.2.3.4", excludeReasonPath},
+		{"prefix covers children", parsedEntry{Path: "/health/live"}, "1.2.3.4", excludeReasonPath},
+		{"glob", parsedEntry{Path: "/admin/users"}, "1.2.3.4", excludeReasonPath},
+		{"glob does not cross slash", parsedEntry{Path: "/admin/users/1"}, "1.2.3.4", ""},
+		{"glob suffix", parsedEntry{Path: "/wp-login.php"}, "1.2.3.4", excludeReasonPath},
+		{"sibling path", parsedEntry{Path: "/about"}, "1.2.3.4", ""},
+		{"cidr", parsedEntry{Path: "/"}, "10.1.2.3", excludeReasonIP},
+		{"single ip", parsedEntry{Path: "/"}, "192.168.1.5", excludeReasonIP},
+		{"neighbour ip", parsedEntry{Path: "/"}, "192.168.1.6", ""},
+		{"ipv6 cidr", parsedEntry{Path: "/"}, "2001:db8::1", excludeReasonIP},
+		{"dnt", parsedEntry{Path: "/", DNT: true}, "1.2.3.4", excludeReasonDNT},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := f.match(tt.entry, tt.ip); got != tt.want {
+				t.Errorf("match() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExcludeFilter_NilMatchesNothing(t *testing.T) {
+	var f *excludeFilter
+	if got := f.match(parsedEntry{Path: "/health", DNT: true}, "10.0.0.1"); got != "" {
+		t.Errorf("nil filter match() = %q, want empty", got)
+	}
+}
+
+func TestParseCaddyLog_DNT(t *testing.T) {
+	line := `{"ts":1700000000,"request":{"host":"example.com","uri":"/","remote_ip":"1.2.3.4","headers":{"Dnt":["1"]}},"status":200}`
+	entry, err := parseCaddyLog(line)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !entry.DNT {
+		t.Error("expected DNT to be set")
+	}
+}
+
+func TestIngestor_ExcludedPathDropped(t *testing.T) {
+	ingestor, store := setupTestIngestor(t, config.Config{
+		ExcludePaths: []string{"/health"},
+		ExcludeIPs:   []string{"10.0.0.0/8"},
+	}, nil)
+	ctx := context.Background()
+
+	lines := []string{
+		testLogLine("/health", "1.2.3.4"),
+		testLogLine("/healthz", "1.2.3.4"),
+		testLogLine("/about", "1.2.3.4"),
+		testLogLine("/about", "10.0.0.7"),
+	}
+	for _, line := range lines {
+		if err := ingestor.handleLineNoNotify(ctx, line); err != nil {
+			t.Fatalf("handleLineNoNotify() error = %v", err)
+		}
+	}
+
+	recent, err := store.RecentRequests(ctx, 10, "")
+	if err != nil {
+		t.Fatalf("RecentRequests() error = %v", err)
+	}
+	if len(recent) != 1 {
+		paths := make([]string, len(recent))
+		for i, r := range recent {
+			paths[i] = fmt.Sprintf("%s from %s", r.Path, r.IP)
+		}
+		t.Fatalf("expected only the sibling path to be stored, got %v", paths)
+	}
+	if recent[0].Path != "/about" || recent[0].IP != "1.2.3.4" {
+		t.Errorf("stored %s from %s, want /about from 1.2.3.4", recent[0].Path, recent[0].IP)
+	}
+}
+
+func TestIngestor_FlagsInternalRequests(t *testing.T) {
+	ingestor, store := setupTestIngestor(t, config.Config{
+		InternalCIDRs: []string{"10.0.0.0/8", "192.168.1.5"},
+	}, nil)
+	ctx := context.Background()
+
+	lines := []string{
+		testLogLine("/about", "1.2.3.4"),
+		testLogLine("/about", "10.0.0.7"),
+		testLogLine("/about", "192.168.1.5"),
+	}
+	for _, line := range lines {
+		if err := ingestor.handleLineNoNotify(ctx, line); err != nil {
+			t.Fatalf("handleLineNoNotify() error = %v", err)
+		}
+	}
+
+	all, err := store.SummaryFiltered(ctx, time.Hour, storage.StatsFilter{})
+	if err != nil {
+		t.Fatalf("SummaryFiltered() error = %v", err)
+	}
+	if all.TotalRequests != 3 {
+		t.Errorf("TotalRequests = %d, want 3 (internal requests are still recorded)", all.TotalRequests)
+	}
+
+	external, err := store.SummaryFiltered(ctx, time.Hour, storage.StatsFilter{ExcludeInternal: true})
+	if err != nil {
+		t.Fatalf("SummaryFiltered(ExcludeInternal) error = %v", err)
+	}
+	if external.TotalRequests != 1 {
+		t.Errorf("TotalRequests with ExcludeInternal = %d, want 1", external.TotalRequests)
+	}
+	if external.UniqueVisitors != 1 {
+		t.Errorf("UniqueVisitors with ExcludeInternal = %d, want 1", external.UniqueVisitors)
+	}
+}
@@ -0,0 +1,26 @@
+package ingest
+
+import (
+	"net"
+	"strings"
+)
+
+// normalizeHost folds the spellings Caddy may log for one site into a single
+// value: "Example.com", "example.com:443" and "example.com." all become
+// "example.com", and with stripWWW so does "www.example.com". IPv6
+// addresses lose their brackets along with the port.
+func normalizeHost(host string, stripWWW bool) string {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	} else if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		host = host[1 : len(host)-1]
+	}
+	host = strings.TrimSuffix(host, ".")
+	if stripWWW {
+		if rest, ok := strings.CutPrefix(host, "www."); ok && rest != "" {
+			host = rest
+		}
+	}
+	return host
+}
@@ -0,0 +1,83 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dustin/Caddystat/internal/config"
+)
+
+func TestNormalizeHost(t *testing.T) {
+	tests := []struct {
+		host     string
+		stripWWW bool
+		want     string
+	}{
+		{"example.com", false, "example.com"},
+		{"Example.COM", false, "example.com"},
+		{"example.com:443", false, "example.com"},
+		{"EXAMPLE.com:8080", false, "example.com"},
+		{"example.com.", false, "example.com"},
+		{"www.example.com", false, "www.example.com"},
+		{"WWW.Example.com:443", true, "example.com"},
+		{"www.", true, "www"},
+		{"wwwexample.com", true, "wwwexample.com"},
+		{"[::1]:8404", false, "::1"},
+		{"[::1]", false, "::1"},
+		{"192.168.1.10:80", false, "192.168.1.10"},
+		{"", false, ""},
+	}
+	for _, tt := range tests {
+		if got := normalizeHost(tt.host, tt.stripWWW); got != tt.want {
+			t.Errorf("normalizeHost(%q, %v) = %q, want %q", tt.host, tt.stripWWW, got, tt.want)
+		}
+	}
+}
+
+func TestIngestor_NormalizesHosts(t *testing.T) {
+	ingestor, store := setupTestIngestor(t, config.Config{
+		NormalizeHosts:         true,
+		NormalizeHostsStripWWW: true,
+	}, nil)
+	ctx := context.Background()
+
+	for _, host := range []string{"Example.com", "example.com:443", "WWW.EXAMPLE.COM:8080", "example.com."} {
+		line := fmt.Sprintf(`{"ts":%d,"request":{"host":%q,"uri":"/","remote_ip":"1.2.3.4","headers":{"User-Agent":["Mozilla/5.0"]}},"status":200}`,
+			time.Now().Unix(), host)
+		if err := ingestor.handleLineNoNotify(ctx, line); err != nil {
+			t.Fatalf("handleLineNoNotify(%q) error = %v", host, err)
+		}
+	}
+
+	recent, err := store.RecentRequests(ctx, 10, "")
+	if err != nil {
+		t.Fatalf("RecentRequests() error = %v", err)
+	}
+	if len(recent) != 4 {
+		t.Fatalf("expected 4 stored requests, got %d", len(recent))
+	}
+	for _, r := range recent {
+		if r.Host != "example.com" {
+			t.Errorf("stored host %q, want example.com", r.Host)
+		}
+	}
+}
+
+func TestIngestor_HostsUnchangedByDefault(t *testing.T) {
+	ingestor, store := setupTestIngestor(t, config.Config{}, nil)
+	ctx := context.Background()
+
+	line := fmt.Sprintf(`{"ts":%d,"request":{"host":"Example.com:8080","uri":"/","remote_ip":"1.2.3.4"},"status":200}`, time.Now().Unix())
+	if err := ingestor.handleLineNoNotify(ctx, line); err != nil {
+		t.Fatalf("handleLineNoNotify() error = %v", err)
+	}
+	recent, err := store.RecentRequests(ctx, 10, "")
+	if err != nil {
+		t.Fatalf("RecentRequests() error = %v", err)
+	}
+	if len(recent) != 1 || recent[0].Host != "Example.com:8080" {
+		t.Fatalf("expected host stored verbatim, got %+v", recent)
+	}
+}
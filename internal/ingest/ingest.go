@@ -5,17 +5,21 @@ import (
 	"compress/gzip"
 	"context"
 	"crypto/sha256"
+	"crypto/tls"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"io"
 	"log/slog"
+	"math/rand/v2"
 	"net"
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/hpcloud/tail"
@@ -38,23 +42,51 @@ const (
 )
 
 type Ingestor struct {
-	cfg     config.Config
-	store   *storage.Storage
-	hub     *sse.Hub
-	geo     *GeoLookup
-	metrics *metrics.Metrics
-	wg      sync.WaitGroup
-	cancel  context.CancelFunc
+	cfg      config.Config
+	store    *storage.Storage
+	hub      *sse.Hub
+	geo      *GeoLookup
+	metrics  *metrics.Metrics
+	salt     *DailySalt // non-nil when PrivacyHashDaily is enabled
+	exclude  *excludeFilter
+	internal []*net.IPNet // INTERNAL_CIDRS; matching requests are flagged
+	spam     *ReferrerDenylist
+	paused   atomic.Bool  // waiting for free disk space
+	queued   atomic.Int64 // lines read or records received but not yet stored
+	wg       sync.WaitGroup
+	tailMu   sync.Mutex
+	tailing  map[string]struct{} // log files already imported and tailed
+	cancel   context.CancelFunc
+
+	// How often a quarantined file is checked for release
+	quarantinePoll time.Duration
 }
 
 func New(cfg config.Config, store *storage.Storage, hub *sse.Hub, geo *GeoLookup, m *metrics.Metrics) *Ingestor {
-	return &Ingestor{
-		cfg:     cfg,
-		store:   store,
-		hub:     hub,
-		geo:     geo,
-		metrics: m,
-	}
+	i := &Ingestor{
+		cfg:      cfg,
+		store:    store,
+		hub:      hub,
+		geo:      geo,
+		metrics:  m,
+		exclude:  newExcludeFilter(cfg.ExcludePaths, cfg.ExcludeIPs, cfg.HonorDNT),
+		internal: parseNetworks(cfg.InternalCIDRs, "internal CIDR"),
+
+		quarantinePoll: 30 * time.Second,
+	}
+	if cfg.PrivacyHashDaily {
+		i.salt = NewDailySalt()
+	}
+	if spam, err := LoadReferrerDenylist(cfg.ReferrerSpamPath); err != nil {
+		slog.Warn("failed to load referrer spam denylist", "path", cfg.ReferrerSpamPath, "error", err)
+	} else if spam != nil {
+		slog.Info("loaded referrer spam denylist", "path", cfg.ReferrerSpamPath, "count", spam.Len())
+		i.spam = spam
+	}
+	if m != nil {
+		m.SetIngestQueueDepthFunc(i.QueueDepth)
+	}
+	return i
 }
 
 func (i *Ingestor) Start(ctx context.Context) error {
@@ -62,20 +94,57 @@ func (i *Ingestor) Start(ctx context.Context) error {
 	tailCtx, cancel := context.WithCancel(ctx)
 	i.cancel = cancel
 
-	// First, import any existing log files (including rotated/gzipped ones)
+	// Split glob patterns (e.g. /var/log/caddy/*.access.log) from plain paths
+	var files, patterns []string
 	for _, path := range i.cfg.LogPaths {
+		if isGlobPattern(path) {
+			patterns = append(patterns, path)
+		} else {
+			files = append(files, path)
+		}
+	}
+
+	// First, import any existing log files (including rotated/gzipped ones)
+	for _, path := range files {
 		if err := i.importHistoricalLogs(ctx, path); err != nil {
 			slog.Warn("failed to import historical logs", "path", path, "error", err)
 		}
 	}
 
 	// Then start tailing for new entries
-	for _, path := range i.cfg.LogPaths {
+	for _, path := range files {
+		i.startTail(tailCtx, path)
+	}
+
+	// Expand patterns now, then keep looking for newly created files
+	if len(patterns) > 0 {
+		i.discoverLogs(tailCtx, patterns)
+		if i.cfg.LogDiscoveryInterval > 0 {
+			i.wg.Add(1)
+			go func() {
+				defer i.wg.Done()
+				i.watchLogGlobs(tailCtx, patterns, i.cfg.LogDiscoveryInterval)
+			}()
+		}
+	}
+
+	// Optionally poll Caddy's metrics endpoint for aggregate counts
+	if i.cfg.CaddyMetricsURL != "" {
+		poller := NewCaddyMetricsPoller(i.cfg.CaddyMetricsURL, i.cfg.CaddyMetricsInterval, i.store)
+		i.wg.Add(1)
+		go func() {
+			defer i.wg.Done()
+			poller.Run(tailCtx)
+		}()
+	}
+
+	// Push "online now" counts to SSE clients
+	if i.hub != nil && i.cfg.OnlinePushInterval > 0 {
 		i.wg.Add(1)
-		go func(p string) {
+		go func() {
 			defer i.wg.Done()
-			i.tailFile(tailCtx, p)
-		}(path)
+			i.runOnlinePublisher(tailCtx, i.cfg.OnlinePushInterval)
+		}()
 	}
 	return nil
 }
@@ -132,6 +201,11 @@ func (i *Ingestor) importLogFile(ctx context.Context, path string) (int, error)
 	fileSize := fileInfo.Size()
 	fileMtime := fileInfo.ModTime().Unix()
 
+	if i.quarantined(ctx, path) {
+		slog.Debug("skipping quarantined log file", "path", path)
+		return 0, nil
+	}
+
 	// Check if we've already imported this file
 	progress, err := i.store.GetImportProgress(ctx, path)
 	if err != nil {
@@ -201,10 +275,82 @@ func (i *Ingestor) importLogFile(ctx context.Context, path string) (int, error)
 	errorCount := 0
 	lineNum := int64(0)
 	var lastParseErr error
+	failures := parseFailures{threshold: i.cfg.QuarantineThreshold}
+	saveProgress := func(offset int64) {
+		_ = i.store.SetImportProgress(ctx, storage.ImportProgress{
+			FilePath:   path,
+			ByteOffset: offset,
+			FileSize:   fileSize,
+			FileMtime:  fileMtime,
+		})
+	}
+
+	// Lines are parsed in batches across the worker pool, then stored in
+	// file order so the saved offset never runs ahead of committed rows.
+	// flush reports whether the file was quarantined.
+	batch := make([]pendingLine, 0, importBatchSize)
+	flush := func() (bool, error) {
+		if len(batch) == 0 {
+			return false, nil
+		}
+		defer func() {
+			i.trackQueued(-len(batch))
+			batch = batch[:0]
+		}()
+
+		results := i.prepareLines(batch)
+		records := make([]storage.RequestRecord, 0, len(batch))
+		for n, res := range results {
+			line := batch[n]
+			if failures.observe(res.err) {
+				if err := i.storeBatch(ctx, records); err != nil {
+					return false, err
+				}
+				saveProgress(line.offset)
+				i.quarantine(ctx, path, failures.count, res.err)
+				return true, nil
+			}
+			if res.err != nil {
+				errorCount++
+				lastParseErr = res.err
+
+				// Log error details periodically (not every line to avoid spam)
+				if errorCount <= 5 || errorCount%1000 == 0 {
+					// Show sample of the malformed line (truncated for safety)
+					sample := line.text
+					if len(sample) > 100 {
+						sample = sample[:100] + "..."
+					}
+					slog.Debug("failed to parse log line",
+						"file", filepath.Base(path),
+						"line_num", line.num,
+						"error", res.err,
+						"sample", sample)
+				}
+
+				// Record error to database
+				_ = i.store.RecordImportError(ctx, path, res.err)
+				continue
+			}
+			count++
+			if res.keep {
+				records = append(records, res.record)
+			}
+		}
+		if err := i.storeBatch(ctx, records); err != nil {
+			return false, err
+		}
+
+		// Save a checkpoint after every batch
+		slog.Debug("import progress", "file", filepath.Base(path), "entries", count, "errors", errorCount)
+		saveProgress(batch[len(batch)-1].offset)
+		return false, nil
+	}
 
 	for scanner.Scan() {
 		select {
 		case <-ctx.Done():
+			i.trackQueued(-len(batch))
 			return count, ctx.Err()
 		default:
 		}
@@ -218,45 +364,21 @@ func (i *Ingestor) importLogFile(ctx context.Context, path string) (int, error)
 			continue
 		}
 
-		// Use handleLineNoNotify to avoid spamming SSE during import
-		if err := i.handleLineNoNotify(ctx, line); err != nil {
-			errorCount++
-			lastParseErr = err
-
-			// Log error details periodically (not every line to avoid spam)
-			if errorCount <= 5 || errorCount%1000 == 0 {
-				// Show sample of the malformed line (truncated for safety)
-				sample := line
-				if len(sample) > 100 {
-					sample = sample[:100] + "..."
-				}
-				slog.Debug("failed to parse log line",
-					"file", filepath.Base(path),
-					"line_num", lineNum,
-					"error", err,
-					"sample", sample)
-			}
-
-			// Record error to database
-			_ = i.store.RecordImportError(ctx, path, err)
+		batch = append(batch, pendingLine{text: line, num: lineNum, offset: currentOffset})
+		i.trackQueued(1)
+		if len(batch) < importBatchSize {
 			continue
 		}
-		count++
-
-		// Log progress and save checkpoint every 1000 entries (reduced from 10000 for more frequent saves)
-		if count%1000 == 0 {
-			slog.Debug("import progress", "file", filepath.Base(path), "entries", count, "errors", errorCount)
-			// Save progress periodically
-			_ = i.store.SetImportProgress(ctx, storage.ImportProgress{
-				FilePath:   path,
-				ByteOffset: currentOffset,
-				FileSize:   fileSize,
-				FileMtime:  fileMtime,
-			})
+		if quarantined, err := flush(); err != nil || quarantined {
+			return count, err
 		}
 	}
 
 	if err := scanner.Err(); err != nil {
+		i.trackQueued(-len(batch))
+		return count, err
+	}
+	if quarantined, err := flush(); err != nil || quarantined {
 		return count, err
 	}
 
@@ -293,35 +415,224 @@ func (i *Ingestor) importLogFile(ctx context.Context, path string) (int, error)
 
 // handleLineNoNotify processes a log line without sending SSE notifications
 func (i *Ingestor) handleLineNoNotify(ctx context.Context, line string) error {
-	entry, err := parseCaddyLog(line)
-	if err != nil {
+	record, keep, err := i.prepareLine(line)
+	if err != nil || !keep {
 		return err
 	}
-	ip := normalizeIP(entry.RemoteAddr)
-	if i.cfg.PrivacyAnonymizeOctet {
-		ip = anonymizeIP(ip)
+	i.trackQueued(1)
+	defer i.trackQueued(-1)
+	if err := i.waitForDiskSpace(ctx, 1); err != nil {
+		return err
 	}
-	if i.cfg.PrivacyHashIPs {
-		ip = hashIP(ip, i.cfg.PrivacyHashSalt)
+
+	// Use retry logic for database inserts
+	return retryWithBackoff(ctx, "insert_request", func() error {
+		return i.store.InsertRequest(ctx, record)
+	})
+}
+
+// IngestRecords stores request events received from outside the log tailer
+// (e.g. pushed over HTTP). Each record goes through the same exclusion,
+// privacy, geo and user-agent handling as a log line; enrichment fields on
+// the input are ignored. Records without a timestamp are stamped now.
+// It returns the number of records stored.
+func (i *Ingestor) IngestRecords(ctx context.Context, events []storage.RequestRecord) (int, error) {
+	start := time.Now()
+	i.trackQueued(len(events))
+	defer i.trackQueued(-len(events))
+	records := make([]storage.RequestRecord, 0, len(events))
+	for _, ev := range events {
+		entry := parsedEntry{
+			Timestamp:   ev.Timestamp.UTC(),
+			Host:        ev.Host,
+			Path:        ev.Path,
+			Status:      ev.Status,
+			Bytes:       ev.Bytes,
+			RemoteAddr:  ev.IP,
+			Referrer:    ev.Referrer,
+			UserAgent:   ev.UserAgent,
+			DurationMs:  ev.ResponseTime,
+			ContentType: mediaType(ev.ContentType),
+			Method:      strings.ToUpper(ev.Method),
+			Protocol:    ev.Protocol,
+			TLSVersion:  ev.TLSVersion,
+			CacheStatus: strings.ToUpper(ev.CacheStatus),
+			Language:    primaryLanguage(ev.Language),
+		}
+		if entry.Timestamp.IsZero() {
+			entry.Timestamp = start.UTC()
+		}
+		if i.excluded(entry) || i.sampledOut() {
+			continue
+		}
+		records = append(records, i.buildRecord(entry))
+	}
+	if len(records) == 0 {
+		return 0, nil
+	}
+
+	if err := i.storeBatch(ctx, records); err != nil {
+		if i.metrics != nil {
+			i.metrics.RecordIngestError()
+		}
+		return 0, err
+	}
+
+	if i.metrics != nil {
+		perRecord := time.Since(start).Seconds() / float64(len(records))
+		for _, record := range records {
+			i.metrics.RecordIngest(perRecord, record.Bytes)
+			if record.IsBot {
+				i.metrics.RecordBotIngest(record.BotIntent, record.Bytes)
+			}
+		}
+		i.metrics.SetLastIngestTimestamp(float64(records[len(records)-1].Timestamp.Unix()))
+	}
+
+	if i.hub != nil {
+		if summary, err := i.store.Summary(ctx, time.Duration(i.cfg.RawRetentionHours)*time.Hour, ""); err == nil {
+			if buf, err := json.Marshal(summary); err == nil {
+				i.hub.Broadcast(buf)
+			}
+		}
+	}
+	return len(records), nil
+}
+
+// storeBatch waits for disk space, then inserts records in one transaction.
+func (i *Ingestor) storeBatch(ctx context.Context, records []storage.RequestRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+	if err := i.waitForDiskSpace(ctx, len(records)); err != nil {
+		return err
+	}
+	return retryWithBackoff(ctx, "insert_request_batch", func() error {
+		return i.store.InsertRequestBatch(ctx, records)
+	})
+}
+
+// excluded reports whether the entry matches an exclusion rule, recording
+// the reason in metrics when it does.
+func (i *Ingestor) excluded(entry parsedEntry) bool {
+	reason := i.exclude.match(entry, normalizeIP(entry.RemoteAddr))
+	if reason == "" {
+		return false
+	}
+	if i.metrics != nil {
+		i.metrics.RecordIngestExcluded(reason)
+	}
+	return true
+}
+
+// sampledOut reports whether a request should be dropped by 1-in-N ingest
+// sampling. Kept requests are stored with a weight of N (see buildRecord).
+func (i *Ingestor) sampledOut() bool {
+	return i.cfg.IngestSampleRate > 1 && rand.IntN(i.cfg.IngestSampleRate) != 0
+}
+
+// diskPollInterval is how often a paused ingestor rechecks free disk space.
+var diskPollInterval = 5 * time.Second
+
+// waitForDiskSpace blocks while storage reports low free disk space so log
+// lines back up in the tailer instead of failing to insert. records is the
+// number of records held up, counted in metrics when ingest blocks.
+func (i *Ingestor) waitForDiskSpace(ctx context.Context, records int) error {
+	if !i.store.DiskSpaceLow() {
+		return nil
+	}
+	if i.metrics != nil {
+		i.metrics.RecordIngestBlocked(records)
+	}
+	i.paused.Store(true)
+	defer i.paused.Store(false)
+
+	ticker := time.NewTicker(diskPollInterval)
+	defer ticker.Stop()
+	for i.store.DiskSpaceLow() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// Paused reports whether ingest is currently waiting for disk space.
+func (i *Ingestor) Paused() bool {
+	return i.paused.Load()
+}
+
+// QueueDepth returns the number of log lines and pushed records that have
+// been read but not yet stored. A depth that stays high means ingest is not
+// keeping up.
+func (i *Ingestor) QueueDepth() int64 {
+	return i.queued.Load()
+}
+
+// trackQueued adjusts the queue depth by delta. The gauge reads it through
+// QueueDepth on each scrape.
+func (i *Ingestor) trackQueued(delta int) {
+	i.queued.Add(int64(delta))
+}
+
+// buildRecord enriches a parsed log entry with geo and user-agent data and
+// applies the configured privacy transforms to the client IP.
+func (i *Ingestor) buildRecord(entry parsedEntry) storage.RequestRecord {
+	ip := normalizeIP(entry.RemoteAddr)
+	internal := containsIP(i.internal, ip)
+
+	// Oversized headers are cut before parsing so they neither bloat the
+	// database nor cost the parser time
+	userAgent := truncateHeader(entry.UserAgent, i.cfg.MaxHeaderLength)
+
+	// Parse user-agent; claimed crawlers are verified against the real address
+	ua := useragent.Parse(userAgent)
+	var botVerification string
+	if ua.IsBot {
+		botVerification = useragent.VerifyBot(ua.BotName, ip)
 	}
 
+	// Geo lookup uses the full address so anonymization doesn't degrade accuracy
 	var country, region, city string
 	if i.geo != nil {
 		country, region, city = i.geo.Lookup(ip)
 	}
 
-	// Parse user-agent
-	ua := useragent.Parse(entry.UserAgent)
+	if i.cfg.AnonymizeIP {
+		ip = anonymizeIP(ip)
+	}
+	if i.salt != nil {
+		ip = i.salt.Hash(ip)
+	} else if i.cfg.PrivacyHashIPs {
+		ip = hashIP(ip, i.cfg.PrivacyHashSalt)
+	}
+
+	// Spam referrers are blanked so they count as direct traffic
+	referrer := truncateHeader(entry.Referrer, i.cfg.MaxHeaderLength)
+	if i.spam.Matches(referrer) {
+		referrer = ""
+	}
+
+	host := entry.Host
+	if i.cfg.NormalizeHosts {
+		host = normalizeHost(host, i.cfg.NormalizeHostsStripWWW)
+	}
+	path := entry.Path
+	if i.cfg.NormalizePaths {
+		path = normalizePath(path, i.cfg.NormalizePathsLowercase, i.cfg.NormalizePathsTrimSlash)
+	}
 
 	record := storage.RequestRecord{
 		Timestamp:      entry.Timestamp,
-		Host:           entry.Host,
-		Path:           entry.Path,
+		Host:           host,
+		Path:           path,
 		Status:         entry.Status,
 		Bytes:          entry.Bytes,
 		IP:             ip,
-		Referrer:       entry.Referrer,
-		UserAgent:      entry.UserAgent,
+		Referrer:       referrer,
+		UserAgent:      userAgent,
 		ResponseTime:   entry.DurationMs,
 		Country:        country,
 		Region:         region,
@@ -331,18 +642,53 @@ func (i *Ingestor) handleLineNoNotify(ctx context.Context, line string) error {
 		OS:             ua.OS,
 		OSVersion:      ua.OSVersion,
 		DeviceType:     ua.DeviceType,
+		DeviceBrand:    ua.DeviceBrand,
+		DeviceModel:    ua.DeviceModel,
 		IsBot:          ua.IsBot,
 		BotName:        ua.BotName,
 		BotIntent:      string(ua.BotIntent),
-	}
-
-	// Use retry logic for database inserts
-	return retryWithBackoff(ctx, "insert_request", func() error {
-		return i.store.InsertRequest(ctx, record)
-	})
+		ContentType:    entry.ContentType,
+		Method:         entry.Method,
+		Protocol:       entry.Protocol,
+		TLSVersion:     entry.TLSVersion,
+		CacheStatus:    entry.CacheStatus,
+		Language:       entry.Language,
+		Internal:       internal,
+	}
+	record.BotVerification = botVerification
+	if i.cfg.IngestSampleRate > 1 {
+		record.SampleWeight = i.cfg.IngestSampleRate
+	}
+	if i.cfg.IngestDedup {
+		record.DedupHash = dedupHash(record)
+	}
+	return record
 }
 
+// tailFile follows path until ctx is done. If the file is quarantined it
+// waits for the quarantine to be lifted, imports what was written in the
+// meantime, and resumes tailing.
 func (i *Ingestor) tailFile(ctx context.Context, path string) {
+	for {
+		if i.quarantined(ctx, path) {
+			if !i.waitForRelease(ctx, path) {
+				return
+			}
+			slog.Info("resuming log file after quarantine", "path", path)
+			if _, err := i.importLogFile(ctx, path); err != nil {
+				slog.Warn("failed to import log file", "path", path, "error", err)
+			}
+			continue
+		}
+		if !i.followFile(ctx, path) {
+			return
+		}
+	}
+}
+
+// followFile tails path from its end. It returns true if the file was
+// quarantined and false when ctx is done or tailing fails.
+func (i *Ingestor) followFile(ctx context.Context, path string) bool {
 	t, err := tail.TailFile(path, tail.Config{
 		ReOpen:    true,
 		Follow:    true,
@@ -352,19 +698,26 @@ func (i *Ingestor) tailFile(ctx context.Context, path string) {
 	})
 	if err != nil {
 		slog.Error("failed to tail log file", "path", path, "error", err)
-		return
+		return false
 	}
 	slog.Info("tailing log file", "path", path)
+	failures := parseFailures{threshold: i.cfg.QuarantineThreshold}
 	for {
 		select {
 		case <-ctx.Done():
 			_ = t.Stop()
-			return
+			return false
 		case line := <-t.Lines:
 			if line == nil {
 				continue
 			}
-			if err := i.handleLine(ctx, line.Text); err != nil {
+			err := i.handleLine(ctx, line.Text)
+			if failures.observe(err) {
+				_ = t.Stop()
+				i.quarantine(ctx, path, failures.count, err)
+				return true
+			}
+			if err != nil {
 				slog.Debug("failed to parse log line", "path", path, "error", err)
 			}
 		}
@@ -381,48 +734,22 @@ func (i *Ingestor) handleLine(ctx context.Context, line string) error {
 		}
 		return err
 	}
-	ip := normalizeIP(entry.RemoteAddr)
-	if i.cfg.PrivacyAnonymizeOctet {
-		ip = anonymizeIP(ip)
-	}
-	if i.cfg.PrivacyHashIPs {
-		ip = hashIP(ip, i.cfg.PrivacyHashSalt)
-	}
-
-	var country, region, city string
-	if i.geo != nil {
-		country, region, city = i.geo.Lookup(ip)
+	if i.excluded(entry) || i.sampledOut() {
+		return nil
 	}
-
-	// Parse user-agent
-	ua := useragent.Parse(entry.UserAgent)
-
-	record := storage.RequestRecord{
-		Timestamp:      entry.Timestamp,
-		Host:           entry.Host,
-		Path:           entry.Path,
-		Status:         entry.Status,
-		Bytes:          entry.Bytes,
-		IP:             ip,
-		Referrer:       entry.Referrer,
-		UserAgent:      entry.UserAgent,
-		ResponseTime:   entry.DurationMs,
-		Country:        country,
-		Region:         region,
-		City:           city,
-		Browser:        ua.Browser,
-		BrowserVersion: ua.BrowserVersion,
-		OS:             ua.OS,
-		OSVersion:      ua.OSVersion,
-		DeviceType:     ua.DeviceType,
-		IsBot:          ua.IsBot,
-		BotName:        ua.BotName,
-		BotIntent:      string(ua.BotIntent),
+	i.trackQueued(1)
+	defer i.trackQueued(-1)
+	if err := i.waitForDiskSpace(ctx, 1); err != nil {
+		return err
 	}
+	record := i.buildRecord(entry)
 
 	// Use retry logic for database inserts
+	var id int64
 	if err := retryWithBackoff(ctx, "insert_request", func() error {
-		return i.store.InsertRequest(ctx, record)
+		var err error
+		id, err = i.store.InsertRequestWithID(ctx, record)
+		return err
 	}); err != nil {
 		return err
 	}
@@ -439,9 +766,11 @@ func (i *Ingestor) handleLine(ctx context.Context, line string) error {
 		}
 	}
 
-	if i.hub != nil {
+	// Skipped duplicates were not stored, so they are not broadcast either
+	if i.hub != nil && id != 0 {
 		// Broadcast the new request for live request log
 		reqEvent := storage.RecentRequest{
+			ID:             id,
 			Timestamp:      record.Timestamp,
 			Host:           record.Host,
 			Path:           record.Path,
@@ -450,7 +779,7 @@ func (i *Ingestor) handleLine(ctx context.Context, line string) error {
 			IP:             record.IP,
 			Referrer:       record.Referrer,
 			UserAgent:      record.UserAgent,
-			ResponseTime:   record.ResponseTime,
+			ResponseTime:   storage.Millis(record.ResponseTime),
 			Country:        record.Country,
 			Region:         record.Region,
 			City:           record.City,
@@ -463,7 +792,7 @@ func (i *Ingestor) handleLine(ctx context.Context, line string) error {
 			BotName:        record.BotName,
 		}
 		if buf, err := json.Marshal(reqEvent); err == nil {
-			i.hub.BroadcastEvent("request", buf)
+			i.hub.BroadcastEvent(sse.RequestEvent, buf)
 		}
 
 		// Also broadcast the summary update
@@ -531,7 +860,7 @@ func (g *GeoLookup) Close() error {
 // Lookup returns the country, region, and city for the given IP address.
 // Results are cached to improve performance for repeated lookups.
 func (g *GeoLookup) Lookup(ip string) (string, string, string) {
-	if g == nil || g.db == nil || ip == "" {
+	if g == nil || ip == "" {
 		return "", "", ""
 	}
 
@@ -541,6 +870,9 @@ func (g *GeoLookup) Lookup(ip string) (string, string, string) {
 			return result.Country, result.Region, result.City
 		}
 	}
+	if g.db == nil {
+		return "", "", ""
+	}
 
 	// Cache miss - do the actual lookup
 	parsed := net.ParseIP(ip)
@@ -602,12 +934,17 @@ func (g *GeoLookup) CacheStats() *GeoCacheStats {
 type caddyLogEntry struct {
 	Timestamp json.RawMessage `json:"ts"` // Can be float64 or string (RFC3339)
 	Request   struct {
+		Method     string              `json:"method"`
 		Host       string              `json:"host"`
 		URI        string              `json:"uri"`
 		RemoteIP   string              `json:"remote_ip"`
 		RemotePort string              `json:"remote_port"`
 		ClientIP   string              `json:"client_ip"`
 		Headers    map[string][]string `json:"headers"`
+		Proto      string              `json:"proto"`
+		TLS        *struct {
+			Version uint16 `json:"version"`
+		} `json:"tls"`
 	} `json:"request"`
 	Status      int                 `json:"status"`
 	Bytes       int64               `json:"bytes_written"`
@@ -627,12 +964,21 @@ type parsedEntry struct {
 	Referrer   string
 	UserAgent  string
 	DurationMs float64
+	DNT        bool // Client sent "DNT: 1"
+	// ContentType is the response media type without parameters, e.g.
+	// "text/html"; empty when the log lacks resp_headers.
+	ContentType string
+	Method      string // Upper-case, e.g. "GET"; empty when not logged
+	Protocol    string // e.g. "HTTP/2.0"; empty when not logged
+	TLSVersion  string // e.g. "TLS 1.3", or "none" for plain HTTP; empty when not logged
+	CacheStatus string // HIT, MISS or BYPASS from upstream cache headers; empty when absent
+	Language    string // First Accept-Language tag, e.g. "en-US"; empty when not logged
 }
 
 func parseCaddyLog(line string) (parsedEntry, error) {
 	var raw caddyLogEntry
 	if err := json.Unmarshal([]byte(line), &raw); err != nil {
-		return parsedEntry{}, err
+		return parsedEntry{}, parseError{err}
 	}
 
 	// Parse timestamp - can be either float64 (Unix) or string (RFC3339)
@@ -684,19 +1030,42 @@ func parseCaddyLog(line string) (parsedEntry, error) {
 		clientIP = raw.Request.RemoteIP // direct connection IP
 	}
 
+	// Caddy omits "tls" for plain HTTP; without "proto" the log predates
+	// (or filters out) both fields, so leave the version unknown.
+	var tlsVersion string
+	if raw.Request.TLS != nil && raw.Request.TLS.Version != 0 {
+		tlsVersion = tls.VersionName(raw.Request.TLS.Version)
+	} else if raw.Request.Proto != "" {
+		tlsVersion = "none"
+	}
+
 	return parsedEntry{
-		Timestamp:  ts,
-		Host:       raw.Request.Host,
-		Path:       raw.Request.URI,
-		Status:     raw.Status,
-		Bytes:      bytes,
-		RemoteAddr: clientIP,
-		Referrer:   ref,
-		UserAgent:  ua,
-		DurationMs: raw.Duration * 1000,
+		Timestamp:   ts,
+		Host:        raw.Request.Host,
+		Path:        raw.Request.URI,
+		Status:      raw.Status,
+		Bytes:       bytes,
+		RemoteAddr:  clientIP,
+		Referrer:    ref,
+		UserAgent:   ua,
+		DurationMs:  raw.Duration * 1000,
+		DNT:         firstHeader(raw.Request.Headers, "Dnt") == "1",
+		ContentType: mediaType(firstHeader(raw.RespHeaders, "Content-Type")),
+		Method:      strings.ToUpper(raw.Request.Method),
+		Protocol:    raw.Request.Proto,
+		TLSVersion:  tlsVersion,
+		CacheStatus: cacheStatus(raw.RespHeaders),
+		Language:    primaryLanguage(firstHeader(raw.Request.Headers, "Accept-Language")),
 	}, nil
 }
 
+// mediaType strips parameters from a Content-Type header value, so
+// "text/html; charset=utf-8" becomes "text/html".
+func mediaType(contentType string) string {
+	mt, _, _ := strings.Cut(contentType, ";")
+	return strings.ToLower(strings.TrimSpace(mt))
+}
+
 func firstHeader(h map[string][]string, key string) string {
 	if len(h) == 0 {
 		return ""
@@ -728,6 +1097,8 @@ func normalizeIP(remoteAddr string) string {
 	return remoteAddr
 }
 
+// anonymizeIP truncates an address so it no longer identifies a single host:
+// the last octet of IPv4 and the last 80 bits of IPv6 (keeping the /48) are zeroed.
 func anonymizeIP(ip string) string {
 	if ip == "" {
 		return ""
@@ -737,15 +1108,17 @@ func anonymizeIP(ip string) string {
 		return ""
 	}
 	if v4 := parsed.To4(); v4 != nil {
-		v4[3] = 0
-		return v4.String()
-	}
-	parts := strings.Split(ip, ":")
-	if len(parts) > 0 {
-		parts[len(parts)-1] = "0"
-		return strings.Join(parts, ":")
+		return v4.Mask(net.CIDRMask(24, 32)).String()
 	}
-	return ip
+	return parsed.Mask(net.CIDRMask(48, 128)).String()
+}
+
+// dedupHash identifies a request by host, path, client IP, timestamp and
+// status so that re-reading the same log line doesn't store it twice.
+func dedupHash(r storage.RequestRecord) string {
+	key := strings.Join([]string{r.Host, r.Path, r.IP, r.Timestamp.UTC().Format(time.RFC3339Nano), strconv.Itoa(r.Status)}, "\x00")
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:16])
 }
 
 func hashIP(ip, salt string) string {
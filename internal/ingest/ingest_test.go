@@ -2,11 +2,47 @@ package ingest
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/dustin/Caddystat/internal/config"
+	"github.com/dustin/Caddystat/internal/metrics"
+	"github.com/dustin/Caddystat/internal/sse"
+	"github.com/dustin/Caddystat/internal/storage"
 )
 
+// setupTestIngestor creates an Ingestor backed by a temporary database.
+func setupTestIngestor(t testing.TB, cfg config.Config, geo *GeoLookup) (*Ingestor, *storage.Storage) {
+	t.Helper()
+	tmpDir, err := os.MkdirTemp("", "caddystat-ingest-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	store, err := storage.New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		os.RemoveAll(tmpDir)
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	t.Cleanup(func() {
+		store.Close()
+		os.RemoveAll(tmpDir)
+	})
+	return New(cfg, store, nil, geo, nil), store
+}
+
+// testLogLine builds a Caddy JSON log line timestamped now.
+func testLogLine(path, ip string) string {
+	return fmt.Sprintf(`{"ts":%d,"request":{"host":"example.com","uri":%q,"remote_ip":%q,"headers":{"User-Agent":["Mozilla/5.0"]}},"status":200,"bytes_written":100,"duration":0.01}`,
+		time.Now().Unix(), path, ip)
+}
+
 func TestParseCaddyLog_UnixTimestamp(t *testing.T) {
 	line := `{"ts":1700000000.123456,"request":{"host":"example.com","uri":"/page","remote_ip":"192.168.1.1","headers":{"User-Agent":["Mozilla/5.0"],"Referer":["https://google.com"]}},"status":200,"bytes_written":1234,"duration":0.05}`
 
@@ -260,7 +296,8 @@ func TestAnonymizeIP(t *testing.T) {
 		{"192.168.1.100", "192.168.1.0"},
 		{"10.0.0.255", "10.0.0.0"},
 		{"172.16.50.123", "172.16.50.0"},
-		{"2001:db8:85a3::8a2e:370:7334", "2001:db8:85a3::8a2e:370:0"},
+		{"2001:db8:85a3::8a2e:370:7334", "2001:db8:85a3::"},
+		{"2001:db8:85a3:1234:5678::1", "2001:db8:85a3::"},
 		{"", ""},
 		{"invalid", ""},
 	}
@@ -275,6 +312,34 @@ func TestAnonymizeIP(t *testing.T) {
 	}
 }
 
+func TestIngestor_AnonymizeIP_GeoBeforeTruncation(t *testing.T) {
+	// Seed the cache so the lookup resolves without a MaxMind database;
+	// only the full address is known, so a hit proves geo ran pre-anonymization.
+	geo := &GeoLookup{cache: NewGeoCache(DefaultGeoCacheConfig())}
+	geo.cache.Set("192.168.1.123", GeoResult{Country: "DE", Region: "Bavaria", City: "Munich"})
+
+	ingestor, store := setupTestIngestor(t, config.Config{AnonymizeIP: true}, geo)
+	ctx := context.Background()
+
+	if err := ingestor.handleLineNoNotify(ctx, testLogLine("/page", "192.168.1.123")); err != nil {
+		t.Fatalf("handleLineNoNotify() error = %v", err)
+	}
+
+	recent, err := store.RecentRequests(ctx, 10, "")
+	if err != nil {
+		t.Fatalf("RecentRequests() error = %v", err)
+	}
+	if len(recent) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(recent))
+	}
+	if recent[0].IP != "192.168.1.0" {
+		t.Errorf("IP = %q, want %q", recent[0].IP, "192.168.1.0")
+	}
+	if recent[0].Country != "DE" || recent[0].City != "Munich" {
+		t.Errorf("geo = %q/%q, want DE/Munich (lookup should use the full IP)", recent[0].Country, recent[0].City)
+	}
+}
+
 func TestHashIP(t *testing.T) {
 	tests := []struct {
 		ip   string
@@ -382,6 +447,7 @@ func TestParseCaddyLog_CompleteEntry(t *testing.T) {
 	line := `{
 		"ts": 1700000000.5,
 		"request": {
+			"method": "post",
 			"host": "example.com",
 			"uri": "/api/users?page=1",
 			"remote_ip": "10.0.0.1",
@@ -440,6 +506,29 @@ func TestParseCaddyLog_CompleteEntry(t *testing.T) {
 	if entry.DurationMs != 125 {
 		t.Errorf("DurationMs = %f, want %f", entry.DurationMs, 125.0)
 	}
+
+	if entry.ContentType != "application/json" {
+		t.Errorf("ContentType = %q, want %q", entry.ContentType, "application/json")
+	}
+
+	if entry.Method != "POST" {
+		t.Errorf("Method = %q, want %q", entry.Method, "POST")
+	}
+}
+
+func TestMediaType(t *testing.T) {
+	tests := map[string]string{
+		"":                         "",
+		"text/html":                "text/html",
+		"text/html; charset=utf-8": "text/html",
+		" Application/JSON ;q=1 ":  "application/json",
+		"image/svg+xml;charset=x":  "image/svg+xml",
+	}
+	for in, want := range tests {
+		if got := mediaType(in); got != want {
+			t.Errorf("mediaType(%q) = %q, want %q", in, got, want)
+		}
+	}
 }
 
 func TestParseCaddyLog_StatusCodes(t *testing.T) {
@@ -757,3 +846,280 @@ func TestRetryWithBackoff_ContextCanceled(t *testing.T) {
 		t.Errorf("retryWithBackoff() error = %v, want context.Canceled", err)
 	}
 }
+
+func TestIngestor_PausesWhileDiskSpaceLow(t *testing.T) {
+	prev := diskPollInterval
+	diskPollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { diskPollInterval = prev })
+
+	ingestor, store := setupTestIngestor(t, config.Config{}, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var free atomic.Uint64
+	store.SetDiskFreeFunc(func(string) (uint64, error) { return free.Load(), nil })
+	store.MonitorDiskSpace(ctx, 1<<20, time.Hour)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- ingestor.handleLineNoNotify(ctx, testLogLine("/page", "1.2.3.4"))
+	}()
+
+	// Ingest blocks while space is low
+	deadline := time.Now().Add(time.Second)
+	for !ingestor.Paused() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !ingestor.Paused() {
+		t.Fatal("expected ingest to pause while disk space is low")
+	}
+	select {
+	case err := <-done:
+		t.Fatalf("handleLineNoNotify() returned while paused: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Recovering space resumes ingest and the line is stored
+	free.Store(1 << 30)
+	store.CheckDiskSpace()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("handleLineNoNotify() error = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ingest did not resume after disk space recovered")
+	}
+	if ingestor.Paused() {
+		t.Error("Paused() = true after resuming")
+	}
+
+	recent, err := store.RecentRequests(ctx, 10, "")
+	if err != nil {
+		t.Fatalf("RecentRequests() error = %v", err)
+	}
+	if len(recent) != 1 {
+		t.Errorf("expected 1 request after resume, got %d", len(recent))
+	}
+}
+
+func TestIngestor_QueueDepthWhileBlocked(t *testing.T) {
+	prev := diskPollInterval
+	diskPollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { diskPollInterval = prev })
+
+	ingestor, store := setupTestIngestor(t, config.Config{}, nil)
+	m := metrics.New(func() int { return 0 }, func() int64 { return 0 }, func() metrics.DBStats { return metrics.DBStats{} }, nil)
+	ingestor.metrics = m
+	m.SetIngestQueueDepthFunc(ingestor.QueueDepth)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var free atomic.Uint64
+	store.SetDiskFreeFunc(func(string) (uint64, error) { return free.Load(), nil })
+	store.MonitorDiskSpace(ctx, 1<<20, time.Hour)
+
+	// Fill the queue with lines that can't be stored yet
+	const lines = 3
+	done := make(chan error, lines)
+	for n := 0; n < lines; n++ {
+		go func() {
+			done <- ingestor.handleLineNoNotify(ctx, testLogLine(fmt.Sprintf("/page/%d", n), "1.2.3.4"))
+		}()
+	}
+	deadline := time.Now().Add(time.Second)
+	for ingestor.QueueDepth() < lines && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := ingestor.QueueDepth(); got != lines {
+		t.Fatalf("QueueDepth() = %d, want %d", got, lines)
+	}
+	if got := testutil.ToFloat64(m.IngestQueueDepth); got != lines {
+		t.Errorf("queue depth gauge = %v, want %d", got, lines)
+	}
+	if got := testutil.ToFloat64(m.IngestBlockedTotal); got != lines {
+		t.Errorf("blocked records = %v, want %d", got, lines)
+	}
+
+	// Draining the queue brings the gauge back to zero
+	free.Store(1 << 30)
+	store.CheckDiskSpace()
+	for n := 0; n < lines; n++ {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatalf("handleLineNoNotify() error = %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("ingest did not resume after disk space recovered")
+		}
+	}
+	if got := ingestor.QueueDepth(); got != 0 {
+		t.Errorf("QueueDepth() after drain = %d, want 0", got)
+	}
+	if got := testutil.ToFloat64(m.IngestQueueDepth); got != 0 {
+		t.Errorf("queue depth gauge after drain = %v, want 0", got)
+	}
+}
+
+func TestIngestor_DedupReimportedLines(t *testing.T) {
+	lines := []string{
+		testLogLine("/a", "10.0.0.1"),
+		testLogLine("/b", "10.0.0.2"),
+		testLogLine("/c", "10.0.0.3"),
+	}
+
+	for _, tt := range []struct {
+		name  string
+		dedup bool
+		want  int64
+	}{
+		{"enabled", true, 3},
+		{"disabled", false, 6},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ingestor, store := setupTestIngestor(t, config.Config{IngestDedup: tt.dedup}, nil)
+			ctx := context.Background()
+
+			// Import the same lines twice, as a rotation bug or manual reimport would
+			for pass := 0; pass < 2; pass++ {
+				for _, line := range lines {
+					if err := ingestor.handleLineNoNotify(ctx, line); err != nil {
+						t.Fatalf("handleLineNoNotify() error = %v", err)
+					}
+				}
+			}
+
+			summary, err := store.Summary(ctx, time.Hour, "")
+			if err != nil {
+				t.Fatalf("Summary() error = %v", err)
+			}
+			if summary.TotalRequests != tt.want {
+				t.Errorf("TotalRequests = %d, want %d", summary.TotalRequests, tt.want)
+			}
+			rollups, err := store.HourlyRollupTotals(ctx, time.Now().Add(-time.Hour), time.Now().Add(time.Hour), "")
+			if err != nil {
+				t.Fatalf("HourlyRollupTotals() error = %v", err)
+			}
+			if rollups.Requests != tt.want {
+				t.Errorf("rollup requests = %d, want %d", rollups.Requests, tt.want)
+			}
+		})
+	}
+}
+
+func TestDedupHash(t *testing.T) {
+	ts := time.Date(2026, 1, 2, 3, 4, 5, 6, time.UTC)
+	base := storage.RequestRecord{Timestamp: ts, Host: "example.com", Path: "/", IP: "1.2.3.4", Status: 200}
+
+	if dedupHash(base) != dedupHash(base) {
+		t.Error("identical records should hash equally")
+	}
+	variants := []storage.RequestRecord{base, base, base, base}
+	variants[0].Path = "/other"
+	variants[1].IP = "1.2.3.5"
+	variants[2].Timestamp = ts.Add(time.Nanosecond)
+	variants[3].Status = 304
+	for i, v := range variants {
+		if dedupHash(v) == dedupHash(base) {
+			t.Errorf("variant %d should hash differently", i)
+		}
+	}
+}
+
+func TestIngestor_SampleRate(t *testing.T) {
+	ingestor, store := setupTestIngestor(t, config.Config{IngestSampleRate: 2}, nil)
+	ctx := context.Background()
+
+	const total = 2000
+	for n := 0; n < total; n++ {
+		line := testLogLine(fmt.Sprintf("/page/%d", n), fmt.Sprintf("10.0.%d.%d", n/256, n%256))
+		if err := ingestor.handleLineNoNotify(ctx, line); err != nil {
+			t.Fatalf("handleLineNoNotify() error = %v", err)
+		}
+	}
+
+	// Roughly half the lines are stored; the bounds are many standard
+	// deviations wide so the test is not flaky.
+	var stored int64
+	if err := store.DB().QueryRowContext(ctx, "SELECT COUNT(*) FROM requests").Scan(&stored); err != nil {
+		t.Fatalf("count requests: %v", err)
+	}
+	if stored < 850 || stored > 1150 {
+		t.Errorf("stored rows = %d, want about %d", stored, total/2)
+	}
+
+	summary, err := store.Summary(ctx, time.Hour, "")
+	if err != nil {
+		t.Fatalf("Summary() error = %v", err)
+	}
+	if summary.TotalRequests != stored*2 {
+		t.Errorf("weighted TotalRequests = %d, want %d (2 x stored)", summary.TotalRequests, stored*2)
+	}
+	if summary.TotalRequests < 1700 || summary.TotalRequests > 2300 {
+		t.Errorf("weighted TotalRequests = %d, want about %d", summary.TotalRequests, total)
+	}
+
+	rollups, err := store.HourlyRollupTotals(ctx, time.Now().Add(-time.Hour), time.Now().Add(time.Hour), "")
+	if err != nil {
+		t.Fatalf("HourlyRollupTotals() error = %v", err)
+	}
+	if rollups.Requests != summary.TotalRequests {
+		t.Errorf("rollup requests = %d, want %d", rollups.Requests, summary.TotalRequests)
+	}
+}
+
+func TestParseCaddyLog_ProtocolAndTLS(t *testing.T) {
+	tests := []struct {
+		name      string
+		request   string
+		wantProto string
+		wantTLS   string
+	}{
+		{"http2 over tls 1.3", `"proto": "HTTP/2.0", "tls": {"version": 772}`, "HTTP/2.0", "TLS 1.3"},
+		{"http1 over tls 1.2", `"proto": "HTTP/1.1", "tls": {"version": 771}`, "HTTP/1.1", "TLS 1.2"},
+		{"plain http", `"proto": "HTTP/1.1"`, "HTTP/1.1", "none"},
+		{"fields absent", `"uri": "/"`, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line := `{"ts": 1700000000, "request": {"host": "example.com", ` + tt.request + `}, "status": 200}`
+			entry, err := parseCaddyLog(line)
+			if err != nil {
+				t.Fatalf("parseCaddyLog() error = %v", err)
+			}
+			if entry.Protocol != tt.wantProto {
+				t.Errorf("Protocol = %q, want %q", entry.Protocol, tt.wantProto)
+			}
+			if entry.TLSVersion != tt.wantTLS {
+				t.Errorf("TLSVersion = %q, want %q", entry.TLSVersion, tt.wantTLS)
+			}
+		})
+	}
+}
+
+func TestIngestor_BroadcastCarriesID(t *testing.T) {
+	ingestor, store := setupTestIngestor(t, config.Config{}, nil)
+	hub := sse.NewHub(sse.WithRecentSize(10))
+	ingestor.hub = hub
+	ctx := context.Background()
+
+	if err := ingestor.handleLine(ctx, testLogLine("/live", "1.2.3.4")); err != nil {
+		t.Fatalf("handleLine() error = %v", err)
+	}
+	buffered := hub.Recent()
+	if len(buffered) != 1 {
+		t.Fatalf("buffered %d request events, want 1", len(buffered))
+	}
+	var got storage.RecentRequest
+	if err := json.Unmarshal(buffered[0], &got); err != nil {
+		t.Fatalf("decode request event: %v", err)
+	}
+	stored, err := store.RecentRequests(ctx, 1, "")
+	if err != nil || len(stored) != 1 {
+		t.Fatalf("RecentRequests() = %v, %v", stored, err)
+	}
+	if got.ID == 0 || got.ID != stored[0].ID {
+		t.Errorf("broadcast id = %d, want stored id %d", got.ID, stored[0].ID)
+	}
+}
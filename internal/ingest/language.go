@@ -0,0 +1,32 @@
+package ingest
+
+import "strings"
+
+// primaryLanguage returns the first language tag of an Accept-Language
+// header, so "en-US,en;q=0.9" becomes "en-US". The primary subtag is
+// lower-cased and a two-letter region upper-cased. It returns "" for an
+// empty header, a wildcard, or a value that isn't a language tag.
+func primaryLanguage(header string) string {
+	tag, _, _ := strings.Cut(header, ",")
+	tag, _, _ = strings.Cut(tag, ";")
+	tag = strings.TrimSpace(tag)
+	if tag == "" || tag == "*" || len(tag) > 35 {
+		return ""
+	}
+
+	parts := strings.Split(strings.ReplaceAll(tag, "_", "-"), "-")
+	for i, p := range parts {
+		if p == "" || len(p) > 8 || strings.IndexFunc(p, func(r rune) bool {
+			return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+		}) >= 0 {
+			return ""
+		}
+		switch {
+		case i == 0:
+			parts[i] = strings.ToLower(p)
+		case len(p) == 2:
+			parts[i] = strings.ToUpper(p)
+		}
+	}
+	return strings.Join(parts, "-")
+}
@@ -0,0 +1,40 @@
+package ingest
+
+import "testing"
+
+func TestPrimaryLanguage(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{"absent", "", ""},
+		{"weighted list", "en-US,en;q=0.9", "en-US"},
+		{"single tag", "fr", "fr"},
+		{"quality on first tag", "de-DE;q=0.8, en;q=0.5", "de-DE"},
+		{"normalizes case", "EN-us", "en-US"},
+		{"underscore separator", "pt_BR", "pt-BR"},
+		{"script subtag", "zh-Hant-TW,zh;q=0.8", "zh-Hant-TW"},
+		{"wildcard", "*", ""},
+		{"garbage", "<script>", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := primaryLanguage(tt.header); got != tt.want {
+				t.Errorf("primaryLanguage(%q) = %q, want %q", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCaddyLog_AcceptLanguage(t *testing.T) {
+	line := `{"ts":1700000000,"request":{"host":"example.com","uri":"/","remote_ip":"10.0.0.1","headers":{"Accept-Language":["en-US,en;q=0.9"]}},"status":200,"duration":0.01}`
+
+	entry, err := parseCaddyLog(line)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if entry.Language != "en-US" {
+		t.Errorf("Language = %q, want %q", entry.Language, "en-US")
+	}
+}
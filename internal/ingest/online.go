@@ -0,0 +1,53 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/dustin/Caddystat/internal/sse"
+	"github.com/dustin/Caddystat/internal/storage"
+)
+
+// onlineWindow is the activity window for the pushed "online now" count,
+// matching the /api/stats/online default.
+const onlineWindow = 5 * time.Minute
+
+// runOnlinePublisher recomputes the active-visitor count every interval and
+// broadcasts it as an sse.OnlineEvent (a storage.OnlineStats payload) when it
+// changes, so dashboards don't have to poll.
+func (i *Ingestor) runOnlinePublisher(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	last := int64(-1)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			last = i.publishOnline(ctx, last)
+		}
+	}
+}
+
+// publishOnline broadcasts the current active-visitor count unless it
+// equals last, and returns the count to compare against next time.
+func (i *Ingestor) publishOnline(ctx context.Context, last int64) int64 {
+	n, err := i.store.ActiveVisitors(ctx, onlineWindow, "")
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.Warn("failed to count active visitors", "error", err)
+		}
+		return last
+	}
+	if n == last {
+		return last
+	}
+	buf, err := json.Marshal(storage.OnlineStats{ActiveVisitors: n, WindowSeconds: int64(onlineWindow / time.Second)})
+	if err != nil {
+		return last
+	}
+	i.hub.BroadcastEvent(sse.OnlineEvent, buf)
+	return n
+}
@@ -0,0 +1,65 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/dustin/Caddystat/internal/config"
+	"github.com/dustin/Caddystat/internal/sse"
+	"github.com/dustin/Caddystat/internal/storage"
+)
+
+func TestIngestor_PublishOnline(t *testing.T) {
+	_, store := setupTestIngestor(t, config.Config{}, nil)
+	hub := sse.NewHub()
+	ingestor := New(config.Config{}, store, hub, nil, nil)
+	events, unsubscribe := hub.Subscribe()
+	defer unsubscribe()
+	ctx := context.Background()
+
+	nextOnline := func() storage.OnlineStats {
+		t.Helper()
+		for {
+			select {
+			case evt := <-events:
+				if evt.Type != sse.OnlineEvent {
+					continue // per-request events from handleLine
+				}
+				var stats storage.OnlineStats
+				if err := json.Unmarshal(evt.Payload, &stats); err != nil {
+					t.Fatalf("decode online payload: %v", err)
+				}
+				return stats
+			case <-time.After(time.Second):
+				t.Fatal("no online event published")
+			}
+		}
+	}
+
+	last := ingestor.publishOnline(ctx, -1)
+	if got := nextOnline(); got.ActiveVisitors != 0 || got.WindowSeconds != 300 {
+		t.Errorf("initial online event = %+v, want 0 visitors over 300s", got)
+	}
+
+	if err := ingestor.handleLine(ctx, testLogLine("/", "10.0.0.1")); err != nil {
+		t.Fatalf("handleLine() error = %v", err)
+	}
+	last = ingestor.publishOnline(ctx, last)
+	if got := nextOnline(); got.ActiveVisitors != 1 {
+		t.Errorf("online after one request = %+v, want 1 visitor", got)
+	}
+
+	// Unchanged counts are not re-sent
+	if again := ingestor.publishOnline(ctx, last); again != last {
+		t.Errorf("publishOnline() = %d, want unchanged %d", again, last)
+	}
+	select {
+	case evt := <-events:
+		if evt.Type == sse.OnlineEvent {
+			t.Errorf("unexpected online event for an unchanged count: %s", evt.Payload)
+		}
+	default:
+	}
+}
@@ -0,0 +1,24 @@
+package ingest
+
+import "strings"
+
+// normalizePath folds spellings of one page into a single stored path:
+// duplicate slashes are collapsed, and optionally the path is lower-cased
+// and its trailing slash dropped, so "/About/" and "/about" count together.
+// Only the part before "?" is touched; the query string is kept verbatim.
+func normalizePath(uri string, lowercase, trimSlash bool) string {
+	p, query, hasQuery := strings.Cut(uri, "?")
+	for strings.Contains(p, "//") {
+		p = strings.ReplaceAll(p, "//", "/")
+	}
+	if lowercase {
+		p = strings.ToLower(p)
+	}
+	if trimSlash && len(p) > 1 {
+		p = strings.TrimSuffix(p, "/")
+	}
+	if hasQuery {
+		return p + "?" + query
+	}
+	return p
+}
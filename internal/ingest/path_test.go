@@ -0,0 +1,69 @@
+package ingest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dustin/Caddystat/internal/config"
+)
+
+func TestNormalizePath(t *testing.T) {
+	tests := []struct {
+		uri       string
+		lowercase bool
+		trimSlash bool
+		want      string
+	}{
+		{"/about", false, false, "/about"},
+		{"//blog///post", false, false, "/blog/post"},
+		{"/About/", false, false, "/About/"},
+		{"/About/", true, false, "/about/"},
+		{"/About/", false, true, "/About"},
+		{"/About/", true, true, "/about"},
+		{"/", true, true, "/"},
+		{"//", true, true, "/"},
+		{"/Search/?Q=Go", true, true, "/search?Q=Go"},
+		{"/a?next=//x", false, false, "/a?next=//x"},
+		{"", true, true, ""},
+	}
+	for _, tt := range tests {
+		if got := normalizePath(tt.uri, tt.lowercase, tt.trimSlash); got != tt.want {
+			t.Errorf("normalizePath(%q, %v, %v) = %q, want %q", tt.uri, tt.lowercase, tt.trimSlash, got, tt.want)
+		}
+	}
+}
+
+func TestIngestor_NormalizePaths(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.Config
+		want int
+	}{
+		{"disabled", config.Config{}, 2},
+		{"enabled", config.Config{NormalizePaths: true, NormalizePathsLowercase: true, NormalizePathsTrimSlash: true}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ingestor, store := setupTestIngestor(t, tt.cfg, nil)
+			ctx := context.Background()
+
+			for _, path := range []string{"/About/", "/about"} {
+				if err := ingestor.handleLineNoNotify(ctx, testLogLine(path, "1.2.3.4")); err != nil {
+					t.Fatalf("handleLineNoNotify(%q) error = %v", path, err)
+				}
+			}
+
+			recent, err := store.RecentRequests(ctx, 10, "")
+			if err != nil {
+				t.Fatalf("RecentRequests() error = %v", err)
+			}
+			paths := map[string]bool{}
+			for _, r := range recent {
+				paths[r.Path] = true
+			}
+			if len(paths) != tt.want {
+				t.Errorf("got distinct paths %v, want %d", paths, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// parseError marks a log line that is not a Caddy JSON access log entry, as
+// opposed to a failure storing a valid one.
+type parseError struct{ err error }
+
+func (e parseError) Error() string { return e.err.Error() }
+func (e parseError) Unwrap() error { return e.err }
+
+func isParseError(err error) bool {
+	var pe parseError
+	return errors.As(err, &pe)
+}
+
+// parseFailures counts consecutive unparseable lines in one file.
+type parseFailures struct {
+	threshold int // 0 = never quarantine
+	count     int
+}
+
+// observe records the outcome of handling a line and reports whether the
+// file has now reached the quarantine threshold.
+func (p *parseFailures) observe(err error) bool {
+	switch {
+	case err == nil:
+		p.count = 0
+	case isParseError(err):
+		p.count++
+	}
+	return p.threshold > 0 && p.count >= p.threshold
+}
+
+// quarantine stops ingest of a file that keeps failing to parse, most likely
+// because it isn't in Caddy's JSON format. It logs once and records the
+// quarantine so it shows in the status endpoint until lifted through
+// POST /api/admin/reimport.
+func (i *Ingestor) quarantine(ctx context.Context, path string, failures int, lastErr error) {
+	slog.Warn("quarantining log file after repeated parse errors",
+		"path", path,
+		"consecutive_errors", failures,
+		"last_error", lastErr)
+	reason := fmt.Sprintf("quarantined after %d consecutive parse errors: %v", failures, lastErr)
+	if err := i.store.QuarantineFile(ctx, path, reason); err != nil {
+		slog.Warn("failed to record quarantine", "path", path, "error", err)
+	}
+}
+
+// quarantined reports whether path is quarantined, treating lookup errors
+// as not quarantined so a database hiccup never stops ingest.
+func (i *Ingestor) quarantined(ctx context.Context, path string) bool {
+	q, err := i.store.IsQuarantined(ctx, path)
+	if err != nil {
+		slog.Debug("failed to check quarantine", "path", path, "error", err)
+		return false
+	}
+	return q
+}
+
+// waitForRelease blocks until path is no longer quarantined. It returns
+// false if ctx is cancelled first.
+func (i *Ingestor) waitForRelease(ctx context.Context, path string) bool {
+	poll := i.quarantinePoll
+	if poll <= 0 {
+		poll = 30 * time.Second
+	}
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+			if !i.quarantined(ctx, path) {
+				return true
+			}
+		}
+	}
+}
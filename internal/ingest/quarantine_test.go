@@ -0,0 +1,96 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dustin/Caddystat/internal/config"
+)
+
+func TestParseFailures(t *testing.T) {
+	p := parseFailures{threshold: 3}
+	bad := parseError{errors.New("invalid character")}
+
+	if p.observe(bad) || p.observe(bad) {
+		t.Fatal("quarantined before the threshold")
+	}
+	// Storage errors and successes don't count toward the threshold
+	if p.observe(errors.New("database is locked")) {
+		t.Fatal("non-parse error counted")
+	}
+	if p.observe(nil) || p.count != 0 {
+		t.Fatal("success should reset the count")
+	}
+	p.observe(bad)
+	p.observe(bad)
+	if !p.observe(bad) {
+		t.Error("expected quarantine after 3 consecutive parse errors")
+	}
+
+	never := parseFailures{}
+	for range 10 {
+		if never.observe(bad) {
+			t.Fatal("threshold 0 should never quarantine")
+		}
+	}
+}
+
+func TestIngestor_QuarantinesGarbageFile(t *testing.T) {
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "access.log")
+	garbage := strings.Repeat("127.0.0.1 - - [10/Oct/2025:13:55:36] \"GET / HTTP/1.1\" 200 2326\n", 50)
+	if err := os.WriteFile(logFile, []byte(garbage), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.Config{LogPaths: []string{logFile}, QuarantineThreshold: 10}
+	ingestor, store := setupTestIngestor(t, cfg, nil)
+	ingestor.quarantinePoll = 10 * time.Millisecond
+	ctx := context.Background()
+	if err := ingestor.Start(ctx); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer ingestor.Stop()
+
+	if q, err := store.IsQuarantined(ctx, logFile); err != nil || !q {
+		t.Fatalf("IsQuarantined() = %v, %v; want true", q, err)
+	}
+	stats, err := store.GetImportErrors(ctx)
+	if err != nil {
+		t.Fatalf("GetImportErrors() error = %v", err)
+	}
+	if len(stats) != 1 || !stats[0].Quarantined {
+		t.Fatalf("GetImportErrors() = %+v, want one quarantined file", stats)
+	}
+	if stats[0].ErrorCount >= 10 {
+		t.Errorf("error_count = %d, want errors to stop at the threshold", stats[0].ErrorCount)
+	}
+
+	// Let the tailer see the quarantine, then fix the file and lift it
+	time.Sleep(20 * ingestor.quarantinePoll)
+	if err := os.WriteFile(logFile, []byte(testLogLine("/fixed", "10.0.0.1")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.ClearImportErrors(ctx, logFile); err != nil {
+		t.Fatalf("ClearImportErrors() error = %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		recent, err := store.RecentRequests(ctx, 10, "")
+		if err != nil {
+			t.Fatalf("RecentRequests() error = %v", err)
+		}
+		if len(recent) == 1 && recent[0].Path == "/fixed" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("ingest did not resume after release, got %d requests", len(recent))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
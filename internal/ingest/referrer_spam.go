@@ -0,0 +1,93 @@
+package ingest
+
+import (
+	"bufio"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// ReferrerDenylist matches referrer URLs against a list of spam domains.
+// A listed domain also matches all of its subdomains.
+type ReferrerDenylist struct {
+	domains map[string]struct{}
+}
+
+// LoadReferrerDenylist reads a denylist file with one domain per line.
+// Blank lines and lines starting with '#' are ignored, and a leading "*."
+// is accepted for readability. Returns nil if path is empty.
+func LoadReferrerDenylist(path string) (*ReferrerDenylist, error) {
+	if path == "" {
+		return nil, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var domains []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		domains = append(domains, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return NewReferrerDenylist(domains), nil
+}
+
+// NewReferrerDenylist builds a denylist from domain patterns.
+func NewReferrerDenylist(domains []string) *ReferrerDenylist {
+	d := &ReferrerDenylist{domains: make(map[string]struct{}, len(domains))}
+	for _, domain := range domains {
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		if domain == "" || strings.HasPrefix(domain, "#") {
+			continue
+		}
+		domain = strings.TrimPrefix(domain, "*.")
+		d.domains[strings.TrimSuffix(domain, ".")] = struct{}{}
+	}
+	return d
+}
+
+// Len returns the number of domains in the denylist.
+func (d *ReferrerDenylist) Len() int {
+	if d == nil {
+		return 0
+	}
+	return len(d.domains)
+}
+
+// Matches reports whether the referrer's host is a denylisted domain or one
+// of its subdomains.
+func (d *ReferrerDenylist) Matches(referrer string) bool {
+	if d == nil || len(d.domains) == 0 || referrer == "" {
+		return false
+	}
+	host := referrerHost(referrer)
+	for host != "" {
+		if _, ok := d.domains[host]; ok {
+			return true
+		}
+		idx := strings.IndexByte(host, '.')
+		if idx == -1 {
+			break
+		}
+		host = host[idx+1:]
+	}
+	return false
+}
+
+// referrerHost extracts the lowercased hostname from a referrer, tolerating
+// values without a scheme.
+func referrerHost(referrer string) string {
+	if !strings.Contains(referrer, "://") {
+		referrer = "http://" + referrer
+	}
+	u, err := url.Parse(referrer)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+}
@@ -0,0 +1,106 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dustin/Caddystat/internal/config"
+)
+
+func TestReferrerDenylist_Matches(t *testing.T) {
+	d := NewReferrerDenylist([]string{"# comment", "", "semalt.com", "*.Buttons-For-Website.com", "darodar.com."})
+
+	tests := []struct {
+		referrer string
+		want     bool
+	}{
+		{"https://semalt.com/", true},
+		{"http://www.semalt.com/page?x=1", true},
+		{"semalt.com", true},
+		{"https://buttons-for-website.com", true},
+		{"https://a.b.buttons-for-website.com/", true},
+		{"https://DARODAR.COM/", true},
+		{"https://notsemalt.com/", false},
+		{"https://semalt.com.example.org/", false},
+		{"https://google.com/", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := d.Matches(tt.referrer); got != tt.want {
+			t.Errorf("Matches(%q) = %v, want %v", tt.referrer, got, tt.want)
+		}
+	}
+	if d.Len() != 3 {
+		t.Errorf("Len() = %d, want 3", d.Len())
+	}
+}
+
+func TestReferrerDenylist_Nil(t *testing.T) {
+	var d *ReferrerDenylist
+	if d.Matches("https://semalt.com/") {
+		t.Error("nil denylist should not match")
+	}
+	if d.Len() != 0 {
+		t.Errorf("Len() = %d, want 0", d.Len())
+	}
+}
+
+func TestLoadReferrerDenylist(t *testing.T) {
+	if d, err := LoadReferrerDenylist(""); d != nil || err != nil {
+		t.Errorf("LoadReferrerDenylist(\"\") = %v, %v; want nil, nil", d, err)
+	}
+	if _, err := LoadReferrerDenylist(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("expected error for missing file")
+	}
+
+	path := filepath.Join(t.TempDir(), "spam.txt")
+	if err := os.WriteFile(path, []byte("# spammers\nsemalt.com\n\nbuttons-for-website.com\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	d, err := LoadReferrerDenylist(path)
+	if err != nil {
+		t.Fatalf("LoadReferrerDenylist() error = %v", err)
+	}
+	if d.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", d.Len())
+	}
+}
+
+func TestIngestor_SpamReferrerExcludedFromReferrers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spam.txt")
+	if err := os.WriteFile(path, []byte("semalt.com\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ingestor, store := setupTestIngestor(t, config.Config{ReferrerSpamPath: path}, nil)
+	ctx := context.Background()
+
+	for _, ref := range []string{"https://www.semalt.com/crawler", "https://news.ycombinator.com/"} {
+		line := fmt.Sprintf(`{"ts":%d,"request":{"host":"example.com","uri":"/","remote_ip":"1.2.3.4","headers":{"User-Agent":["Mozilla/5.0"],"Referer":[%q]}},"status":200,"bytes_written":100,"duration":0.01}`,
+			time.Now().Unix(), ref)
+		if err := ingestor.handleLineNoNotify(ctx, line); err != nil {
+			t.Fatalf("handleLineNoNotify() error = %v", err)
+		}
+	}
+
+	refs, err := store.Referrers(ctx, time.Hour, "", 10)
+	if err != nil {
+		t.Fatalf("Referrers() error = %v", err)
+	}
+	seen := map[string]bool{}
+	for _, r := range refs {
+		seen[r.Referrer] = true
+	}
+	if seen["https://www.semalt.com/crawler"] {
+		t.Error("spam referrer should not appear in referrers")
+	}
+	if !seen["https://news.ycombinator.com/"] {
+		t.Error("legitimate referrer missing from referrers")
+	}
+	if !seen["Direct / Bookmark"] {
+		t.Error("spam referrer should be counted as direct")
+	}
+}
@@ -0,0 +1,55 @@
+package ingest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// DailySalt hashes IPs with a random salt that is regenerated at UTC midnight.
+// The salt only lives in memory, so hashes can be compared within a day (for
+// unique-visitor counts) but can't be reversed or correlated across days.
+type DailySalt struct {
+	mu   sync.Mutex
+	day  string
+	salt string
+	now  func() time.Time
+}
+
+// NewDailySalt creates a DailySalt using the wall clock.
+func NewDailySalt() *DailySalt {
+	return &DailySalt{now: time.Now}
+}
+
+// Hash returns the SHA-256 hash of ip salted with the current day's salt.
+func (d *DailySalt) Hash(ip string) string {
+	if ip == "" {
+		return ""
+	}
+	return hashIP(ip, d.current())
+}
+
+// current returns the salt for the current UTC day, rotating it if the day changed.
+func (d *DailySalt) current() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	day := d.now().UTC().Format("2006-01-02")
+	if day != d.day || d.salt == "" {
+		d.day = day
+		d.salt = generateSalt()
+	}
+	return d.salt
+}
+
+// generateSalt returns 32 random bytes as a hex string.
+func generateSalt() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand never fails on supported platforms; fall back to
+		// a time-based value rather than hashing with an empty salt.
+		return time.Now().UTC().Format(time.RFC3339Nano)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,90 @@
+package ingest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dustin/Caddystat/internal/config"
+)
+
+func TestDailySalt_SameDay(t *testing.T) {
+	now := time.Date(2024, 3, 10, 0, 5, 0, 0, time.UTC)
+	d := &DailySalt{now: func() time.Time { return now }}
+
+	first := d.Hash("203.0.113.7")
+	now = now.Add(23 * time.Hour) // 23:05, still the same UTC day
+	second := d.Hash("203.0.113.7")
+
+	if first != second {
+		t.Errorf("hash changed within the same day: %q vs %q", first, second)
+	}
+	if len(first) != 64 {
+		t.Errorf("hash length = %d, want 64", len(first))
+	}
+	if first == hashIP("203.0.113.7", "") {
+		t.Error("hash should be salted")
+	}
+}
+
+func TestDailySalt_RotatesAtMidnight(t *testing.T) {
+	now := time.Date(2024, 3, 10, 23, 59, 59, 0, time.UTC)
+	d := &DailySalt{now: func() time.Time { return now }}
+
+	before := d.Hash("203.0.113.7")
+	now = now.Add(2 * time.Second) // 00:00:01 the next day
+	after := d.Hash("203.0.113.7")
+
+	if before == after {
+		t.Error("hash should differ after the salt rotates at UTC midnight")
+	}
+	if d.Hash("203.0.113.7") != after {
+		t.Error("hash should be stable after rotation")
+	}
+}
+
+func TestDailySalt_Empty(t *testing.T) {
+	d := NewDailySalt()
+	if got := d.Hash(""); got != "" {
+		t.Errorf("Hash(\"\") = %q, want empty", got)
+	}
+}
+
+func TestIngestor_DailySaltVisitors(t *testing.T) {
+	ingestor, store := setupTestIngestor(t, config.Config{PrivacyHashDaily: true}, nil)
+	ctx := context.Background()
+
+	for _, line := range []string{
+		testLogLine("/", "198.51.100.1"),
+		testLogLine("/about", "198.51.100.1"),
+		testLogLine("/", "198.51.100.2"),
+	} {
+		if err := ingestor.handleLineNoNotify(ctx, line); err != nil {
+			t.Fatalf("handleLineNoNotify() error = %v", err)
+		}
+	}
+
+	summary, err := store.Summary(ctx, time.Hour, "")
+	if err != nil {
+		t.Fatalf("Summary() error = %v", err)
+	}
+	if summary.UniqueVisitors != 2 {
+		t.Errorf("UniqueVisitors = %d, want 2", summary.UniqueVisitors)
+	}
+
+	visitors, err := store.Visitors(ctx, time.Hour, "", 10, "")
+	if err != nil {
+		t.Fatalf("Visitors() error = %v", err)
+	}
+	if len(visitors) != 2 {
+		t.Fatalf("expected 2 visitors, got %d", len(visitors))
+	}
+	for _, v := range visitors {
+		if v.IP == "198.51.100.1" || v.IP == "198.51.100.2" {
+			t.Errorf("raw IP %q stored, want hash", v.IP)
+		}
+	}
+	if visitors[0].Hits != 2 {
+		t.Errorf("top visitor hits = %d, want 2", visitors[0].Hits)
+	}
+}
@@ -0,0 +1,17 @@
+package ingest
+
+import "unicode/utf8"
+
+// truncateHeader cuts s to at most n bytes without splitting a UTF-8
+// sequence. n <= 0 leaves s unchanged. Browser and OS tokens come early in
+// a user agent, so a truncated one still classifies like the original.
+func truncateHeader(s string, n int) string {
+	if n <= 0 || len(s) <= n {
+		return s
+	}
+	// Back off to the start of the rune that straddles the limit
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
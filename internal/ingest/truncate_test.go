@@ -0,0 +1,60 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dustin/Caddystat/internal/config"
+)
+
+func TestTruncateHeader(t *testing.T) {
+	tests := []struct {
+		in   string
+		n    int
+		want string
+	}{
+		{"Mozilla/5.0", 0, "Mozilla/5.0"},
+		{"Mozilla/5.0", 20, "Mozilla/5.0"},
+		{"Mozilla/5.0", 7, "Mozilla"},
+		{"abcé", 4, "abc"}, // é is two bytes; don't split it
+		{"abcé", 5, "abcé"},
+		{"€€", 4, "€"},
+	}
+	for _, tt := range tests {
+		if got := truncateHeader(tt.in, tt.n); got != tt.want {
+			t.Errorf("truncateHeader(%q, %d) = %q, want %q", tt.in, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestIngestor_TruncatesOversizedHeaders(t *testing.T) {
+	ingestor, store := setupTestIngestor(t, config.Config{MaxHeaderLength: 200}, nil)
+	ctx := context.Background()
+
+	ua := "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 " +
+		strings.Repeat("x", 8000)
+	ref := "https://example.org/?q=" + strings.Repeat("a", 8000)
+	line := fmt.Sprintf(`{"ts":%d,"request":{"host":"example.com","uri":"/","remote_ip":"1.2.3.4","headers":{"User-Agent":[%q],"Referer":[%q]}},"status":200}`,
+		time.Now().Unix(), ua, ref)
+	if err := ingestor.handleLineNoNotify(ctx, line); err != nil {
+		t.Fatalf("handleLineNoNotify() error = %v", err)
+	}
+
+	recent, err := store.RecentRequests(ctx, 1, "")
+	if err != nil || len(recent) != 1 {
+		t.Fatalf("RecentRequests() = %d rows, %v; want 1", len(recent), err)
+	}
+	r := recent[0]
+	if len(r.UserAgent) != 200 || !strings.HasPrefix(ua, r.UserAgent) {
+		t.Errorf("stored user agent of %d bytes, want the first 200", len(r.UserAgent))
+	}
+	if len(r.Referrer) != 200 || !strings.HasPrefix(ref, r.Referrer) {
+		t.Errorf("stored referrer of %d bytes, want the first 200", len(r.Referrer))
+	}
+	if r.Browser != "Chrome" || r.OS != "Windows" {
+		t.Errorf("truncated user agent classified as %s on %s, want Chrome on Windows", r.Browser, r.OS)
+	}
+}
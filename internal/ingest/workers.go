@@ -0,0 +1,72 @@
+package ingest
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/dustin/Caddystat/internal/storage"
+)
+
+// importBatchSize is how many lines of a file are parsed together, stored in
+// one transaction and checkpointed in import_progress during import.
+const importBatchSize = 1000
+
+// pendingLine is a log line waiting to be parsed during import.
+type pendingLine struct {
+	text   string
+	num    int64 // 1-based line number, for error logs
+	offset int64 // Byte offset just past the line
+}
+
+// preparedLine is the result of parsing and enriching one pendingLine.
+type preparedLine struct {
+	record storage.RequestRecord
+	keep   bool // false when excluded or sampled out
+	err    error
+}
+
+// prepareLine parses and enriches a log line. keep is false when the line
+// is valid but dropped by an exclusion rule or sampling.
+func (i *Ingestor) prepareLine(line string) (record storage.RequestRecord, keep bool, err error) {
+	entry, err := parseCaddyLog(line)
+	if err != nil {
+		return storage.RequestRecord{}, false, err
+	}
+	if i.excluded(entry) || i.sampledOut() {
+		return storage.RequestRecord{}, false, nil
+	}
+	return i.buildRecord(entry), true, nil
+}
+
+// prepareLines runs prepareLine over lines on up to IngestWorkers
+// goroutines. Parsing, user-agent and geo enrichment are CPU-bound and safe
+// to run concurrently; results come back in input order so the caller can
+// store them, and advance import progress, in file order.
+func (i *Ingestor) prepareLines(lines []pendingLine) []preparedLine {
+	out := make([]preparedLine, len(lines))
+	workers := min(i.cfg.IngestWorkers, len(lines))
+	if workers <= 1 {
+		for n, line := range lines {
+			out[n].record, out[n].keep, out[n].err = i.prepareLine(line.text)
+		}
+		return out
+	}
+
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				n := int(next.Add(1) - 1)
+				if n >= len(lines) {
+					return
+				}
+				out[n].record, out[n].keep, out[n].err = i.prepareLine(lines[n].text)
+			}
+		}()
+	}
+	wg.Wait()
+	return out
+}
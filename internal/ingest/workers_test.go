@@ -0,0 +1,159 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/dustin/Caddystat/internal/config"
+)
+
+// writeLogFixture writes n log lines with paths /page/0 ... /page/n-1,
+// replacing every badEvery-th line with garbage when badEvery > 0.
+func writeLogFixture(t testing.TB, n, badEvery int) string {
+	t.Helper()
+	var b strings.Builder
+	for k := 0; k < n; k++ {
+		if badEvery > 0 && k%badEvery == badEvery-1 {
+			b.WriteString("not json\n")
+			continue
+		}
+		line := fmt.Sprintf(`{"ts":1700000000,"request":{"host":"example.com","uri":"/page/%d","remote_ip":"10.0.%d.%d","headers":{"User-Agent":["Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"]}},"status":200,"bytes_written":100,"duration":0.01}`,
+			k, k/256%256, k%256)
+		b.WriteString(line + "\n")
+	}
+	path := filepath.Join(t.TempDir(), "access.log")
+	if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+		t.Fatalf("write fixture: %v", err)
+	}
+	return path
+}
+
+func TestPrepareLines_PreservesOrder(t *testing.T) {
+	ingestor, _ := setupTestIngestor(t, config.Config{IngestWorkers: 8}, nil)
+
+	lines := make([]pendingLine, 500)
+	for n := range lines {
+		lines[n] = pendingLine{text: testLogLine(fmt.Sprintf("/page/%d", n), "10.0.0.1")}
+		if n%7 == 0 {
+			lines[n].text = "garbage"
+		}
+	}
+
+	results := ingestor.prepareLines(lines)
+	if len(results) != len(lines) {
+		t.Fatalf("got %d results, want %d", len(results), len(lines))
+	}
+	for n, res := range results {
+		if n%7 == 0 {
+			if !isParseError(res.err) {
+				t.Errorf("result %d: err = %v, want parse error", n, res.err)
+			}
+			continue
+		}
+		if res.err != nil || !res.keep {
+			t.Fatalf("result %d: err = %v, keep = %v", n, res.err, res.keep)
+		}
+		if want := fmt.Sprintf("/page/%d", n); res.record.Path != want {
+			t.Errorf("result %d: Path = %q, want %q", n, res.record.Path, want)
+		}
+	}
+}
+
+func TestIngestor_ParallelImport(t *testing.T) {
+	const total = 2500 // spans several batches, ending mid-batch
+	path := writeLogFixture(t, total, 10)
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat fixture: %v", err)
+	}
+
+	for _, workers := range []int{1, 4} {
+		t.Run(fmt.Sprintf("workers=%d", workers), func(t *testing.T) {
+			ingestor, store := setupTestIngestor(t, config.Config{IngestWorkers: workers}, nil)
+			ctx := context.Background()
+
+			count, err := ingestor.importLogFile(ctx, path)
+			if err != nil {
+				t.Fatalf("importLogFile() error = %v", err)
+			}
+			if want := total - total/10; count != want {
+				t.Errorf("imported %d entries, want %d", count, want)
+			}
+
+			// Rows are stored in file order
+			rows, err := store.DB().QueryContext(ctx, "SELECT path FROM requests ORDER BY id")
+			if err != nil {
+				t.Fatalf("query requests: %v", err)
+			}
+			defer rows.Close()
+			k := 0
+			for rows.Next() {
+				var got string
+				if err := rows.Scan(&got); err != nil {
+					t.Fatalf("scan: %v", err)
+				}
+				if k%10 == 9 {
+					k++ // skip the garbage line
+				}
+				if want := fmt.Sprintf("/page/%d", k); got != want {
+					t.Fatalf("row path = %q, want %q", got, want)
+				}
+				k++
+			}
+
+			progress, err := store.GetImportProgress(ctx, path)
+			if err != nil || progress == nil {
+				t.Fatalf("GetImportProgress() = %v, %v", progress, err)
+			}
+			if progress.ByteOffset != fi.Size() {
+				t.Errorf("ByteOffset = %d, want %d", progress.ByteOffset, fi.Size())
+			}
+
+			// A second import finds nothing new
+			if count, err := ingestor.importLogFile(ctx, path); err != nil || count != 0 {
+				t.Errorf("re-import = %d, %v; want 0, nil", count, err)
+			}
+		})
+	}
+}
+
+// BenchmarkPrepareLines measures parsing and enrichment alone, serial
+// against one worker per CPU.
+func BenchmarkPrepareLines(b *testing.B) {
+	lines := make([]pendingLine, importBatchSize)
+	for n := range lines {
+		lines[n] = pendingLine{text: testLogLine(fmt.Sprintf("/page/%d", n), fmt.Sprintf("10.0.%d.%d", n/256, n%256))}
+	}
+	for _, workers := range []int{1, max(2, runtime.NumCPU())} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			ingestor, _ := setupTestIngestor(b, config.Config{IngestWorkers: workers}, nil)
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				ingestor.prepareLines(lines)
+			}
+		})
+	}
+}
+
+// BenchmarkImportLogFile imports a large fixture end to end, including the
+// single-writer inserts.
+func BenchmarkImportLogFile(b *testing.B) {
+	path := writeLogFixture(b, 20000, 0)
+	for _, workers := range []int{1, max(2, runtime.NumCPU())} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				b.StopTimer()
+				ingestor, _ := setupTestIngestor(b, config.Config{IngestWorkers: workers}, nil)
+				b.StartTimer()
+				if _, err := ingestor.importLogFile(context.Background(), path); err != nil {
+					b.Fatalf("importLogFile() error = %v", err)
+				}
+			}
+		})
+	}
+}
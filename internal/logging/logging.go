@@ -17,19 +17,30 @@ const (
 	LevelError
 )
 
+// levelVar holds the effective level of the logger installed by Setup so it
+// can be changed at runtime.
+var levelVar = new(slog.LevelVar)
+
 // ParseLevel converts a string level name to Level
 func ParseLevel(s string) Level {
+	level, _ := LookupLevel(s)
+	return level
+}
+
+// LookupLevel converts a string level name to Level, reporting whether the
+// name was recognized. Unknown names return LevelInfo and false.
+func LookupLevel(s string) (Level, bool) {
 	switch strings.ToUpper(strings.TrimSpace(s)) {
 	case "DEBUG":
-		return LevelDebug
+		return LevelDebug, true
 	case "INFO":
-		return LevelInfo
+		return LevelInfo, true
 	case "WARN", "WARNING":
-		return LevelWarn
+		return LevelWarn, true
 	case "ERROR":
-		return LevelError
+		return LevelError, true
 	default:
-		return LevelInfo
+		return LevelInfo, false
 	}
 }
 
@@ -72,11 +83,38 @@ func Setup(level Level) *slog.Logger {
 
 // SetupWithWriter initializes slog with a custom writer (useful for testing)
 func SetupWithWriter(level Level, w io.Writer) *slog.Logger {
+	levelVar.Set(level.ToSlogLevel())
 	opts := &slog.HandlerOptions{
-		Level: level.ToSlogLevel(),
+		Level: levelVar,
 	}
 	handler := slog.NewTextHandler(w, opts)
 	logger := slog.New(handler)
 	slog.SetDefault(logger)
 	return logger
 }
+
+// LevelVar returns the variable controlling the level of the logger
+// installed by Setup.
+func LevelVar() *slog.LevelVar {
+	return levelVar
+}
+
+// SetLevel changes the level of the logger installed by Setup without
+// reinstalling it.
+func SetLevel(level Level) {
+	levelVar.Set(level.ToSlogLevel())
+}
+
+// CurrentLevel returns the effective level of the logger installed by Setup.
+func CurrentLevel() Level {
+	switch l := levelVar.Level(); {
+	case l <= slog.LevelDebug:
+		return LevelDebug
+	case l <= slog.LevelInfo:
+		return LevelInfo
+	case l <= slog.LevelWarn:
+		return LevelWarn
+	default:
+		return LevelError
+	}
+}
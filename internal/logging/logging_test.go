@@ -109,3 +109,36 @@ func TestSetupWithWriter(t *testing.T) {
 		t.Error("Debug message should be logged at DEBUG level")
 	}
 }
+
+func TestLookupLevel(t *testing.T) {
+	if level, ok := LookupLevel("warning"); !ok || level != LevelWarn {
+		t.Errorf("LookupLevel(warning) = %v, %v; want WARN, true", level, ok)
+	}
+	if level, ok := LookupLevel("trace"); ok || level != LevelInfo {
+		t.Errorf("LookupLevel(trace) = %v, %v; want INFO, false", level, ok)
+	}
+}
+
+func TestSetLevel_ChangesInstalledLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := SetupWithWriter(LevelInfo, &buf)
+	t.Cleanup(func() { SetLevel(LevelInfo) })
+
+	logger.Debug("before")
+	if buf.Len() > 0 {
+		t.Fatal("Debug message should not be logged at INFO level")
+	}
+
+	SetLevel(LevelDebug)
+	if got := LevelVar().Level(); got != slog.LevelDebug {
+		t.Errorf("LevelVar().Level() = %v, want %v", got, slog.LevelDebug)
+	}
+	if got := CurrentLevel(); got != LevelDebug {
+		t.Errorf("CurrentLevel() = %v, want DEBUG", got)
+	}
+
+	logger.Debug("after")
+	if buf.Len() == 0 {
+		t.Error("Debug message should be logged after SetLevel(LevelDebug)")
+	}
+}
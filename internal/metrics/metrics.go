@@ -2,6 +2,7 @@ package metrics
 
 import (
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -23,11 +24,18 @@ type Metrics struct {
 	IngestDuration      prometheus.Histogram
 	LastIngestTimestamp prometheus.Gauge
 	IngestBytesTotal    prometheus.Counter
+	IngestExcludedTotal *prometheus.CounterVec
+	IngestQueueDepth    prometheus.GaugeFunc
+	IngestBlockedTotal  prometheus.Counter
 
 	// Bot ingestion metrics
 	IngestBotRequestsTotal *prometheus.CounterVec
 	IngestBotBytesTotal    *prometheus.CounterVec
 
+	// Traffic totals from the rollup tables, for the busiest hosts
+	TrafficRequests *prometheus.GaugeVec
+	TrafficBytes    *prometheus.GaugeVec
+
 	// Database metrics
 	DBSizeBytes      prometheus.GaugeFunc
 	DBRequestsTotal  prometheus.GaugeFunc
@@ -43,6 +51,8 @@ type Metrics struct {
 	GeoCacheEvicts   prometheus.GaugeFunc
 	GeoCacheHitRate  prometheus.GaugeFunc
 	GeoCacheCapacity prometheus.GaugeFunc
+
+	queueDepth *atomic.Pointer[func() int64] // read by IngestQueueDepth
 }
 
 // DBStats represents database statistics returned by the stats provider function.
@@ -111,6 +121,7 @@ func New(
 	geoCacheStatsFunc func() *GeoCacheStats,
 ) *Metrics {
 	cache := newCachedDBStats(dbStatsFunc)
+	queueDepth := new(atomic.Pointer[func() int64])
 
 	// Helper to safely get geo cache stats (handles nil function)
 	getGeoStats := func() GeoCacheStats {
@@ -125,6 +136,7 @@ func New(
 	}
 
 	m := &Metrics{
+		queueDepth: queueDepth,
 		HTTPRequestsTotal: prometheus.NewCounterVec(
 			prometheus.CounterOpts{
 				Namespace: "caddystat",
@@ -204,6 +216,37 @@ func New(
 				Help:      "Total bytes processed from log entries",
 			},
 		),
+		IngestExcludedTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "caddystat",
+				Subsystem: "ingest",
+				Name:      "excluded_total",
+				Help:      "Total number of log entries dropped by exclusion rules, by reason",
+			},
+			[]string{"reason"},
+		),
+		IngestQueueDepth: prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Namespace: "caddystat",
+				Subsystem: "ingest",
+				Name:      "queue_depth",
+				Help:      "Log lines and pushed records read but not yet stored",
+			},
+			func() float64 {
+				if f := queueDepth.Load(); f != nil {
+					return float64((*f)())
+				}
+				return 0
+			},
+		),
+		IngestBlockedTotal: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace: "caddystat",
+				Subsystem: "ingest",
+				Name:      "blocked_records_total",
+				Help:      "Total number of records whose insert was held back waiting for free disk space",
+			},
+		),
 		IngestBotRequestsTotal: prometheus.NewCounterVec(
 			prometheus.CounterOpts{
 				Namespace: "caddystat",
@@ -222,6 +265,22 @@ func New(
 			},
 			[]string{"intent"},
 		),
+		TrafficRequests: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: "caddystat",
+				Name:      "requests_total",
+				Help:      "Requests recorded for a host, by status class",
+			},
+			[]string{"host", "status_class"},
+		),
+		TrafficBytes: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: "caddystat",
+				Name:      "bytes_total",
+				Help:      "Response bytes recorded for a host",
+			},
+			[]string{"host"},
+		),
 		DBSizeBytes: prometheus.NewGaugeFunc(
 			prometheus.GaugeOpts{
 				Namespace: "caddystat",
@@ -371,8 +430,13 @@ func (m *Metrics) Register() error {
 		m.IngestDuration,
 		m.LastIngestTimestamp,
 		m.IngestBytesTotal,
+		m.IngestExcludedTotal,
+		m.IngestQueueDepth,
+		m.IngestBlockedTotal,
 		m.IngestBotRequestsTotal,
 		m.IngestBotBytesTotal,
+		m.TrafficRequests,
+		m.TrafficBytes,
 		m.DBSizeBytes,
 		m.DBRequestsTotal,
 		m.DBSessionsTotal,
@@ -413,6 +477,23 @@ func (m *Metrics) RecordIngestError() {
 	m.IngestErrorsTotal.Inc()
 }
 
+// RecordIngestExcluded records a log entry dropped by an exclusion rule.
+func (m *Metrics) RecordIngestExcluded(reason string) {
+	m.IngestExcludedTotal.WithLabelValues(reason).Inc()
+}
+
+// SetIngestQueueDepthFunc sets the function the queue depth gauge reads on
+// each scrape.
+func (m *Metrics) SetIngestQueueDepthFunc(depth func() int64) {
+	m.queueDepth.Store(&depth)
+}
+
+// RecordIngestBlocked records n records held back while ingest waits for
+// free disk space.
+func (m *Metrics) RecordIngestBlocked(n int) {
+	m.IngestBlockedTotal.Add(float64(n))
+}
+
 // SetLastIngestTimestamp sets the timestamp of the last ingested entry.
 func (m *Metrics) SetLastIngestTimestamp(ts float64) {
 	m.LastIngestTimestamp.Set(ts)
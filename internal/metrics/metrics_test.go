@@ -155,6 +155,32 @@ func TestMetrics_RecordIngestError(t *testing.T) {
 	}
 }
 
+func TestMetrics_RecordIngestExcluded(t *testing.T) {
+	reg := prometheus.NewRegistry()
+
+	m := &Metrics{
+		IngestExcludedTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "test",
+				Name:      "ingest_excluded_total",
+			},
+			[]string{"reason"},
+		),
+	}
+	reg.MustRegister(m.IngestExcludedTotal)
+
+	m.RecordIngestExcluded("path")
+	m.RecordIngestExcluded("path")
+	m.RecordIngestExcluded("ip")
+
+	if count := testutil.ToFloat64(m.IngestExcludedTotal.WithLabelValues("path")); count != 2 {
+		t.Errorf("expected 2 path exclusions, got %v", count)
+	}
+	if count := testutil.ToFloat64(m.IngestExcludedTotal.WithLabelValues("ip")); count != 1 {
+		t.Errorf("expected 1 ip exclusion, got %v", count)
+	}
+}
+
 func TestMetrics_SetLastIngestTimestamp(t *testing.T) {
 	reg := prometheus.NewRegistry()
 
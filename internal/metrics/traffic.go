@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// HostTraffic is the stored traffic total for one host.
+type HostTraffic struct {
+	Host      string
+	Bytes     int64
+	Status2xx int64
+	Status3xx int64
+	Status4xx int64
+	Status5xx int64
+}
+
+// TrafficSource returns per-host traffic totals for the busiest limit hosts.
+// Implementations may fold the remaining hosts into a single "other" entry.
+type TrafficSource interface {
+	HostTraffic(ctx context.Context, limit int) ([]HostTraffic, error)
+}
+
+// SetTraffic replaces the traffic gauges with hosts. Hosts missing from the
+// new set are removed, so label cardinality stays bounded by the source.
+func (m *Metrics) SetTraffic(hosts []HostTraffic) {
+	m.TrafficRequests.Reset()
+	m.TrafficBytes.Reset()
+	for _, h := range hosts {
+		m.TrafficRequests.WithLabelValues(h.Host, "2xx").Set(float64(h.Status2xx))
+		m.TrafficRequests.WithLabelValues(h.Host, "3xx").Set(float64(h.Status3xx))
+		m.TrafficRequests.WithLabelValues(h.Host, "4xx").Set(float64(h.Status4xx))
+		m.TrafficRequests.WithLabelValues(h.Host, "5xx").Set(float64(h.Status5xx))
+		m.TrafficBytes.WithLabelValues(h.Host).Set(float64(h.Bytes))
+	}
+}
+
+// RunTrafficUpdater refreshes the traffic gauges from src every interval
+// until ctx is done, starting immediately.
+func (m *Metrics) RunTrafficUpdater(ctx context.Context, src TrafficSource, topHosts int, interval time.Duration) {
+	update := func() {
+		hosts, err := src.HostTraffic(ctx, topHosts)
+		if err != nil {
+			slog.Warn("failed to update traffic metrics", "error", err)
+			return
+		}
+		m.SetTraffic(hosts)
+	}
+
+	update()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			update()
+		}
+	}
+}
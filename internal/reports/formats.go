@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"html/template"
 	"time"
+
+	"github.com/dustin/Caddystat/internal/storage"
 )
 
 // GenerateJSON generates a JSON report.
@@ -25,7 +27,11 @@ func GenerateHTML(data *ReportData) ([]byte, error) {
 		"formatPercent": func(f float64) string {
 			return fmt.Sprintf("%.1f%%", f)
 		},
-		"formatFloat": func(f float64) string {
+		// f is a float64, a storage.Millis or a non-nil *storage.Millis
+		"formatFloat": func(f any) string {
+			if m, ok := f.(*storage.Millis); ok {
+				f = *m
+			}
 			return fmt.Sprintf("%.2f", f)
 		},
 		"truncate": truncate,
@@ -415,7 +421,7 @@ const htmlTemplate = `<!DOCTYPE html>
             </div>
             <div class="card">
                 <div class="card-title">P95 Response</div>
-                <div class="card-value">{{formatFloat .Performance.ResponseTime.P95}} ms</div>
+                <div class="card-value">{{with .Performance.ResponseTime.P95}}{{formatFloat .}} ms{{else}}n/a{{end}}</div>
             </div>
             <div class="card">
                 <div class="card-title">Max Response</div>
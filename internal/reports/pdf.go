@@ -145,9 +145,13 @@ func GeneratePDF(data *ReportData) ([]byte, error) {
 		pdf.addText(fmt.Sprintf("Min Response Time: %.2f ms", data.Performance.ResponseTime.Min))
 		pdf.addText(fmt.Sprintf("Avg Response Time: %.2f ms", data.Performance.ResponseTime.Avg))
 		pdf.addText(fmt.Sprintf("Max Response Time: %.2f ms", data.Performance.ResponseTime.Max))
-		pdf.addText(fmt.Sprintf("P50: %.2f ms", data.Performance.ResponseTime.P50))
-		pdf.addText(fmt.Sprintf("P95: %.2f ms", data.Performance.ResponseTime.P95))
-		pdf.addText(fmt.Sprintf("P99: %.2f ms", data.Performance.ResponseTime.P99))
+		if rt := data.Performance.ResponseTime; rt.P50 == nil {
+			pdf.addText(fmt.Sprintf("Percentiles: not enough samples (%d)", rt.Count))
+		} else {
+			pdf.addText(fmt.Sprintf("P50: %.2f ms", *rt.P50))
+			pdf.addText(fmt.Sprintf("P95: %.2f ms", *rt.P95))
+			pdf.addText(fmt.Sprintf("P99: %.2f ms", *rt.P99))
+		}
 	}
 
 	// Footer
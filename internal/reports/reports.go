@@ -155,7 +155,7 @@ type Config struct {
 // StatsProvider interface for fetching stats data.
 type StatsProvider interface {
 	Summary(ctx context.Context, since time.Duration, host string) (storage.Summary, error)
-	TimeSeriesRange(ctx context.Context, dur time.Duration, host string) ([]storage.TimeSeriesStat, error)
+	TimeSeriesRange(ctx context.Context, dur time.Duration, host string, bucket storage.Bucket) ([]storage.TimeSeriesStat, error)
 	Geo(ctx context.Context, dur time.Duration, host string) ([]storage.GeoStat, error)
 	Browsers(ctx context.Context, dur time.Duration, host string, limit int) ([]storage.BrowserStat, error)
 	OperatingSystems(ctx context.Context, dur time.Duration, host string, limit int) ([]storage.OSStat, error)
@@ -384,7 +384,7 @@ func (m *Manager) gatherReportData(ctx context.Context, reportType ReportType, h
 		PageViews:       summary.Traffic.Viewed.Pages,
 		BandwidthBytes:  summary.BandwidthBytes,
 		BandwidthHuman:  formatBytes(summary.BandwidthBytes),
-		AvgResponseTime: summary.AvgResponseTime,
+		AvgResponseTime: float64(summary.AvgResponseTime),
 		Status2xx:       summary.Status2xx,
 		Status3xx:       summary.Status3xx,
 		Status4xx:       summary.Status4xx,
@@ -397,7 +397,7 @@ func (m *Manager) gatherReportData(ctx context.Context, reportType ReportType, h
 	}
 
 	// Get time series
-	data.TimeSeries, _ = m.stats.TimeSeriesRange(ctx, dur, host)
+	data.TimeSeries, _ = m.stats.TimeSeriesRange(ctx, dur, host, storage.BucketHour)
 
 	// Get top pages
 	data.TopPages = summary.TopPaths
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// accessLogger emits a debug-level log line for every Nth API request.
+type accessLogger struct {
+	every uint64
+	count atomic.Uint64
+}
+
+// newAccessLogger returns a logger sampling 1 in every requests, or nil if
+// every is not positive (access logging disabled).
+func newAccessLogger(every int) *accessLogger {
+	if every <= 0 {
+		return nil
+	}
+	return &accessLogger{every: uint64(every)}
+}
+
+// sample reports whether the current request should be logged. The first
+// request is always sampled.
+func (a *accessLogger) sample() bool {
+	if a == nil {
+		return false
+	}
+	return (a.count.Add(1)-1)%a.every == 0
+}
+
+// log records a completed API request if it is sampled.
+func (a *accessLogger) log(r *http.Request, status int, duration time.Duration) {
+	if !strings.HasPrefix(r.URL.Path, "/api/") {
+		return
+	}
+	if !slog.Default().Enabled(r.Context(), slog.LevelDebug) || !a.sample() {
+		return
+	}
+	slog.Debug("api request",
+		"method", r.Method,
+		"path", r.URL.Path,
+		"status", status,
+		"duration_ms", float64(duration.Microseconds())/1000,
+		"ip", extractIP(r),
+		"request_id", requestID(r),
+	)
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dustin/Caddystat/internal/logging"
+)
+
+// captureDebugLogs installs a debug-level default logger writing to buf for
+// the duration of the test.
+func captureDebugLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	prev := slog.Default()
+	prevLevel := logging.CurrentLevel()
+	var buf bytes.Buffer
+	logging.SetupWithWriter(logging.LevelDebug, &buf)
+	t.Cleanup(func() {
+		slog.SetDefault(prev)
+		logging.SetLevel(prevLevel)
+	})
+	return &buf
+}
+
+func TestAccessLogger_Disabled(t *testing.T) {
+	if a := newAccessLogger(0); a != nil {
+		t.Error("access logger should be nil when sample rate is 0")
+	}
+	var a *accessLogger
+	if a.sample() {
+		t.Error("nil access logger should never sample")
+	}
+}
+
+func TestAccessLogger_Sampling(t *testing.T) {
+	a := newAccessLogger(3)
+	var got []bool
+	for i := 0; i < 6; i++ {
+		got = append(got, a.sample())
+	}
+	want := []bool{true, false, false, true, false, false}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("sample() #%d = %v, want %v", i+1, got[i], want[i])
+		}
+	}
+}
+
+func TestServeHTTP_LogsSampledAPIRequest(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+	srv.accessLog = newAccessLogger(2)
+	buf := captureDebugLogs(t)
+
+	for _, path := range []string{"/api/stats/status", "/api/auth/check", "/health"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = "203.0.113.9:5555"
+		srv.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	out := buf.String()
+	if !strings.Contains(out, `msg="api request"`) ||
+		!strings.Contains(out, "path=/api/stats/status") ||
+		!strings.Contains(out, "status=200") ||
+		!strings.Contains(out, "ip=203.0.113.9") {
+		t.Errorf("expected access log line for sampled request, got:\n%s", out)
+	}
+	if strings.Contains(out, "path=/api/auth/check") {
+		t.Errorf("second request should not be sampled at 1 in 2, got:\n%s", out)
+	}
+	if strings.Contains(out, "path=/health") {
+		t.Errorf("non-API requests should not be logged, got:\n%s", out)
+	}
+}
@@ -4,15 +4,18 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/dustin/Caddystat/internal/config"
+	"github.com/dustin/Caddystat/internal/logging"
 	"github.com/dustin/Caddystat/internal/sse"
 	"github.com/dustin/Caddystat/internal/storage"
 )
@@ -206,6 +209,40 @@ func TestAPISummary_WithHostFilter(t *testing.T) {
 	}
 }
 
+func TestAPISummary_CountryFilter(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	for _, country := range []string{"DE", "DE", "US", ""} {
+		rec := storage.RequestRecord{Timestamp: time.Now().UTC(), Host: "example.com", Path: "/", Status: 200, IP: "10.0.0.1", Country: country}
+		if err := srv.store.InsertRequest(ctx, rec); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/stats/summary?range=24h&country=de", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var resp storage.Summary
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.TotalRequests != 2 {
+		t.Errorf("country=de TotalRequests = %d, want 2", resp.TotalRequests)
+	}
+
+	for _, path := range []string{"/api/stats/summary?country=Germany", "/api/stats/requests?country=D", "/api/stats/hosts?country=12"} {
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "INVALID_COUNTRY") {
+			t.Errorf("GET %s: got %d %s, want 400 INVALID_COUNTRY", path, w.Code, w.Body.String())
+		}
+	}
+}
+
 func TestAPIRequests(t *testing.T) {
 	srv, cleanup := setupTestServerWithData(t)
 	defer cleanup()
@@ -230,6 +267,27 @@ func TestAPIRequests(t *testing.T) {
 	}
 }
 
+func TestAPIRequests_Bucket(t *testing.T) {
+	srv, cleanup := setupTestServerWithData(t)
+	defer cleanup()
+
+	for _, bucket := range []string{"minute", "5min", "hour", "day", "auto"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/stats/requests?range=24h&bucket="+bucket, nil)
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Errorf("bucket=%s: expected status %d, got %d", bucket, http.StatusOK, w.Code)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/stats/requests?range=24h&bucket=week", nil)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for invalid bucket, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
 func TestAPIGeo(t *testing.T) {
 	srv, cleanup := setupTestServerWithData(t)
 	defer cleanup()
@@ -967,3 +1025,806 @@ func TestAPIInvalidJSON(t *testing.T) {
 		t.Errorf("expected status %d for invalid JSON, got %d", http.StatusBadRequest, w.Code)
 	}
 }
+
+func TestAPILogLevel_ChangesAtRuntime(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	logging.SetLevel(logging.LevelInfo)
+	t.Cleanup(func() { logging.SetLevel(logging.LevelInfo) })
+
+	csrfReq := httptest.NewRequest(http.MethodGet, "/api/auth/check", nil)
+	csrfW := httptest.NewRecorder()
+	srv.ServeHTTP(csrfW, csrfReq)
+
+	var csrfToken string
+	for _, cookie := range csrfW.Result().Cookies() {
+		if cookie.Name == csrfCookieName {
+			csrfToken = cookie.Value
+			break
+		}
+	}
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/admin/loglevel", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(csrfHeaderName, csrfToken)
+		req.AddCookie(&http.Cookie{Name: csrfCookieName, Value: csrfToken})
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		return w
+	}
+
+	w := post(`{"level":"debug"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if got := logging.LevelVar().Level(); got != slog.LevelDebug {
+		t.Errorf("effective level = %v, want %v", got, slog.LevelDebug)
+	}
+
+	// Status reflects the new level
+	req := httptest.NewRequest(http.MethodGet, "/api/stats/status", nil)
+	statusW := httptest.NewRecorder()
+	srv.ServeHTTP(statusW, req)
+	var status map[string]any
+	if err := json.NewDecoder(statusW.Body).Decode(&status); err != nil {
+		t.Fatalf("failed to decode status: %v", err)
+	}
+	if status["log_level"] != "DEBUG" {
+		t.Errorf("status log_level = %v, want DEBUG", status["log_level"])
+	}
+
+	// Unknown levels are rejected and leave the level unchanged
+	w = post(`{"level":"trace"}`)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for unknown level, got %d", http.StatusBadRequest, w.Code)
+	}
+	if got := logging.CurrentLevel(); got != logging.LevelDebug {
+		t.Errorf("level after rejected update = %v, want DEBUG", got)
+	}
+}
+
+func TestAPILogLevel_RequiresAuth(t *testing.T) {
+	srv, cleanup := setupTestServerWithAuth(t)
+	defer cleanup()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/loglevel", nil)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected status %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+}
+
+// loginRestricted logs in with a session limited to allowed.com and returns
+// the session cookie plus the CSRF token for mutating requests.
+func loginRestricted(t *testing.T, srv *Server) (*http.Cookie, string) {
+	t.Helper()
+	initW := httptest.NewRecorder()
+	srv.ServeHTTP(initW, httptest.NewRequest(http.MethodGet, "/api/auth/check", nil))
+	var csrfToken string
+	for _, cookie := range initW.Result().Cookies() {
+		if cookie.Name == csrfCookieName {
+			csrfToken = cookie.Value
+		}
+	}
+
+	body := `{"username": "admin", "password": "secret123", "allowed_sites": ["allowed.com"]}`
+	loginReq := httptest.NewRequest(http.MethodPost, "/api/auth/login", strings.NewReader(body))
+	loginReq.Header.Set("Content-Type", "application/json")
+	loginReq.Header.Set(csrfHeaderName, csrfToken)
+	loginReq.AddCookie(&http.Cookie{Name: csrfCookieName, Value: csrfToken})
+	loginW := httptest.NewRecorder()
+	srv.ServeHTTP(loginW, loginReq)
+	session := getSessionCookie(loginW.Result().Cookies())
+	if session == nil {
+		t.Fatalf("session cookie not set: %d %s", loginW.Code, loginW.Body.String())
+	}
+	return session, csrfToken
+}
+
+// postAsRestricted sends a CSRF-valid POST from a site-restricted session.
+func postAsRestricted(t *testing.T, srv *Server, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	session, csrfToken := loginRestricted(t, srv)
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(csrfHeaderName, csrfToken)
+	req.AddCookie(&http.Cookie{Name: csrfCookieName, Value: csrfToken})
+	req.AddCookie(session)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	return w
+}
+
+func TestAPILogLevel_RequiresAdmin(t *testing.T) {
+	srv, cleanup := setupTestServerWithAuth(t)
+	defer cleanup()
+
+	logging.SetLevel(logging.LevelInfo)
+	t.Cleanup(func() { logging.SetLevel(logging.LevelInfo) })
+
+	w := postAsRestricted(t, srv, "/api/admin/loglevel", `{"level":"debug"}`)
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "ADMIN_REQUIRED") {
+		t.Errorf("restricted session: expected %d ADMIN_REQUIRED, got %d: %s", http.StatusForbidden, w.Code, w.Body.String())
+	}
+	if got := logging.CurrentLevel(); got != logging.LevelInfo {
+		t.Errorf("level changed to %v by a restricted session", got)
+	}
+}
+
+func TestAPICleanup_ReportsDeletions(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+	srv.cfg.DataRetentionDays = 7
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	records := []storage.RequestRecord{
+		{Timestamp: now.AddDate(0, 0, -30), Host: "example.com", Path: "/old1", Status: 200, IP: "1.1.1.1"},
+		{Timestamp: now.AddDate(0, 0, -10), Host: "example.com", Path: "/old2", Status: 200, IP: "1.1.1.1"},
+		{Timestamp: now.Add(-time.Hour), Host: "example.com", Path: "/recent", Status: 200, IP: "2.2.2.2"},
+	}
+	for _, rec := range records {
+		if err := srv.store.InsertRequest(ctx, rec); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	csrfReq := httptest.NewRequest(http.MethodGet, "/api/auth/check", nil)
+	csrfW := httptest.NewRecorder()
+	srv.ServeHTTP(csrfW, csrfReq)
+
+	var csrfToken string
+	for _, cookie := range csrfW.Result().Cookies() {
+		if cookie.Name == csrfCookieName {
+			csrfToken = cookie.Value
+			break
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/cleanup", nil)
+	req.Header.Set(csrfHeaderName, csrfToken)
+	req.AddCookie(&http.Cookie{Name: csrfCookieName, Value: csrfToken})
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var resp storage.MaintenanceResult
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Cleanup == nil {
+		t.Fatal("expected cleanup result in response")
+	}
+	if resp.Cleanup.TotalDeleted != 2 {
+		t.Errorf("total_deleted = %d, want 2", resp.Cleanup.TotalDeleted)
+	}
+	if resp.BytesFreed < 0 {
+		t.Errorf("bytes_freed = %d, want >= 0", resp.BytesFreed)
+	}
+
+	recent, err := srv.store.RecentRequests(ctx, 10, "")
+	if err != nil {
+		t.Fatalf("RecentRequests() error = %v", err)
+	}
+	if len(recent) != 1 {
+		t.Errorf("expected 1 request after cleanup, got %d", len(recent))
+	}
+}
+
+func TestAPICleanup_RequiresAdmin(t *testing.T) {
+	srv, cleanup := setupTestServerWithAuth(t)
+	defer cleanup()
+	srv.cfg.DataRetentionDays = 7
+
+	ctx := context.Background()
+	old := storage.RequestRecord{Timestamp: time.Now().UTC().AddDate(0, 0, -30), Host: "allowed.com", Path: "/old", Status: 200}
+	if err := srv.store.InsertRequest(ctx, old); err != nil {
+		t.Fatalf("InsertRequest() error = %v", err)
+	}
+
+	w := postAsRestricted(t, srv, "/api/admin/cleanup", "")
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "ADMIN_REQUIRED") {
+		t.Errorf("restricted session: expected %d ADMIN_REQUIRED, got %d: %s", http.StatusForbidden, w.Code, w.Body.String())
+	}
+	var n int
+	if err := srv.store.DB().QueryRowContext(ctx, "SELECT COUNT(*) FROM requests").Scan(&n); err != nil {
+		t.Fatalf("count requests: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("expected the old request to survive, got %d rows", n)
+	}
+}
+
+func TestAPICleanup_MethodNotAllowed(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/cleanup", nil)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status %d, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+}
+
+func TestAPIReimport_LiftsQuarantine(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	if err := srv.store.QuarantineFile(ctx, "/var/log/caddy/bad.log", "not JSON"); err != nil {
+		t.Fatalf("QuarantineFile() error = %v", err)
+	}
+
+	csrfReq := httptest.NewRequest(http.MethodGet, "/api/auth/check", nil)
+	csrfW := httptest.NewRecorder()
+	srv.ServeHTTP(csrfW, csrfReq)
+
+	var csrfToken string
+	for _, cookie := range csrfW.Result().Cookies() {
+		if cookie.Name == csrfCookieName {
+			csrfToken = cookie.Value
+			break
+		}
+	}
+
+	body := strings.NewReader(`{"file_path": "/var/log/caddy/bad.log"}`)
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/reimport", body)
+	req.Header.Set(csrfHeaderName, csrfToken)
+	req.AddCookie(&http.Cookie{Name: csrfCookieName, Value: csrfToken})
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var resp struct {
+		Released bool `json:"released"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !resp.Released {
+		t.Error("expected released = true")
+	}
+	if q, err := srv.store.IsQuarantined(ctx, "/var/log/caddy/bad.log"); err != nil || q {
+		t.Errorf("IsQuarantined() = %v, %v; want false", q, err)
+	}
+}
+
+func TestAPIErrorIPs(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	for i := 0; i < 5; i++ {
+		rec := storage.RequestRecord{Timestamp: now, Host: "example.com", Path: "/.env", Status: 404, IP: "6.6.6.6"}
+		if err := srv.store.InsertRequest(ctx, rec); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+	rec := storage.RequestRecord{Timestamp: now, Host: "example.com", Path: "/gone", Status: 404, IP: "1.1.1.1"}
+	if err := srv.store.InsertRequest(ctx, rec); err != nil {
+		t.Fatalf("InsertRequest() error = %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/stats/security/error-ips?range=1h", nil)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var stats []storage.ErrorIPStat
+	if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(stats) != 2 {
+		t.Fatalf("expected 2 IPs, got %d", len(stats))
+	}
+	if stats[0].IP != "6.6.6.6" || stats[0].Errors != 5 {
+		t.Errorf("top entry = %s with %d errors, want 6.6.6.6 with 5", stats[0].IP, stats[0].Errors)
+	}
+}
+
+func TestAPIScans_DefaultPatterns(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	for _, ip := range []string{"6.6.6.1", "6.6.6.2"} {
+		rec := storage.RequestRecord{Timestamp: now, Host: "example.com", Path: "/.env", Status: 404, IP: ip}
+		if err := srv.store.InsertRequest(ctx, rec); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/stats/security/scans?range=1h", nil)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var stats []storage.ScanPathStat
+	if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(stats) != 1 || stats[0].Path != "/.env" || stats[0].DistinctIPs != 2 {
+		t.Errorf("expected /.env probed by 2 IPs, got %+v", stats)
+	}
+}
+
+func TestAPIPeak(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+	for i := 0; i < 4; i++ {
+		rec := storage.RequestRecord{Timestamp: now, Host: "example.com", Path: "/", Status: 200}
+		if err := srv.store.InsertRequest(ctx, rec); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/stats/peak?range=1h", nil)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var stat storage.PeakTrafficStat
+	if err := json.NewDecoder(w.Body).Decode(&stat); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if stat.PeakRequests != 4 || stat.BucketSeconds != 1 {
+		t.Errorf("expected peak of 4 in a 1s bucket, got %+v", stat)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/stats/peak?bucket=week", nil)
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for invalid bucket, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestAPIWeekly(t *testing.T) {
+	srv, cleanup := setupTestServerWithData(t)
+	defer cleanup()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/stats/weekly?weeks=4", nil)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	var resp storage.WeeklyHistory
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp.Weeks) != 4 {
+		t.Errorf("expected 4 weeks, got %d", len(resp.Weeks))
+	}
+	if resp.Totals.Hits == 0 {
+		t.Error("expected sample data to be counted in the current week")
+	}
+}
+
+func TestAPIMethods(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	for _, m := range []string{"GET", "POST", "GET"} {
+		rec := storage.RequestRecord{Timestamp: now, Host: "example.com", Path: "/", Status: 200, Method: m}
+		if err := srv.store.InsertRequest(ctx, rec); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/stats/methods?range=1h", nil)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var resp []storage.MethodStat
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp) != 2 || resp[0].Method != "GET" || resp[0].Hits != 2 || resp[1].Method != "POST" {
+		t.Errorf("unexpected methods response: %+v", resp)
+	}
+}
+
+func TestAPIProtocols(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	rec := storage.RequestRecord{Timestamp: time.Now().UTC(), Host: "example.com", Path: "/", Status: 200, Protocol: "HTTP/2.0", TLSVersion: "TLS 1.3"}
+	if err := srv.store.InsertRequest(ctx, rec); err != nil {
+		t.Fatalf("InsertRequest() error = %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/stats/protocols?range=1h", nil)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var resp storage.ProtocolStats
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp.Protocols) != 1 || resp.Protocols[0].Name != "HTTP/2.0" || len(resp.TLSVersions) != 1 || resp.TLSVersions[0].Name != "TLS 1.3" {
+		t.Errorf("unexpected protocols response: %+v", resp)
+	}
+}
+
+func TestAPICache(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	for _, cs := range []string{"HIT", "MISS"} {
+		rec := storage.RequestRecord{Timestamp: time.Now().UTC(), Host: "example.com", Path: "/", Status: 200, CacheStatus: cs}
+		if err := srv.store.InsertRequest(ctx, rec); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/stats/cache?range=1h", nil)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var resp storage.CacheStats
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Hits != 1 || resp.Misses != 1 || resp.HitRatio != 0.5 {
+		t.Errorf("unexpected cache response: %+v", resp)
+	}
+}
+
+func TestAPIPathGroups(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	for _, p := range []string{"/blog/a", "/blog/b", "/about"} {
+		rec := storage.RequestRecord{Timestamp: time.Now().UTC(), Host: "example.com", Path: p, Status: 200}
+		if err := srv.store.InsertRequest(ctx, rec); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/stats/path-groups?range=1h&depth=1", nil)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var resp []storage.PathGroupStat
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp) != 2 || resp[0].Group != "/blog" || resp[0].Hits != 2 {
+		t.Errorf("unexpected path groups response: %+v", resp)
+	}
+}
+
+func TestAPIDownloads(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	for _, b := range []int64{500, 50 << 20, 800} {
+		rec := storage.RequestRecord{Timestamp: time.Now().UTC(), Host: "example.com", Path: "/file-" + strconv.FormatInt(b, 10), Status: 200, Bytes: b}
+		if err := srv.store.InsertRequest(ctx, rec); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/stats/downloads?range=1h&limit=2", nil)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var resp []storage.DownloadStat
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp) != 2 || resp[0].MaxBytes != 50<<20 || resp[1].MaxBytes != 800 {
+		t.Errorf("unexpected downloads response: %+v", resp)
+	}
+}
+
+func TestAPIBandwidthCountries(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	for _, rec := range []storage.RequestRecord{
+		{Timestamp: time.Now().UTC(), Host: "example.com", Path: "/", Status: 200, Bytes: 300, Country: "US"},
+		{Timestamp: time.Now().UTC(), Host: "example.com", Path: "/", Status: 200, Bytes: 700, Country: "GB"},
+	} {
+		if err := srv.store.InsertRequest(ctx, rec); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/stats/bandwidth/countries?range=1h", nil)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var resp []storage.CountryBandwidth
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp) != 2 || resp[0].Country != "GB" || resp[0].Bytes != 700 {
+		t.Errorf("unexpected countries response: %+v", resp)
+	}
+}
+
+func TestAPIZeroBytes(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	rec := storage.RequestRecord{Timestamp: time.Now().UTC(), Host: "example.com", Path: "/broken", Status: 200}
+	if err := srv.store.InsertRequest(context.Background(), rec); err != nil {
+		t.Fatalf("InsertRequest() error = %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/stats/zero-bytes?range=1h", nil)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var resp []storage.ZeroByteStat
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp) != 1 || resp[0].Path != "/broken" || resp[0].Count != 1 {
+		t.Errorf("unexpected zero-bytes response: %+v", resp)
+	}
+}
+
+func TestAPIConfiguredDefaultRange(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	for _, age := range []time.Duration{10 * time.Minute, 3 * time.Hour} {
+		rec := storage.RequestRecord{Timestamp: now.Add(-age), Host: "example.com", Path: "/", Status: 200}
+		if err := srv.store.InsertRequest(ctx, rec); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	totalFor := func(url string) int64 {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s: status %d: %s", url, w.Code, w.Body.String())
+		}
+		var summary storage.Summary
+		if err := json.NewDecoder(w.Body).Decode(&summary); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return summary.TotalRequests
+	}
+
+	// Built-in default is 24h
+	if got := totalFor("/api/stats/summary"); got != 2 {
+		t.Errorf("default range total = %d, want 2", got)
+	}
+
+	srv.cfg.DefaultRange = time.Hour
+	if got := totalFor("/api/stats/summary"); got != 1 {
+		t.Errorf("configured 1h default range total = %d, want 1", got)
+	}
+	// An explicit range still wins
+	if got := totalFor("/api/stats/summary?range=24h"); got != 2 {
+		t.Errorf("explicit range total = %d, want 2", got)
+	}
+}
+
+func TestAPIConfiguredDefaultRecentLimit(t *testing.T) {
+	srv, cleanup := setupTestServerWithData(t)
+	defer cleanup()
+	srv.cfg.DefaultRecentLimit = 1
+
+	req := httptest.NewRequest(http.MethodGet, "/api/stats/recent", nil)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	var resp []storage.RecentRequest
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp) != 1 {
+		t.Errorf("expected 1 recent request with DefaultRecentLimit=1, got %d", len(resp))
+	}
+}
+
+func TestAPIRecentRequests_Cursor(t *testing.T) {
+	srv, cleanup := setupTestServerWithData(t)
+	defer cleanup()
+
+	get := func(url string) ([]storage.RecentRequest, string) {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s status = %d, want %d", url, w.Code, http.StatusOK)
+		}
+		var resp []storage.RecentRequest
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return resp, w.Header().Get("X-Next-Before-Id")
+	}
+
+	first, next := get("/api/stats/recent?limit=1")
+	if len(first) != 1 || next != strconv.FormatInt(first[0].ID, 10) {
+		t.Fatalf("first page = %d rows, cursor %q", len(first), next)
+	}
+	second, _ := get("/api/stats/recent?limit=1&before_id=" + next)
+	if len(second) != 1 || !second[0].Timestamp.Before(first[0].Timestamp) {
+		t.Errorf("second page should hold an older row, got %+v", second)
+	}
+
+	all, cursor := get("/api/stats/recent?limit=100")
+	if cursor != "" {
+		t.Errorf("partial page should not set a cursor, got %q (%d rows)", cursor, len(all))
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/stats/recent?before_id=abc", nil)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("invalid before_id status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestAPIRecentRequests_StatusFilter(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	for _, status := range []int{200, 500, 404, 503} {
+		rec := storage.RequestRecord{Timestamp: time.Now().UTC(), Host: "example.com", Path: "/", Status: status, IP: "10.0.0.1"}
+		if err := srv.store.InsertRequest(ctx, rec); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/stats/recent?status=5xx&host=example.com", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var resp []storage.RecentRequest
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp) != 2 {
+		t.Fatalf("expected the 2 server errors, got %d rows", len(resp))
+	}
+	for _, r := range resp {
+		if r.Status < 500 {
+			t.Errorf("status=5xx returned a %d", r.Status)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/stats/recent?path="+strings.Repeat("a", storage.MaxPathSearchLength+1), nil))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "INVALID_PATH") {
+		t.Errorf("long path: got %d %s, want 400 INVALID_PATH", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/stats/recent?status=9xx", nil))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "INVALID_STATUS") {
+		t.Errorf("invalid status: got %d %s, want 400 INVALID_STATUS", w.Code, w.Body.String())
+	}
+}
+
+func TestAPIRealtime(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	rec := storage.RequestRecord{Timestamp: time.Now().UTC(), Host: "example.com", Path: "/", Status: 200, IP: "10.0.0.1"}
+	if err := srv.store.InsertRequest(context.Background(), rec); err != nil {
+		t.Fatalf("InsertRequest() error = %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/stats/realtime?minutes=10", nil)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var resp storage.RealtimeStats
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Minutes != 10 || len(resp.PerMinute) != 10 || resp.TotalRequests != 1 || resp.ActiveVisitors != 1 {
+		t.Errorf("unexpected realtime response: %+v", resp)
+	}
+	// The current minute, or the previous one if the clock just ticked over
+	if resp.PerMinute[8].Requests+resp.PerMinute[9].Requests != 1 {
+		t.Errorf("expected the request in the latest minute, got %+v", resp.PerMinute)
+	}
+}
+
+func TestAPIOnline(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	for i, ip := range []string{"10.0.0.1", "10.0.0.2"} {
+		rec := storage.RequestRecord{Timestamp: now.Add(-time.Duration(i*4) * time.Minute), Host: "example.com", Path: "/", Status: 200, IP: ip}
+		if err := srv.store.InsertRequest(ctx, rec); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	for _, tt := range []struct {
+		url        string
+		want       int64
+		wantWindow int64
+	}{
+		{"/api/stats/online", 2, 300},
+		{"/api/stats/online?window=2m", 1, 120},
+		{"/api/stats/online?window=bogus", 2, 300},
+	} {
+		req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s: status %d: %s", tt.url, w.Code, w.Body.String())
+		}
+		var resp storage.OnlineStats
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if resp.ActiveVisitors != tt.want || resp.WindowSeconds != tt.wantWindow {
+			t.Errorf("GET %s = %+v, want %d visitors over %ds", tt.url, resp, tt.want, tt.wantWindow)
+		}
+	}
+}
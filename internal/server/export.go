@@ -0,0 +1,295 @@
+package server
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dustin/Caddystat/internal/storage"
+)
+
+// csvDelimiters maps the export "delimiter" parameter to a field separator.
+// Semicolons suit spreadsheet locales that use a decimal comma.
+var csvDelimiters = map[string]rune{
+	"":          ',',
+	"comma":     ',',
+	"semicolon": ';',
+	"tab":       '\t',
+}
+
+// utf8BOM makes Excel open a CSV export as UTF-8 instead of the system code page.
+const utf8BOM = "\xef\xbb\xbf"
+
+// csvOptions reads the "delimiter" and "bom" export parameters. ok is false
+// for an unknown delimiter.
+func csvOptions(q url.Values) (delim rune, bom bool, ok bool) {
+	delim, ok = csvDelimiters[q.Get("delimiter")]
+	return delim, q.Get("bom") == "true", ok
+}
+
+// exportColumn is one field of a request export. name is both the CSV
+// header and the JSON key, matching storage.ExportRequest's tags.
+type exportColumn struct {
+	name  string
+	value func(r storage.ExportRequest) any
+}
+
+// exportColumns lists every exported field in output order.
+var exportColumns = []exportColumn{
+	{"id", func(r storage.ExportRequest) any { return r.ID }},
+	{"timestamp", func(r storage.ExportRequest) any { return r.Timestamp }},
+	{"host", func(r storage.ExportRequest) any { return r.Host }},
+	{"path", func(r storage.ExportRequest) any { return r.Path }},
+	{"status", func(r storage.ExportRequest) any { return r.Status }},
+	{"bytes", func(r storage.ExportRequest) any { return r.Bytes }},
+	{"ip", func(r storage.ExportRequest) any { return r.IP }},
+	{"referrer", func(r storage.ExportRequest) any { return r.Referrer }},
+	{"user_agent", func(r storage.ExportRequest) any { return r.UserAgent }},
+	{"response_time_ms", func(r storage.ExportRequest) any { return r.ResponseTimeMs }},
+	{"country", func(r storage.ExportRequest) any { return r.Country }},
+	{"region", func(r storage.ExportRequest) any { return r.Region }},
+	{"city", func(r storage.ExportRequest) any { return r.City }},
+	{"browser", func(r storage.ExportRequest) any { return r.Browser }},
+	{"browser_version", func(r storage.ExportRequest) any { return r.BrowserVersion }},
+	{"os", func(r storage.ExportRequest) any { return r.OS }},
+	{"os_version", func(r storage.ExportRequest) any { return r.OSVersion }},
+	{"device_type", func(r storage.ExportRequest) any { return r.DeviceType }},
+	{"is_bot", func(r storage.ExportRequest) any { return r.IsBot }},
+	{"bot_name", func(r storage.ExportRequest) any { return r.BotName }},
+}
+
+// exportFields resolves the comma-separated "fields" parameter to columns,
+// in the order given. An empty parameter selects every column.
+func exportFields(param string) ([]exportColumn, error) {
+	if strings.TrimSpace(param) == "" {
+		return exportColumns, nil
+	}
+	var cols []exportColumn
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(param, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		col, ok := findExportColumn(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown field %q", name)
+		}
+		seen[name] = true
+		cols = append(cols, col)
+	}
+	return cols, nil
+}
+
+func findExportColumn(name string) (exportColumn, bool) {
+	for _, col := range exportColumns {
+		if col.name == name {
+			return col, true
+		}
+	}
+	return exportColumn{}, false
+}
+
+// exportHeader returns the CSV header row for cols.
+func exportHeader(cols []exportColumn) []string {
+	header := make([]string, len(cols))
+	for i, col := range cols {
+		header[i] = col.name
+	}
+	return header
+}
+
+// exportCSVRecord formats r as a CSV row of cols.
+func exportCSVRecord(cols []exportColumn, r storage.ExportRequest) []string {
+	record := make([]string, len(cols))
+	for i, col := range cols {
+		switch v := col.value(r).(type) {
+		case string:
+			record[i] = v
+		case int:
+			record[i] = strconv.Itoa(v)
+		case int64:
+			record[i] = strconv.FormatInt(v, 10)
+		case float64:
+			record[i] = strconv.FormatFloat(v, 'f', 2, 64)
+		case bool:
+			record[i] = strconv.FormatBool(v)
+		case time.Time:
+			record[i] = v.Format(time.RFC3339)
+		}
+	}
+	return record
+}
+
+// appendExportJSON appends r as a JSON object holding cols, in order.
+func appendExportJSON(buf []byte, cols []exportColumn, r storage.ExportRequest) ([]byte, error) {
+	buf = append(buf, '{')
+	for i, col := range cols {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = strconv.AppendQuote(buf, col.name)
+		buf = append(buf, ':')
+		val, err := json.Marshal(col.value(r))
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, val...)
+	}
+	return append(buf, '}'), nil
+}
+
+// handleExportArchive streams a ZIP holding one CSV per UTC day in the
+// range, named caddystat-YYYY-MM-DD.csv. It accepts the same parameters as
+// the CSV export. Days without requests get a header-only file, so there is
+// always one entry per day.
+func (s *Server) handleExportArchive(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	dur := parseRange(q.Get("range"), s.cfg.DefaultRange)
+	host := q.Get("host")
+	delim, bom, ok := csvOptions(q)
+	if !ok {
+		writeErrorWithCode(w, http.StatusBadRequest, "delimiter must be comma, semicolon or tab", "INVALID_DELIMITER")
+		return
+	}
+	cols, err := exportFields(q.Get("fields"))
+	if err != nil {
+		writeErrorWithCode(w, http.StatusBadRequest, err.Error(), "INVALID_FIELDS")
+		return
+	}
+
+	now := time.Now().UTC()
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=caddystat-export-%s.zip", now.Format("2006-01-02")))
+
+	archive := &csvArchive{
+		zip:   zip.NewWriter(w),
+		cols:  cols,
+		delim: delim,
+		bom:   bom,
+		next:  utcDay(now.Add(-dur)),
+	}
+	err = s.store.ExportRequests(r.Context(), dur, host, 1000, func(requests []storage.ExportRequest) error {
+		for _, req := range requests {
+			if err := archive.write(req); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		err = archive.close(utcDay(now))
+	}
+	if err != nil {
+		// Leave the ZIP without its central directory so the client
+		// sees a broken download rather than silently missing days
+		slog.Warn("failed to export archive", "error", err)
+	}
+}
+
+// csvArchive writes export rows, in timestamp order, into per-day CSV
+// entries of a ZIP. Only the current entry is buffered.
+type csvArchive struct {
+	zip   *zip.Writer
+	cols  []exportColumn
+	delim rune
+	bom   bool
+	csv   *csv.Writer // Current day's entry; nil before the first
+	next  time.Time   // First day without an entry yet
+}
+
+func utcDay(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
+
+// write adds r to the entry for its day, opening entries up to that day.
+func (a *csvArchive) write(r storage.ExportRequest) error {
+	if err := a.advance(utcDay(r.Timestamp)); err != nil {
+		return err
+	}
+	return a.csv.Write(exportCSVRecord(a.cols, r))
+}
+
+// advance finishes the current entry and opens one per day through day.
+func (a *csvArchive) advance(day time.Time) error {
+	for !a.next.After(day) {
+		if err := a.flush(); err != nil {
+			return err
+		}
+		f, err := a.zip.Create("caddystat-" + a.next.Format("2006-01-02") + ".csv")
+		if err != nil {
+			return err
+		}
+		if a.bom {
+			if _, err := io.WriteString(f, utf8BOM); err != nil {
+				return err
+			}
+		}
+		a.csv = csv.NewWriter(f)
+		a.csv.Comma = a.delim
+		if err := a.csv.Write(exportHeader(a.cols)); err != nil {
+			return err
+		}
+		a.next = a.next.AddDate(0, 0, 1)
+	}
+	return nil
+}
+
+func (a *csvArchive) flush() error {
+	if a.csv == nil {
+		return nil
+	}
+	a.csv.Flush()
+	return a.csv.Error()
+}
+
+// close fills in entries through lastDay and writes the ZIP directory.
+func (a *csvArchive) close(lastDay time.Time) error {
+	if err := a.advance(lastDay); err != nil {
+		return err
+	}
+	if err := a.flush(); err != nil {
+		return err
+	}
+	return a.zip.Close()
+}
+
+// influxTagEscaper escapes the characters InfluxDB line protocol reserves
+// in tag values.
+var influxTagEscaper = strings.NewReplacer(",", "\\,", "=", "\\=", " ", "\\ ")
+
+// handleExportInflux writes the hourly time series in InfluxDB line
+// protocol, one "caddystat" point per hour with a nanosecond timestamp.
+// Points carry a host tag when the export is filtered to one host.
+func (s *Server) handleExportInflux(w http.ResponseWriter, r *http.Request) {
+	dur := parseRange(r.URL.Query().Get("range"), s.cfg.DefaultRange)
+	host := r.URL.Query().Get("host")
+	series, err := s.store.TimeSeriesRange(r.Context(), dur, host, storage.BucketHour)
+	if err != nil {
+		writeInternalError(w, r, err, "get time series for influx export")
+		return
+	}
+
+	measurement := "caddystat"
+	if host != "" {
+		measurement += ",host=" + influxTagEscaper.Replace(host)
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	var buf []byte
+	for _, p := range series {
+		buf = fmt.Appendf(buf[:0], "%s requests=%d,bytes=%d,status_2xx=%d,status_4xx=%d,status_5xx=%d,avg_latency_ms=%s %d\n",
+			measurement, p.Requests, p.Bytes, p.Status2xx, p.Status4xx, p.Status5xx,
+			strconv.FormatFloat(float64(p.AvgLatency), 'f', -1, 64), p.Bucket.UnixNano())
+		if _, err := w.Write(buf); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dustin/Caddystat/internal/storage"
+)
+
+// maxIngestBatch caps the number of events accepted in one push request.
+const maxIngestBatch = 1000
+
+// RecordIngester stores pushed request events and reports ingest backlog;
+// implemented by *ingest.Ingestor.
+type RecordIngester interface {
+	IngestRecords(ctx context.Context, events []storage.RequestRecord) (int, error)
+	QueueDepth() int64
+}
+
+// SetIngester enables POST /api/ingest, which also requires INGEST_API_KEY.
+func (s *Server) SetIngester(i RecordIngester) {
+	s.ingester = i
+}
+
+// ingestEvent is one pushed request. Field names follow RecentRequest;
+// derived fields (geo, browser, OS, bot) are computed server-side.
+type ingestEvent struct {
+	Timestamp    time.Time `json:"timestamp"`
+	Method       string    `json:"method"`
+	Host         string    `json:"host"`
+	Path         string    `json:"path"`
+	Status       int       `json:"status"`
+	Bytes        int64     `json:"bytes"`
+	IP           string    `json:"ip"`
+	Referrer     string    `json:"referrer"`
+	UserAgent    string    `json:"user_agent"`
+	ResponseTime float64   `json:"response_time_ms"`
+	ContentType  string    `json:"content_type"`
+	Protocol     string    `json:"protocol"`
+	TLSVersion   string    `json:"tls_version"`
+	CacheStatus  string    `json:"cache_status"`
+	Language     string    `json:"language"` // Accept-Language header or a single tag
+}
+
+// ingestAPIKey extracts the key from "Authorization: Bearer <key>" or
+// "X-API-Key: <key>".
+func ingestAPIKey(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		if key, ok := strings.CutPrefix(auth, "Bearer "); ok {
+			return strings.TrimSpace(key)
+		}
+	}
+	return r.Header.Get("X-API-Key")
+}
+
+func (s *Server) handleIngest(w http.ResponseWriter, r *http.Request) {
+	if s.cfg.IngestAPIKey == "" || s.ingester == nil {
+		writeErrorWithCode(w, http.StatusNotFound, "push ingest is disabled", "INGEST_DISABLED")
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeErrorWithCode(w, http.StatusMethodNotAllowed, "method not allowed", "METHOD_NOT_ALLOWED")
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(ingestAPIKey(r)), []byte(s.cfg.IngestAPIKey)) != 1 {
+		writeErrorWithCode(w, http.StatusUnauthorized, "invalid API key", "INVALID_API_KEY")
+		return
+	}
+	if !s.ingestLimit.Allow(extractIP(r)) {
+		writeErrorWithCode(w, http.StatusTooManyRequests, "ingest rate limit exceeded", "RATE_LIMITED")
+		return
+	}
+
+	var events []ingestEvent
+	if err := json.NewDecoder(r.Body).Decode(&events); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			writeErrorWithCode(w, http.StatusRequestEntityTooLarge, "request body too large", "REQUEST_TOO_LARGE")
+			return
+		}
+		writeErrorWithCode(w, http.StatusBadRequest, "body must be a JSON array of request events", "INVALID_JSON")
+		return
+	}
+	if len(events) > maxIngestBatch {
+		writeErrorWithCode(w, http.StatusRequestEntityTooLarge, "too many events in one batch", "BATCH_TOO_LARGE")
+		return
+	}
+
+	records := make([]storage.RequestRecord, 0, len(events))
+	for _, ev := range events {
+		if ev.Host == "" || ev.Path == "" || ev.Status < 100 || ev.Status > 599 {
+			writeErrorWithCode(w, http.StatusBadRequest, "each event needs host, path and a valid status", "INVALID_EVENT")
+			return
+		}
+		records = append(records, storage.RequestRecord{
+			Timestamp:    ev.Timestamp,
+			Method:       ev.Method,
+			Host:         ev.Host,
+			Path:         ev.Path,
+			Status:       ev.Status,
+			Bytes:        ev.Bytes,
+			IP:           ev.IP,
+			Referrer:     ev.Referrer,
+			UserAgent:    ev.UserAgent,
+			ResponseTime: ev.ResponseTime,
+			ContentType:  ev.ContentType,
+			Protocol:     ev.Protocol,
+			TLSVersion:   ev.TLSVersion,
+			CacheStatus:  ev.CacheStatus,
+			Language:     ev.Language,
+		})
+	}
+
+	stored, err := s.ingester.IngestRecords(r.Context(), records)
+	if err != nil {
+		writeInternalError(w, r, err, "ingest pushed events")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	writeJSON(w, map[string]int{
+		"received": len(records),
+		"stored":   stored, // excluded events are not stored
+	})
+}
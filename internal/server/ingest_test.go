@@ -0,0 +1,201 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dustin/Caddystat/internal/config"
+	"github.com/dustin/Caddystat/internal/ingest"
+)
+
+const testIngestKey = "push-secret"
+
+func setupIngestServer(t *testing.T) (*Server, func()) {
+	t.Helper()
+	srv, cleanup := setupTestServer(t)
+	srv.cfg.IngestAPIKey = testIngestKey
+	srv.SetIngester(ingest.New(config.Config{}, srv.store, nil, nil, nil))
+	return srv, cleanup
+}
+
+func postIngest(srv *Server, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/ingest", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	return w
+}
+
+func TestIngest_StoresEnrichedRecords(t *testing.T) {
+	srv, cleanup := setupIngestServer(t)
+	defer cleanup()
+
+	ts := time.Now().UTC().Add(-time.Minute).Format(time.RFC3339)
+	body := `[
+		{"timestamp": "` + ts + `", "host": "push.example.com", "path": "/a", "status": 200, "bytes": 512, "ip": "203.0.113.9",
+		 "user_agent": "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"},
+		{"host": "push.example.com", "path": "/b", "status": 404, "ip": "203.0.113.9", "user_agent": "Googlebot/2.1 (+http://www.google.com/bot.html)"}
+	]`
+	w := postIngest(srv, testIngestKey, body)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusAccepted, w.Code, w.Body.String())
+	}
+	var resp map[string]int
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp["received"] != 2 || resp["stored"] != 2 {
+		t.Errorf("response = %v, want 2 received and stored", resp)
+	}
+
+	recent, err := srv.store.RecentRequests(context.Background(), 10, "push.example.com")
+	if err != nil {
+		t.Fatalf("RecentRequests() error = %v", err)
+	}
+	if len(recent) != 2 {
+		t.Fatalf("expected 2 stored requests, got %d", len(recent))
+	}
+	var human, bot bool
+	for _, r := range recent {
+		switch r.Path {
+		case "/a":
+			human = true
+			if r.Browser != "Chrome" || r.OS != "Windows" {
+				t.Errorf("expected Chrome on Windows, got %q on %q", r.Browser, r.OS)
+			}
+			if r.Bytes != 512 {
+				t.Errorf("bytes = %d, want 512", r.Bytes)
+			}
+		case "/b":
+			bot = true
+			if !r.IsBot {
+				t.Error("expected Googlebot to be classified as a bot")
+			}
+			if r.Timestamp.IsZero() {
+				t.Error("expected missing timestamp to default to now")
+			}
+		}
+	}
+	if !human || !bot {
+		t.Errorf("missing stored paths: %+v", recent)
+	}
+}
+
+func TestIngest_NormalizesTimestampToUTC(t *testing.T) {
+	srv, cleanup := setupIngestServer(t)
+	defer cleanup()
+
+	at := time.Now().UTC().Add(-time.Minute).Truncate(time.Second)
+	ts := at.In(time.FixedZone("", 5*60*60)).Format(time.RFC3339)
+	body := `[{"timestamp": "` + ts + `", "host": "push.example.com", "path": "/tz", "status": 200, "ip": "203.0.113.9"}]`
+	if w := postIngest(srv, testIngestKey, body); w.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusAccepted, w.Code, w.Body.String())
+	}
+
+	// Timestamps compare as text, so a +05:00 value would fall outside a
+	// UTC range that contains the instant
+	var n int
+	err := srv.store.DB().QueryRowContext(context.Background(),
+		"SELECT COUNT(*) FROM requests WHERE ts >= ? AND ts < ?", at, at.Add(time.Second)).Scan(&n)
+	if err != nil {
+		t.Fatalf("count requests: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("requests in UTC range = %d, want 1", n)
+	}
+}
+
+func TestIngest_Auth(t *testing.T) {
+	srv, cleanup := setupIngestServer(t)
+	defer cleanup()
+
+	body := `[{"host": "example.com", "path": "/", "status": 200}]`
+	if w := postIngest(srv, "", body); w.Code != http.StatusUnauthorized {
+		t.Errorf("missing key: expected %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+	if w := postIngest(srv, "wrong", body); w.Code != http.StatusUnauthorized {
+		t.Errorf("wrong key: expected %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/ingest", strings.NewReader(body))
+	req.Header.Set("X-API-Key", testIngestKey)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Errorf("X-API-Key: expected %d, got %d", http.StatusAccepted, w.Code)
+	}
+}
+
+func TestIngest_DisabledWithoutKey(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+	srv.SetIngester(ingest.New(config.Config{}, srv.store, nil, nil, nil))
+
+	if w := postIngest(srv, "anything", `[]`); w.Code != http.StatusNotFound {
+		t.Errorf("expected %d when INGEST_API_KEY is unset, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestIngest_Validation(t *testing.T) {
+	srv, cleanup := setupIngestServer(t)
+	defer cleanup()
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"not an array", `{"host": "example.com"}`, http.StatusBadRequest},
+		{"missing host", `[{"path": "/", "status": 200}]`, http.StatusBadRequest},
+		{"bad status", `[{"host": "example.com", "path": "/", "status": 42}]`, http.StatusBadRequest},
+		{"too many events", "[" + strings.Repeat(`{},`, maxIngestBatch) + "{}]", http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := postIngest(srv, testIngestKey, tt.body); w.Code != tt.want {
+				t.Errorf("expected %d, got %d: %s", tt.want, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestIngest_RateLimited(t *testing.T) {
+	srv, cleanup := setupIngestServer(t)
+	defer cleanup()
+	srv.ingestLimit = NewRateLimiter(1, time.Minute)
+
+	body := `[{"host": "example.com", "path": "/", "status": 200}]`
+	if w := postIngest(srv, testIngestKey, body); w.Code != http.StatusAccepted {
+		t.Fatalf("first batch: expected %d, got %d", http.StatusAccepted, w.Code)
+	}
+	if w := postIngest(srv, testIngestKey, body); w.Code != http.StatusTooManyRequests {
+		t.Errorf("second batch: expected %d, got %d", http.StatusTooManyRequests, w.Code)
+	}
+}
+
+func TestStatus_IngestQueueDepth(t *testing.T) {
+	srv, cleanup := setupIngestServer(t)
+	defer cleanup()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/stats/status", nil)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	var resp map[string]any
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if depth, ok := resp["ingest_queue_depth"]; !ok || depth != float64(0) {
+		t.Errorf("ingest_queue_depth = %v (present %v), want 0", depth, ok)
+	}
+}
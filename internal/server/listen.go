@@ -0,0 +1,44 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+
+	"github.com/dustin/Caddystat/internal/config"
+)
+
+// NewHTTPServer returns the http.Server that serves handler on
+// cfg.ListenAddr. When TLS is configured it loads the certificate and key
+// and negotiates HTTP/2, falling back to HTTP/1.1, over TLS.
+func NewHTTPServer(cfg config.Config, handler http.Handler) (*http.Server, error) {
+	srv := &http.Server{
+		Addr:    cfg.ListenAddr,
+		Handler: handler,
+	}
+	if !cfg.TLSEnabled() {
+		return srv, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load TLS key pair: %w", err)
+	}
+	srv.TLSConfig = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	srv.Protocols = new(http.Protocols)
+	srv.Protocols.SetHTTP1(true)
+	srv.Protocols.SetHTTP2(true)
+	return srv, nil
+}
+
+// ListenAndServe serves srv over TLS when NewHTTPServer gave it a
+// certificate, and over plain HTTP otherwise.
+func ListenAndServe(srv *http.Server) error {
+	if srv.TLSConfig != nil {
+		return srv.ListenAndServeTLS("", "")
+	}
+	return srv.ListenAndServe()
+}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dustin/Caddystat/internal/config"
+)
+
+// writeTestCert writes a self-signed certificate for 127.0.0.1 and its key
+// to dir and returns their paths.
+func writeTestCert(t *testing.T, dir string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "caddystat test"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644); err != nil {
+		t.Fatalf("write cert: %v", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+	return certFile, keyFile
+}
+
+func TestNewHTTPServer_PlainByDefault(t *testing.T) {
+	srv, err := NewHTTPServer(config.Config{ListenAddr: ":0"}, http.NotFoundHandler())
+	if err != nil {
+		t.Fatalf("NewHTTPServer() error = %v", err)
+	}
+	if srv.TLSConfig != nil {
+		t.Error("expected no TLS config without TLS_CERT_FILE and TLS_KEY_FILE")
+	}
+}
+
+func TestNewHTTPServer_ServesTLSAndHTTP2(t *testing.T) {
+	base, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	certFile, keyFile := writeTestCert(t, t.TempDir())
+	cfg := config.Config{ListenAddr: "127.0.0.1:0", TLSCertFile: certFile, TLSKeyFile: keyFile}
+	srv, err := NewHTTPServer(cfg, base)
+	if err != nil {
+		t.Fatalf("NewHTTPServer() error = %v", err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go srv.ServeTLS(ln, "", "")
+	defer srv.Close()
+
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		ForceAttemptHTTP2: true,
+	}}
+	resp, err := client.Get("https://" + ln.Addr().String() + "/health")
+	if err != nil {
+		t.Fatalf("GET /health over TLS: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	if resp.TLS == nil {
+		t.Error("expected the response to arrive over TLS")
+	}
+	if resp.ProtoMajor != 2 {
+		t.Errorf("Proto = %s, want HTTP/2", resp.Proto)
+	}
+}
+
+func TestNewHTTPServer_BadKeyPair(t *testing.T) {
+	dir := t.TempDir()
+	certFile, _ := writeTestCert(t, dir)
+	cfg := config.Config{TLSCertFile: certFile, TLSKeyFile: filepath.Join(dir, "missing.pem")}
+	if _, err := NewHTTPServer(cfg, http.NotFoundHandler()); err == nil {
+		t.Error("expected an error for a missing key file")
+	}
+}
@@ -0,0 +1,243 @@
+package server
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/dustin/Caddystat/internal/storage"
+	"github.com/dustin/Caddystat/internal/version"
+)
+
+// openAPIParam describes a query parameter of a documented endpoint.
+type openAPIParam struct {
+	Name        string
+	Type        string // OpenAPI primitive type
+	Description string
+	Default     any
+	Enum        []string
+	Required    bool
+}
+
+// openAPIEndpoint describes a GET endpoint. Response is a zero value of the
+// type the handler encodes; its schema is derived from the JSON tags.
+type openAPIEndpoint struct {
+	Path     string
+	Summary  string
+	Params   []openAPIParam
+	Response any
+}
+
+var (
+	rangeParam    = openAPIParam{Name: "range", Type: "string", Description: "Time range as a Go duration (e.g. 1h, 24h, 168h)", Default: "24h"}
+	hostParam     = openAPIParam{Name: "host", Type: "string", Description: "Restrict results to one site; must be permitted for the session"}
+	countryParam  = openAPIParam{Name: "country", Type: "string", Description: "Restrict results to a two-letter country code (e.g. DE)"}
+	internalParam = openAPIParam{Name: "exclude_internal", Type: "boolean", Description: "Drop requests from INTERNAL_CIDRS", Default: false}
+)
+
+func limitParam(def int) openAPIParam {
+	return openAPIParam{Name: "limit", Type: "integer", Description: "Maximum number of rows", Default: def}
+}
+
+// systemStatusResponse is the body written by handleStatus.
+type systemStatusResponse struct {
+	storage.SystemStatus
+	LogLevel         string `json:"log_level"`
+	IngestQueueDepth int64  `json:"ingest_queue_depth"` // Records read but not yet stored
+	// PollIntervalSeconds repeats the X-Poll-Interval header
+	PollIntervalSeconds int `json:"poll_interval_seconds"`
+}
+
+// distinctPathsResponse is the body of /api/stats/distinct-paths.
+type distinctPathsResponse struct {
+	DistinctPaths int64 `json:"distinct_paths"` // Pages with at least one request
+}
+
+// openAPIEndpoints lists the documented read-only stats endpoints. Keep in
+// sync with routes() when adding /api/stats endpoints.
+var openAPIEndpoints = []openAPIEndpoint{
+	{Path: "/api/stats/summary", Summary: "Aggregated totals, top paths, hosts and hourly series", Params: []openAPIParam{rangeParam, hostParam, countryParam, internalParam}, Response: storage.Summary{}},
+	{Path: "/api/stats/requests", Summary: "Request time series", Params: []openAPIParam{rangeParam, hostParam, countryParam, internalParam, {Name: "bucket", Type: "string", Description: "Bucket granularity", Default: "hour", Enum: []string{"minute", "5min", "hour", "day", "auto"}}}, Response: []storage.TimeSeriesStat{}},
+	{Path: "/api/stats/requests/by-ip", Summary: "Chronological requests from one IP", Params: []openAPIParam{{Name: "ip", Type: "string", Description: "Client IP address", Required: true}, rangeParam, hostParam, limitParam(100), {Name: "offset", Type: "integer", Description: "Rows to skip", Default: 0}}, Response: []storage.RecentRequest{}},
+	{Path: "/api/stats/monthly", Summary: "Monthly history with growth", Params: []openAPIParam{hostParam, {Name: "months", Type: "integer", Description: "Number of months", Default: 12}}, Response: storage.MonthlyHistory{}},
+	{Path: "/api/stats/weekly", Summary: "ISO-week history", Params: []openAPIParam{hostParam, {Name: "weeks", Type: "integer", Description: "Number of weeks", Default: 12}}, Response: storage.WeeklyHistory{}},
+	{Path: "/api/stats/daily", Summary: "Daily breakdown of the current month", Params: []openAPIParam{hostParam}, Response: storage.DailyHistory{}},
+	{Path: "/api/stats/geo", Summary: "Requests by location", Params: []openAPIParam{rangeParam, hostParam}, Response: []storage.GeoStat{}},
+	{Path: "/api/stats/known-hosts", Summary: "Hosts with data in the range, limited to the session's sites", Params: []openAPIParam{rangeParam}, Response: []storage.HostStat{}},
+	{Path: "/api/stats/hosts/activity", Summary: "First and last request time per host, limited to the session's sites", Response: []storage.HostActivityStat{}},
+	{Path: "/api/stats/totals", Summary: "Combined requests, unique visitors, bandwidth and error rate across the session's sites", Params: []openAPIParam{rangeParam}, Response: storage.GrandTotals{}},
+	{Path: "/api/stats/hosts", Summary: "Top visitors", Params: []openAPIParam{rangeParam, hostParam, countryParam, internalParam, limitParam(20), {Name: "sort", Type: "string", Description: "Sort order", Default: "hits", Enum: []string{"hits", "bandwidth", "recent"}}}, Response: []storage.VisitorStat{}},
+	{Path: "/api/stats/browsers", Summary: "Browser usage", Params: []openAPIParam{rangeParam, hostParam, limitParam(10)}, Response: []storage.BrowserStat{}},
+	{Path: "/api/stats/devices", Summary: "Device brand and model usage", Params: []openAPIParam{rangeParam, hostParam, limitParam(10)}, Response: []storage.DeviceStat{}},
+	{Path: "/api/stats/languages", Summary: "Visitor languages from the first Accept-Language tag", Params: []openAPIParam{rangeParam, hostParam, limitParam(10)}, Response: []storage.LanguageStat{}},
+	{Path: "/api/stats/os", Summary: "Operating system usage", Params: []openAPIParam{rangeParam, hostParam, limitParam(10)}, Response: []storage.OSStat{}},
+	{Path: "/api/stats/user-agents", Summary: "Most frequent raw User-Agent strings, bots included", Params: []openAPIParam{rangeParam, hostParam, limitParam(20)}, Response: []storage.UserAgentStat{}},
+	{Path: "/api/stats/unclassified", Summary: "Raw User-Agents of non-bot requests with an unknown browser or OS", Params: []openAPIParam{rangeParam, hostParam, limitParam(20)}, Response: []storage.UnclassifiedUserAgent{}},
+	{Path: "/api/stats/methods", Summary: "Requests per HTTP method", Params: []openAPIParam{rangeParam, hostParam}, Response: []storage.MethodStat{}},
+	{Path: "/api/stats/protocols", Summary: "Requests per HTTP protocol and TLS version", Params: []openAPIParam{rangeParam, hostParam}, Response: storage.ProtocolStats{}},
+	{Path: "/api/stats/robots", Summary: "Bot and spider traffic", Params: []openAPIParam{rangeParam, hostParam, limitParam(20)}, Response: []storage.RobotStat{}},
+	{Path: "/api/stats/robots/verification", Summary: "Crawler requests verified by IP vs claimed by user-agent only", Params: []openAPIParam{rangeParam, hostParam}, Response: []storage.BotVerificationStat{}},
+	{Path: "/api/stats/referrers", Summary: "Referrers", Params: []openAPIParam{rangeParam, hostParam, limitParam(20)}, Response: []storage.ReferrerStat{}},
+	{Path: "/api/stats/recent", Summary: "Most recent requests, newest first; full pages set X-Next-Before-Id", Params: []openAPIParam{hostParam, limitParam(20), {Name: "before_id", Type: "integer", Description: "Cursor: id of the last row of the previous page; only older requests are returned"}, {Name: "status", Type: "string", Description: "Status code (404) or class (5xx) to keep"}, {Name: "path", Type: "string", Description: "Case-insensitive path substring to keep (max 256 bytes)"}}, Response: []storage.RecentRequest{}},
+	{Path: "/api/stats/status", Summary: "System status", Response: systemStatusResponse{}},
+	{Path: "/api/stats/performance", Summary: "Response time percentiles and slow pages", Params: []openAPIParam{rangeParam, hostParam}, Response: storage.PerformanceStats{}},
+	{Path: "/api/stats/realtime", Summary: "Per-minute requests and active visitors for the last few minutes", Params: []openAPIParam{hostParam, {Name: "minutes", Type: "integer", Description: "Window length in minutes (max 180)", Default: 30}}, Response: storage.RealtimeStats{}},
+	{Path: "/api/stats/online", Summary: "Distinct non-bot visitors active in the last few minutes", Params: []openAPIParam{hostParam, {Name: "window", Type: "string", Description: "Activity window as a Go duration", Default: "5m"}}, Response: storage.OnlineStats{}},
+	{Path: "/api/stats/peak", Summary: "Busiest second or minute", Params: []openAPIParam{rangeParam, hostParam, {Name: "bucket", Type: "string", Description: "Peak bucket width", Default: "second", Enum: []string{"second", "minute"}}}, Response: storage.PeakTrafficStat{}},
+	{Path: "/api/stats/path-groups", Summary: "Traffic grouped by leading path segments", Params: []openAPIParam{rangeParam, hostParam, limitParam(20), {Name: "depth", Type: "integer", Description: "Number of leading path segments to group by (max 10)", Default: 1}}, Response: []storage.PathGroupStat{}},
+	{Path: "/api/stats/cache", Summary: "Upstream cache hit ratio", Params: []openAPIParam{rangeParam, hostParam}, Response: storage.CacheStats{}},
+	{Path: "/api/stats/bandwidth", Summary: "Bandwidth by host, path and content type", Params: []openAPIParam{rangeParam, hostParam, limitParam(10)}, Response: storage.BandwidthStats{}},
+	{Path: "/api/stats/bandwidth/countries", Summary: "Bandwidth by visitor country", Params: []openAPIParam{rangeParam, hostParam, limitParam(10)}, Response: []storage.CountryBandwidth{}},
+	{Path: "/api/stats/distinct-paths", Summary: "Number of distinct pages that received traffic", Params: []openAPIParam{rangeParam, hostParam}, Response: distinctPathsResponse{}},
+	{Path: "/api/stats/slo", Summary: "Share of good requests and remaining error budget", Params: []openAPIParam{rangeParam, hostParam, {Name: "objective", Type: "number", Description: "Success target in percent (default SLO_OBJECTIVE)"}, {Name: "latency_ms", Type: "integer", Description: "Slower requests count as bad; 0 checks status only (default SLO_LATENCY_TARGET_MS)"}}, Response: storage.SLOStat{}},
+	{Path: "/api/stats/downloads", Summary: "Paths with the largest single responses", Params: []openAPIParam{rangeParam, hostParam, limitParam(10)}, Response: []storage.DownloadStat{}},
+	{Path: "/api/stats/zero-bytes", Summary: "Paths returning 2xx with an empty body", Params: []openAPIParam{rangeParam, hostParam, limitParam(20)}, Response: []storage.ZeroByteStat{}},
+	{Path: "/api/stats/sessions", Summary: "Reconstructed visitor sessions", Params: []openAPIParam{rangeParam, hostParam, limitParam(50), {Name: "timeout", Type: "integer", Description: "Session inactivity timeout in seconds", Default: 1800}}, Response: storage.VisitorSessionSummary{}},
+	{Path: "/api/stats/security/error-ips", Summary: "IPs ranked by error responses", Params: []openAPIParam{rangeParam, hostParam, limitParam(20), {Name: "exclude_bots", Type: "boolean", Description: "Ignore requests classified as bots", Default: false}}, Response: []storage.ErrorIPStat{}},
+	{Path: "/api/stats/security/scans", Summary: "Suspicious 404 paths", Params: []openAPIParam{rangeParam, hostParam, limitParam(20)}, Response: []storage.ScanPathStat{}},
+	{Path: "/api/stats/export-progress", Summary: "Rows written so far by an export started with progress=true", Params: []openAPIParam{{Name: "id", Type: "string", Description: "Export ID from the X-Export-ID header", Required: true}}, Response: exportProgress{}},
+}
+
+// openAPISpec builds an OpenAPI 3 document for openAPIEndpoints.
+func openAPISpec() map[string]any {
+	schemas := map[string]any{
+		"APIError": schemaFor(reflect.TypeOf(APIError{}), nil),
+	}
+	paths := make(map[string]any, len(openAPIEndpoints))
+	for _, ep := range openAPIEndpoints {
+		params := make([]any, 0, len(ep.Params))
+		for _, p := range ep.Params {
+			schema := map[string]any{"type": p.Type}
+			if p.Default != nil {
+				schema["default"] = p.Default
+			}
+			if len(p.Enum) > 0 {
+				schema["enum"] = p.Enum
+			}
+			params = append(params, map[string]any{
+				"name":        p.Name,
+				"in":          "query",
+				"description": p.Description,
+				"required":    p.Required,
+				"schema":      schema,
+			})
+		}
+		paths[ep.Path] = map[string]any{
+			"get": map[string]any{
+				"summary":    ep.Summary,
+				"parameters": params,
+				"responses": map[string]any{
+					"200": jsonResponse("OK", schemaFor(reflect.TypeOf(ep.Response), schemas)),
+					"400": jsonResponse("Invalid parameters", schemaRef("APIError")),
+					"401": jsonResponse("Not authenticated", schemaRef("APIError")),
+					"403": jsonResponse("Site not permitted", schemaRef("APIError")),
+				},
+			},
+		}
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "Caddystat API",
+			"version": version.Version,
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": schemas,
+			"securitySchemes": map[string]any{
+				"session": map[string]any{"type": "apiKey", "in": "cookie", "name": sessionCookieName},
+			},
+		},
+		"security": []any{map[string]any{"session": []string{}}},
+	}
+}
+
+func jsonResponse(description string, schema map[string]any) map[string]any {
+	return map[string]any{
+		"description": description,
+		"content":     map[string]any{"application/json": map[string]any{"schema": schema}},
+	}
+}
+
+func schemaRef(name string) map[string]any {
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaFor returns the JSON schema for t. Named structs are added to
+// schemas (when non-nil) and referenced rather than inlined.
+func schemaFor(t reflect.Type, schemas map[string]any) map[string]any {
+	switch t.Kind() {
+	case reflect.Pointer:
+		s := schemaFor(t.Elem(), schemas)
+		if _, isRef := s["$ref"]; isRef {
+			return map[string]any{"allOf": []any{s}, "nullable": true}
+		}
+		s["nullable"] = true
+		return s
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": schemaFor(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaFor(t.Elem(), schemas)}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Struct:
+		if t == timeType {
+			return map[string]any{"type": "string", "format": "date-time"}
+		}
+		if schemas == nil || t.Name() == "" {
+			return structSchema(t, schemas)
+		}
+		if _, ok := schemas[t.Name()]; !ok {
+			schemas[t.Name()] = map[string]any{} // placeholder guards recursive types
+			schemas[t.Name()] = structSchema(t, schemas)
+		}
+		return schemaRef(t.Name())
+	default:
+		return map[string]any{}
+	}
+}
+
+func structSchema(t reflect.Type, schemas map[string]any) map[string]any {
+	props := map[string]any{}
+	addStructFields(t, schemas, props)
+	return map[string]any{"type": "object", "properties": props}
+}
+
+// addStructFields adds t's JSON-visible fields to props, flattening
+// embedded structs the way encoding/json does.
+func addStructFields(t reflect.Type, schemas map[string]any, props map[string]any) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			addStructFields(f.Type, schemas, props)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = schemaFor(f.Type, schemas)
+	}
+}
+
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, openAPISpec())
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOpenAPISpec(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	var doc struct {
+		OpenAPI    string                    `json:"openapi"`
+		Paths      map[string]map[string]any `json:"paths"`
+		Components struct {
+			Schemas map[string]any `json:"schemas"`
+		} `json:"components"`
+	}
+	body := w.Body.String()
+	if err := json.Unmarshal([]byte(body), &doc); err != nil {
+		t.Fatalf("spec is not valid JSON: %v", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Errorf("openapi = %q, want 3.x", doc.OpenAPI)
+	}
+
+	for _, path := range []string{
+		"/api/stats/summary",
+		"/api/stats/requests",
+		"/api/stats/monthly",
+		"/api/stats/geo",
+		"/api/stats/referrers",
+		"/api/stats/performance",
+		"/api/stats/security/scans",
+	} {
+		if _, ok := doc.Paths[path]["get"]; !ok {
+			t.Errorf("spec is missing GET %s", path)
+		}
+	}
+
+	for _, name := range []string{"Summary", "TimeSeriesStat", "MonthlyStat", "APIError"} {
+		if _, ok := doc.Components.Schemas[name]; !ok {
+			t.Errorf("spec is missing schema %s", name)
+		}
+	}
+
+	// Every $ref must resolve to a component schema
+	const prefix = `"$ref":"#/components/schemas/`
+	for rest := body; ; {
+		i := strings.Index(rest, prefix)
+		if i < 0 {
+			break
+		}
+		rest = rest[i+len(prefix):]
+		name := rest[:strings.IndexByte(rest, '"')]
+		if _, ok := doc.Components.Schemas[name]; !ok {
+			t.Errorf("unresolved $ref to %s", name)
+		}
+	}
+}
+
+func TestSchemaFor_JSONTags(t *testing.T) {
+	type inner struct {
+		N int `json:"n"`
+	}
+	type sample struct {
+		inner
+		Name    string   `json:"name"`
+		Skipped string   `json:"-"`
+		Rate    *float64 `json:"rate,omitempty"`
+		Tags    []string `json:"tags"`
+		private int
+	}
+
+	schema := schemaFor(reflect.TypeOf(sample{}), nil)
+	props := schema["properties"].(map[string]any)
+	for _, name := range []string{"n", "name", "rate", "tags"} {
+		if _, ok := props[name]; !ok {
+			t.Errorf("expected property %q", name)
+		}
+	}
+	if len(props) != 4 {
+		t.Errorf("expected 4 properties, got %d: %v", len(props), props)
+	}
+	if props["rate"].(map[string]any)["nullable"] != true {
+		t.Error("pointer field should be nullable")
+	}
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// pollIntervalHeader suggests, in whole seconds, how long a polling
+	// client should wait before refreshing.
+	pollIntervalHeader = "X-Poll-Interval"
+	// pollRateWindow is how far back the request rate is measured.
+	pollRateWindow = 5 // minutes
+	// pollHalfRate is the requests per minute at which the suggested
+	// interval drops to half of the maximum.
+	pollHalfRate = 10
+	// maxPollRateHosts bounds the rate cache; host comes from the query
+	// string, so it is cleared rather than left to grow.
+	maxPollRateHosts = 1000
+)
+
+// pollInterval scales the suggested refresh interval with traffic: an idle
+// site gets hi, and each further pollHalfRate requests per minute shortens
+// it, down to lo.
+func pollInterval(perMinute float64, lo, hi time.Duration) time.Duration {
+	d := time.Duration(float64(hi) * pollHalfRate / (pollHalfRate + max(perMinute, 0)))
+	return max(d.Round(time.Second), lo)
+}
+
+// setPollInterval sets X-Poll-Interval from the request rate of the
+// request's host (site-wide without one) over the last few minutes and
+// returns the value in seconds. If the rate can't be read it suggests the
+// longest interval.
+func (s *Server) setPollInterval(w http.ResponseWriter, r *http.Request) int {
+	perMinute := s.pollRates.get(r.Context(), r.URL.Query().Get("host"), func(ctx context.Context, host string) (float64, bool) {
+		stats, err := s.store.Realtime(ctx, pollRateWindow, host)
+		if err != nil {
+			return 0, false
+		}
+		return float64(stats.TotalRequests) / pollRateWindow, true
+	})
+	secs := max(int(pollInterval(perMinute, s.cfg.PollIntervalMin, s.cfg.PollIntervalMax)/time.Second), 1)
+	w.Header().Set(pollIntervalHeader, strconv.Itoa(secs))
+	return secs
+}
+
+// pollRateCache keeps each host's request rate for ttl, so dashboards polling
+// the summary don't each rescan the last few minutes of requests.
+type pollRateCache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]pollRate
+}
+
+type pollRate struct {
+	perMinute float64
+	expires   time.Time
+}
+
+func newPollRateCache(ttl time.Duration) *pollRateCache {
+	return &pollRateCache{ttl: ttl, entries: make(map[string]pollRate)}
+}
+
+// get returns host's cached rate, calling load when it is missing or
+// expired. Failed loads are not cached and report a rate of 0.
+func (c *pollRateCache) get(ctx context.Context, host string, load func(context.Context, string) (float64, bool)) float64 {
+	now := time.Now()
+	c.mu.Lock()
+	e, ok := c.entries[host]
+	c.mu.Unlock()
+	if ok && now.Before(e.expires) {
+		return e.perMinute
+	}
+
+	perMinute, ok := load(ctx, host)
+	if !ok {
+		return 0
+	}
+	c.mu.Lock()
+	if len(c.entries) >= maxPollRateHosts {
+		clear(c.entries)
+	}
+	c.entries[host] = pollRate{perMinute: perMinute, expires: now.Add(c.ttl)}
+	c.mu.Unlock()
+	return perMinute
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/dustin/Caddystat/internal/storage"
+)
+
+func TestPollInterval(t *testing.T) {
+	lo, hi := 5*time.Second, time.Minute
+	tests := []struct {
+		perMinute float64
+		want      time.Duration
+	}{
+		{0, time.Minute},
+		{10, 30 * time.Second},
+		{50, 10 * time.Second},
+		{10000, 5 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := pollInterval(tt.perMinute, lo, hi); got != tt.want {
+			t.Errorf("pollInterval(%v) = %v, want %v", tt.perMinute, got, tt.want)
+		}
+	}
+}
+
+func TestPollIntervalHeader_BusyShorterThanIdle(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+	// Measure the rate on every request
+	srv.pollRates = newPollRateCache(0)
+
+	get := func(path string) int {
+		t.Helper()
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s: expected status %d, got %d", path, http.StatusOK, w.Code)
+		}
+		secs, err := strconv.Atoi(w.Header().Get(pollIntervalHeader))
+		if err != nil {
+			t.Fatalf("GET %s: bad %s header %q", path, pollIntervalHeader, w.Header().Get(pollIntervalHeader))
+		}
+		return secs
+	}
+
+	// Traffic from an hour ago doesn't count towards the current rate
+	ctx := context.Background()
+	old := storage.RequestRecord{Timestamp: time.Now().UTC().Add(-time.Hour), Host: "example.com", Path: "/", Status: 200, IP: "10.0.0.1"}
+	if err := srv.store.InsertRequest(ctx, old); err != nil {
+		t.Fatalf("InsertRequest() error = %v", err)
+	}
+	idle := get("/api/stats/summary")
+	if idle != 60 {
+		t.Errorf("idle interval = %ds, want the 60s maximum", idle)
+	}
+
+	for i := 0; i < 200; i++ {
+		rec := storage.RequestRecord{Timestamp: time.Now().UTC().Add(-time.Duration(i) * time.Second), Host: "example.com", Path: "/", Status: 200, IP: "10.0.0.1"}
+		if err := srv.store.InsertRequest(ctx, rec); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	busy := get("/api/stats/summary")
+	if busy >= idle {
+		t.Errorf("busy interval %ds should be shorter than idle %ds", busy, idle)
+	}
+	if status := get("/api/stats/status"); status != busy {
+		t.Errorf("status endpoint interval = %ds, want %ds like summary", status, busy)
+	}
+}
+
+func TestPollRateCache(t *testing.T) {
+	c := newPollRateCache(time.Hour)
+	loads := map[string]int{}
+	load := func(_ context.Context, host string) (float64, bool) {
+		loads[host]++
+		return float64(len(host)), host != "broken.com"
+	}
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if got := c.get(ctx, "example.com", load); got != 11 {
+			t.Fatalf("get(example.com) = %v, want 11", got)
+		}
+	}
+	if got := c.get(ctx, "", load); got != 0 {
+		t.Errorf("get(\"\") = %v, want 0", got)
+	}
+	if loads["example.com"] != 1 || loads[""] != 1 {
+		t.Errorf("loads = %v, want one per host", loads)
+	}
+
+	// Failures are retried rather than cached
+	c.get(ctx, "broken.com", load)
+	c.get(ctx, "broken.com", load)
+	if loads["broken.com"] != 2 {
+		t.Errorf("failed loads = %d, want 2", loads["broken.com"])
+	}
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// exportProgressTTL is how long a finished export's progress stays
+// queryable, so a client polling on an interval still sees it complete.
+const exportProgressTTL = 10 * time.Minute
+
+// exportProgress is the state of one tracked export.
+type exportProgress struct {
+	ID         string     `json:"id"`
+	Rows       int64      `json:"rows"` // Rows written to the response so far
+	Done       bool       `json:"done"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// exportTracker holds progress for exports started with progress=true.
+type exportTracker struct {
+	mu      sync.Mutex
+	exports map[string]*exportProgress
+}
+
+func newExportTracker() *exportTracker {
+	return &exportTracker{exports: make(map[string]*exportProgress)}
+}
+
+// start registers a new export under a random ID, dropping finished
+// exports older than exportProgressTTL.
+func (t *exportTracker) start() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	id := hex.EncodeToString(b)
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for k, p := range t.exports {
+		if p.Done && now.Sub(*p.FinishedAt) > exportProgressTTL {
+			delete(t.exports, k)
+		}
+	}
+	t.exports[id] = &exportProgress{ID: id, StartedAt: now.UTC()}
+	return id, nil
+}
+
+func (t *exportTracker) add(id string, rows int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if p, ok := t.exports[id]; ok {
+		p.Rows += int64(rows)
+	}
+}
+
+func (t *exportTracker) finish(id string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if p, ok := t.exports[id]; ok {
+		now := time.Now().UTC()
+		p.Done = true
+		p.FinishedAt = &now
+	}
+}
+
+// get returns a copy of the progress for id.
+func (t *exportTracker) get(id string) (exportProgress, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, ok := t.exports[id]
+	if !ok {
+		return exportProgress{}, false
+	}
+	return *p, true
+}
+
+// trackExport starts progress tracking when the request has progress=true
+// and returns the ID in the X-Export-ID header. add records rows written
+// and done marks the export finished; both are no-ops when untracked.
+func (s *Server) trackExport(w http.ResponseWriter, r *http.Request) (add func(rows int), done func()) {
+	if on, _ := strconv.ParseBool(r.URL.Query().Get("progress")); !on {
+		return func(int) {}, func() {}
+	}
+	id, err := s.exports.start()
+	if err != nil {
+		return func(int) {}, func() {}
+	}
+	w.Header().Set("X-Export-ID", id)
+	return func(rows int) { s.exports.add(id, rows) }, func() { s.exports.finish(id) }
+}
+
+// handleExportProgress reports rows written so far by a tracked export.
+func (s *Server) handleExportProgress(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeErrorWithCode(w, http.StatusBadRequest, "id is required", "MISSING_ID")
+		return
+	}
+	p, ok := s.exports.get(id)
+	if !ok {
+		writeErrorWithCode(w, http.StatusNotFound, "export not found", "NOT_FOUND")
+		return
+	}
+	writeJSON(w, p)
+}
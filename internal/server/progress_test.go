@@ -0,0 +1,105 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dustin/Caddystat/internal/storage"
+)
+
+// progressRecorder samples the tracked export's row count on every write.
+type progressRecorder struct {
+	*httptest.ResponseRecorder
+	srv  *Server
+	seen []int64
+}
+
+func (p *progressRecorder) Write(b []byte) (int, error) {
+	if id := p.Header().Get("X-Export-ID"); id != "" {
+		if prog, ok := p.srv.exports.get(id); ok && !prog.Done {
+			if n := len(p.seen); n == 0 || p.seen[n-1] != prog.Rows {
+				p.seen = append(p.seen, prog.Rows)
+			}
+		}
+	}
+	return p.ResponseRecorder.Write(b)
+}
+
+func TestExportProgress_AdvancesPerChunk(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	const total = 2500 // three export chunks of up to 1000 rows
+	records := make([]storage.RequestRecord, total)
+	now := time.Now().UTC()
+	for k := range records {
+		records[k] = storage.RequestRecord{Timestamp: now.Add(-time.Duration(k) * time.Second), Host: "example.com", Path: fmt.Sprintf("/p/%d", k), Status: 200}
+	}
+	if err := srv.store.InsertRequestBatch(context.Background(), records); err != nil {
+		t.Fatalf("InsertRequestBatch() error = %v", err)
+	}
+
+	for _, format := range []string{"csv", "json"} {
+		t.Run(format, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/export/"+format+"?range=24h&progress=true", nil)
+			w := &progressRecorder{ResponseRecorder: httptest.NewRecorder(), srv: srv}
+			srv.ServeHTTP(w, req)
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+			}
+			id := w.Header().Get("X-Export-ID")
+			if id == "" {
+				t.Fatal("expected an X-Export-ID header")
+			}
+
+			// JSON also writes its closing bracket after the last chunk
+			want := []int64{0, 1000, 2000}
+			if len(w.seen) < len(want) || fmt.Sprint(w.seen[:len(want)]) != fmt.Sprint(want) {
+				t.Errorf("progress seen while writing = %v, want it to start %v", w.seen, want)
+			}
+
+			req = httptest.NewRequest(http.MethodGet, "/api/stats/export-progress?id="+id, nil)
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("export-progress status = %d, want %d", rec.Code, http.StatusOK)
+			}
+			var got exportProgress
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("decode progress: %v", err)
+			}
+			if got.Rows != total || !got.Done || got.FinishedAt == nil {
+				t.Errorf("final progress = %+v, want %d rows, done", got, total)
+			}
+		})
+	}
+}
+
+func TestExportProgress_UntrackedAndUnknown(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/export/csv", nil)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if id := w.Header().Get("X-Export-ID"); id != "" {
+		t.Errorf("X-Export-ID = %q without progress=true, want none", id)
+	}
+
+	for path, want := range map[string]int{
+		"/api/stats/export-progress":            http.StatusBadRequest,
+		"/api/stats/export-progress?id=missing": http.StatusNotFound,
+	} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Errorf("GET %s: status = %d, want %d", path, w.Code, want)
+		}
+	}
+}
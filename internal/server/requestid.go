@@ -0,0 +1,58 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// requestIDHeader carries the correlation ID of a request. A valid
+// client-supplied value is kept, so IDs from a reverse proxy line up with
+// Caddystat's logs; otherwise one is generated.
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength caps accepted client-supplied request IDs.
+const maxRequestIDLength = 128
+
+// assignRequestID echoes the request's correlation ID in the response and
+// returns r carrying it in its context.
+func assignRequestID(w http.ResponseWriter, r *http.Request) *http.Request {
+	id := r.Header.Get(requestIDHeader)
+	if !validRequestID(id) {
+		id = newRequestID()
+	}
+	w.Header().Set(requestIDHeader, id)
+	return r.WithContext(context.WithValue(r.Context(), requestIDKey, id))
+}
+
+// requestID returns the correlation ID assigned to r, or "" if none was.
+func requestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey).(string)
+	return id
+}
+
+// newRequestID returns a random 16-byte hex ID.
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b) // never fails; see crypto/rand.Read
+	return hex.EncodeToString(b)
+}
+
+// validRequestID reports whether a client-supplied ID is short and made of
+// characters that are safe to log and echo: letters, digits, '-', '_', '.'
+// and ':'.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-' || c == '_' || c == '.' || c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
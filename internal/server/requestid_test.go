@@ -0,0 +1,80 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestID(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	req.Header.Set(requestIDHeader, "proxy-abc.123")
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if got := w.Header().Get(requestIDHeader); got != "proxy-abc.123" {
+		t.Errorf("%s = %q, want the client-supplied ID echoed", requestIDHeader, got)
+	}
+
+	seen := make(map[string]bool)
+	for _, supplied := range []string{"", "bad id\twith spaces", strings.Repeat("a", maxRequestIDLength+1)} {
+		req := httptest.NewRequest(http.MethodGet, "/health", nil)
+		if supplied != "" {
+			req.Header.Set(requestIDHeader, supplied)
+		}
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		got := w.Header().Get(requestIDHeader)
+		if got == "" || got == supplied || seen[got] {
+			t.Errorf("%s for supplied %q = %q, want a new generated ID", requestIDHeader, supplied, got)
+		}
+		seen[got] = true
+	}
+}
+
+func TestRequestID_InInternalError(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+	srv.store.Close() // make every query fail
+
+	req := httptest.NewRequest(http.MethodGet, "/api/stats/summary", nil)
+	req.Header.Set(requestIDHeader, "trace-42")
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+	var body APIError
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("decode error body: %v", err)
+	}
+	if body.Code != "INTERNAL_ERROR" || body.RequestID != "trace-42" {
+		t.Errorf("error body = %+v, want INTERNAL_ERROR with request_id trace-42", body)
+	}
+}
+
+func TestRequestID_InClientError(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/stats/export-progress", nil)
+	req.Header.Set(requestIDHeader, "trace-43")
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	var body APIError
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("decode error body: %v", err)
+	}
+	if body.Code != "MISSING_ID" || body.RequestID != "trace-43" {
+		t.Errorf("error body = %+v, want MISSING_ID with request_id trace-43", body)
+	}
+}
@@ -1,14 +1,17 @@
 package server
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/subtle"
 	"encoding/base64"
+	"net"
 	"net/http"
 	"strings"
 )
 
-// Content-Security-Policy header value.
+// defaultContentSecurityPolicy is the Content-Security-Policy header value
+// used unless CONTENT_SECURITY_POLICY overrides it.
 // This policy allows:
 // - Scripts from self, cdn.jsdelivr.net (Alpine.js), unsafe-inline and unsafe-eval (required by Alpine.js)
 // - Styles from self, fonts.googleapis.com, and unsafe-inline (for Alpine.js dynamic styles)
@@ -18,7 +21,7 @@ import (
 // Note: Alpine.js requires 'unsafe-eval' to evaluate x-data, x-show, @click expressions.
 // The CSP build of Alpine.js (alpine-csp) could be used instead to avoid unsafe-eval,
 // but would require significant refactoring of the frontend.
-const contentSecurityPolicy = "default-src 'self'; " +
+const defaultContentSecurityPolicy = "default-src 'self'; " +
 	"script-src 'self' 'unsafe-inline' 'unsafe-eval' https://cdn.jsdelivr.net; " +
 	"style-src 'self' 'unsafe-inline' https://fonts.googleapis.com; " +
 	"font-src 'self' https://fonts.gstatic.com; " +
@@ -28,6 +31,22 @@ const contentSecurityPolicy = "default-src 'self'; " +
 	"form-action 'self'; " +
 	"base-uri 'self'"
 
+// cspNonceLength is the length of per-response CSP nonces in bytes (before
+// base64 encoding).
+const cspNonceLength = 16
+
+// cspNoncePlaceholder is replaced with the response's nonce wherever it
+// appears in the dashboard's index.html, e.g. <script nonce="__CSP_NONCE__">.
+const cspNoncePlaceholder = "__CSP_NONCE__"
+
+// contextKey namespaces values the server stores in request contexts.
+type contextKey int
+
+const (
+	cspNonceKey  contextKey = iota // CSP nonce generated for the request
+	requestIDKey                   // Correlation ID; see requestIDHeader
+)
+
 // csrfTokenLength is the length of CSRF tokens in bytes (before base64 encoding).
 const csrfTokenLength = 32
 
@@ -38,13 +57,74 @@ const csrfCookieName = "caddystat_csrf"
 const csrfHeaderName = "X-CSRF-Token"
 
 // setSecurityHeaders adds security-related headers to the response.
-func setSecurityHeaders(w http.ResponseWriter) {
-	w.Header().Set("Content-Security-Policy", contentSecurityPolicy)
+func setSecurityHeaders(w http.ResponseWriter, csp string) {
+	w.Header().Set("Content-Security-Policy", csp)
 	w.Header().Set("X-Content-Type-Options", "nosniff")
 	w.Header().Set("X-Frame-Options", "DENY")
 	w.Header().Set("Referrer-Policy", "strict-origin-when-cross-origin")
 }
 
+// contentSecurityPolicy returns the configured CSP, or the built-in default
+// when CONTENT_SECURITY_POLICY is unset.
+func (s *Server) contentSecurityPolicy() string {
+	if s.cfg.ContentSecurityPolicy != "" {
+		return s.cfg.ContentSecurityPolicy
+	}
+	return defaultContentSecurityPolicy
+}
+
+// generateNonce creates a new random CSP nonce.
+func generateNonce() (string, error) {
+	b := make([]byte, cspNonceLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// cspWithNonce adds nonce as an allowed source to the script-src directive
+// of csp, or to default-src when there is no script-src. The policy is
+// returned unchanged if nonce is empty or neither directive is present.
+// Browsers that honour nonces ignore 'unsafe-inline' next to one, so inline
+// scripts must then carry the nonce attribute.
+func cspWithNonce(csp, nonce string) string {
+	if nonce == "" {
+		return csp
+	}
+	directives := strings.Split(csp, ";")
+	target := -1
+	for i, d := range directives {
+		fields := strings.Fields(d)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "script-src":
+			target = i
+		case "default-src":
+			if target < 0 {
+				target = i
+			}
+		}
+	}
+	if target < 0 {
+		return csp
+	}
+	directives[target] = strings.TrimRight(directives[target], " \t") + " 'nonce-" + nonce + "'"
+	return strings.Join(directives, ";")
+}
+
+// withCSPNonce returns r carrying nonce for handlers that render HTML.
+func withCSPNonce(r *http.Request, nonce string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), cspNonceKey, nonce))
+}
+
+// cspNonce returns the CSP nonce for r, or "" if none was generated.
+func cspNonce(r *http.Request) string {
+	nonce, _ := r.Context().Value(cspNonceKey).(string)
+	return nonce
+}
+
 // generateCSRFToken creates a new random CSRF token.
 func generateCSRFToken() (string, error) {
 	b := make([]byte, csrfTokenLength)
@@ -116,3 +196,43 @@ func (s *Server) requireCSRF(next http.HandlerFunc) http.HandlerFunc {
 		next(w, r)
 	}
 }
+
+// trustedHosts is the set of Host header values the server answers to.
+// A nil set allows any host.
+type trustedHosts map[string]struct{}
+
+// newTrustedHosts builds a lowercased host set. Entries may include a port to
+// restrict that exact host:port, or omit it to allow any port.
+func newTrustedHosts(hosts []string) trustedHosts {
+	var t trustedHosts
+	for _, h := range hosts {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if h == "" {
+			continue
+		}
+		if t == nil {
+			t = make(trustedHosts)
+		}
+		if strings.HasPrefix(h, "[") && strings.HasSuffix(h, "]") {
+			h = h[1 : len(h)-1] // bare IPv6 literal
+		}
+		t[h] = struct{}{}
+	}
+	return t
+}
+
+// allows reports whether the request Host header is trusted.
+func (t trustedHosts) allows(host string) bool {
+	if t == nil {
+		return true
+	}
+	host = strings.ToLower(host)
+	if _, ok := t[strings.Trim(host, "[]")]; ok {
+		return true
+	}
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		_, ok := t[strings.Trim(hostname, "[]")]
+		return ok
+	}
+	return false
+}
@@ -5,6 +5,8 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+
+	"github.com/dustin/Caddystat/internal/sse"
 )
 
 func TestSecurityHeaders(t *testing.T) {
@@ -57,6 +59,51 @@ func TestSecurityHeaders(t *testing.T) {
 	}
 }
 
+func TestSecurityHeaders_ConfiguredCSP(t *testing.T) {
+	base, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	get := func(srv *Server) string {
+		req := httptest.NewRequest(http.MethodGet, "/health", nil)
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		return w.Header().Get("Content-Security-Policy")
+	}
+	// Only a templated dashboard index adds a nonce
+	if got := get(base); got != defaultContentSecurityPolicy {
+		t.Errorf("CSP without CONTENT_SECURITY_POLICY = %q, want the default %q", got, defaultContentSecurityPolicy)
+	}
+
+	cfg := base.cfg
+	cfg.ContentSecurityPolicy = "default-src 'self'; font-src 'self' https://fonts.example.com"
+	srv := New(base.store, sse.NewHub(), cfg, nil)
+	if got := get(srv); got != cfg.ContentSecurityPolicy {
+		t.Errorf("CSP = %q, want the configured %q", got, cfg.ContentSecurityPolicy)
+	}
+}
+
+func TestCSPWithNonce(t *testing.T) {
+	tests := []struct {
+		name string
+		csp  string
+		want string
+	}{
+		{"script-src", "default-src 'self'; script-src 'self'; img-src 'self'", "default-src 'self'; script-src 'self' 'nonce-abc'; img-src 'self'"},
+		{"default-src only", "default-src 'self'; img-src data:", "default-src 'self' 'nonce-abc'; img-src data:"},
+		{"neither", "img-src 'self'", "img-src 'self'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cspWithNonce(tt.csp, "abc"); got != tt.want {
+				t.Errorf("cspWithNonce(%q) = %q, want %q", tt.csp, got, tt.want)
+			}
+		})
+	}
+	if got := cspWithNonce("script-src 'self'", ""); got != "script-src 'self'" {
+		t.Errorf("cspWithNonce without nonce = %q, want the policy unchanged", got)
+	}
+}
+
 func TestCSRFCookieIsSet(t *testing.T) {
 	srv, cleanup := setupTestServer(t)
 	defer cleanup()
@@ -301,3 +348,65 @@ func TestCSRFCookieSecureFlag(t *testing.T) {
 		}
 	})
 }
+
+func TestTrustedHosts_Allows(t *testing.T) {
+	hosts := newTrustedHosts([]string{"Stats.Example.com", "localhost:8404", "[::1]", " "})
+
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"stats.example.com", true},
+		{"STATS.EXAMPLE.COM:443", true},
+		{"localhost:8404", true},
+		{"localhost:9999", false},
+		{"localhost", false},
+		{"[::1]:8404", true},
+		{"[::1]", true},
+		{"evil.example.com", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := hosts.allows(tt.host); got != tt.want {
+			t.Errorf("allows(%q) = %v, want %v", tt.host, got, tt.want)
+		}
+	}
+
+	if !newTrustedHosts(nil).allows("anything.example") {
+		t.Error("empty trusted hosts should allow any host")
+	}
+}
+
+func TestServeHTTP_TrustedHosts(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+	srv.hosts = newTrustedHosts([]string{"stats.example.com"})
+
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	req.Host = "stats.example.com"
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("allowed host: expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/stats/summary", nil)
+	req.Host = "203.0.113.10"
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusMisdirectedRequest {
+		t.Errorf("rejected host: expected status %d, got %d", http.StatusMisdirectedRequest, w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "UNTRUSTED_HOST") {
+		t.Errorf("expected UNTRUSTED_HOST error code, got %s", w.Body.String())
+	}
+
+	// Container health checks address the server by IP
+	req = httptest.NewRequest(http.MethodGet, "/health", nil)
+	req.Host = "172.17.0.2:8404"
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("health on untrusted host: expected status %d, got %d", http.StatusOK, w.Code)
+	}
+}
@@ -7,18 +7,23 @@ import (
 	"encoding/base64"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"net"
 	"net/http"
+	"net/http/pprof"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"github.com/dustin/Caddystat/internal/config"
+	"github.com/dustin/Caddystat/internal/logging"
 	"github.com/dustin/Caddystat/internal/metrics"
 	"github.com/dustin/Caddystat/internal/sse"
 	"github.com/dustin/Caddystat/internal/storage"
@@ -31,17 +36,61 @@ type Server struct {
 	mux         *http.ServeMux
 	cfg         config.Config
 	rateLimiter *RateLimiter
+	accessLog   *accessLogger
+	ingester    RecordIngester // nil unless push ingest is wired up
+	ingestLimit *RateLimiter
+	hosts       trustedHosts
 	metrics     *metrics.Metrics
+	exports     *exportTracker
+	recent      recentRequester // Fallback for the SSE snapshot when the hub's buffer is cold
+	pollRates   *pollRateCache  // Request rates behind X-Poll-Interval, per host
+}
+
+// recentRequester loads recent requests; implemented by *storage.Storage.
+type recentRequester interface {
+	RecentRequests(ctx context.Context, limit int, host string) ([]storage.RecentRequest, error)
 }
 
 func New(store *storage.Storage, hub *sse.Hub, cfg config.Config, m *metrics.Metrics) *Server {
+	// Fall back to the built-in defaults when cfg wasn't built by config.Load
+	if cfg.DefaultRange <= 0 {
+		cfg.DefaultRange = 24 * time.Hour
+	}
+	if cfg.DefaultTopLimit <= 0 {
+		cfg.DefaultTopLimit = 20
+	}
+	if cfg.DefaultRecentLimit <= 0 {
+		cfg.DefaultRecentLimit = 20
+	}
+	if cfg.MaxRecentRequests <= 0 {
+		cfg.MaxRecentRequests = storage.DefaultMaxRecentRequests
+	}
+	cfg.MaxRecentRequests = min(cfg.MaxRecentRequests, storage.MaxRecentRequestsCeiling)
+	if cfg.PollIntervalMin <= 0 {
+		cfg.PollIntervalMin = 5 * time.Second
+	}
+	if cfg.PollIntervalMax <= 0 {
+		cfg.PollIntervalMax = time.Minute
+	}
+	cfg.PollIntervalMax = max(cfg.PollIntervalMax, cfg.PollIntervalMin)
+	// The poll rate is refreshed as often as the "online" SSE count
+	pollRateTTL := cfg.OnlinePushInterval
+	if pollRateTTL <= 0 {
+		pollRateTTL = 10 * time.Second
+	}
 	s := &Server{
 		store:       store,
 		hub:         hub,
 		mux:         http.NewServeMux(),
 		cfg:         cfg,
 		rateLimiter: NewRateLimiter(cfg.RateLimitPerMinute, time.Minute),
+		ingestLimit: NewRateLimiter(cfg.IngestRatePerMinute, time.Minute),
+		accessLog:   newAccessLogger(cfg.AccessLogSampleRate),
+		hosts:       newTrustedHosts(cfg.TrustedHosts),
 		metrics:     m,
+		exports:     newExportTracker(),
+		recent:      store,
+		pollRates:   newPollRateCache(pollRateTTL),
 	}
 	s.routes()
 	return s
@@ -52,52 +101,124 @@ func (s *Server) routes() {
 	s.mux.HandleFunc("/health", s.handleHealth)
 	s.mux.HandleFunc("/robots.txt", s.handleRobotsTxt)
 	s.mux.Handle("/metrics", promhttp.Handler())
+	s.mux.HandleFunc("/api/openapi.json", s.handleOpenAPI)
+	s.mux.HandleFunc("/api/version", s.handleVersion)
 
 	// Auth endpoints (always accessible, POST endpoints require CSRF)
 	s.mux.HandleFunc("/api/auth/login", s.requireCSRF(s.handleLogin))
 	s.mux.HandleFunc("/api/auth/logout", s.requireCSRF(s.handleLogout))
 	s.mux.HandleFunc("/api/auth/check", s.handleAuthCheck)
 
+	// Push ingest authenticates with INGEST_API_KEY rather than a session
+	s.mux.HandleFunc("/api/ingest", s.handleIngest)
+
 	// Protected API endpoints with site permission checks
 	// These endpoints accept a "host" query parameter that must be authorized
 	s.mux.HandleFunc("/api/stats/summary", s.requireAuth(s.requireSitePermission(s.handleSummary)))
 	s.mux.HandleFunc("/api/stats/monthly", s.requireAuth(s.requireSitePermission(s.handleMonthly)))
+	s.mux.HandleFunc("/api/stats/weekly", s.requireAuth(s.requireSitePermission(s.handleWeekly)))
 	s.mux.HandleFunc("/api/stats/daily", s.requireAuth(s.requireSitePermission(s.handleDaily)))
 	s.mux.HandleFunc("/api/stats/requests", s.requireAuth(s.requireSitePermission(s.handleRequests)))
+	s.mux.HandleFunc("/api/stats/requests/by-ip", s.requireAuth(s.requireSitePermission(s.handleRequestsByIP)))
 	s.mux.HandleFunc("/api/stats/geo", s.requireAuth(s.requireSitePermission(s.handleGeo)))
+	s.mux.HandleFunc("/api/stats/known-hosts", s.requireAuth(s.handleKnownHosts)) // Filtered by session permissions
+	s.mux.HandleFunc("/api/stats/totals", s.requireAuth(s.handleTotals))          // Filtered by session permissions
 	s.mux.HandleFunc("/api/stats/hosts", s.requireAuth(s.requireSitePermission(s.handleVisitors)))
+	s.mux.HandleFunc("/api/stats/hosts/activity", s.requireAuth(s.handleHostActivity)) // Filtered by session permissions
 	s.mux.HandleFunc("/api/stats/browsers", s.requireAuth(s.requireSitePermission(s.handleBrowsers)))
+	s.mux.HandleFunc("/api/stats/devices", s.requireAuth(s.requireSitePermission(s.handleDevices)))
+	s.mux.HandleFunc("/api/stats/languages", s.requireAuth(s.requireSitePermission(s.handleLanguages)))
 	s.mux.HandleFunc("/api/stats/os", s.requireAuth(s.requireSitePermission(s.handleOS)))
+	s.mux.HandleFunc("/api/stats/user-agents", s.requireAuth(s.requireSitePermission(s.handleUserAgents)))
+	s.mux.HandleFunc("/api/stats/unclassified", s.requireAuth(s.requireSitePermission(s.handleUnclassified)))
+	s.mux.HandleFunc("/api/stats/methods", s.requireAuth(s.requireSitePermission(s.handleMethods)))
+	s.mux.HandleFunc("/api/stats/protocols", s.requireAuth(s.requireSitePermission(s.handleProtocols)))
 	s.mux.HandleFunc("/api/stats/robots", s.requireAuth(s.requireSitePermission(s.handleRobots)))
+	s.mux.HandleFunc("/api/stats/robots/verification", s.requireAuth(s.requireSitePermission(s.handleBotVerification)))
 	s.mux.HandleFunc("/api/stats/referrers", s.requireAuth(s.requireSitePermission(s.handleReferrers)))
 	s.mux.HandleFunc("/api/stats/recent", s.requireAuth(s.requireSitePermission(s.handleRecentRequests)))
 	s.mux.HandleFunc("/api/stats/status", s.requireAuth(s.handleStatus)) // Status doesn't filter by host
 	s.mux.HandleFunc("/api/stats/performance", s.requireAuth(s.requireSitePermission(s.handlePerformance)))
+	s.mux.HandleFunc("/api/stats/realtime", s.requireAuth(s.requireSitePermission(s.handleRealtime)))
+	s.mux.HandleFunc("/api/stats/online", s.requireAuth(s.requireSitePermission(s.handleOnline)))
+	s.mux.HandleFunc("/api/stats/peak", s.requireAuth(s.requireSitePermission(s.handlePeak)))
+	s.mux.HandleFunc("/api/stats/path-groups", s.requireAuth(s.requireSitePermission(s.handlePathGroups)))
+	s.mux.HandleFunc("/api/stats/cache", s.requireAuth(s.requireSitePermission(s.handleCache)))
 	s.mux.HandleFunc("/api/stats/bandwidth", s.requireAuth(s.requireSitePermission(s.handleBandwidth)))
+	s.mux.HandleFunc("/api/stats/bandwidth/countries", s.requireAuth(s.requireSitePermission(s.handleBandwidthCountries)))
+	s.mux.HandleFunc("/api/stats/distinct-paths", s.requireAuth(s.requireSitePermission(s.handleDistinctPaths)))
+	s.mux.HandleFunc("/api/stats/slo", s.requireAuth(s.requireSitePermission(s.handleSLO)))
+	s.mux.HandleFunc("/api/stats/downloads", s.requireAuth(s.requireSitePermission(s.handleDownloads)))
+	s.mux.HandleFunc("/api/stats/zero-bytes", s.requireAuth(s.requireSitePermission(s.handleZeroBytes)))
 	s.mux.HandleFunc("/api/stats/sessions", s.requireAuth(s.requireSitePermission(s.handleSessions)))
+	s.mux.HandleFunc("/api/stats/security/error-ips", s.requireAuth(s.requireSitePermission(s.handleErrorIPs)))
+	s.mux.HandleFunc("/api/stats/security/scans", s.requireAuth(s.requireSitePermission(s.handleScans)))
 	s.mux.HandleFunc("/api/sse", s.requireAuth(s.requireSitePermission(s.handleSSE)))
 
 	// Export endpoints with site permission checks
 	s.mux.HandleFunc("/api/export/csv", s.requireAuth(s.requireSitePermission(s.handleExportCSV)))
 	s.mux.HandleFunc("/api/export/json", s.requireAuth(s.requireSitePermission(s.handleExportJSON)))
+	s.mux.HandleFunc("/api/export/archive.zip", s.requireAuth(s.requireSitePermission(s.handleExportArchive)))
+	s.mux.HandleFunc("/api/stats/export-progress", s.requireAuth(s.handleExportProgress))
+	s.mux.HandleFunc("/api/export/influx", s.requireAuth(s.requireSitePermission(s.handleExportInflux)))
 	s.mux.HandleFunc("/api/export/backup", s.requireAuth(s.handleExportBackup)) // Backup is system-wide, admin only
 
 	// Site management endpoints
 	s.mux.HandleFunc("/api/sites", s.requireAuth(s.requireCSRF(s.handleSites)))
 	s.mux.HandleFunc("/api/sites/", s.requireAuth(s.requireCSRF(s.handleSiteByID)))
+	s.mux.HandleFunc("/api/sites/bulk", s.requireAuth(s.requireAdmin(s.requireCSRF(s.handleBulkSites))))
+
+	// Admin endpoints
+	s.mux.HandleFunc("/api/admin/loglevel", s.requireAuth(s.requireAdmin(s.requireCSRF(s.handleLogLevel))))
+	s.mux.HandleFunc("/api/admin/cleanup", s.requireAuth(s.requireAdmin(s.requireCSRF(s.handleCleanup))))
+	s.mux.HandleFunc("/api/admin/config", s.requireAuth(s.requireAdmin(s.handleConfig)))
+	s.mux.HandleFunc("/api/admin/growth", s.requireAuth(s.requireAdmin(s.handleGrowth)))
+	s.mux.HandleFunc("/api/admin/reimport", s.requireAuth(s.requireAdmin(s.requireCSRF(s.handleReimport))))
+
+	// Profiling is opt-in and admin only; config.Validate requires auth for it
+	if s.cfg.EnableProfiling {
+		s.mux.HandleFunc("/debug/pprof/", s.requireAuth(s.requireAdmin(pprof.Index)))
+		s.mux.HandleFunc("/debug/pprof/cmdline", s.requireAuth(s.requireAdmin(pprof.Cmdline)))
+		s.mux.HandleFunc("/debug/pprof/profile", s.requireAuth(s.requireAdmin(pprof.Profile)))
+		s.mux.HandleFunc("/debug/pprof/symbol", s.requireAuth(s.requireAdmin(pprof.Symbol)))
+		s.mux.HandleFunc("/debug/pprof/trace", s.requireAuth(s.requireAdmin(pprof.Trace)))
+	}
 
-	site := http.Dir(filepath.Join(".", "web", "_site"))
-	s.mux.Handle("/", http.FileServer(site))
+	if s.cfg.ServeDashboard {
+		s.mux.Handle("/", s.staticHandler())
+	} else {
+		s.mux.HandleFunc("/", s.handleAPIInfo)
+	}
 }
 
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
+	r = assignRequestID(w, r)
+
+	// Reject requests for hosts we don't serve before doing any other work.
+	// Health checks from Docker or a load balancer usually address the
+	// container by IP, so /health answers on any host.
+	if r.URL.Path != "/health" && !s.hosts.allows(r.Host) {
+		slog.Debug("untrusted host header", "host", r.Host, "path", r.URL.Path, "request_id", requestID(r))
+		if s.metrics != nil {
+			s.metrics.RecordHTTPRequest(r.Method, normalizePath(r.URL.Path), "421", time.Since(start).Seconds())
+		}
+		writeErrorWithCode(w, http.StatusMisdirectedRequest, "unknown host", "UNTRUSTED_HOST")
+		return
+	}
 
 	// Prevent search engine indexing
 	w.Header().Set("X-Robots-Tag", "noindex, nofollow")
 
-	// Set security headers (CSP, X-Frame-Options, etc.)
-	setSecurityHeaders(w)
+	// Set security headers (CSP, X-Frame-Options, etc.). The fresh nonce
+	// is only added to the CSP by serveIndexWithNonce, once it has stamped
+	// the dashboard's inline scripts with it.
+	nonce, err := generateNonce()
+	if err != nil {
+		slog.Warn("failed to generate CSP nonce", "error", err)
+	}
+	setSecurityHeaders(w, s.contentSecurityPolicy())
+	r = withCSPNonce(r, nonce)
 
 	// Ensure CSRF cookie is set for all requests
 	if _, err := ensureCSRFCookie(w, r); err != nil {
@@ -108,7 +229,7 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if s.rateLimiter.enabled {
 		ip := extractIP(r)
 		if !s.rateLimiter.Allow(ip) {
-			slog.Debug("rate limit exceeded", "ip", ip, "path", r.URL.Path)
+			slog.Debug("rate limit exceeded", "ip", ip, "path", r.URL.Path, "request_id", requestID(r))
 			if s.metrics != nil {
 				s.metrics.RecordHTTPRequest(r.Method, r.URL.Path, "429", time.Since(start).Seconds())
 			}
@@ -133,6 +254,8 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	wrapped := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
 	s.mux.ServeHTTP(wrapped, r)
 
+	s.accessLog.log(r, wrapped.statusCode, time.Since(start))
+
 	// Record metrics (skip /metrics endpoint to avoid self-referential metrics)
 	if s.metrics != nil && r.URL.Path != "/metrics" {
 		s.metrics.RecordHTTPRequest(r.Method, normalizePath(r.URL.Path), strconv.Itoa(wrapped.statusCode), time.Since(start).Seconds())
@@ -186,21 +309,57 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	dbStatus := "connected"
 	httpStatus := http.StatusOK
 
+	diskStatus := "ok"
+
 	if err := s.store.Ping(ctx); err != nil {
 		status = "error"
 		dbStatus = "disconnected"
 		httpStatus = http.StatusServiceUnavailable
 	}
 
+	// Low disk space pauses ingest but the dashboard keeps serving
+	if s.store.DiskSpaceLow() {
+		diskStatus = "low"
+		if status == "ok" {
+			status = "degraded"
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(httpStatus)
 	_ = json.NewEncoder(w).Encode(map[string]any{
 		"status":  status,
 		"db":      dbStatus,
+		"disk":    diskStatus,
 		"version": version.Version,
 	})
 }
 
+func (s *Server) handleVersion(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]string{
+		"version":    version.Version,
+		"git_commit": version.GitCommit,
+		"build_time": version.BuildTime,
+	})
+}
+
+// handleAPIInfo answers / on headless deployments (SERVE_DASHBOARD=false)
+// with pointers to the API instead of a file server 404.
+func (s *Server) handleAPIInfo(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		writeErrorWithCode(w, http.StatusNotFound, "not found", "NOT_FOUND")
+		return
+	}
+	writeJSON(w, map[string]any{
+		"name":      "caddystat",
+		"version":   version.Version,
+		"dashboard": false,
+		"api":       "/api/stats/",
+		"openapi":   "/api/openapi.json",
+		"health":    "/health",
+	})
+}
+
 func (s *Server) handleRobotsTxt(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/plain")
 	_, _ = w.Write([]byte("User-agent: *\nDisallow: /\n"))
@@ -274,6 +433,23 @@ func (s *Server) requireAuth(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// requireAdmin rejects sessions restricted to specific sites. Use it after
+// requireAuth on endpoints that expose server-wide details.
+func (s *Server) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		hosts, err := s.permittedHosts(r)
+		if err != nil {
+			writeInternalError(w, r, err, "get session permissions")
+			return
+		}
+		if hosts != nil {
+			writeErrorWithCode(w, http.StatusForbidden, "admin access required", "ADMIN_REQUIRED")
+			return
+		}
+		next(w, r)
+	}
+}
+
 // requireSitePermission wraps a handler to check if the session has permission to access
 // the requested site (determined by the "host" query parameter).
 // If no host is specified, it allows access (aggregate view).
@@ -304,7 +480,7 @@ func (s *Server) requireSitePermission(next http.HandlerFunc) http.HandlerFunc {
 		hasPermission, err := s.store.HasSitePermission(r.Context(), cookie.Value, host)
 		if err != nil {
 			slog.Warn("failed to check site permission", "error", err)
-			writeInternalError(w, err, "check site permission")
+			writeInternalError(w, r, err, "check site permission")
 			return
 		}
 
@@ -317,6 +493,26 @@ func (s *Server) requireSitePermission(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// permittedHosts returns the hosts the request may read when no single host
+// is requested: nil means every host, an empty slice means none.
+func (s *Server) permittedHosts(r *http.Request) ([]string, error) {
+	if !s.cfg.AuthEnabled() {
+		return nil, nil
+	}
+	cookie, err := r.Cookie(sessionCookieName)
+	if err != nil {
+		return []string{}, nil
+	}
+	perms, err := s.store.GetSessionPermissions(r.Context(), cookie.Value)
+	if err != nil {
+		return nil, err
+	}
+	if perms.AllSites {
+		return nil, nil
+	}
+	return append([]string{}, perms.AllowedHosts...), nil
+}
+
 func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		writeErrorWithCode(w, http.StatusMethodNotAllowed, "method not allowed", "METHOD_NOT_ALLOWED")
@@ -350,7 +546,7 @@ func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 
 	token, err := s.createSession(r.Context())
 	if err != nil {
-		writeInternalError(w, err, "create session")
+		writeInternalError(w, r, err, "create session")
 		return
 	}
 
@@ -432,58 +628,93 @@ func (s *Server) handleAuthCheck(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// statsFilter reads the host, country and exclude_internal query parameters.
+// It writes a 400 and returns false if the country isn't a two-letter code.
+func statsFilter(w http.ResponseWriter, r *http.Request) (storage.StatsFilter, bool) {
+	country, err := storage.ParseCountry(r.URL.Query().Get("country"))
+	if err != nil {
+		writeErrorWithCode(w, http.StatusBadRequest, "country must be a two-letter code such as DE", "INVALID_COUNTRY")
+		return storage.StatsFilter{}, false
+	}
+	return storage.StatsFilter{
+		Host:            r.URL.Query().Get("host"),
+		Country:         country,
+		ExcludeInternal: r.URL.Query().Get("exclude_internal") == "true",
+	}, true
+}
+
 func (s *Server) handleSummary(w http.ResponseWriter, r *http.Request) {
-	dur := parseRange(r.URL.Query().Get("range"), 24*time.Hour)
-	host := r.URL.Query().Get("host")
-	stats, err := s.store.Summary(r.Context(), dur, host)
+	dur := parseRange(r.URL.Query().Get("range"), s.cfg.DefaultRange)
+	filter, ok := statsFilter(w, r)
+	if !ok {
+		return
+	}
+	stats, err := s.store.SummaryFiltered(r.Context(), dur, filter)
 	if err != nil {
-		writeInternalError(w, err, "get summary")
+		writeInternalError(w, r, err, "get summary")
 		return
 	}
+	s.setPollInterval(w, r)
 	writeJSON(w, stats)
 }
 
 func (s *Server) handleRequests(w http.ResponseWriter, r *http.Request) {
-	dur := parseRange(r.URL.Query().Get("range"), 24*time.Hour)
-	host := r.URL.Query().Get("host")
-	stats, err := s.store.TimeSeriesRange(r.Context(), dur, host)
+	dur := parseRange(r.URL.Query().Get("range"), s.cfg.DefaultRange)
+	filter, ok := statsFilter(w, r)
+	if !ok {
+		return
+	}
+	bucket, err := storage.ParseBucket(r.URL.Query().Get("bucket"))
+	if err != nil {
+		writeErrorWithCode(w, http.StatusBadRequest, "bucket must be minute, 5min, hour, day or auto", "INVALID_BUCKET")
+		return
+	}
+	stats, err := s.store.TimeSeriesRangeFiltered(r.Context(), dur, filter, bucket)
 	if err != nil {
-		writeInternalError(w, err, "get requests")
+		writeInternalError(w, r, err, "get requests")
 		return
 	}
 	writeJSON(w, stats)
 }
 
 func (s *Server) handleGeo(w http.ResponseWriter, r *http.Request) {
-	dur := parseRange(r.URL.Query().Get("range"), 24*time.Hour)
+	dur := parseRange(r.URL.Query().Get("range"), s.cfg.DefaultRange)
 	host := r.URL.Query().Get("host")
 	stats, err := s.store.Geo(r.Context(), dur, host)
 	if err != nil {
-		writeInternalError(w, err, "get geo")
+		writeInternalError(w, r, err, "get geo")
 		return
 	}
 	writeJSON(w, stats)
 }
 
 func (s *Server) handleVisitors(w http.ResponseWriter, r *http.Request) {
-	dur := parseRange(r.URL.Query().Get("range"), 24*time.Hour)
-	host := r.URL.Query().Get("host")
-	limit := 20
+	dur := parseRange(r.URL.Query().Get("range"), s.cfg.DefaultRange)
+	filter, ok := statsFilter(w, r)
+	if !ok {
+		return
+	}
+	sort, err := storage.ParseVisitorSort(r.URL.Query().Get("sort"))
+	if err != nil {
+		writeErrorWithCode(w, http.StatusBadRequest, "sort must be hits, bandwidth or recent", "INVALID_SORT")
+		return
+	}
+	limit := s.cfg.DefaultTopLimit
 	if l := r.URL.Query().Get("limit"); l != "" {
 		if v, err := strconv.Atoi(l); err == nil && v > 0 {
 			limit = v
 		}
 	}
-	stats, err := s.store.Visitors(r.Context(), dur, host, limit)
+	stats, err := s.store.VisitorsFiltered(r.Context(), dur, filter, limit, sort)
 	if err != nil {
-		writeInternalError(w, err, "get visitors")
+		writeInternalError(w, r, err, "get visitors")
 		return
 	}
 	writeJSON(w, stats)
 }
 
 func (s *Server) handleBrowsers(w http.ResponseWriter, r *http.Request) {
-	dur := parseRange(r.URL.Query().Get("range"), 24*time.Hour)
+	dur := parseRange(r.URL.Query().Get("range"), s.cfg.DefaultRange)
 	host := r.URL.Query().Get("host")
 	limit := 10
 	if l := r.URL.Query().Get("limit"); l != "" {
@@ -493,14 +724,82 @@ func (s *Server) handleBrowsers(w http.ResponseWriter, r *http.Request) {
 	}
 	stats, err := s.store.Browsers(r.Context(), dur, host, limit)
 	if err != nil {
-		writeInternalError(w, err, "get browsers")
+		writeInternalError(w, r, err, "get browsers")
+		return
+	}
+	writeJSON(w, stats)
+}
+
+func (s *Server) handleDevices(w http.ResponseWriter, r *http.Request) {
+	dur := parseRange(r.URL.Query().Get("range"), s.cfg.DefaultRange)
+	host := r.URL.Query().Get("host")
+	limit := 10
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if v, err := strconv.Atoi(l); err == nil && v > 0 {
+			limit = v
+		}
+	}
+	stats, err := s.store.Devices(r.Context(), dur, host, limit)
+	if err != nil {
+		writeInternalError(w, r, err, "get devices")
+		return
+	}
+	writeJSON(w, stats)
+}
+
+func (s *Server) handleLanguages(w http.ResponseWriter, r *http.Request) {
+	dur := parseRange(r.URL.Query().Get("range"), s.cfg.DefaultRange)
+	host := r.URL.Query().Get("host")
+	limit := 10
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if v, err := strconv.Atoi(l); err == nil && v > 0 {
+			limit = v
+		}
+	}
+	stats, err := s.store.Languages(r.Context(), dur, host, limit)
+	if err != nil {
+		writeInternalError(w, r, err, "get languages")
+		return
+	}
+	writeJSON(w, stats)
+}
+
+func (s *Server) handleUserAgents(w http.ResponseWriter, r *http.Request) {
+	dur := parseRange(r.URL.Query().Get("range"), s.cfg.DefaultRange)
+	host := r.URL.Query().Get("host")
+	limit := s.cfg.DefaultTopLimit
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if v, err := strconv.Atoi(l); err == nil && v > 0 {
+			limit = v
+		}
+	}
+	stats, err := s.store.TopUserAgents(r.Context(), dur, host, limit)
+	if err != nil {
+		writeInternalError(w, r, err, "get user agents")
+		return
+	}
+	writeJSON(w, stats)
+}
+
+func (s *Server) handleUnclassified(w http.ResponseWriter, r *http.Request) {
+	dur := parseRange(r.URL.Query().Get("range"), s.cfg.DefaultRange)
+	host := r.URL.Query().Get("host")
+	limit := s.cfg.DefaultTopLimit
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if v, err := strconv.Atoi(l); err == nil && v > 0 {
+			limit = v
+		}
+	}
+	stats, err := s.store.UnclassifiedUserAgents(r.Context(), dur, host, limit)
+	if err != nil {
+		writeInternalError(w, r, err, "get unclassified user agents")
 		return
 	}
 	writeJSON(w, stats)
 }
 
 func (s *Server) handleOS(w http.ResponseWriter, r *http.Request) {
-	dur := parseRange(r.URL.Query().Get("range"), 24*time.Hour)
+	dur := parseRange(r.URL.Query().Get("range"), s.cfg.DefaultRange)
 	host := r.URL.Query().Get("host")
 	limit := 10
 	if l := r.URL.Query().Get("limit"); l != "" {
@@ -510,16 +809,16 @@ func (s *Server) handleOS(w http.ResponseWriter, r *http.Request) {
 	}
 	stats, err := s.store.OperatingSystems(r.Context(), dur, host, limit)
 	if err != nil {
-		writeInternalError(w, err, "get operating systems")
+		writeInternalError(w, r, err, "get operating systems")
 		return
 	}
 	writeJSON(w, stats)
 }
 
 func (s *Server) handleRobots(w http.ResponseWriter, r *http.Request) {
-	dur := parseRange(r.URL.Query().Get("range"), 24*time.Hour)
+	dur := parseRange(r.URL.Query().Get("range"), s.cfg.DefaultRange)
 	host := r.URL.Query().Get("host")
-	limit := 20
+	limit := s.cfg.DefaultTopLimit
 	if l := r.URL.Query().Get("limit"); l != "" {
 		if v, err := strconv.Atoi(l); err == nil && v > 0 {
 			limit = v
@@ -527,16 +826,27 @@ func (s *Server) handleRobots(w http.ResponseWriter, r *http.Request) {
 	}
 	stats, err := s.store.Robots(r.Context(), dur, host, limit)
 	if err != nil {
-		writeInternalError(w, err, "get robots")
+		writeInternalError(w, r, err, "get robots")
+		return
+	}
+	writeJSON(w, stats)
+}
+
+func (s *Server) handleBotVerification(w http.ResponseWriter, r *http.Request) {
+	dur := parseRange(r.URL.Query().Get("range"), s.cfg.DefaultRange)
+	host := r.URL.Query().Get("host")
+	stats, err := s.store.BotVerification(r.Context(), dur, host)
+	if err != nil {
+		writeInternalError(w, r, err, "get bot verification")
 		return
 	}
 	writeJSON(w, stats)
 }
 
 func (s *Server) handleReferrers(w http.ResponseWriter, r *http.Request) {
-	dur := parseRange(r.URL.Query().Get("range"), 24*time.Hour)
+	dur := parseRange(r.URL.Query().Get("range"), s.cfg.DefaultRange)
 	host := r.URL.Query().Get("host")
-	limit := 20
+	limit := s.cfg.DefaultTopLimit
 	if l := r.URL.Query().Get("limit"); l != "" {
 		if v, err := strconv.Atoi(l); err == nil && v > 0 {
 			limit = v
@@ -544,7 +854,7 @@ func (s *Server) handleReferrers(w http.ResponseWriter, r *http.Request) {
 	}
 	stats, err := s.store.Referrers(r.Context(), dur, host, limit)
 	if err != nil {
-		writeInternalError(w, err, "get referrers")
+		writeInternalError(w, r, err, "get referrers")
 		return
 	}
 	writeJSON(w, stats)
@@ -552,17 +862,43 @@ func (s *Server) handleReferrers(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) handleRecentRequests(w http.ResponseWriter, r *http.Request) {
 	host := r.URL.Query().Get("host")
-	limit := 20
+	limit := s.cfg.DefaultRecentLimit
 	if l := r.URL.Query().Get("limit"); l != "" {
-		if v, err := strconv.Atoi(l); err == nil && v > 0 && v <= 100 {
-			limit = v
+		if v, err := strconv.Atoi(l); err == nil && v > 0 {
+			limit = min(v, s.cfg.MaxRecentRequests)
 		}
 	}
-	stats, err := s.store.RecentRequests(r.Context(), limit, host)
+	var beforeID int64
+	if b := r.URL.Query().Get("before_id"); b != "" {
+		v, err := strconv.ParseInt(b, 10, 64)
+		if err != nil || v <= 0 {
+			writeErrorWithCode(w, http.StatusBadRequest, "before_id must be a positive integer", "INVALID_CURSOR")
+			return
+		}
+		beforeID = v
+	}
+	var filter storage.RecentFilter
+	var err error
+	filter.Status, err = storage.ParseStatusFilter(r.URL.Query().Get("status"))
+	if err != nil {
+		writeErrorWithCode(w, http.StatusBadRequest, err.Error(), "INVALID_STATUS")
+		return
+	}
+	filter.PathContains = r.URL.Query().Get("path")
+	if len(filter.PathContains) > storage.MaxPathSearchLength {
+		writeErrorWithCode(w, http.StatusBadRequest, fmt.Sprintf("path must be at most %d bytes", storage.MaxPathSearchLength), "INVALID_PATH")
+		return
+	}
+	stats, err := s.store.RecentRequestsBefore(r.Context(), limit, host, beforeID, filter)
 	if err != nil {
-		writeInternalError(w, err, "get recent requests")
+		writeInternalError(w, r, err, "get recent requests")
 		return
 	}
+	// A full page may have more rows behind it; hand back the cursor for
+	// the next one. The body stays a plain array for existing clients.
+	if len(stats) == limit {
+		w.Header().Set("X-Next-Before-Id", strconv.FormatInt(stats[len(stats)-1].ID, 10))
+	}
 	writeJSON(w, stats)
 }
 
@@ -576,7 +912,23 @@ func (s *Server) handleMonthly(w http.ResponseWriter, r *http.Request) {
 	}
 	stats, err := s.store.MonthlyHistory(r.Context(), months, host)
 	if err != nil {
-		writeInternalError(w, err, "get monthly history")
+		writeInternalError(w, r, err, "get monthly history")
+		return
+	}
+	writeJSON(w, stats)
+}
+
+func (s *Server) handleWeekly(w http.ResponseWriter, r *http.Request) {
+	host := r.URL.Query().Get("host")
+	weeks := 12
+	if v := r.URL.Query().Get("weeks"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			weeks = n
+		}
+	}
+	stats, err := s.store.WeeklyHistory(r.Context(), weeks, host)
+	if err != nil {
+		writeInternalError(w, r, err, "get weekly history")
 		return
 	}
 	writeJSON(w, stats)
@@ -586,7 +938,7 @@ func (s *Server) handleDaily(w http.ResponseWriter, r *http.Request) {
 	host := r.URL.Query().Get("host")
 	stats, err := s.store.DailyHistory(r.Context(), host)
 	if err != nil {
-		writeInternalError(w, err, "get daily history")
+		writeInternalError(w, r, err, "get daily history")
 		return
 	}
 	writeJSON(w, stats)
@@ -595,25 +947,136 @@ func (s *Server) handleDaily(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
 	status, err := s.store.GetSystemStatus(r.Context())
 	if err != nil {
-		writeInternalError(w, err, "get system status")
+		writeInternalError(w, r, err, "get system status")
 		return
 	}
-	writeJSON(w, status)
+	resp := systemStatusResponse{SystemStatus: status, LogLevel: logging.CurrentLevel().String()}
+	if s.ingester != nil {
+		resp.IngestQueueDepth = s.ingester.QueueDepth()
+	}
+	resp.PollIntervalSeconds = s.setPollInterval(w, r)
+	writeJSON(w, resp)
 }
 
 func (s *Server) handlePerformance(w http.ResponseWriter, r *http.Request) {
-	dur := parseRange(r.URL.Query().Get("range"), 24*time.Hour)
+	dur := parseRange(r.URL.Query().Get("range"), s.cfg.DefaultRange)
 	host := r.URL.Query().Get("host")
 	stats, err := s.store.PerformanceStats(r.Context(), dur, host)
 	if err != nil {
-		writeInternalError(w, err, "get performance stats")
+		writeInternalError(w, r, err, "get performance stats")
+		return
+	}
+	writeJSON(w, stats)
+}
+
+func (s *Server) handleMethods(w http.ResponseWriter, r *http.Request) {
+	dur := parseRange(r.URL.Query().Get("range"), s.cfg.DefaultRange)
+	host := r.URL.Query().Get("host")
+	stats, err := s.store.Methods(r.Context(), dur, host)
+	if err != nil {
+		writeInternalError(w, r, err, "get methods")
+		return
+	}
+	writeJSON(w, stats)
+}
+
+func (s *Server) handleProtocols(w http.ResponseWriter, r *http.Request) {
+	dur := parseRange(r.URL.Query().Get("range"), s.cfg.DefaultRange)
+	host := r.URL.Query().Get("host")
+	stats, err := s.store.Protocols(r.Context(), dur, host)
+	if err != nil {
+		writeInternalError(w, r, err, "get protocols")
+		return
+	}
+	writeJSON(w, stats)
+}
+
+func (s *Server) handleRealtime(w http.ResponseWriter, r *http.Request) {
+	host := r.URL.Query().Get("host")
+	minutes := 30
+	if v := r.URL.Query().Get("minutes"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			minutes = n
+		}
+	}
+	stats, err := s.store.Realtime(r.Context(), minutes, host)
+	if err != nil {
+		writeInternalError(w, r, err, "get realtime stats")
+		return
+	}
+	writeJSON(w, stats)
+}
+
+func (s *Server) handleOnline(w http.ResponseWriter, r *http.Request) {
+	window := parseRange(r.URL.Query().Get("window"), 5*time.Minute)
+	if window <= 0 {
+		window = 5 * time.Minute
+	}
+	host := r.URL.Query().Get("host")
+	n, err := s.store.ActiveVisitors(r.Context(), window, host)
+	if err != nil {
+		writeInternalError(w, r, err, "get active visitors")
+		return
+	}
+	writeJSON(w, storage.OnlineStats{ActiveVisitors: n, WindowSeconds: int64(window / time.Second)})
+}
+
+func (s *Server) handlePeak(w http.ResponseWriter, r *http.Request) {
+	dur := parseRange(r.URL.Query().Get("range"), s.cfg.DefaultRange)
+	host := r.URL.Query().Get("host")
+	bucket := time.Second
+	switch r.URL.Query().Get("bucket") {
+	case "", "second":
+	case "minute":
+		bucket = time.Minute
+	default:
+		writeErrorWithCode(w, http.StatusBadRequest, "bucket must be second or minute", "INVALID_BUCKET")
+		return
+	}
+	stats, err := s.store.PeakTraffic(r.Context(), dur, host, bucket)
+	if err != nil {
+		writeInternalError(w, r, err, "get peak traffic")
+		return
+	}
+	writeJSON(w, stats)
+}
+
+func (s *Server) handlePathGroups(w http.ResponseWriter, r *http.Request) {
+	dur := parseRange(r.URL.Query().Get("range"), s.cfg.DefaultRange)
+	host := r.URL.Query().Get("host")
+	depth := 1
+	if v := r.URL.Query().Get("depth"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			depth = n
+		}
+	}
+	limit := s.cfg.DefaultTopLimit
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if v, err := strconv.Atoi(l); err == nil && v > 0 {
+			limit = v
+		}
+	}
+	stats, err := s.store.PathGroups(r.Context(), dur, host, depth, limit)
+	if err != nil {
+		writeInternalError(w, r, err, "get path groups")
+		return
+	}
+	writeJSON(w, stats)
+}
+
+func (s *Server) handleCache(w http.ResponseWriter, r *http.Request) {
+	dur := parseRange(r.URL.Query().Get("range"), s.cfg.DefaultRange)
+	host := r.URL.Query().Get("host")
+	stats, err := s.store.CacheStats(r.Context(), dur, host)
+	if err != nil {
+		writeInternalError(w, r, err, "get cache stats")
 		return
 	}
 	writeJSON(w, stats)
 }
 
 func (s *Server) handleBandwidth(w http.ResponseWriter, r *http.Request) {
-	dur := parseRange(r.URL.Query().Get("range"), 24*time.Hour)
+	dur := parseRange(r.URL.Query().Get("range"), s.cfg.DefaultRange)
 	host := r.URL.Query().Get("host")
 	limit := 10
 	if l := r.URL.Query().Get("limit"); l != "" {
@@ -623,14 +1086,230 @@ func (s *Server) handleBandwidth(w http.ResponseWriter, r *http.Request) {
 	}
 	stats, err := s.store.BandwidthStats(r.Context(), dur, host, limit)
 	if err != nil {
-		writeInternalError(w, err, "get bandwidth stats")
+		writeInternalError(w, r, err, "get bandwidth stats")
+		return
+	}
+	writeJSON(w, stats)
+}
+
+func (s *Server) handleBandwidthCountries(w http.ResponseWriter, r *http.Request) {
+	dur := parseRange(r.URL.Query().Get("range"), s.cfg.DefaultRange)
+	host := r.URL.Query().Get("host")
+	limit := 10
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if v, err := strconv.Atoi(l); err == nil && v > 0 {
+			limit = v
+		}
+	}
+	stats, err := s.store.BandwidthByCountry(r.Context(), dur, host, limit)
+	if err != nil {
+		writeInternalError(w, r, err, "get bandwidth by country")
+		return
+	}
+	writeJSON(w, stats)
+}
+
+func (s *Server) handleDistinctPaths(w http.ResponseWriter, r *http.Request) {
+	dur := parseRange(r.URL.Query().Get("range"), s.cfg.DefaultRange)
+	host := r.URL.Query().Get("host")
+	n, err := s.store.DistinctPaths(r.Context(), dur, host)
+	if err != nil {
+		writeInternalError(w, r, err, "get distinct paths")
+		return
+	}
+	writeJSON(w, distinctPathsResponse{DistinctPaths: n})
+}
+
+func (s *Server) handleSLO(w http.ResponseWriter, r *http.Request) {
+	dur := parseRange(r.URL.Query().Get("range"), s.cfg.DefaultRange)
+	host := r.URL.Query().Get("host")
+	objective := s.cfg.SLOObjective
+	if v := r.URL.Query().Get("objective"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f <= 0 || f > 100 {
+			writeErrorWithCode(w, http.StatusBadRequest, "objective must be a percentage above 0 and at most 100", "INVALID_OBJECTIVE")
+			return
+		}
+		objective = f
+	}
+	latency := s.cfg.SLOLatencyTargetMs
+	if v := r.URL.Query().Get("latency_ms"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeErrorWithCode(w, http.StatusBadRequest, "latency_ms must be a non-negative integer", "INVALID_LATENCY")
+			return
+		}
+		latency = n
+	}
+	stats, err := s.store.SLO(r.Context(), dur, host, latency, objective)
+	if err != nil {
+		writeInternalError(w, r, err, "get slo")
+		return
+	}
+	writeJSON(w, stats)
+}
+
+func (s *Server) handleDownloads(w http.ResponseWriter, r *http.Request) {
+	dur := parseRange(r.URL.Query().Get("range"), s.cfg.DefaultRange)
+	host := r.URL.Query().Get("host")
+	limit := 10
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if v, err := strconv.Atoi(l); err == nil && v > 0 {
+			limit = v
+		}
+	}
+	stats, err := s.store.TopDownloads(r.Context(), dur, host, limit)
+	if err != nil {
+		writeInternalError(w, r, err, "get top downloads")
+		return
+	}
+	writeJSON(w, stats)
+}
+
+func (s *Server) handleZeroBytes(w http.ResponseWriter, r *http.Request) {
+	dur := parseRange(r.URL.Query().Get("range"), s.cfg.DefaultRange)
+	host := r.URL.Query().Get("host")
+	limit := s.cfg.DefaultTopLimit
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if v, err := strconv.Atoi(l); err == nil && v > 0 {
+			limit = v
+		}
+	}
+	stats, err := s.store.ZeroByteResponses(r.Context(), dur, host, limit)
+	if err != nil {
+		writeInternalError(w, r, err, "get zero-byte responses")
+		return
+	}
+	writeJSON(w, stats)
+}
+
+func (s *Server) handleRequestsByIP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	ip := net.ParseIP(strings.TrimSpace(q.Get("ip")))
+	if ip == nil {
+		writeErrorWithCode(w, http.StatusBadRequest, "ip must be a valid IPv4 or IPv6 address", "INVALID_IP")
+		return
+	}
+	dur := parseRange(q.Get("range"), s.cfg.DefaultRange)
+	limit := 100
+	if l := q.Get("limit"); l != "" {
+		if v, err := strconv.Atoi(l); err == nil && v > 0 {
+			limit = v
+		}
+	}
+	offset := 0
+	if o := q.Get("offset"); o != "" {
+		if v, err := strconv.Atoi(o); err == nil && v >= 0 {
+			offset = v
+		}
+	}
+
+	// requireSitePermission has already checked an explicit host; otherwise
+	// limit results to the hosts this session may see.
+	var hosts []string
+	if host := q.Get("host"); host != "" {
+		hosts = []string{host}
+	} else {
+		var err error
+		if hosts, err = s.permittedHosts(r); err != nil {
+			writeInternalError(w, r, err, "get session permissions")
+			return
+		}
+	}
+
+	requests, err := s.store.RequestsByIP(r.Context(), ip.String(), dur, limit, offset, hosts)
+	if err != nil {
+		writeInternalError(w, r, err, "get requests by IP")
+		return
+	}
+	writeJSON(w, requests)
+}
+
+func (s *Server) handleKnownHosts(w http.ResponseWriter, r *http.Request) {
+	dur := parseRange(r.URL.Query().Get("range"), s.cfg.DefaultRange)
+	hosts, err := s.permittedHosts(r)
+	if err != nil {
+		writeInternalError(w, r, err, "get session permissions")
+		return
+	}
+	known, err := s.store.KnownHosts(r.Context(), dur, hosts)
+	if err != nil {
+		writeInternalError(w, r, err, "get known hosts")
+		return
+	}
+	writeJSON(w, known)
+}
+
+func (s *Server) handleTotals(w http.ResponseWriter, r *http.Request) {
+	dur := parseRange(r.URL.Query().Get("range"), s.cfg.DefaultRange)
+	hosts, err := s.permittedHosts(r)
+	if err != nil {
+		writeInternalError(w, r, err, "get session permissions")
+		return
+	}
+	totals, err := s.store.GrandTotals(r.Context(), dur, hosts)
+	if err != nil {
+		writeInternalError(w, r, err, "get grand totals")
+		return
+	}
+	writeJSON(w, totals)
+}
+
+func (s *Server) handleHostActivity(w http.ResponseWriter, r *http.Request) {
+	hosts, err := s.permittedHosts(r)
+	if err != nil {
+		writeInternalError(w, r, err, "get session permissions")
+		return
+	}
+	activity, err := s.store.HostActivity(r.Context(), hosts)
+	if err != nil {
+		writeInternalError(w, r, err, "get host activity")
+		return
+	}
+	writeJSON(w, activity)
+}
+
+func (s *Server) handleErrorIPs(w http.ResponseWriter, r *http.Request) {
+	dur := parseRange(r.URL.Query().Get("range"), s.cfg.DefaultRange)
+	host := r.URL.Query().Get("host")
+	limit := s.cfg.DefaultTopLimit
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if v, err := strconv.Atoi(l); err == nil && v > 0 {
+			limit = v
+		}
+	}
+	excludeBots := r.URL.Query().Get("exclude_bots") == "true"
+	stats, err := s.store.TopErrorIPs(r.Context(), dur, host, limit, excludeBots)
+	if err != nil {
+		writeInternalError(w, r, err, "get error IPs")
+		return
+	}
+	writeJSON(w, stats)
+}
+
+func (s *Server) handleScans(w http.ResponseWriter, r *http.Request) {
+	dur := parseRange(r.URL.Query().Get("range"), s.cfg.DefaultRange)
+	host := r.URL.Query().Get("host")
+	limit := s.cfg.DefaultTopLimit
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if v, err := strconv.Atoi(l); err == nil && v > 0 {
+			limit = v
+		}
+	}
+	patterns := s.cfg.ScannerPatterns
+	if len(patterns) == 0 {
+		patterns = config.DefaultScannerPatterns
+	}
+	stats, err := s.store.ScannerPaths(r.Context(), dur, host, limit, patterns)
+	if err != nil {
+		writeInternalError(w, r, err, "get scanner paths")
 		return
 	}
 	writeJSON(w, stats)
 }
 
 func (s *Server) handleSessions(w http.ResponseWriter, r *http.Request) {
-	dur := parseRange(r.URL.Query().Get("range"), 24*time.Hour)
+	dur := parseRange(r.URL.Query().Get("range"), s.cfg.DefaultRange)
 	host := r.URL.Query().Get("host")
 	limit := 50
 	if l := r.URL.Query().Get("limit"); l != "" {
@@ -647,7 +1326,7 @@ func (s *Server) handleSessions(w http.ResponseWriter, r *http.Request) {
 	}
 	sessions, err := s.store.VisitorSessions(r.Context(), dur, host, limit, sessionTimeout)
 	if err != nil {
-		writeInternalError(w, err, "get visitor sessions")
+		writeInternalError(w, r, err, "get visitor sessions")
 		return
 	}
 	writeJSON(w, sessions)
@@ -664,7 +1343,7 @@ func (s *Server) handleSSE(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Connection", "keep-alive")
 
 	host := r.URL.Query().Get("host")
-	dur := parseRange(r.URL.Query().Get("range"), 24*time.Hour)
+	dur := parseRange(r.URL.Query().Get("range"), s.cfg.DefaultRange)
 
 	ch, cancel := s.hub.Subscribe()
 	if ch == nil {
@@ -683,22 +1362,34 @@ func (s *Server) handleSSE(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Also send initial recent requests
-	if recent, err := s.store.RecentRequests(r.Context(), 20, host); err == nil {
+	if recent, err := s.recentSnapshot(r.Context(), sseRecentLimit, host); err == nil {
 		if buf, err := json.Marshal(recent); err == nil {
 			writeSSE(w, "recent", buf)
 			flusher.Flush()
 		}
 	}
 
+	// And the current "online now" count; updates arrive as online events
+	s.writeOnlineSSE(w, r, host)
+	flusher.Flush()
+
 	for {
 		select {
 		case <-r.Context().Done():
 			return
 		case evt := <-ch:
-			if evt.Type == "request" {
+			if evt.Type == sse.RequestEvent {
 				// New request event - send directly
 				writeSSE(w, "request", evt.Payload)
 				flusher.Flush()
+			} else if evt.Type == sse.OnlineEvent {
+				// The payload is site-wide; recount for a single site
+				if host == "" {
+					writeSSE(w, sse.OnlineEvent, evt.Payload)
+				} else {
+					s.writeOnlineSSE(w, r, host)
+				}
+				flusher.Flush()
 			} else {
 				// Summary update - re-fetch with host filter
 				if summary, err := s.store.Summary(r.Context(), dur, host); err == nil {
@@ -712,6 +1403,55 @@ func (s *Server) handleSSE(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// sseRecentLimit is how many recent requests a new SSE client is sent.
+const sseRecentLimit = 20
+
+// recentSnapshot returns the newest limit requests to host (all hosts when
+// empty) from the last 24 hours, newest first. It is served from the hub's
+// in-memory buffer when that holds enough matching requests and falls back
+// to the database otherwise, such as after a restart or for quiet hosts.
+// The buffer matches the logged host exactly, so a site with aliases is
+// always read from the database, which folds the aliases in.
+func (s *Server) recentSnapshot(ctx context.Context, limit int, host string) ([]storage.RecentRequest, error) {
+	if host != "" {
+		if site, err := s.store.GetSiteByHost(ctx, host); err == nil && site != nil && len(site.Aliases) > 0 {
+			return s.recent.RecentRequests(ctx, limit, host)
+		}
+	}
+	since := time.Now().Add(-24 * time.Hour)
+	out := make([]storage.RecentRequest, 0, limit)
+	for _, payload := range s.hub.Recent() {
+		var req storage.RecentRequest
+		if err := json.Unmarshal(payload, &req); err != nil {
+			continue
+		}
+		if req.Timestamp.Before(since) {
+			break
+		}
+		if host != "" && req.Host != host {
+			continue
+		}
+		out = append(out, req)
+		if len(out) == limit {
+			return out, nil
+		}
+	}
+	return s.recent.RecentRequests(ctx, limit, host)
+}
+
+// writeOnlineSSE writes an online event with the active-visitor count for
+// host over the default window.
+func (s *Server) writeOnlineSSE(w http.ResponseWriter, r *http.Request, host string) {
+	const window = 5 * time.Minute
+	n, err := s.store.ActiveVisitors(r.Context(), window, host)
+	if err != nil {
+		return
+	}
+	if buf, err := json.Marshal(storage.OnlineStats{ActiveVisitors: n, WindowSeconds: int64(window / time.Second)}); err == nil {
+		writeSSE(w, sse.OnlineEvent, buf)
+	}
+}
+
 func writeSSE(w http.ResponseWriter, eventType string, payload []byte) {
 	if eventType != "" {
 		_, _ = w.Write([]byte("event: "))
@@ -735,22 +1475,33 @@ type APIError struct {
 	Error   string `json:"error"`
 	Code    string `json:"code,omitempty"`
 	Details string `json:"details,omitempty"`
+	// RequestID echoes the X-Request-ID response header.
+	RequestID string `json:"request_id,omitempty"`
 }
 
 // writeErrorWithCode writes a structured JSON error response with a machine-readable error code.
+// The request's correlation ID is taken from the X-Request-ID header that
+// assignRequestID already set on w.
 func writeErrorWithCode(w http.ResponseWriter, statusCode int, message, code string) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
-	if err := json.NewEncoder(w).Encode(APIError{Error: message, Code: code}); err != nil {
+	body := APIError{Error: message, Code: code, RequestID: w.Header().Get(requestIDHeader)}
+	if err := json.NewEncoder(w).Encode(body); err != nil {
 		slog.Warn("failed to write JSON error response", "error", err)
 	}
 }
 
 // writeInternalError writes an internal server error, logging the original error
-// while returning a generic message to the client.
-func writeInternalError(w http.ResponseWriter, err error, context string) {
-	slog.Error("internal error", "context", context, "error", err)
-	writeErrorWithCode(w, http.StatusInternalServerError, "internal server error", "INTERNAL_ERROR")
+// while returning a generic message to the client. Both carry the request's
+// correlation ID so a reported failure can be found in the logs.
+func writeInternalError(w http.ResponseWriter, r *http.Request, err error, context string) {
+	id := requestID(r)
+	slog.Error("internal error", "context", context, "error", err, "request_id", id)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusInternalServerError)
+	if err := json.NewEncoder(w).Encode(APIError{Error: "internal server error", Code: "INTERNAL_ERROR", RequestID: id}); err != nil {
+		slog.Warn("failed to write JSON error response", "error", err)
+	}
 }
 
 func parseRange(val string, def time.Duration) time.Duration {
@@ -764,56 +1515,50 @@ func parseRange(val string, def time.Duration) time.Duration {
 }
 
 func (s *Server) handleExportCSV(w http.ResponseWriter, r *http.Request) {
-	dur := parseRange(r.URL.Query().Get("range"), 24*time.Hour)
+	dur := parseRange(r.URL.Query().Get("range"), s.cfg.DefaultRange)
 	host := r.URL.Query().Get("host")
+	delim, bom, ok := csvOptions(r.URL.Query())
+	if !ok {
+		writeErrorWithCode(w, http.StatusBadRequest, "delimiter must be comma, semicolon or tab", "INVALID_DELIMITER")
+		return
+	}
+	cols, err := exportFields(r.URL.Query().Get("fields"))
+	if err != nil {
+		writeErrorWithCode(w, http.StatusBadRequest, err.Error(), "INVALID_FIELDS")
+		return
+	}
+
+	addRows, done := s.trackExport(w, r)
+	defer done()
 
 	w.Header().Set("Content-Type", "text/csv")
 	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=caddystat-export-%s.csv", time.Now().Format("2006-01-02")))
+	if bom {
+		if _, err := io.WriteString(w, utf8BOM); err != nil {
+			return
+		}
+	}
 
 	csvWriter := csv.NewWriter(w)
+	csvWriter.Comma = delim
 	defer csvWriter.Flush()
 
 	// Write header
-	header := []string{
-		"id", "timestamp", "host", "path", "status", "bytes", "ip", "referrer", "user_agent",
-		"response_time_ms", "country", "region", "city", "browser", "browser_version",
-		"os", "os_version", "device_type", "is_bot", "bot_name",
-	}
-	if err := csvWriter.Write(header); err != nil {
+	if err := csvWriter.Write(exportHeader(cols)); err != nil {
 		// At this point content type is already set to CSV, can't return JSON error
 		slog.Warn("failed to write CSV header", "error", err)
 		return
 	}
 
-	err := s.store.ExportRequests(r.Context(), dur, host, 1000, func(requests []storage.ExportRequest) error {
+	err = s.store.ExportRequests(r.Context(), dur, host, 1000, func(requests []storage.ExportRequest) error {
 		for _, req := range requests {
-			record := []string{
-				strconv.FormatInt(req.ID, 10),
-				req.Timestamp.Format(time.RFC3339),
-				req.Host,
-				req.Path,
-				strconv.Itoa(req.Status),
-				strconv.FormatInt(req.Bytes, 10),
-				req.IP,
-				req.Referrer,
-				req.UserAgent,
-				strconv.FormatFloat(req.ResponseTimeMs, 'f', 2, 64),
-				req.Country,
-				req.Region,
-				req.City,
-				req.Browser,
-				req.BrowserVersion,
-				req.OS,
-				req.OSVersion,
-				req.DeviceType,
-				strconv.FormatBool(req.IsBot),
-				req.BotName,
-			}
-			if err := csvWriter.Write(record); err != nil {
+			if err := csvWriter.Write(exportCSVRecord(cols, req)); err != nil {
 				return err
 			}
 		}
-		return nil
+		csvWriter.Flush()
+		addRows(len(requests))
+		return csvWriter.Error()
 	})
 	if err != nil {
 		slog.Warn("failed to export CSV", "error", err)
@@ -821,8 +1566,39 @@ func (s *Server) handleExportCSV(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleExportJSON(w http.ResponseWriter, r *http.Request) {
-	dur := parseRange(r.URL.Query().Get("range"), 24*time.Hour)
+	dur := parseRange(r.URL.Query().Get("range"), s.cfg.DefaultRange)
 	host := r.URL.Query().Get("host")
+	cols, err := exportFields(r.URL.Query().Get("fields"))
+	if err != nil {
+		writeErrorWithCode(w, http.StatusBadRequest, err.Error(), "INVALID_FIELDS")
+		return
+	}
+
+	// since_id switches to an incremental export: rows stored after the
+	// client's last-seen id, in id order, optionally capped by limit.
+	export := func(fn storage.ExportRequestsCallback) error {
+		return s.store.ExportRequests(r.Context(), dur, host, 1000, fn)
+	}
+	if v := r.URL.Query().Get("since_id"); v != "" {
+		sinceID, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || sinceID < 0 {
+			writeErrorWithCode(w, http.StatusBadRequest, "since_id must be a non-negative integer", "INVALID_SINCE_ID")
+			return
+		}
+		limit := 0
+		if l := r.URL.Query().Get("limit"); l != "" {
+			if limit, err = strconv.Atoi(l); err != nil || limit < 0 {
+				writeErrorWithCode(w, http.StatusBadRequest, "limit must be a non-negative integer", "INVALID_LIMIT")
+				return
+			}
+		}
+		export = func(fn storage.ExportRequestsCallback) error {
+			return s.store.ExportRequestsSince(r.Context(), sinceID, host, limit, fn)
+		}
+	}
+
+	addRows, done := s.trackExport(w, r)
+	defer done()
 
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=caddystat-export-%s.json", time.Now().Format("2006-01-02")))
@@ -833,7 +1609,8 @@ func (s *Server) handleExportJSON(w http.ResponseWriter, r *http.Request) {
 	}
 
 	first := true
-	err := s.store.ExportRequests(r.Context(), dur, host, 1000, func(requests []storage.ExportRequest) error {
+	var buf []byte
+	err = export(func(requests []storage.ExportRequest) error {
 		for _, req := range requests {
 			if !first {
 				if _, err := w.Write([]byte(",\n")); err != nil {
@@ -842,14 +1619,16 @@ func (s *Server) handleExportJSON(w http.ResponseWriter, r *http.Request) {
 			}
 			first = false
 
-			data, err := json.Marshal(req)
+			var err error
+			buf, err = appendExportJSON(buf[:0], cols, req)
 			if err != nil {
 				return err
 			}
-			if _, err := w.Write(data); err != nil {
+			if _, err := w.Write(buf); err != nil {
 				return err
 			}
 		}
+		addRows(len(requests))
 		return nil
 	})
 	if err != nil {
@@ -861,12 +1640,16 @@ func (s *Server) handleExportJSON(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleExportBackup(w http.ResponseWriter, r *http.Request) {
-	dbPath := s.cfg.DBPath
+	path, err := s.snapshotDB(r.Context())
+	if err != nil {
+		writeInternalError(w, r, err, "snapshot database for backup")
+		return
+	}
+	defer os.Remove(path)
 
-	// Open the database file for reading
-	file, err := os.Open(dbPath)
+	file, err := os.Open(path)
 	if err != nil {
-		writeInternalError(w, err, "open database for backup")
+		writeInternalError(w, r, err, "open database snapshot for backup")
 		return
 	}
 	defer file.Close()
@@ -874,7 +1657,7 @@ func (s *Server) handleExportBackup(w http.ResponseWriter, r *http.Request) {
 	// Get file info for size
 	info, err := file.Stat()
 	if err != nil {
-		writeInternalError(w, err, "stat database for backup")
+		writeInternalError(w, r, err, "stat database snapshot for backup")
 		return
 	}
 
@@ -887,6 +1670,147 @@ func (s *Server) handleExportBackup(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// dataDir is where backups and other auxiliary files go: DATA_DIR, or the
+// database's directory when unset.
+func (s *Server) dataDir() string {
+	if s.cfg.DataDir != "" {
+		return s.cfg.DataDir
+	}
+	return filepath.Dir(s.cfg.DBPath)
+}
+
+// snapshotDB writes a consistent copy of the database to a temp file in
+// the data directory and returns its path. The caller removes the file.
+// Copying the live file instead could miss pages still in the WAL.
+func (s *Server) snapshotDB(ctx context.Context) (string, error) {
+	dir := s.dataDir()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	f, err := os.CreateTemp(dir, "caddystat-backup-*.db")
+	if err != nil {
+		return "", err
+	}
+	path := f.Name()
+	f.Close()
+	if err := s.store.VacuumInto(ctx, path); err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	return path, nil
+}
+
+// handleLogLevel reports (GET) or changes (POST) the log level at runtime.
+func (s *Server) handleLogLevel(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var input struct {
+			Level string `json:"level"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			writeErrorWithCode(w, http.StatusBadRequest, "invalid request body", "INVALID_REQUEST")
+			return
+		}
+		level, ok := logging.LookupLevel(input.Level)
+		if !ok {
+			writeErrorWithCode(w, http.StatusBadRequest, "level must be one of debug, info, warn, error", "INVALID_LOG_LEVEL")
+			return
+		}
+		previous := logging.CurrentLevel()
+		logging.SetLevel(level)
+		slog.Info("log level changed", "from", previous.String(), "to", level.String())
+	default:
+		writeErrorWithCode(w, http.StatusMethodNotAllowed, "method not allowed", "METHOD_NOT_ALLOWED")
+		return
+	}
+	writeJSON(w, map[string]string{"level": logging.CurrentLevel().String()})
+}
+
+// handleCleanup applies data retention and vacuums the database immediately
+// instead of waiting for the next scheduled run.
+func (s *Server) handleCleanup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeErrorWithCode(w, http.StatusMethodNotAllowed, "method not allowed", "METHOD_NOT_ALLOWED")
+		return
+	}
+
+	// Don't abort a half-finished vacuum if the client goes away
+	ctx := context.WithoutCancel(r.Context())
+	result, err := s.store.RunMaintenance(ctx, s.cfg.DataRetentionDays)
+	if errors.Is(err, storage.ErrMaintenanceRunning) {
+		writeErrorWithCode(w, http.StatusConflict, "cleanup already running", "CLEANUP_RUNNING")
+		return
+	}
+	if err != nil {
+		writeInternalError(w, r, err, "run cleanup")
+		return
+	}
+	slog.Info("manual cleanup completed",
+		"total_deleted", result.Cleanup.TotalDeleted,
+		"bytes_freed", result.BytesFreed,
+	)
+	writeJSON(w, result)
+}
+
+// handleConfig returns the running configuration with secrets redacted.
+func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeErrorWithCode(w, http.StatusMethodNotAllowed, "method not allowed", "METHOD_NOT_ALLOWED")
+		return
+	}
+	writeJSON(w, s.cfg.Sanitized())
+}
+
+// handleGrowth reports recent row growth and the bytes it costs, for
+// capacity planning.
+func (s *Server) handleGrowth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeErrorWithCode(w, http.StatusMethodNotAllowed, "method not allowed", "METHOD_NOT_ALLOWED")
+		return
+	}
+	growth, err := s.store.GrowthEstimate(r.Context())
+	if err != nil {
+		writeInternalError(w, r, err, "growth estimate")
+		return
+	}
+	writeJSON(w, growth)
+}
+
+// handleReimport clears a log file's import errors and lifts its quarantine.
+// The ingestor notices within its poll interval and resumes the file from
+// its saved import progress.
+func (s *Server) handleReimport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeErrorWithCode(w, http.StatusMethodNotAllowed, "method not allowed", "METHOD_NOT_ALLOWED")
+		return
+	}
+	var input struct {
+		FilePath string `json:"file_path"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeErrorWithCode(w, http.StatusBadRequest, "invalid request body", "INVALID_REQUEST")
+		return
+	}
+	if input.FilePath == "" {
+		writeErrorWithCode(w, http.StatusBadRequest, "file_path is required", "INVALID_REQUEST")
+		return
+	}
+	wasQuarantined, err := s.store.IsQuarantined(r.Context(), input.FilePath)
+	if err != nil {
+		writeInternalError(w, r, err, "check quarantine")
+		return
+	}
+	if err := s.store.ClearImportErrors(r.Context(), input.FilePath); err != nil {
+		writeInternalError(w, r, err, "clear import errors")
+		return
+	}
+	if wasQuarantined {
+		slog.Info("log file released from quarantine", "path", input.FilePath)
+	}
+	writeJSON(w, map[string]any{"file_path": input.FilePath, "released": wasQuarantined})
+}
+
 // Site management handlers
 
 func (s *Server) handleSites(w http.ResponseWriter, r *http.Request) {
@@ -903,7 +1827,7 @@ func (s *Server) handleSites(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleListSites(w http.ResponseWriter, r *http.Request) {
 	summary, err := s.store.ListSites(r.Context())
 	if err != nil {
-		writeInternalError(w, err, "list sites")
+		writeInternalError(w, r, err, "list sites")
 		return
 	}
 	writeJSON(w, summary)
@@ -920,11 +1844,15 @@ func (s *Server) handleCreateSite(w http.ResponseWriter, r *http.Request) {
 		writeErrorWithCode(w, http.StatusBadRequest, "host is required", "MISSING_HOST")
 		return
 	}
+	if msg, code := siteInputError(input); msg != "" {
+		writeErrorWithCode(w, http.StatusBadRequest, msg, code)
+		return
+	}
 
 	// Check if site already exists
 	existing, err := s.store.GetSiteByHost(r.Context(), input.Host)
 	if err != nil {
-		writeInternalError(w, err, "check existing site")
+		writeInternalError(w, r, err, "check existing site")
 		return
 	}
 	if existing != nil {
@@ -933,8 +1861,12 @@ func (s *Server) handleCreateSite(w http.ResponseWriter, r *http.Request) {
 	}
 
 	site, err := s.store.CreateSite(r.Context(), input)
+	if errors.Is(err, storage.ErrAliasInUse) {
+		writeErrorWithCode(w, http.StatusConflict, err.Error(), "ALIAS_IN_USE")
+		return
+	}
 	if err != nil {
-		writeInternalError(w, err, "create site")
+		writeInternalError(w, r, err, "create site")
 		return
 	}
 
@@ -942,6 +1874,102 @@ func (s *Server) handleCreateSite(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, site)
 }
 
+// maxBulkSites caps the sites accepted by one POST /api/sites/bulk.
+const maxBulkSites = 1000
+
+func (s *Server) handleBulkSites(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeErrorWithCode(w, http.StatusMethodNotAllowed, "method not allowed", "METHOD_NOT_ALLOWED")
+		return
+	}
+	var inputs []storage.SiteInput
+	if err := json.NewDecoder(r.Body).Decode(&inputs); err != nil {
+		writeErrorWithCode(w, http.StatusBadRequest, "invalid request body", "INVALID_REQUEST")
+		return
+	}
+	if len(inputs) == 0 {
+		writeErrorWithCode(w, http.StatusBadRequest, "at least one site is required", "MISSING_SITES")
+		return
+	}
+	if len(inputs) > maxBulkSites {
+		writeErrorWithCode(w, http.StatusBadRequest, fmt.Sprintf("at most %d sites per request", maxBulkSites), "TOO_MANY_SITES")
+		return
+	}
+	for i, input := range inputs {
+		if !validSiteHost(input.Host) {
+			writeErrorWithCode(w, http.StatusBadRequest, fmt.Sprintf("sites[%d]: invalid host %q", i, input.Host), "INVALID_HOST")
+			return
+		}
+		if msg, code := siteInputError(input); msg != "" {
+			writeErrorWithCode(w, http.StatusBadRequest, fmt.Sprintf("sites[%d]: %s", i, msg), code)
+			return
+		}
+	}
+
+	result, err := s.store.CreateSites(r.Context(), inputs)
+	if errors.Is(err, storage.ErrAliasInUse) {
+		writeErrorWithCode(w, http.StatusConflict, err.Error(), "ALIAS_IN_USE")
+		return
+	}
+	if err != nil {
+		writeInternalError(w, r, err, "bulk create sites")
+		return
+	}
+	writeJSON(w, result)
+}
+
+// siteInputError checks the retention and aliases of a site input shared by
+// create, update and bulk create. It returns the error message and code of
+// the first problem, or an empty message when there is none.
+func siteInputError(input storage.SiteInput) (msg, code string) {
+	if input.RetentionDays < 0 {
+		return "retention_days must not be negative", "INVALID_RETENTION"
+	}
+	if alias, ok := invalidAlias(input.Aliases); ok {
+		return fmt.Sprintf("invalid alias %q", alias), "INVALID_ALIAS"
+	}
+	return "", ""
+}
+
+// invalidAlias returns the first alias that is not a valid site host.
+// Blank aliases are ignored.
+func invalidAlias(aliases []string) (string, bool) {
+	for _, alias := range aliases {
+		if alias = strings.TrimSpace(alias); alias != "" && !validSiteHost(alias) {
+			return alias, true
+		}
+	}
+	return "", false
+}
+
+// validSiteHost reports whether host is a host name or IP address, with an
+// optional port, as Caddy logs it in request.host.
+func validSiteHost(host string) bool {
+	if h, port, err := net.SplitHostPort(host); err == nil {
+		if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+			return false
+		}
+		host = h
+	}
+	if net.ParseIP(host) != nil {
+		return true
+	}
+	if host == "" || len(host) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(host, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
 func (s *Server) handleSiteByID(w http.ResponseWriter, r *http.Request) {
 	// Extract site ID from path: /api/sites/{id}
 	path := r.URL.Path
@@ -973,7 +2001,7 @@ func (s *Server) handleSiteByID(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleGetSite(w http.ResponseWriter, r *http.Request, id int64) {
 	site, err := s.store.GetSite(r.Context(), id)
 	if err != nil {
-		writeInternalError(w, err, "get site")
+		writeInternalError(w, r, err, "get site")
 		return
 	}
 	if site == nil {
@@ -989,10 +2017,18 @@ func (s *Server) handleUpdateSite(w http.ResponseWriter, r *http.Request, id int
 		writeErrorWithCode(w, http.StatusBadRequest, "invalid request body", "INVALID_REQUEST")
 		return
 	}
+	if msg, code := siteInputError(input); msg != "" {
+		writeErrorWithCode(w, http.StatusBadRequest, msg, code)
+		return
+	}
 
 	site, err := s.store.UpdateSite(r.Context(), id, input)
+	if errors.Is(err, storage.ErrAliasInUse) {
+		writeErrorWithCode(w, http.StatusConflict, err.Error(), "ALIAS_IN_USE")
+		return
+	}
 	if err != nil {
-		writeInternalError(w, err, "update site")
+		writeInternalError(w, r, err, "update site")
 		return
 	}
 	if site == nil {
@@ -1008,7 +2044,7 @@ func (s *Server) handleDeleteSite(w http.ResponseWriter, r *http.Request, id int
 			writeErrorWithCode(w, http.StatusNotFound, "site not found", "NOT_FOUND")
 			return
 		}
-		writeInternalError(w, err, "delete site")
+		writeInternalError(w, r, err, "delete site")
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
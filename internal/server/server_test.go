@@ -1,15 +1,25 @@
 package server
 
 import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/dustin/Caddystat/internal/config"
+	"github.com/dustin/Caddystat/internal/metrics"
 	"github.com/dustin/Caddystat/internal/sse"
 	"github.com/dustin/Caddystat/internal/storage"
 	"github.com/dustin/Caddystat/internal/version"
@@ -78,6 +88,67 @@ func TestHealthEndpoint_Healthy(t *testing.T) {
 	}
 }
 
+func TestVersionEndpoint(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/version", nil)
+	w := httptest.NewRecorder()
+
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var resp map[string]string
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	want := map[string]string{
+		"version":    version.Version,
+		"git_commit": version.GitCommit,
+		"build_time": version.BuildTime,
+	}
+	for k, v := range want {
+		got, ok := resp[k]
+		if !ok {
+			t.Errorf("missing field %q", k)
+		} else if got != v {
+			t.Errorf("%s = %q, want %q", k, got, v)
+		}
+	}
+}
+
+func TestHealthEndpoint_DegradedOnLowDisk(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	srv.store.SetDiskFreeFunc(func(string) (uint64, error) { return 1024, nil })
+	srv.store.MonitorDiskSpace(ctx, 1<<20, time.Hour)
+
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	var resp map[string]any
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp["status"] != "degraded" {
+		t.Errorf("expected status 'degraded', got %q", resp["status"])
+	}
+	if resp["disk"] != "low" {
+		t.Errorf("expected disk 'low', got %q", resp["disk"])
+	}
+}
+
 func TestRobotsTxt(t *testing.T) {
 	srv, cleanup := setupTestServer(t)
 	defer cleanup()
@@ -223,6 +294,256 @@ func TestExportCSV_HostFilter(t *testing.T) {
 	}
 }
 
+func TestExportCSV_SemicolonWithBOM(t *testing.T) {
+	srv, cleanup := setupTestServerWithData(t)
+	defer cleanup()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/export/csv?range=24h&delimiter=semicolon&bom=true", nil)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	body, ok := strings.CutPrefix(w.Body.String(), "\xef\xbb\xbf")
+	if !ok {
+		t.Fatal("expected export to start with a UTF-8 BOM")
+	}
+	r := csv.NewReader(strings.NewReader(body))
+	r.Comma = ';'
+	records, err := r.ReadAll()
+	if err != nil {
+		t.Fatalf("failed to parse semicolon-delimited CSV: %v", err)
+	}
+	if len(records) < 4 {
+		t.Fatalf("expected header + at least 3 records, got %d rows", len(records))
+	}
+	if len(records[0]) != 20 || records[0][0] != "id" || records[0][3] != "path" {
+		t.Errorf("unexpected header %v", records[0])
+	}
+}
+
+func TestExportCSV_DefaultHasNoBOM(t *testing.T) {
+	srv, cleanup := setupTestServerWithData(t)
+	defer cleanup()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/export/csv?range=24h&delimiter=tab", nil)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if !strings.HasPrefix(w.Body.String(), "id\ttimestamp\t") {
+		t.Errorf("expected tab-separated header without BOM, got %q", w.Body.String()[:20])
+	}
+}
+
+func TestExportCSV_InvalidDelimiter(t *testing.T) {
+	srv, cleanup := setupTestServerWithData(t)
+	defer cleanup()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/export/csv?delimiter=pipe", nil)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestExportCSV_Fields(t *testing.T) {
+	srv, cleanup := setupTestServerWithData(t)
+	defer cleanup()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/export/csv?range=24h&fields=timestamp,path,status", nil)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to parse CSV: %v", err)
+	}
+	if len(records) < 4 {
+		t.Fatalf("expected header + at least 3 records, got %d rows", len(records))
+	}
+	if got := strings.Join(records[0], ","); got != "timestamp,path,status" {
+		t.Errorf("header = %q, want %q", got, "timestamp,path,status")
+	}
+	for _, rec := range records[1:] {
+		if len(rec) != 3 {
+			t.Fatalf("row %v has %d columns, want 3", rec, len(rec))
+		}
+		if _, err := time.Parse(time.RFC3339, rec[0]); err != nil {
+			t.Errorf("timestamp %q: %v", rec[0], err)
+		}
+		if !strings.HasPrefix(rec[1], "/") {
+			t.Errorf("path = %q, want a path", rec[1])
+		}
+	}
+}
+
+func TestExportJSON_Fields(t *testing.T) {
+	srv, cleanup := setupTestServerWithData(t)
+	defer cleanup()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/export/json?range=24h&fields=timestamp,path,status", nil)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var data []map[string]any
+	if err := json.NewDecoder(w.Body).Decode(&data); err != nil {
+		t.Fatalf("failed to decode JSON response: %v", err)
+	}
+	if len(data) < 3 {
+		t.Fatalf("expected at least 3 records, got %d", len(data))
+	}
+	for _, record := range data {
+		if len(record) != 3 {
+			t.Errorf("record %v has %d keys, want 3", record, len(record))
+		}
+		for _, key := range []string{"timestamp", "path", "status"} {
+			if _, ok := record[key]; !ok {
+				t.Errorf("record %v is missing %q", record, key)
+			}
+		}
+	}
+}
+
+func TestExport_UnknownField(t *testing.T) {
+	srv, cleanup := setupTestServerWithData(t)
+	defer cleanup()
+
+	for _, path := range []string{"/api/export/csv?fields=path,password", "/api/export/json?fields=nope"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", path, http.StatusBadRequest, w.Code)
+		}
+	}
+}
+
+func TestExportArchive(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	// One request per day for the last three days
+	ctx := context.Background()
+	now := time.Now().UTC()
+	for d := 0; d < 3; d++ {
+		rec := storage.RequestRecord{Timestamp: now.Add(-time.Duration(d)*24*time.Hour - time.Minute), Host: "example.com", Path: fmt.Sprintf("/day/%d", d), Status: 200}
+		if err := srv.store.InsertRequest(ctx, rec); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/export/archive.zip?range=72h", nil)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/zip" {
+		t.Errorf("Content-Type = %q, want application/zip", ct)
+	}
+	if cd := w.Header().Get("Content-Disposition"); !strings.HasSuffix(cd, ".zip") {
+		t.Errorf("Content-Disposition = %q, want a .zip filename", cd)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatalf("failed to open zip: %v", err)
+	}
+
+	// Every UTC day from the start of the range through today has an entry
+	var wantNames []string
+	for day := now.Add(-72 * time.Hour).Truncate(24 * time.Hour); !day.After(now); day = day.AddDate(0, 0, 1) {
+		wantNames = append(wantNames, "caddystat-"+day.Format("2006-01-02")+".csv")
+	}
+	if len(zr.File) != len(wantNames) {
+		t.Fatalf("zip has %d entries, want %d", len(zr.File), len(wantNames))
+	}
+	rows := 0
+	for i, f := range zr.File {
+		if f.Name != wantNames[i] {
+			t.Errorf("entry %d = %q, want %q", i, f.Name, wantNames[i])
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", f.Name, err)
+		}
+		records, err := csv.NewReader(rc).ReadAll()
+		rc.Close()
+		if err != nil {
+			t.Fatalf("parse %s: %v", f.Name, err)
+		}
+		if len(records) == 0 || records[0][0] != "id" || len(records[0]) != 20 {
+			t.Fatalf("%s: unexpected header %v", f.Name, records)
+		}
+		for _, rec := range records[1:] {
+			if ts := rec[1][:10]; "caddystat-"+ts+".csv" != f.Name {
+				t.Errorf("%s holds a request from %s", f.Name, ts)
+			}
+		}
+		rows += len(records) - 1
+	}
+	if rows != 3 {
+		t.Errorf("archive holds %d requests, want 3", rows)
+	}
+}
+
+func TestExportInflux(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	hour := time.Now().UTC().Truncate(time.Hour).Add(-time.Hour)
+	for k := 0; k < 3; k++ {
+		rec := storage.RequestRecord{Timestamp: hour.Add(time.Duration(k) * time.Minute), Host: "example.com", Path: "/", Status: 200, Bytes: 100}
+		if err := srv.store.InsertRequest(ctx, rec); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/export/influx?range=24h&host=example.com", nil)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Content-Type = %q, want text/plain", ct)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("got %d lines, want 1: %q", len(lines), w.Body.String())
+	}
+	parts := strings.Split(lines[0], " ")
+	if len(parts) != 3 {
+		t.Fatalf("line %q: want measurement, fields and timestamp", lines[0])
+	}
+	if parts[0] != "caddystat,host=example.com" {
+		t.Errorf("measurement and tags = %q, want caddystat,host=example.com", parts[0])
+	}
+	fields := map[string]string{}
+	for _, kv := range strings.Split(parts[1], ",") {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			t.Fatalf("malformed field %q", kv)
+		}
+		fields[k] = v
+	}
+	if fields["requests"] != "3" || fields["bytes"] != "300" || fields["status_2xx"] != "3" {
+		t.Errorf("fields = %v, want requests=3, bytes=300, status_2xx=3", fields)
+	}
+	if want := strconv.FormatInt(hour.UnixNano(), 10); parts[2] != want {
+		t.Errorf("timestamp = %s, want %s", parts[2], want)
+	}
+}
+
 func TestExportJSON(t *testing.T) {
 	srv, cleanup := setupTestServerWithData(t)
 	defer cleanup()
@@ -300,6 +621,51 @@ func TestExportJSON_HostFilter(t *testing.T) {
 	}
 }
 
+func TestExportJSON_SinceID(t *testing.T) {
+	srv, cleanup := setupTestServerWithData(t)
+	defer cleanup()
+
+	export := func(query string) []map[string]any {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/export/json?"+query, nil)
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected status %d, got %d: %s", query, http.StatusOK, w.Code, w.Body.String())
+		}
+		var data []map[string]any
+		if err := json.NewDecoder(w.Body).Decode(&data); err != nil {
+			t.Fatalf("%s: failed to decode JSON response: %v", query, err)
+		}
+		return data
+	}
+
+	all := export("since_id=0")
+	if len(all) < 2 {
+		t.Fatalf("expected seeded rows, got %d", len(all))
+	}
+	firstID := int64(all[0]["id"].(float64))
+	rest := export(fmt.Sprintf("since_id=%d", firstID))
+	if len(rest) != len(all)-1 {
+		t.Errorf("since_id=%d returned %d rows, want %d", firstID, len(rest), len(all)-1)
+	}
+	for _, row := range rest {
+		if id := int64(row["id"].(float64)); id <= firstID {
+			t.Errorf("since_id=%d returned row %d", firstID, id)
+		}
+	}
+	if got := export(fmt.Sprintf("since_id=%d&limit=1", firstID)); len(got) != 1 {
+		t.Errorf("limit=1 returned %d rows", len(got))
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/export/json?since_id=abc", nil)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("since_id=abc: expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
 func TestExportBackup(t *testing.T) {
 	srv, cleanup := setupTestServerWithData(t)
 	defer cleanup()
@@ -346,6 +712,43 @@ func TestExportBackup(t *testing.T) {
 	}
 }
 
+func TestExportBackup_UsesDataDir(t *testing.T) {
+	srv, cleanup := setupTestServerWithData(t)
+	defer cleanup()
+	srv.cfg.DataDir = filepath.Join(t.TempDir(), "backups")
+
+	path, err := srv.snapshotDB(context.Background())
+	if err != nil {
+		t.Fatalf("snapshotDB() error = %v", err)
+	}
+	defer os.Remove(path)
+	if filepath.Dir(path) != srv.cfg.DataDir {
+		t.Errorf("snapshot created at %q, want it under %q", path, srv.cfg.DataDir)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read snapshot: %v", err)
+	}
+	if len(data) < 16 || string(data[:16]) != "SQLite format 3\x00" {
+		t.Error("expected snapshot to be a valid SQLite database file")
+	}
+
+	// The handler removes its snapshot once sent
+	req := httptest.NewRequest(http.MethodGet, "/api/export/backup", nil)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	entries, err := os.ReadDir(srv.cfg.DataDir)
+	if err != nil {
+		t.Fatalf("read data dir: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("data dir has %d entries after backup, want only the earlier snapshot", len(entries))
+	}
+}
+
 func TestJSONErrorResponse_Unauthorized(t *testing.T) {
 	tmpDir, err := os.MkdirTemp("", "caddystat-test-*")
 	if err != nil {
@@ -1072,3 +1475,387 @@ func getSessionCookie(cookies []*http.Cookie) *http.Cookie {
 	}
 	return nil
 }
+
+func TestRequestsByIP_InvalidIP(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	for _, ip := range []string{"", "not-an-ip", "1.2.3"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/stats/requests/by-ip?ip="+ip, nil)
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("ip=%q: expected status %d, got %d", ip, http.StatusBadRequest, w.Code)
+		}
+	}
+}
+
+func TestRequestsByIP_RestrictedToPermittedHosts(t *testing.T) {
+	srv, store, cleanup := setupTestServerWithAuthAndStore(t, "admin", "secret")
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	for i, host := range []string{"allowed.com", "secret.com", "allowed.com"} {
+		rec := storage.RequestRecord{Timestamp: now.Add(time.Duration(i-10) * time.Minute), Host: host, Path: fmt.Sprintf("/p%d", i), Status: 200, IP: "203.0.113.5"}
+		if err := store.InsertRequest(ctx, rec); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	initReq := httptest.NewRequest(http.MethodGet, "/api/auth/check", nil)
+	initW := httptest.NewRecorder()
+	srv.ServeHTTP(initW, initReq)
+	csrfCookie := initW.Result().Cookies()[0]
+
+	body := strings.NewReader(`{"username": "admin", "password": "secret", "allowed_sites": ["allowed.com"]}`)
+	loginReq := httptest.NewRequest(http.MethodPost, "/api/auth/login", body)
+	loginReq.Header.Set("Content-Type", "application/json")
+	loginReq.AddCookie(csrfCookie)
+	loginReq.Header.Set("X-CSRF-Token", csrfCookie.Value)
+	loginW := httptest.NewRecorder()
+	srv.ServeHTTP(loginW, loginReq)
+	sessionCookie := getSessionCookie(loginW.Result().Cookies())
+	if sessionCookie == nil {
+		t.Fatal("session cookie not set")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/stats/requests/by-ip?ip=203.0.113.5", nil)
+	req.AddCookie(sessionCookie)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var got []storage.RecentRequest
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 requests on allowed.com, got %+v", got)
+	}
+	if got[0].Path != "/p0" || got[1].Path != "/p2" {
+		t.Errorf("paths = %s, %s; want /p0, /p2 in time order", got[0].Path, got[1].Path)
+	}
+	for _, r := range got {
+		if r.Host != "allowed.com" {
+			t.Errorf("leaked request for host %q", r.Host)
+		}
+	}
+}
+
+func TestKnownHosts_RestrictedToPermittedHosts(t *testing.T) {
+	srv, store, cleanup := setupTestServerWithAuthAndStore(t, "admin", "secret")
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	for _, host := range []string{"allowed.com", "secret.com", "allowed.com"} {
+		rec := storage.RequestRecord{Timestamp: now.Add(-time.Minute), Host: host, Path: "/", Status: 200}
+		if err := store.InsertRequest(ctx, rec); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	initReq := httptest.NewRequest(http.MethodGet, "/api/auth/check", nil)
+	initW := httptest.NewRecorder()
+	srv.ServeHTTP(initW, initReq)
+	csrfCookie := initW.Result().Cookies()[0]
+
+	body := strings.NewReader(`{"username": "admin", "password": "secret", "allowed_sites": ["allowed.com"]}`)
+	loginReq := httptest.NewRequest(http.MethodPost, "/api/auth/login", body)
+	loginReq.Header.Set("Content-Type", "application/json")
+	loginReq.AddCookie(csrfCookie)
+	loginReq.Header.Set("X-CSRF-Token", csrfCookie.Value)
+	loginW := httptest.NewRecorder()
+	srv.ServeHTTP(loginW, loginReq)
+	sessionCookie := getSessionCookie(loginW.Result().Cookies())
+	if sessionCookie == nil {
+		t.Fatal("session cookie not set")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/stats/known-hosts?range=1h", nil)
+	req.AddCookie(sessionCookie)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var got []storage.HostStat
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(got) != 1 || got[0].Host != "allowed.com" || got[0].Count != 2 {
+		t.Errorf("known hosts = %+v, want only allowed.com with 2 requests", got)
+	}
+}
+
+func TestAdminConfig_RedactsSecrets(t *testing.T) {
+	srv, _, cleanup := setupTestServerWithAuthAndStore(t, "admin", "secret")
+	defer cleanup()
+	srv.cfg.DataRetentionDays = 30
+	srv.cfg.IngestAPIKey = "push-key"
+	srv.cfg.LogPaths = []string{"/var/log/caddy/access.log"}
+
+	login := func(body string) *http.Cookie {
+		initReq := httptest.NewRequest(http.MethodGet, "/api/auth/check", nil)
+		initW := httptest.NewRecorder()
+		srv.ServeHTTP(initW, initReq)
+		csrfCookie := initW.Result().Cookies()[0]
+
+		loginReq := httptest.NewRequest(http.MethodPost, "/api/auth/login", strings.NewReader(body))
+		loginReq.Header.Set("Content-Type", "application/json")
+		loginReq.AddCookie(csrfCookie)
+		loginReq.Header.Set("X-CSRF-Token", csrfCookie.Value)
+		loginW := httptest.NewRecorder()
+		srv.ServeHTTP(loginW, loginReq)
+		sessionCookie := getSessionCookie(loginW.Result().Cookies())
+		if sessionCookie == nil {
+			t.Fatal("session cookie not set")
+		}
+		return sessionCookie
+	}
+
+	// Unauthenticated requests are rejected
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/admin/config", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("unauthenticated: expected %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+
+	// Site-restricted sessions are not admins
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/config", nil)
+	req.AddCookie(login(`{"username": "admin", "password": "secret", "allowed_sites": ["allowed.com"]}`))
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("restricted session: expected %d, got %d", http.StatusForbidden, w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/admin/config", nil)
+	req.AddCookie(login(`{"username": "admin", "password": "secret"}`))
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "secret") || strings.Contains(w.Body.String(), "push-key") {
+		t.Errorf("response leaks a secret: %s", w.Body.String())
+	}
+
+	var got config.Sanitized
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if got.AuthPassword != "set" || got.IngestAPIKey != "set" || got.ReportsSMTPPassword != "unset" {
+		t.Errorf("secrets = %q/%q/%q, want set/set/unset", got.AuthPassword, got.IngestAPIKey, got.ReportsSMTPPassword)
+	}
+	if got.DataRetentionDays != 30 {
+		t.Errorf("DataRetentionDays = %d, want 30", got.DataRetentionDays)
+	}
+	if len(got.LogPaths) != 1 || got.LogPaths[0] != "/var/log/caddy/access.log" {
+		t.Errorf("LogPaths = %v", got.LogPaths)
+	}
+}
+
+func TestProfiling_AdminOnly(t *testing.T) {
+	base, _, cleanup := setupTestServerWithAuthAndStore(t, "admin", "secret")
+	defer cleanup()
+
+	get := func(srv *Server, cookie *http.Cookie) int {
+		req := httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		return w.Code
+	}
+	login := func(srv *Server) *http.Cookie {
+		initW := httptest.NewRecorder()
+		srv.ServeHTTP(initW, httptest.NewRequest(http.MethodGet, "/api/auth/check", nil))
+		csrfCookie := initW.Result().Cookies()[0]
+
+		loginReq := httptest.NewRequest(http.MethodPost, "/api/auth/login", strings.NewReader(`{"username": "admin", "password": "secret"}`))
+		loginReq.Header.Set("Content-Type", "application/json")
+		loginReq.AddCookie(csrfCookie)
+		loginReq.Header.Set("X-CSRF-Token", csrfCookie.Value)
+		loginW := httptest.NewRecorder()
+		srv.ServeHTTP(loginW, loginReq)
+		sessionCookie := getSessionCookie(loginW.Result().Cookies())
+		if sessionCookie == nil {
+			t.Fatal("session cookie not set")
+		}
+		return sessionCookie
+	}
+
+	// Disabled: not mounted, even for admins
+	if code := get(base, login(base)); code != http.StatusNotFound {
+		t.Errorf("profiling disabled: expected %d, got %d", http.StatusNotFound, code)
+	}
+
+	cfg := base.cfg
+	cfg.EnableProfiling = true
+	srv := New(base.store, sse.NewHub(), cfg, nil)
+	if code := get(srv, nil); code != http.StatusUnauthorized {
+		t.Errorf("unauthenticated: expected %d, got %d", http.StatusUnauthorized, code)
+	}
+	if code := get(srv, login(srv)); code != http.StatusOK {
+		t.Errorf("admin session: expected %d, got %d", http.StatusOK, code)
+	}
+}
+
+func TestAdminGrowth(t *testing.T) {
+	srv, cleanup := setupTestServerWithData(t)
+	defer cleanup()
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/admin/growth", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var growth storage.GrowthEstimate
+	if err := json.NewDecoder(w.Body).Decode(&growth); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if growth.RequestsCount == 0 || growth.DBSizeBytes == 0 || growth.AvgBytesPerRow == 0 {
+		t.Errorf("expected row count and size from the test data, got %+v", growth)
+	}
+
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/admin/growth", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST: expected %d, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+}
+
+// countingRecent stubs the SSE snapshot's database fallback.
+type countingRecent struct {
+	calls int
+}
+
+func (c *countingRecent) RecentRequests(ctx context.Context, limit int, host string) ([]storage.RecentRequest, error) {
+	c.calls++
+	return []storage.RecentRequest{{Host: "db.example.com", Path: "/from-db"}}, nil
+}
+
+// cancelOnFlush cancels a streaming request once marker has been flushed.
+type cancelOnFlush struct {
+	*httptest.ResponseRecorder
+	marker string
+	cancel context.CancelFunc
+}
+
+func (w *cancelOnFlush) Flush() {
+	w.ResponseRecorder.Flush()
+	if strings.Contains(w.Body.String(), w.marker) {
+		w.cancel()
+	}
+}
+
+func TestSSE_RecentSnapshot(t *testing.T) {
+	base, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	// snapshot runs the handler until the recent event is flushed, then
+	// cancels the request so it returns
+	snapshot := func(srv *Server, host string) []storage.RecentRequest {
+		t.Helper()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		req := httptest.NewRequest(http.MethodGet, "/api/sse?host="+host, nil).WithContext(ctx)
+		w := &cancelOnFlush{ResponseRecorder: httptest.NewRecorder(), marker: "event: recent", cancel: cancel}
+		srv.ServeHTTP(w, req)
+		_, rest, ok := strings.Cut(w.Body.String(), "event: recent\ndata: ")
+		if !ok {
+			t.Fatalf("no recent event in %q", w.Body.String())
+		}
+		data, _, _ := strings.Cut(rest, "\n")
+		var recent []storage.RecentRequest
+		if err := json.Unmarshal([]byte(data), &recent); err != nil {
+			t.Fatalf("decode recent event: %v", err)
+		}
+		return recent
+	}
+
+	hub := sse.NewHub(sse.WithRecentSize(50))
+	srv := New(base.store, hub, base.cfg, nil)
+	stub := &countingRecent{}
+	srv.recent = stub
+
+	// Cold start: nothing buffered yet, so the database answers
+	if got := snapshot(srv, ""); len(got) != 1 || got[0].Path != "/from-db" {
+		t.Errorf("cold start: got %+v, want the database result", got)
+	}
+	if stub.calls != 1 {
+		t.Fatalf("cold start: expected 1 database query, got %d", stub.calls)
+	}
+
+	for i := 0; i < 25; i++ {
+		host := "example.com"
+		if i%5 == 0 {
+			host = "other.com"
+		}
+		buf, _ := json.Marshal(storage.RecentRequest{Timestamp: time.Now(), Host: host, Path: fmt.Sprintf("/p%d", i)})
+		hub.BroadcastEvent(sse.RequestEvent, buf)
+	}
+
+	got := snapshot(srv, "")
+	if stub.calls != 1 {
+		t.Errorf("warm buffer: expected no further database queries, got %d", stub.calls-1)
+	}
+	if len(got) != sseRecentLimit || got[0].Path != "/p24" {
+		t.Errorf("warm buffer: got %d requests starting with %+v, want %d newest first", len(got), got[0], sseRecentLimit)
+	}
+
+	// Only 5 buffered requests match other.com, so the database fills in
+	if snapshot(srv, "other.com"); stub.calls != 2 {
+		t.Errorf("quiet host: expected a database query, got %d total", stub.calls)
+	}
+
+	// example.com fills a snapshot from the buffer until it gains an alias,
+	// whose requests only the database folds in
+	if snapshot(srv, "example.com"); stub.calls != 2 {
+		t.Errorf("busy host: expected no database query, got %d total", stub.calls)
+	}
+	if _, err := base.store.CreateSite(context.Background(), storage.SiteInput{Host: "example.com", Aliases: []string{"www.example.com"}}); err != nil {
+		t.Fatalf("CreateSite() error = %v", err)
+	}
+	if snapshot(srv, "example.com"); stub.calls != 3 {
+		t.Errorf("aliased host: expected a database query, got %d total", stub.calls)
+	}
+}
+
+func TestMetricsEndpoint_TrafficGauges(t *testing.T) {
+	srv, cleanup := setupTestServerWithData(t)
+	defer cleanup()
+
+	// /metrics serves the default registry
+	m := metrics.New(func() int { return 0 }, func() int64 { return 0 }, func() metrics.DBStats { return metrics.DBStats{} }, nil)
+	prometheus.MustRegister(m.TrafficRequests, m.TrafficBytes)
+	t.Cleanup(func() {
+		prometheus.Unregister(m.TrafficRequests)
+		prometheus.Unregister(m.TrafficBytes)
+	})
+	hosts, err := storage.NewMetricsTrafficAdapter(srv.store).HostTraffic(context.Background(), 10)
+	if err != nil {
+		t.Fatalf("HostTraffic() error = %v", err)
+	}
+	m.SetTraffic(hosts)
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, `caddystat_requests_total{host="example.com",status_class="2xx"}`) {
+		t.Errorf("expected caddystat_requests_total with a host label, got:\n%s", body)
+	}
+	if !strings.Contains(body, `caddystat_bytes_total{host="example.com"}`) {
+		t.Errorf("expected caddystat_bytes_total with a host label, got:\n%s", body)
+	}
+}
@@ -2,11 +2,14 @@ package server
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/dustin/Caddystat/internal/storage"
 )
@@ -174,6 +177,150 @@ func TestCreateSite_Duplicate(t *testing.T) {
 	}
 }
 
+func TestBulkCreateSites(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	if _, err := srv.store.CreateSite(context.Background(), storage.SiteInput{Host: "existing.com"}); err != nil {
+		t.Fatalf("CreateSite() error = %v", err)
+	}
+
+	// Get CSRF token
+	csrfReq := httptest.NewRequest(http.MethodGet, "/api/auth/check", nil)
+	csrfW := httptest.NewRecorder()
+	srv.ServeHTTP(csrfW, csrfReq)
+
+	var csrfCookie *http.Cookie
+	for _, c := range csrfW.Result().Cookies() {
+		if c.Name == "caddystat_csrf" {
+			csrfCookie = c
+			break
+		}
+	}
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/sites/bulk", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-CSRF-Token", csrfCookie.Value)
+		req.AddCookie(csrfCookie)
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		return w
+	}
+
+	w := post(`[{"host": "one.com"}, {"host": "existing.com"}, {"host": "two.com:8443", "display_name": "Two"}]`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var result storage.BulkSiteResult
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(result.Created) != 2 || result.Created[0].Host != "one.com" || result.Created[1].Host != "two.com:8443" {
+		t.Errorf("created = %+v, want one.com and two.com:8443", result.Created)
+	}
+	if len(result.Skipped) != 1 || result.Skipped[0].Host != "existing.com" {
+		t.Errorf("skipped = %+v, want existing.com", result.Skipped)
+	}
+
+	// One invalid host rejects the whole batch
+	w = post(`[{"host": "three.com"}, {"host": "https://bad.com/"}]`)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("invalid host: expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+	if site, _ := srv.store.GetSiteByHost(context.Background(), "three.com"); site != nil {
+		t.Error("three.com was created by a rejected batch")
+	}
+}
+
+func TestSiteAliases_AggregateStats(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	for _, host := range []string{"example.com", "www.example.com", "other.com"} {
+		if err := srv.store.InsertRequest(ctx, storage.RequestRecord{Timestamp: now.Add(-time.Minute), Host: host, Path: "/", Status: 200}); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	// Get CSRF token
+	csrfReq := httptest.NewRequest(http.MethodGet, "/api/auth/check", nil)
+	csrfW := httptest.NewRecorder()
+	srv.ServeHTTP(csrfW, csrfReq)
+
+	var csrfCookie *http.Cookie
+	for _, c := range csrfW.Result().Cookies() {
+		if c.Name == "caddystat_csrf" {
+			csrfCookie = c
+			break
+		}
+	}
+
+	body := `{"host": "example.com", "aliases": ["www.example.com"]}`
+	req := httptest.NewRequest(http.MethodPost, "/api/sites", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-CSRF-Token", csrfCookie.Value)
+	req.AddCookie(csrfCookie)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create site: expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/stats/summary?range=1h&host=example.com", nil)
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("summary: expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	var summary storage.Summary
+	if err := json.NewDecoder(w.Body).Decode(&summary); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if summary.TotalRequests != 2 {
+		t.Errorf("total_requests for example.com = %d, want 2 (example.com + www.example.com)", summary.TotalRequests)
+	}
+
+	// Aliases must be host names too
+	req = httptest.NewRequest(http.MethodPost, "/api/sites", bytes.NewBufferString(`{"host": "other.com", "aliases": ["not a host"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-CSRF-Token", csrfCookie.Value)
+	req.AddCookie(csrfCookie)
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("invalid alias: expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	// An alias can't also become a site of its own
+	req = httptest.NewRequest(http.MethodPost, "/api/sites", bytes.NewBufferString(`{"host": "www.example.com"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-CSRF-Token", csrfCookie.Value)
+	req.AddCookie(csrfCookie)
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusConflict {
+		t.Errorf("alias as host: expected status %d, got %d: %s", http.StatusConflict, w.Code, w.Body.String())
+	}
+}
+
+func TestValidSiteHost(t *testing.T) {
+	valid := []string{"example.com", "www.example.com", "localhost:8080", "192.168.1.10", "[::1]:443", "my_host.internal"}
+	invalid := []string{"", "https://example.com", "example.com/path", "exa mple.com", "-bad.com", "a..com", "example.com:99999"}
+	for _, h := range valid {
+		if !validSiteHost(h) {
+			t.Errorf("validSiteHost(%q) = false, want true", h)
+		}
+	}
+	for _, h := range invalid {
+		if validSiteHost(h) {
+			t.Errorf("validSiteHost(%q) = true, want false", h)
+		}
+	}
+}
+
 func TestGetSite(t *testing.T) {
 	srv, cleanup := setupTestServer(t)
 	defer cleanup()
@@ -308,6 +455,94 @@ func TestUpdateSite(t *testing.T) {
 	}
 }
 
+func TestUpdateSite_RetentionAppliedByCleanup(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	csrfReq := httptest.NewRequest(http.MethodGet, "/api/auth/check", nil)
+	csrfW := httptest.NewRecorder()
+	srv.ServeHTTP(csrfW, csrfReq)
+
+	var csrfCookie *http.Cookie
+	for _, c := range csrfW.Result().Cookies() {
+		if c.Name == "caddystat_csrf" {
+			csrfCookie = c
+			break
+		}
+	}
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-CSRF-Token", csrfCookie.Value)
+		req.AddCookie(csrfCookie)
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		return w
+	}
+
+	createW := send(http.MethodPost, "/api/sites", `{"host": "short.example"}`)
+	var created storage.Site
+	_ = json.NewDecoder(createW.Body).Decode(&created)
+
+	if w := send(http.MethodPut, "/api/sites/"+itoa(created.ID), `{"retention_days": -1}`); w.Code != http.StatusBadRequest {
+		t.Errorf("negative retention: expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+	if w := send(http.MethodPut, "/api/sites/"+itoa(created.ID), `{"retention_days": 3}`); w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	ctx := context.Background()
+	fiveDaysAgo := time.Now().UTC().AddDate(0, 0, -5)
+	for _, host := range []string{"short.example", "default.example"} {
+		if err := srv.store.InsertRequest(ctx, storage.RequestRecord{Timestamp: fiveDaysAgo, Host: host, Path: "/", Status: 200}); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	// Default retention (7 days) keeps the other site's 5-day-old row
+	result, err := srv.store.CleanupWithPerSiteRetention(ctx, 7)
+	if err != nil {
+		t.Fatalf("CleanupWithPerSiteRetention() error = %v", err)
+	}
+	if result.PerSiteDeleted["short.example"] != 1 {
+		t.Errorf("short.example deleted = %d, want 1", result.PerSiteDeleted["short.example"])
+	}
+	if result.GlobalDeleted != 0 {
+		t.Errorf("GlobalDeleted = %d, want 0", result.GlobalDeleted)
+	}
+}
+
+func TestCreateSite_NegativeRetention(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	csrfReq := httptest.NewRequest(http.MethodGet, "/api/auth/check", nil)
+	csrfW := httptest.NewRecorder()
+	srv.ServeHTTP(csrfW, csrfReq)
+
+	var csrfCookie *http.Cookie
+	for _, c := range csrfW.Result().Cookies() {
+		if c.Name == "caddystat_csrf" {
+			csrfCookie = c
+			break
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/sites", bytes.NewBufferString(`{"host": "neg.example", "retention_days": -5}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-CSRF-Token", csrfCookie.Value)
+	req.AddCookie(csrfCookie)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "retention_days must not be negative") {
+		t.Errorf("body = %s, want negative retention message", w.Body.String())
+	}
+}
+
 func TestDeleteSite(t *testing.T) {
 	srv, cleanup := setupTestServer(t)
 	defer cleanup()
@@ -0,0 +1,155 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/dustin/Caddystat/web"
+)
+
+// staticHandler serves the dashboard from STATIC_DIR when set, otherwise
+// from the assets embedded in the binary.
+func (s *Server) staticHandler() http.Handler {
+	if s.cfg.StaticDir != "" {
+		return spaHandler(http.Dir(s.cfg.StaticDir), nil)
+	}
+	embedded := web.Site()
+	return spaHandler(http.FS(embedded), contentETags(embedded))
+}
+
+// spaHandler serves files from site, and index.html for extensionless paths
+// that don't exist so client-side routes like /dashboard/sessions survive
+// a reload. Missing assets (paths with an extension) still 404, and
+// unknown API, debug, health and metrics paths never fall back to the
+// dashboard.
+//
+// etags maps file paths to strong validators for file systems without
+// modification times (the embedded site), so conditional requests can still
+// be answered with 304 Not Modified.
+func spaHandler(site http.FileSystem, etags map[string]string) http.Handler {
+	files := http.FileServer(site)
+	serve := func(w http.ResponseWriter, r *http.Request, p string) {
+		w.Header().Set("Cache-Control", cacheControl(p))
+		if p == "/" && serveIndexWithNonce(w, r, site) {
+			return
+		}
+		if tag, ok := etags[strings.TrimPrefix(p, "/")]; ok {
+			w.Header().Set("ETag", tag)
+		}
+		files.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := path.Clean("/" + r.URL.Path)
+		if underPrefix(p, "/api") || underPrefix(p, "/debug") {
+			writeErrorWithCode(w, http.StatusNotFound, "not found", "NOT_FOUND")
+			return
+		}
+		if underPrefix(p, "/health") || underPrefix(p, "/metrics") || !isSPARoute(site, p) {
+			serve(w, r, p)
+			return
+		}
+		r2 := r.Clone(r.Context())
+		r2.URL.Path = "/"
+		serve(w, r2, "/")
+	})
+}
+
+// serveIndexWithNonce writes index.html with every cspNoncePlaceholder
+// replaced by the request's CSP nonce, adds the nonce to the response's CSP
+// and reports whether it did so. An index without the placeholder is left
+// to the file server and its validators, and its CSP keeps no nonce so
+// 'unsafe-inline' still covers unstamped inline scripts. One with it
+// differs per response, so it is sent without ETag or Last-Modified and
+// never answered with 304.
+func serveIndexWithNonce(w http.ResponseWriter, r *http.Request, site http.FileSystem) bool {
+	f, err := site.Open("/index.html")
+	if err != nil {
+		return false
+	}
+	data, err := io.ReadAll(f)
+	f.Close()
+	if err != nil || !bytes.Contains(data, []byte(cspNoncePlaceholder)) {
+		return false
+	}
+	nonce := cspNonce(r)
+	data = bytes.ReplaceAll(data, []byte(cspNoncePlaceholder), []byte(nonce))
+	w.Header().Set("Content-Security-Policy", cspWithNonce(w.Header().Get("Content-Security-Policy"), nonce))
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		w.Write(data)
+	}
+	return true
+}
+
+// hashedAsset matches fingerprinted file names such as app.3f9c2b1a.js or
+// index-B4x9kQ2z.css: a run of at least eight name characters, containing a
+// digit, right before the extension.
+var hashedAsset = regexp.MustCompile(`[.-]([A-Za-z0-9_]{8,})\.[A-Za-z0-9]+$`)
+
+// cacheControl returns the Cache-Control value for the static path p.
+// Fingerprinted assets never change under the same name and are cached for
+// a year; everything else, index.html included, must be revalidated so a
+// new release is picked up on the next load.
+func cacheControl(p string) string {
+	if m := hashedAsset.FindStringSubmatch(path.Base(p)); m != nil && strings.ContainsAny(m[1], "0123456789") {
+		return "public, max-age=31536000, immutable"
+	}
+	return "no-cache"
+}
+
+// contentETags hashes every file in fsys and returns a quoted ETag per path,
+// with the directory index under both "dir/index.html" and "dir".
+func contentETags(fsys fs.FS) map[string]string {
+	tags := make(map[string]string)
+	_ = fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		data, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return nil
+		}
+		sum := sha256.Sum256(data)
+		tag := `"` + hex.EncodeToString(sum[:16]) + `"`
+		tags[p] = tag
+		if path.Base(p) == "index.html" {
+			dir := path.Dir(p)
+			if dir == "." {
+				dir = ""
+			}
+			tags[dir] = tag
+		}
+		return nil
+	})
+	return tags
+}
+
+// isSPARoute reports whether p should be answered with index.html: it
+// names nothing in site and has no file extension.
+func isSPARoute(site http.FileSystem, p string) bool {
+	if path.Ext(p) != "" {
+		return false
+	}
+	f, err := site.Open(p)
+	if err == nil {
+		f.Close()
+		return false
+	}
+	return errors.Is(err, fs.ErrNotExist)
+}
+
+// underPrefix reports whether p is prefix or a path below it.
+func underPrefix(p, prefix string) bool {
+	return p == prefix || strings.HasPrefix(p, prefix+"/")
+}
@@ -0,0 +1,262 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/dustin/Caddystat/internal/sse"
+)
+
+func TestStatic_ServesConfiguredDir(t *testing.T) {
+	base, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "index.html"), []byte("<h1>custom dashboard</h1>"), 0o644); err != nil {
+		t.Fatalf("write index.html: %v", err)
+	}
+	cfg := base.cfg
+	cfg.StaticDir = dir
+	cfg.ServeDashboard = true
+	srv := New(base.store, sse.NewHub(), cfg, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "custom dashboard") {
+		t.Errorf("body = %q, want the configured index.html", w.Body.String())
+	}
+}
+
+func TestStatic_SPAFallback(t *testing.T) {
+	base, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "index.html"), []byte("<h1>spa shell</h1>"), 0o644); err != nil {
+		t.Fatalf("write index.html: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "bundle.css"), []byte("body{}"), 0o644); err != nil {
+		t.Fatalf("write bundle.css: %v", err)
+	}
+	cfg := base.cfg
+	cfg.StaticDir = dir
+	cfg.ServeDashboard = true
+	srv := New(base.store, sse.NewHub(), cfg, nil)
+
+	tests := []struct {
+		name     string
+		path     string
+		wantCode int
+		wantBody string
+	}{
+		{"deep SPA route", "/dashboard/sessions", http.StatusOK, "spa shell"},
+		{"existing asset", "/bundle.css", http.StatusOK, "body{}"},
+		{"missing asset", "/app.js", http.StatusNotFound, ""},
+		{"unknown API path", "/api/foo", http.StatusNotFound, "NOT_FOUND"},
+		{"API route", "/api/version", http.StatusOK, "version"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			w := httptest.NewRecorder()
+			srv.ServeHTTP(w, req)
+			if w.Code != tt.wantCode {
+				t.Fatalf("GET %s: status = %d, want %d", tt.path, w.Code, tt.wantCode)
+			}
+			if strings.Contains(w.Body.String(), "spa shell") != (tt.wantBody == "spa shell") {
+				t.Errorf("GET %s: body = %q", tt.path, w.Body.String())
+			}
+			if tt.wantBody != "" && !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("GET %s: body = %q, want it to contain %q", tt.path, w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestStatic_CacheHeaders(t *testing.T) {
+	base, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"index.html":         "<h1>shell</h1>",
+		"app.3f9c2b1a7d.js":  "console.log(1)",
+		"index-B4x9kQ2z.css": "body{}",
+		"bundle.css":         "body{}",
+	}
+	for name, body := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	cfg := base.cfg
+	cfg.StaticDir = dir
+	cfg.ServeDashboard = true
+	srv := New(base.store, sse.NewHub(), cfg, nil)
+
+	immutable := "public, max-age=31536000, immutable"
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/app.3f9c2b1a7d.js", immutable},
+		{"/index-B4x9kQ2z.css", immutable},
+		{"/bundle.css", "no-cache"},
+		{"/", "no-cache"},
+		{"/dashboard/sessions", "no-cache"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s: status = %d, want %d", tt.path, w.Code, http.StatusOK)
+		}
+		if got := w.Header().Get("Cache-Control"); got != tt.want {
+			t.Errorf("GET %s: Cache-Control = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+
+	// Files from STATIC_DIR carry Last-Modified, so revalidation yields 304.
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("If-Modified-Since", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("conditional GET /: status = %d, want %d", w.Code, http.StatusNotModified)
+	}
+}
+
+func TestStatic_ETagNotModified(t *testing.T) {
+	site := fstest.MapFS{
+		"index.html":        {Data: []byte("<h1>embedded</h1>")},
+		"app.3f9c2b1a7d.js": {Data: []byte("console.log(1)")},
+	}
+	h := spaHandler(http.FS(site), contentETags(site))
+
+	for _, p := range []string{"/", "/app.3f9c2b1a7d.js", "/dashboard"} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, p, nil))
+		tag := w.Header().Get("ETag")
+		if w.Code != http.StatusOK || tag == "" {
+			t.Fatalf("GET %s: status = %d, ETag = %q; want 200 with an ETag", p, w.Code, tag)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, p, nil)
+		req.Header.Set("If-None-Match", tag)
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != http.StatusNotModified {
+			t.Errorf("GET %s with If-None-Match: status = %d, want %d", p, w.Code, http.StatusNotModified)
+		}
+	}
+}
+
+func TestStatic_IndexCSPNonce(t *testing.T) {
+	base, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	dir := t.TempDir()
+	index := `<script nonce="__CSP_NONCE__">init()</script>`
+	if err := os.WriteFile(filepath.Join(dir, "index.html"), []byte(index), 0o644); err != nil {
+		t.Fatalf("write index.html: %v", err)
+	}
+	cfg := base.cfg
+	cfg.StaticDir = dir
+	cfg.ServeDashboard = true
+	srv := New(base.store, sse.NewHub(), cfg, nil)
+
+	nonceAttr := regexp.MustCompile(`nonce="([^"]*)"`)
+	seen := make(map[string]bool)
+	for _, p := range []string{"/", "/", "/dashboard/sessions"} {
+		req := httptest.NewRequest(http.MethodGet, p, nil)
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s: status = %d, want %d", p, w.Code, http.StatusOK)
+		}
+		m := nonceAttr.FindStringSubmatch(w.Body.String())
+		if m == nil || m[1] == "" || m[1] == cspNoncePlaceholder {
+			t.Fatalf("GET %s: body = %q, want a substituted nonce", p, w.Body.String())
+		}
+		nonce := m[1]
+		if csp := w.Header().Get("Content-Security-Policy"); !strings.Contains(csp, "'nonce-"+nonce+"'") {
+			t.Errorf("GET %s: CSP = %q, want it to allow nonce %q", p, csp, nonce)
+		}
+		if w.Header().Get("ETag") != "" || w.Header().Get("Last-Modified") != "" {
+			t.Errorf("GET %s: per-response index.html sent with validators", p)
+		}
+		if seen[nonce] {
+			t.Errorf("GET %s: nonce %q reused", p, nonce)
+		}
+		seen[nonce] = true
+	}
+}
+
+func TestStatic_IndexWithoutPlaceholderKeepsCSP(t *testing.T) {
+	base, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	// A custom dashboard with unstamped inline scripts relies on
+	// 'unsafe-inline', which a nonce in the CSP would switch off
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "index.html"), []byte(`<script>init()</script>`), 0o644); err != nil {
+		t.Fatalf("write index.html: %v", err)
+	}
+	cfg := base.cfg
+	cfg.StaticDir = dir
+	cfg.ServeDashboard = true
+	srv := New(base.store, sse.NewHub(), cfg, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /: status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if csp := w.Header().Get("Content-Security-Policy"); strings.Contains(csp, "'nonce-") {
+		t.Errorf("CSP = %q, want no nonce for an index without the placeholder", csp)
+	}
+}
+
+func TestStatic_DashboardDisabled(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+	if srv.cfg.ServeDashboard {
+		t.Fatal("test server unexpectedly serves the dashboard")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /: status = %d, want %d", w.Code, http.StatusOK)
+	}
+	var info map[string]any
+	if err := json.NewDecoder(w.Body).Decode(&info); err != nil {
+		t.Fatalf("GET /: decode: %v", err)
+	}
+	if info["dashboard"] != false || info["openapi"] != "/api/openapi.json" {
+		t.Errorf("GET / = %v, want API info with dashboard false", info)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/dashboard/sessions", nil)
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), "NOT_FOUND") {
+		t.Errorf("GET /dashboard/sessions: status = %d, body = %q; want JSON 404", w.Code, w.Body.String())
+	}
+}
@@ -9,6 +9,14 @@ import (
 // DefaultBufferSize is the default channel buffer size for SSE clients.
 const DefaultBufferSize = 32
 
+// RequestEvent is the event type of single-request broadcasts, the ones
+// kept in the recent buffer.
+const RequestEvent = "request"
+
+// OnlineEvent is the event type of periodic online-visitor broadcasts; the
+// payload is a site-wide storage.OnlineStats.
+const OnlineEvent = "online"
+
 // DroppedCounter is an interface for recording dropped SSE messages.
 type DroppedCounter interface {
 	RecordSSEDropped()
@@ -28,6 +36,12 @@ type Hub struct {
 	bufferSize     int
 	droppedCounter DroppedCounter
 	droppedTotal   atomic.Uint64
+
+	// recent is a ring of the last RequestEvent payloads; recentNext is
+	// the slot the next one is written to.
+	recent     [][]byte
+	recentNext int
+	recentLen  int
 }
 
 // HubOption configures Hub behavior.
@@ -42,6 +56,17 @@ func WithBufferSize(size int) HubOption {
 	}
 }
 
+// WithRecentSize keeps the payloads of the last size RequestEvent broadcasts
+// in memory so new subscribers can be sent recent traffic without a
+// database query. 0 disables the buffer.
+func WithRecentSize(size int) HubOption {
+	return func(h *Hub) {
+		if size > 0 {
+			h.recent = make([][]byte, size)
+		}
+	}
+}
+
 // WithDroppedCounter sets the counter for tracking dropped messages.
 func WithDroppedCounter(counter DroppedCounter) HubOption {
 	return func(h *Hub) {
@@ -119,6 +144,11 @@ func (h *Hub) Broadcast(payload []byte) {
 func (h *Hub) BroadcastEvent(eventType string, payload []byte) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
+	if eventType == RequestEvent && len(h.recent) > 0 {
+		h.recent[h.recentNext] = payload
+		h.recentNext = (h.recentNext + 1) % len(h.recent)
+		h.recentLen = min(h.recentLen+1, len(h.recent))
+	}
 	for ch := range h.clients {
 		select {
 		case ch <- Event{Type: eventType, Payload: payload}:
@@ -137,6 +167,19 @@ func (h *Hub) BroadcastEvent(eventType string, payload []byte) {
 	}
 }
 
+// Recent returns the buffered RequestEvent payloads, newest first. It is
+// empty until the first request is broadcast after startup, or when the
+// buffer is disabled.
+func (h *Hub) Recent() [][]byte {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	out := make([][]byte, 0, h.recentLen)
+	for i := 1; i <= h.recentLen; i++ {
+		out = append(out, h.recent[(h.recentNext-i+len(h.recent))%len(h.recent)])
+	}
+	return out
+}
+
 // DroppedTotal returns the total number of messages dropped since startup.
 func (h *Hub) DroppedTotal() uint64 {
 	return h.droppedTotal.Load()
@@ -378,3 +378,42 @@ done2:
 		t.Errorf("expected 4 total dropped, got %d", hub.DroppedTotal())
 	}
 }
+
+func TestHub_Recent(t *testing.T) {
+	hub := NewHub(WithRecentSize(3))
+	if got := hub.Recent(); len(got) != 0 {
+		t.Fatalf("expected empty buffer on start, got %d entries", len(got))
+	}
+
+	hub.BroadcastEvent(RequestEvent, []byte("1"))
+	hub.Broadcast([]byte("summary")) // Only request events are buffered
+	hub.BroadcastEvent(RequestEvent, []byte("2"))
+	assertRecent(t, hub, "2", "1")
+
+	// Older entries fall out once the ring wraps
+	hub.BroadcastEvent(RequestEvent, []byte("3"))
+	hub.BroadcastEvent(RequestEvent, []byte("4"))
+	hub.BroadcastEvent(RequestEvent, []byte("5"))
+	assertRecent(t, hub, "5", "4", "3")
+}
+
+func TestHub_RecentDisabled(t *testing.T) {
+	hub := NewHub()
+	hub.BroadcastEvent(RequestEvent, []byte("1"))
+	if got := hub.Recent(); len(got) != 0 {
+		t.Errorf("expected no buffering by default, got %d entries", len(got))
+	}
+}
+
+func assertRecent(t *testing.T, hub *Hub, want ...string) {
+	t.Helper()
+	got := hub.Recent()
+	if len(got) != len(want) {
+		t.Fatalf("Recent() returned %d entries, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if string(got[i]) != want[i] {
+			t.Errorf("Recent()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
@@ -3,11 +3,52 @@ package storage
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"strings"
 	"time"
 )
 
-// Visitors returns top visitor IPs with their stats.
-func (s *Storage) Visitors(ctx context.Context, dur time.Duration, host string, limit int) ([]VisitorStat, error) {
+// VisitorSort is the ordering of the visitors report.
+type VisitorSort string
+
+const (
+	VisitorSortHits      VisitorSort = "hits"
+	VisitorSortBandwidth VisitorSort = "bandwidth"
+	VisitorSortRecent    VisitorSort = "recent"
+)
+
+// ParseVisitorSort validates a sort key. An empty string means hits.
+func ParseVisitorSort(v string) (VisitorSort, error) {
+	switch o := VisitorSort(strings.ToLower(strings.TrimSpace(v))); o {
+	case "":
+		return VisitorSortHits, nil
+	case VisitorSortHits, VisitorSortBandwidth, VisitorSortRecent:
+		return o, nil
+	default:
+		return "", fmt.Errorf("invalid sort %q", v)
+	}
+}
+
+// orderBy returns the ORDER BY expression for the sort key. Unknown keys
+// fall back to hits so callers can't inject SQL.
+func (o VisitorSort) orderBy() string {
+	switch o {
+	case VisitorSortBandwidth:
+		return "bandwidth DESC, hits DESC"
+	case VisitorSortRecent:
+		return "last_visit DESC"
+	default:
+		return "hits DESC"
+	}
+}
+
+// Visitors returns top visitor IPs with their stats, ordered by sort.
+func (s *Storage) Visitors(ctx context.Context, dur time.Duration, host string, limit int, sort VisitorSort) ([]VisitorStat, error) {
+	return s.VisitorsFiltered(ctx, dur, StatsFilter{Host: host}, limit, sort)
+}
+
+// VisitorsFiltered is Visitors narrowed by f.
+func (s *Storage) VisitorsFiltered(ctx context.Context, dur time.Duration, f StatsFilter, limit int, sort VisitorSort) ([]VisitorStat, error) {
 	from := time.Now().Add(-dur)
 	if limit <= 0 {
 		limit = 20
@@ -16,29 +57,18 @@ func (s *Storage) Visitors(ctx context.Context, dur time.Duration, host string,
 	query := `
 SELECT
 	ip,
-	SUM(CASE WHEN
-		(CASE WHEN instr(path, '?') > 0 THEN substr(path, 1, instr(path, '?') - 1) ELSE path END) NOT LIKE '%.css'
-		AND (CASE WHEN instr(path, '?') > 0 THEN substr(path, 1, instr(path, '?') - 1) ELSE path END) NOT LIKE '%.js'
-		AND (CASE WHEN instr(path, '?') > 0 THEN substr(path, 1, instr(path, '?') - 1) ELSE path END) NOT LIKE '%.png'
-		AND (CASE WHEN instr(path, '?') > 0 THEN substr(path, 1, instr(path, '?') - 1) ELSE path END) NOT LIKE '%.jpg'
-		AND (CASE WHEN instr(path, '?') > 0 THEN substr(path, 1, instr(path, '?') - 1) ELSE path END) NOT LIKE '%.gif'
-		AND (CASE WHEN instr(path, '?') > 0 THEN substr(path, 1, instr(path, '?') - 1) ELSE path END) NOT LIKE '%.svg'
-		AND (CASE WHEN instr(path, '?') > 0 THEN substr(path, 1, instr(path, '?') - 1) ELSE path END) NOT LIKE '%.ico'
-		AND (CASE WHEN instr(path, '?') > 0 THEN substr(path, 1, instr(path, '?') - 1) ELSE path END) NOT LIKE '%.woff%'
-	THEN 1 ELSE 0 END) as pages,
-	COUNT(*) as hits,
-	IFNULL(SUM(bytes), 0) as bandwidth,
+	SUM(CASE WHEN ` + s.assets.pageSQL(cleanPathSQL) + ` THEN IFNULL(sample_weight, 1) ELSE 0 END) as pages,
+	SUM(IFNULL(sample_weight, 1)) as hits,
+	IFNULL(SUM(bytes * IFNULL(sample_weight, 1)), 0) as bandwidth,
 	MAX(ts) as last_visit,
 	IFNULL(MAX(country), '') as country
 FROM requests
 WHERE ts >= ? AND is_bot = 0`
 
-	args := []any{from}
-	if host != "" {
-		query += " AND host = ?"
-		args = append(args, host)
-	}
-	query += " GROUP BY ip ORDER BY hits DESC LIMIT ?"
+	cond, condArgs := f.sql()
+	query += cond
+	args := append([]any{from}, condArgs...)
+	query += " GROUP BY ip ORDER BY " + sort.orderBy() + " LIMIT ?"
 	args = append(args, limit)
 
 	rows, err := s.db.QueryContext(ctx, query, args...)
@@ -54,6 +84,7 @@ WHERE ts >= ? AND is_bot = 0`
 		if err := rows.Scan(&v.IP, &v.Pages, &v.Hits, &v.BandwidthBytes, &lastVisitStr, &v.Country); err != nil {
 			return nil, err
 		}
+		v.BandwidthHuman = humanizeBytes(v.BandwidthBytes)
 		if lastVisitStr.Valid {
 			v.LastVisit, _ = time.Parse("2006-01-02 15:04:05.999999999-07:00", lastVisitStr.String)
 			if v.LastVisit.IsZero() {
@@ -78,27 +109,19 @@ func (s *Storage) Browsers(ctx context.Context, dur time.Duration, host string,
 	query := `
 WITH stats AS (
 	SELECT
-		CASE WHEN browser = '' THEN 'Unknown' ELSE browser END as browser,
-		SUM(CASE WHEN
-			(CASE WHEN instr(path, '?') > 0 THEN substr(path, 1, instr(path, '?') - 1) ELSE path END) NOT LIKE '%.css'
-			AND (CASE WHEN instr(path, '?') > 0 THEN substr(path, 1, instr(path, '?') - 1) ELSE path END) NOT LIKE '%.js'
-			AND (CASE WHEN instr(path, '?') > 0 THEN substr(path, 1, instr(path, '?') - 1) ELSE path END) NOT LIKE '%.png'
-			AND (CASE WHEN instr(path, '?') > 0 THEN substr(path, 1, instr(path, '?') - 1) ELSE path END) NOT LIKE '%.jpg'
-			AND (CASE WHEN instr(path, '?') > 0 THEN substr(path, 1, instr(path, '?') - 1) ELSE path END) NOT LIKE '%.gif'
-			AND (CASE WHEN instr(path, '?') > 0 THEN substr(path, 1, instr(path, '?') - 1) ELSE path END) NOT LIKE '%.svg'
-			AND (CASE WHEN instr(path, '?') > 0 THEN substr(path, 1, instr(path, '?') - 1) ELSE path END) NOT LIKE '%.ico'
-		THEN 1 ELSE 0 END) as pages,
-		COUNT(*) as hits
+		` + s.labels.orUnknown("browser") + ` as browser,
+		SUM(CASE WHEN ` + s.assets.pageSQL(cleanPathSQL) + ` THEN IFNULL(sample_weight, 1) ELSE 0 END) as pages,
+		SUM(IFNULL(sample_weight, 1)) as hits
 	FROM requests
 	WHERE ts >= ? AND is_bot = 0`
 
 	args := []any{from}
 	if host != "" {
-		query += " AND host = ?"
+		query += " AND " + hostMatch
 		args = append(args, host)
 	}
 	query += `
-	GROUP BY browser
+	GROUP BY 1
 ),
 totals AS (SELECT SUM(hits) as total FROM stats)
 SELECT browser, pages, hits, ROUND(100.0 * hits / NULLIF((SELECT total FROM totals), 0), 1) as percent
@@ -123,6 +146,97 @@ ORDER BY hits DESC LIMIT ?`
 	return out, rows.Err()
 }
 
+// Devices returns the most common device models among human visitors, with
+// each model's share of the requests whose device could be identified.
+func (s *Storage) Devices(ctx context.Context, dur time.Duration, host string, limit int) ([]DeviceStat, error) {
+	from := time.Now().Add(-dur)
+	if limit <= 0 {
+		limit = 10
+	}
+
+	query := `
+WITH stats AS (
+	SELECT MAX(device_brand) as brand, device_model as model, SUM(IFNULL(sample_weight, 1)) as hits
+	FROM requests
+	WHERE ts >= ? AND is_bot = 0 AND IFNULL(device_model, '') != ''`
+
+	args := []any{from}
+	if host != "" {
+		query += " AND " + hostMatch
+		args = append(args, host)
+	}
+	query += `
+	GROUP BY device_model
+),
+totals AS (SELECT SUM(hits) as total FROM stats)
+SELECT brand, model, hits, ROUND(100.0 * hits / NULLIF((SELECT total FROM totals), 0), 1) as percent
+FROM stats
+ORDER BY hits DESC, model LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []DeviceStat
+	for rows.Next() {
+		var d DeviceStat
+		if err := rows.Scan(&d.Brand, &d.Model, &d.Hits, &d.Percent); err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+// Languages returns the most common preferred languages (the first
+// Accept-Language tag) among human visitors. Requests without a logged
+// Accept-Language header are left out.
+func (s *Storage) Languages(ctx context.Context, dur time.Duration, host string, limit int) ([]LanguageStat, error) {
+	from := time.Now().Add(-dur)
+	if limit <= 0 {
+		limit = 10
+	}
+
+	query := `
+WITH stats AS (
+	SELECT language, SUM(IFNULL(sample_weight, 1)) as hits
+	FROM requests
+	WHERE ts >= ? AND is_bot = 0 AND IFNULL(language, '') != ''`
+
+	args := []any{from}
+	if host != "" {
+		query += " AND " + hostMatch
+		args = append(args, host)
+	}
+	query += `
+	GROUP BY language
+),
+totals AS (SELECT SUM(hits) as total FROM stats)
+SELECT language, hits, ROUND(100.0 * hits / NULLIF((SELECT total FROM totals), 0), 1) as percent
+FROM stats
+ORDER BY hits DESC, language LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []LanguageStat
+	for rows.Next() {
+		var l LanguageStat
+		if err := rows.Scan(&l.Language, &l.Hits, &l.Percent); err != nil {
+			return nil, err
+		}
+		out = append(out, l)
+	}
+	return out, rows.Err()
+}
+
 // OperatingSystems returns OS usage statistics.
 func (s *Storage) OperatingSystems(ctx context.Context, dur time.Duration, host string, limit int) ([]OSStat, error) {
 	from := time.Now().Add(-dur)
@@ -133,27 +247,19 @@ func (s *Storage) OperatingSystems(ctx context.Context, dur time.Duration, host
 	query := `
 WITH stats AS (
 	SELECT
-		CASE WHEN os = '' THEN 'Unknown' ELSE os END as os,
-		SUM(CASE WHEN
-			(CASE WHEN instr(path, '?') > 0 THEN substr(path, 1, instr(path, '?') - 1) ELSE path END) NOT LIKE '%.css'
-			AND (CASE WHEN instr(path, '?') > 0 THEN substr(path, 1, instr(path, '?') - 1) ELSE path END) NOT LIKE '%.js'
-			AND (CASE WHEN instr(path, '?') > 0 THEN substr(path, 1, instr(path, '?') - 1) ELSE path END) NOT LIKE '%.png'
-			AND (CASE WHEN instr(path, '?') > 0 THEN substr(path, 1, instr(path, '?') - 1) ELSE path END) NOT LIKE '%.jpg'
-			AND (CASE WHEN instr(path, '?') > 0 THEN substr(path, 1, instr(path, '?') - 1) ELSE path END) NOT LIKE '%.gif'
-			AND (CASE WHEN instr(path, '?') > 0 THEN substr(path, 1, instr(path, '?') - 1) ELSE path END) NOT LIKE '%.svg'
-			AND (CASE WHEN instr(path, '?') > 0 THEN substr(path, 1, instr(path, '?') - 1) ELSE path END) NOT LIKE '%.ico'
-		THEN 1 ELSE 0 END) as pages,
-		COUNT(*) as hits
+		` + s.labels.orUnknown("os") + ` as os,
+		SUM(CASE WHEN ` + s.assets.pageSQL(cleanPathSQL) + ` THEN IFNULL(sample_weight, 1) ELSE 0 END) as pages,
+		SUM(IFNULL(sample_weight, 1)) as hits
 	FROM requests
 	WHERE ts >= ? AND is_bot = 0`
 
 	args := []any{from}
 	if host != "" {
-		query += " AND host = ?"
+		query += " AND " + hostMatch
 		args = append(args, host)
 	}
 	query += `
-	GROUP BY os
+	GROUP BY 1
 ),
 totals AS (SELECT SUM(hits) as total FROM stats)
 SELECT os, pages, hits, ROUND(100.0 * hits / NULLIF((SELECT total FROM totals), 0), 1) as percent
@@ -178,6 +284,173 @@ ORDER BY hits DESC LIMIT ?`
 	return out, rows.Err()
 }
 
+// TopUserAgents returns the most frequent raw User-Agent strings, bots
+// included, with whether each was flagged as a bot. Percent is relative to
+// all requests in the range.
+func (s *Storage) TopUserAgents(ctx context.Context, dur time.Duration, host string, limit int) ([]UserAgentStat, error) {
+	from := time.Now().Add(-dur)
+	if limit <= 0 {
+		limit = 20
+	}
+
+	query := `
+WITH stats AS (
+	SELECT
+		IFNULL(user_agent, '') as user_agent,
+		SUM(IFNULL(sample_weight, 1)) as hits,
+		MAX(is_bot) as is_bot,
+		MAX(IFNULL(bot_name, '')) as bot_name
+	FROM requests
+	WHERE ts >= ?`
+
+	args := []any{from}
+	if host != "" {
+		query += " AND " + hostMatch
+		args = append(args, host)
+	}
+	query += `
+	GROUP BY IFNULL(user_agent, '')
+),
+totals AS (SELECT SUM(hits) as total FROM stats)
+SELECT user_agent, hits, ROUND(100.0 * hits / NULLIF((SELECT total FROM totals), 0), 1) as percent, is_bot, bot_name
+FROM stats
+ORDER BY hits DESC, user_agent LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []UserAgentStat{}
+	for rows.Next() {
+		var u UserAgentStat
+		if err := rows.Scan(&u.UserAgent, &u.Hits, &u.Percent, &u.IsBot, &u.BotName); err != nil {
+			return nil, err
+		}
+		out = append(out, u)
+	}
+	return out, rows.Err()
+}
+
+// UnclassifiedUserAgents returns the most frequent raw User-Agent strings
+// whose browser or OS parsed as empty (reported as "Unknown" by Browsers and
+// OperatingSystems), to guide improvements to the useragent parser. Bots are
+// excluded since they are classified separately.
+func (s *Storage) UnclassifiedUserAgents(ctx context.Context, dur time.Duration, host string, limit int) ([]UnclassifiedUserAgent, error) {
+	from := time.Now().Add(-dur)
+	if limit <= 0 {
+		limit = 20
+	}
+
+	query := `
+SELECT
+	IFNULL(user_agent, '') as user_agent,
+	SUM(IFNULL(sample_weight, 1)) as hits,
+	MAX(IFNULL(browser, '') = '') as unknown_browser,
+	MAX(IFNULL(os, '') = '') as unknown_os
+FROM requests
+WHERE ts >= ? AND is_bot = 0 AND (IFNULL(browser, '') = '' OR IFNULL(os, '') = '')`
+
+	args := []any{from}
+	if host != "" {
+		query += " AND " + hostMatch
+		args = append(args, host)
+	}
+	query += `
+GROUP BY IFNULL(user_agent, '')
+ORDER BY hits DESC, user_agent LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []UnclassifiedUserAgent{}
+	for rows.Next() {
+		var u UnclassifiedUserAgent
+		if err := rows.Scan(&u.UserAgent, &u.Hits, &u.UnknownBrowser, &u.UnknownOS); err != nil {
+			return nil, err
+		}
+		out = append(out, u)
+	}
+	return out, rows.Err()
+}
+
+// Methods returns request counts per HTTP method. Rows stored before the
+// method was recorded are reported as "Unknown".
+func (s *Storage) Methods(ctx context.Context, dur time.Duration, host string) ([]MethodStat, error) {
+	usage, err := s.usageBy(ctx, "method", time.Now().Add(-dur), host)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]MethodStat, 0, len(usage))
+	for _, u := range usage {
+		out = append(out, MethodStat{Method: u.Name, Hits: u.Hits, Percent: u.Percent})
+	}
+	return out, nil
+}
+
+// Protocols returns request counts per HTTP protocol version and per TLS
+// version ("none" for plain HTTP). Rows from logs without these fields are
+// reported as "Unknown".
+func (s *Storage) Protocols(ctx context.Context, dur time.Duration, host string) (ProtocolStats, error) {
+	from := time.Now().Add(-dur)
+	protocols, err := s.usageBy(ctx, "protocol", from, host)
+	if err != nil {
+		return ProtocolStats{}, fmt.Errorf("protocols: %w", err)
+	}
+	tlsVersions, err := s.usageBy(ctx, "tls_version", from, host)
+	if err != nil {
+		return ProtocolStats{}, fmt.Errorf("tls versions: %w", err)
+	}
+	return ProtocolStats{Protocols: protocols, TLSVersions: tlsVersions}, nil
+}
+
+// usageBy counts requests per value of column, most used first. column is
+// interpolated into the query and must be a trusted column name.
+func (s *Storage) usageBy(ctx context.Context, column string, from time.Time, host string) ([]UsageStat, error) {
+	query := `
+WITH stats AS (
+	SELECT
+		` + s.labels.orUnknown(column) + ` as name,
+		SUM(IFNULL(sample_weight, 1)) as hits
+	FROM requests
+	WHERE ts >= ?`
+
+	args := []any{from}
+	if host != "" {
+		query += " AND " + hostMatch
+		args = append(args, host)
+	}
+	query += `
+	GROUP BY 1
+),
+totals AS (SELECT SUM(hits) as total FROM stats)
+SELECT name, hits, ROUND(100.0 * hits / NULLIF((SELECT total FROM totals), 0), 1) as percent
+FROM stats
+ORDER BY hits DESC, name`
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []UsageStat{}
+	for rows.Next() {
+		var u UsageStat
+		if err := rows.Scan(&u.Name, &u.Hits, &u.Percent); err != nil {
+			return nil, err
+		}
+		out = append(out, u)
+	}
+	return out, rows.Err()
+}
+
 // Robots returns bot/spider statistics.
 func (s *Storage) Robots(ctx context.Context, dur time.Duration, host string, limit int) ([]RobotStat, error) {
 	from := time.Now().Add(-dur)
@@ -189,15 +462,15 @@ func (s *Storage) Robots(ctx context.Context, dur time.Duration, host string, li
 SELECT
 	CASE WHEN bot_name = '' THEN 'Unknown Bot' ELSE bot_name END as name,
 	CASE WHEN bot_intent = '' THEN 'unknown' ELSE bot_intent END as intent,
-	COUNT(*) as hits,
-	IFNULL(SUM(bytes), 0) as bandwidth,
+	SUM(IFNULL(sample_weight, 1)) as hits,
+	IFNULL(SUM(bytes * IFNULL(sample_weight, 1)), 0) as bandwidth,
 	MAX(ts) as last_visit
 FROM requests
 WHERE ts >= ? AND is_bot = 1`
 
 	args := []any{from}
 	if host != "" {
-		query += " AND host = ?"
+		query += " AND " + hostMatch
 		args = append(args, host)
 	}
 	query += " GROUP BY bot_name, bot_intent ORDER BY hits DESC LIMIT ?"
@@ -216,6 +489,7 @@ WHERE ts >= ? AND is_bot = 1`
 		if err := rows.Scan(&r.Name, &r.Intent, &r.Hits, &r.BandwidthBytes, &lastVisitStr); err != nil {
 			return nil, err
 		}
+		r.BandwidthHuman = humanizeBytes(r.BandwidthBytes)
 		if lastVisitStr.Valid {
 			r.LastVisit, _ = time.Parse("2006-01-02 15:04:05.999999999-07:00", lastVisitStr.String)
 			if r.LastVisit.IsZero() {
@@ -230,6 +504,47 @@ WHERE ts >= ? AND is_bot = 1`
 	return out, rows.Err()
 }
 
+// BotVerification returns, per bot with published IP ranges, how many
+// requests came from inside those ranges and how many merely claimed the bot's
+// user-agent. Bots that can't be verified by IP are omitted.
+func (s *Storage) BotVerification(ctx context.Context, dur time.Duration, host string) ([]BotVerificationStat, error) {
+	from := time.Now().Add(-dur)
+
+	query := `
+SELECT
+	bot_name,
+	IFNULL(SUM(CASE WHEN bot_verification = 'verified' THEN IFNULL(sample_weight, 1) ELSE 0 END), 0) as verified,
+	IFNULL(SUM(CASE WHEN bot_verification = 'unverified' THEN IFNULL(sample_weight, 1) ELSE 0 END), 0) as unverified
+FROM requests
+WHERE ts >= ? AND is_bot = 1 AND IFNULL(bot_verification, '') != ''`
+
+	args := []any{from}
+	if host != "" {
+		query += " AND " + hostMatch
+		args = append(args, host)
+	}
+	query += " GROUP BY bot_name ORDER BY unverified DESC, verified DESC"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []BotVerificationStat
+	for rows.Next() {
+		var b BotVerificationStat
+		if err := rows.Scan(&b.Name, &b.Verified, &b.Unverified); err != nil {
+			return nil, err
+		}
+		if total := b.Verified + b.Unverified; total > 0 {
+			b.SpoofedPercent = float64(b.Unverified) / float64(total) * 100
+		}
+		out = append(out, b)
+	}
+	return out, rows.Err()
+}
+
 // Referrers returns referrer statistics.
 func (s *Storage) Referrers(ctx context.Context, dur time.Duration, host string, limit int) ([]ReferrerStat, error) {
 	from := time.Now().Add(-dur)
@@ -239,32 +554,21 @@ func (s *Storage) Referrers(ctx context.Context, dur time.Duration, host string,
 
 	query := `
 SELECT
-	CASE
-		WHEN referrer IS NULL OR referrer = '' THEN 'Direct / Bookmark'
-		ELSE referrer
-	END as ref,
+	` + s.labels.orDirect("referrer") + ` as ref,
 	CASE
 		WHEN referrer IS NULL OR referrer = '' THEN 'direct'
 		WHEN referrer LIKE '%google.%' OR referrer LIKE '%bing.%' OR referrer LIKE '%yahoo.%'
 			OR referrer LIKE '%duckduckgo.%' OR referrer LIKE '%baidu.%' OR referrer LIKE '%yandex.%' THEN 'search'
 		ELSE 'external'
 	END as ref_type,
-	SUM(CASE WHEN
-		(CASE WHEN instr(path, '?') > 0 THEN substr(path, 1, instr(path, '?') - 1) ELSE path END) NOT LIKE '%.css'
-		AND (CASE WHEN instr(path, '?') > 0 THEN substr(path, 1, instr(path, '?') - 1) ELSE path END) NOT LIKE '%.js'
-		AND (CASE WHEN instr(path, '?') > 0 THEN substr(path, 1, instr(path, '?') - 1) ELSE path END) NOT LIKE '%.png'
-		AND (CASE WHEN instr(path, '?') > 0 THEN substr(path, 1, instr(path, '?') - 1) ELSE path END) NOT LIKE '%.jpg'
-		AND (CASE WHEN instr(path, '?') > 0 THEN substr(path, 1, instr(path, '?') - 1) ELSE path END) NOT LIKE '%.gif'
-		AND (CASE WHEN instr(path, '?') > 0 THEN substr(path, 1, instr(path, '?') - 1) ELSE path END) NOT LIKE '%.svg'
-		AND (CASE WHEN instr(path, '?') > 0 THEN substr(path, 1, instr(path, '?') - 1) ELSE path END) NOT LIKE '%.ico'
-	THEN 1 ELSE 0 END) as pages,
-	COUNT(*) as hits
+	SUM(CASE WHEN ` + s.assets.pageSQL(cleanPathSQL) + ` THEN IFNULL(sample_weight, 1) ELSE 0 END) as pages,
+	SUM(IFNULL(sample_weight, 1)) as hits
 FROM requests
 WHERE ts >= ? AND is_bot = 0`
 
 	args := []any{from}
 	if host != "" {
-		query += " AND host = ?"
+		query += " AND " + hostMatch
 		args = append(args, host)
 	}
 	query += " GROUP BY ref ORDER BY hits DESC LIMIT ?"
@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"path"
+	"strings"
+)
+
+// defaultAssetExtensions lists file extensions whose requests are counted as
+// hits but not as page views. Data and text files such as .json, .xml, .csv
+// and .txt are left out, as they always have been: they are often the
+// content a visitor came for.
+var defaultAssetExtensions = []string{
+	"css", "js", "mjs", "map",
+	"png", "jpg", "jpeg", "gif", "svg", "ico", "avif", "bmp",
+	"woff", "woff2", "ttf", "eot", "otf",
+}
+
+// assetMatcher decides which paths are static assets rather than pages. The
+// same extension list drives isAsset and the SQL predicates built by
+// assetSQL/pageSQL, so Go code and every query agree on what a page is.
+type assetMatcher struct {
+	exts []string
+	set  map[string]struct{}
+}
+
+// newAssetMatcher builds a matcher from an extension list; a nil list uses
+// defaultAssetExtensions. Extensions are lowercased, a leading dot is
+// dropped, and anything other than [a-z0-9] is discarded so the values can
+// be embedded in SQL.
+func newAssetMatcher(exts []string) *assetMatcher {
+	if exts == nil {
+		exts = defaultAssetExtensions
+	}
+	m := &assetMatcher{set: make(map[string]struct{})}
+	for _, e := range exts {
+		e = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(e)), ".")
+		if e == "" || strings.IndexFunc(e, func(r rune) bool {
+			return (r < 'a' || r > 'z') && (r < '0' || r > '9')
+		}) >= 0 {
+			continue
+		}
+		if _, ok := m.set[e]; ok {
+			continue
+		}
+		m.set[e] = struct{}{}
+		m.exts = append(m.exts, e)
+	}
+	return m
+}
+
+// isAsset reports whether p (with or without a query string) is a static
+// asset.
+func (m *assetMatcher) isAsset(p string) bool {
+	if i := strings.IndexByte(p, '?'); i >= 0 {
+		p = p[:i]
+	}
+	ext := path.Ext(p)
+	if ext == "" {
+		return false
+	}
+	_, ok := m.set[strings.ToLower(ext[1:])]
+	return ok
+}
+
+// assetSQL returns a predicate that is true when the query-stripped path in
+// col ends with an asset extension. SQLite's LIKE is case-insensitive for
+// ASCII, matching isAsset.
+func (m *assetMatcher) assetSQL(col string) string {
+	if len(m.exts) == 0 {
+		return "0"
+	}
+	parts := make([]string, len(m.exts))
+	for i, e := range m.exts {
+		parts[i] = col + " LIKE '%." + e + "'"
+	}
+	return "(" + strings.Join(parts, " OR ") + ")"
+}
+
+// pageSQL returns the negation of assetSQL for counting page views.
+func (m *assetMatcher) pageSQL(col string) string {
+	if len(m.exts) == 0 {
+		return "1"
+	}
+	parts := make([]string, len(m.exts))
+	for i, e := range m.exts {
+		parts[i] = col + " NOT LIKE '%." + e + "'"
+	}
+	return "(" + strings.Join(parts, " AND ") + ")"
+}
+
+// cleanPathSQL strips the query string from the path column.
+const cleanPathSQL = "(CASE WHEN instr(path, '?') > 0 THEN substr(path, 1, instr(path, '?') - 1) ELSE path END)"
@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestIsAsset(t *testing.T) {
+	m := newAssetMatcher(nil)
+	assets := []string{
+		"/style.css", "/app.js", "/module.mjs", "/app.js.map",
+		"/logo.png", "/photo.jpg", "/photo.jpeg", "/anim.gif", "/icon.svg",
+		"/favicon.ico", "/hero.avif", "/old.bmp",
+		"/font.woff", "/font.woff2", "/font.ttf", "/font.eot", "/font.otf",
+		"/STYLE.CSS", "/Logo.PNG", "/app.js?v=123", "/favicon.ico?", "/a/b/c/deep.woff2?x=1&y=2",
+	}
+	for _, p := range assets {
+		if !m.isAsset(p) {
+			t.Errorf("isAsset(%q) = false, want true", p)
+		}
+	}
+
+	pages := []string{
+		"", "/", "/about", "/blog/post/", "/index.html", "/page.htm", "/page.php",
+		"/download.pdf", "/archive.zip", "/search?q=style.css", "/css", "/js/",
+		"/v1.2/docs", "/file.cssx", "/file.json5", "/post.woff3",
+		"/api/data.json", "/sitemap.xml", "/export.csv", "/robots.txt", "/hero.webp",
+	}
+	for _, p := range pages {
+		if m.isAsset(p) {
+			t.Errorf("isAsset(%q) = true, want false", p)
+		}
+	}
+}
+
+func TestNewAssetMatcher_Custom(t *testing.T) {
+	m := newAssetMatcher([]string{" .PDF", "zip", "bad'ext", "", "pdf"})
+	if len(m.exts) != 2 {
+		t.Fatalf("exts = %v, want [pdf zip]", m.exts)
+	}
+	if !m.isAsset("/report.pdf") || !m.isAsset("/bundle.ZIP") {
+		t.Error("expected configured extensions to match")
+	}
+	if m.isAsset("/style.css") {
+		t.Error("custom list should replace the defaults")
+	}
+	if got := m.assetSQL("p"); got != "(p LIKE '%.pdf' OR p LIKE '%.zip')" {
+		t.Errorf("assetSQL() = %q", got)
+	}
+	if got := m.pageSQL("p"); got != "(p NOT LIKE '%.pdf' AND p NOT LIKE '%.zip')" {
+		t.Errorf("pageSQL() = %q", got)
+	}
+
+	empty := newAssetMatcher([]string{})
+	if empty.isAsset("/style.css") || empty.assetSQL("p") != "0" || empty.pageSQL("p") != "1" {
+		t.Error("empty list should treat every path as a page")
+	}
+}
+
+func TestStorage_PageCountsAgree(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	paths := []string{
+		"/", "/about", "/blog/post?id=1", "/style.css", "/app.js?v=2",
+		"/font.woff2", "/favicon.ico", "/sitemap.xml", "/photo.JPEG", "/feed.json",
+	}
+	assets := newAssetMatcher(nil)
+	wantPages := 0
+	for i, p := range paths {
+		if !assets.isAsset(p) {
+			wantPages++
+		}
+		req := RequestRecord{
+			Timestamp: now.Add(-time.Duration(i+1) * time.Minute),
+			Host:      "example.com",
+			Path:      p,
+			Status:    200,
+			IP:        "10.0.0.1",
+			UserAgent: "Mozilla/5.0",
+		}
+		if err := s.InsertRequest(ctx, req); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	summary, err := s.Summary(ctx, time.Hour, "")
+	if err != nil {
+		t.Fatalf("Summary() error = %v", err)
+	}
+	visitors, err := s.Visitors(ctx, time.Hour, "", 10, VisitorSortHits)
+	if err != nil {
+		t.Fatalf("Visitors() error = %v", err)
+	}
+	var visitorPages int64
+	for _, v := range visitors {
+		visitorPages += v.Pages
+	}
+
+	if got := summary.Traffic.Viewed.Pages; got != int64(wantPages) {
+		t.Errorf("Summary viewed pages = %d, want %d", got, wantPages)
+	}
+	if visitorPages != summary.Traffic.Viewed.Pages {
+		t.Errorf("Visitors pages = %d, Summary pages = %d; want equal", visitorPages, summary.Traffic.Viewed.Pages)
+	}
+}
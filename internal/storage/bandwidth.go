@@ -61,10 +61,10 @@ func (s *Storage) BandwidthStats(ctx context.Context, dur time.Duration, host st
 
 // totalBandwidth returns the total bytes transferred in the given time range.
 func (s *Storage) totalBandwidth(ctx context.Context, from time.Time, host string) (int64, error) {
-	query := `SELECT IFNULL(SUM(bytes), 0) FROM requests WHERE ts >= ?`
+	query := `SELECT IFNULL(SUM(bytes * IFNULL(sample_weight, 1)), 0) FROM requests WHERE ts >= ?`
 	args := []any{from}
 	if host != "" {
-		query += " AND host = ?"
+		query += " AND " + hostMatch
 		args = append(args, host)
 	}
 
@@ -79,14 +79,14 @@ func (s *Storage) totalBandwidth(ctx context.Context, from time.Time, host strin
 func (s *Storage) bandwidthByHost(ctx context.Context, from time.Time, limit int) ([]HostBandwidth, error) {
 	query := `
 WITH totals AS (
-	SELECT IFNULL(SUM(bytes), 0) AS total_bytes FROM requests WHERE ts >= ?
+	SELECT IFNULL(SUM(bytes * IFNULL(sample_weight, 1)), 0) AS total_bytes FROM requests WHERE ts >= ?
 )
 SELECT
 	host,
-	IFNULL(SUM(bytes), 0) AS bytes,
-	COUNT(*) AS requests,
+	IFNULL(SUM(bytes * IFNULL(sample_weight, 1)), 0) AS bytes,
+	SUM(IFNULL(sample_weight, 1)) AS requests,
 	ROUND(IFNULL(AVG(bytes), 0), 2) AS avg_bytes,
-	ROUND(100.0 * IFNULL(SUM(bytes), 0) / NULLIF((SELECT total_bytes FROM totals), 0), 2) AS percent
+	ROUND(100.0 * IFNULL(SUM(bytes * IFNULL(sample_weight, 1)), 0) / NULLIF((SELECT total_bytes FROM totals), 0), 2) AS percent
 FROM requests
 WHERE ts >= ?
 GROUP BY host
@@ -117,27 +117,28 @@ func (s *Storage) bandwidthByPath(ctx context.Context, from time.Time, host stri
 WITH filtered AS (
 	SELECT
 		CASE WHEN instr(path, '?') > 0 THEN substr(path, 1, instr(path, '?') - 1) ELSE path END AS clean_path,
-		bytes
+		bytes,
+		IFNULL(sample_weight, 1) AS weight
 	FROM requests
 	WHERE ts >= ?`
 
 	args := []any{from}
 	if host != "" {
-		query += " AND host = ?"
+		query += " AND " + hostMatch
 		args = append(args, host)
 	}
 
 	query += `
 ),
 totals AS (
-	SELECT IFNULL(SUM(bytes), 0) AS total_bytes FROM filtered
+	SELECT IFNULL(SUM(bytes * weight), 0) AS total_bytes FROM filtered
 )
 SELECT
 	clean_path,
-	IFNULL(SUM(bytes), 0) AS bytes,
-	COUNT(*) AS requests,
+	IFNULL(SUM(bytes * weight), 0) AS bytes,
+	SUM(weight) AS requests,
 	ROUND(IFNULL(AVG(bytes), 0), 2) AS avg_bytes,
-	ROUND(100.0 * IFNULL(SUM(bytes), 0) / NULLIF((SELECT total_bytes FROM totals), 0), 2) AS percent
+	ROUND(100.0 * IFNULL(SUM(bytes * weight), 0) / NULLIF((SELECT total_bytes FROM totals), 0), 2) AS percent
 FROM filtered
 GROUP BY clean_path
 ORDER BY bytes DESC
@@ -162,52 +163,230 @@ LIMIT ?`
 	return results, rows.Err()
 }
 
-// bandwidthByContentType returns bandwidth statistics grouped by content type (file extension).
+// BandwidthByCountry returns bytes served per visitor country, largest
+// first. Requests without a geo lookup are grouped as "Unknown".
+func (s *Storage) BandwidthByCountry(ctx context.Context, dur time.Duration, host string, limit int) ([]CountryBandwidth, error) {
+	from := time.Now().Add(-dur)
+	if limit <= 0 {
+		limit = 10
+	}
+
+	query := `
+WITH filtered AS (
+	SELECT
+		` + s.labels.orUnknown("country") + ` AS country,
+		bytes,
+		IFNULL(sample_weight, 1) AS weight
+	FROM requests
+	WHERE ts >= ?`
+	args := []any{from}
+	if host != "" {
+		query += " AND " + hostMatch
+		args = append(args, host)
+	}
+	query += `
+),
+totals AS (
+	SELECT IFNULL(SUM(bytes * weight), 0) AS total_bytes FROM filtered
+)
+SELECT
+	country,
+	IFNULL(SUM(bytes * weight), 0) AS bytes,
+	SUM(weight) AS requests,
+	ROUND(100.0 * IFNULL(SUM(bytes * weight), 0) / NULLIF((SELECT total_bytes FROM totals), 0), 2) AS percent
+FROM filtered
+GROUP BY country
+ORDER BY bytes DESC, country
+LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []CountryBandwidth{}
+	for rows.Next() {
+		var cb CountryBandwidth
+		var percent sql.NullFloat64
+		if err := rows.Scan(&cb.Country, &cb.Bytes, &cb.Requests, &percent); err != nil {
+			return nil, err
+		}
+		cb.Percent = percent.Float64
+		cb.BytesHuman = humanizeBytes(cb.Bytes)
+		results = append(results, cb)
+	}
+	return results, rows.Err()
+}
+
+// TopDownloads returns the paths with the largest individual responses,
+// which surfaces a single oversized asset that per-path totals can hide
+// behind frequently requested small pages.
+func (s *Storage) TopDownloads(ctx context.Context, dur time.Duration, host string, limit int) ([]DownloadStat, error) {
+	from := time.Now().Add(-dur)
+	if limit <= 0 {
+		limit = 10
+	}
+
+	query := `
+SELECT
+	CASE WHEN instr(path, '?') > 0 THEN substr(path, 1, instr(path, '?') - 1) ELSE path END AS clean_path,
+	MAX(bytes) AS max_bytes,
+	SUM(IFNULL(sample_weight, 1)) AS requests,
+	IFNULL(SUM(bytes * IFNULL(sample_weight, 1)), 0) AS total_bytes
+FROM requests
+WHERE ts >= ? AND bytes > 0`
+	args := []any{from}
+	if host != "" {
+		query += " AND " + hostMatch
+		args = append(args, host)
+	}
+	query += `
+GROUP BY clean_path
+ORDER BY max_bytes DESC, clean_path
+LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []DownloadStat{}
+	for rows.Next() {
+		var d DownloadStat
+		if err := rows.Scan(&d.Path, &d.MaxBytes, &d.Requests, &d.TotalBytes); err != nil {
+			return nil, err
+		}
+		d.MaxBytesHuman = humanizeBytes(d.MaxBytes)
+		results = append(results, d)
+	}
+	return results, rows.Err()
+}
+
+// ZeroByteResponses returns paths that answered 2xx with an empty body,
+// which usually points at a broken backend or truncated transfer. 204 and
+// 205 responses and HEAD requests have no body by definition and are
+// ignored.
+func (s *Storage) ZeroByteResponses(ctx context.Context, dur time.Duration, host string, limit int) ([]ZeroByteStat, error) {
+	from := time.Now().Add(-dur)
+	if limit <= 0 {
+		limit = 20
+	}
+
+	query := `
+SELECT
+	CASE WHEN instr(path, '?') > 0 THEN substr(path, 1, instr(path, '?') - 1) ELSE path END AS clean_path,
+	SUM(IFNULL(sample_weight, 1)) AS c
+FROM requests
+WHERE ts >= ?
+	AND status BETWEEN 200 AND 299 AND status NOT IN (204, 205)
+	AND bytes = 0
+	AND IFNULL(method, '') != 'HEAD'`
+	args := []any{from}
+	if host != "" {
+		query += " AND " + hostMatch
+		args = append(args, host)
+	}
+	query += `
+GROUP BY clean_path
+ORDER BY c DESC, clean_path
+LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []ZeroByteStat{}
+	for rows.Next() {
+		var z ZeroByteStat
+		if err := rows.Scan(&z.Path, &z.Count); err != nil {
+			return nil, err
+		}
+		results = append(results, z)
+	}
+	return results, rows.Err()
+}
+
+// contentTypeSQL labels a request's content type. The logged response
+// Content-Type wins; rows without one (older rows, or logs without
+// resp_headers) fall back to guessing from the path's file extension.
+// Media types without a label of their own are reported as-is.
+const contentTypeSQL = `CASE
+	WHEN IFNULL(content_type, '') = '' THEN CASE
+		WHEN path LIKE '%.html' OR path LIKE '%.htm' THEN 'HTML'
+		WHEN path LIKE '%.css' THEN 'CSS'
+		WHEN path LIKE '%.js' THEN 'JavaScript'
+		WHEN path LIKE '%.json' THEN 'JSON'
+		WHEN path LIKE '%.xml' THEN 'XML'
+		WHEN path LIKE '%.png' THEN 'PNG Image'
+		WHEN path LIKE '%.jpg' OR path LIKE '%.jpeg' THEN 'JPEG Image'
+		WHEN path LIKE '%.gif' THEN 'GIF Image'
+		WHEN path LIKE '%.svg' THEN 'SVG Image'
+		WHEN path LIKE '%.webp' THEN 'WebP Image'
+		WHEN path LIKE '%.ico' THEN 'Icon'
+		WHEN path LIKE '%.woff' OR path LIKE '%.woff2' THEN 'Web Font'
+		WHEN path LIKE '%.ttf' OR path LIKE '%.otf' OR path LIKE '%.eot' THEN 'Font'
+		WHEN path LIKE '%.pdf' THEN 'PDF'
+		WHEN path LIKE '%.zip' OR path LIKE '%.gz' OR path LIKE '%.tar' THEN 'Archive'
+		WHEN path LIKE '%.mp4' OR path LIKE '%.webm' OR path LIKE '%.avi' THEN 'Video'
+		WHEN path LIKE '%.mp3' OR path LIKE '%.wav' OR path LIKE '%.ogg' THEN 'Audio'
+		WHEN path NOT LIKE '%.%' OR path LIKE '%/' THEN 'Page'
+		ELSE 'Other'
+	END
+	WHEN content_type IN ('text/html', 'application/xhtml+xml') THEN 'HTML'
+	WHEN content_type = 'text/css' THEN 'CSS'
+	WHEN content_type LIKE '%javascript' THEN 'JavaScript'
+	WHEN content_type LIKE '%json' THEN 'JSON'
+	WHEN content_type = 'image/png' THEN 'PNG Image'
+	WHEN content_type = 'image/jpeg' THEN 'JPEG Image'
+	WHEN content_type = 'image/gif' THEN 'GIF Image'
+	WHEN content_type = 'image/svg+xml' THEN 'SVG Image'
+	WHEN content_type = 'image/webp' THEN 'WebP Image'
+	WHEN content_type IN ('image/x-icon', 'image/vnd.microsoft.icon') THEN 'Icon'
+	WHEN content_type LIKE '%xml' THEN 'XML'
+	WHEN content_type IN ('font/woff', 'font/woff2') THEN 'Web Font'
+	WHEN content_type LIKE 'font/%' THEN 'Font'
+	WHEN content_type = 'application/pdf' THEN 'PDF'
+	WHEN content_type IN ('application/zip', 'application/gzip', 'application/x-tar') THEN 'Archive'
+	WHEN content_type LIKE 'video/%' THEN 'Video'
+	WHEN content_type LIKE 'audio/%' THEN 'Audio'
+	ELSE content_type
+END`
+
+// bandwidthByContentType returns bandwidth statistics grouped by content
+// type (see contentTypeSQL).
 func (s *Storage) bandwidthByContentType(ctx context.Context, from time.Time, host string, limit int) ([]ContentBandwidth, error) {
 	query := `
 WITH filtered AS (
 	SELECT
 		bytes,
-		CASE
-			WHEN path LIKE '%.html' OR path LIKE '%.htm' THEN 'HTML'
-			WHEN path LIKE '%.css' THEN 'CSS'
-			WHEN path LIKE '%.js' THEN 'JavaScript'
-			WHEN path LIKE '%.json' THEN 'JSON'
-			WHEN path LIKE '%.xml' THEN 'XML'
-			WHEN path LIKE '%.png' THEN 'PNG Image'
-			WHEN path LIKE '%.jpg' OR path LIKE '%.jpeg' THEN 'JPEG Image'
-			WHEN path LIKE '%.gif' THEN 'GIF Image'
-			WHEN path LIKE '%.svg' THEN 'SVG Image'
-			WHEN path LIKE '%.webp' THEN 'WebP Image'
-			WHEN path LIKE '%.ico' THEN 'Icon'
-			WHEN path LIKE '%.woff' OR path LIKE '%.woff2' THEN 'Web Font'
-			WHEN path LIKE '%.ttf' OR path LIKE '%.otf' OR path LIKE '%.eot' THEN 'Font'
-			WHEN path LIKE '%.pdf' THEN 'PDF'
-			WHEN path LIKE '%.zip' OR path LIKE '%.gz' OR path LIKE '%.tar' THEN 'Archive'
-			WHEN path LIKE '%.mp4' OR path LIKE '%.webm' OR path LIKE '%.avi' THEN 'Video'
-			WHEN path LIKE '%.mp3' OR path LIKE '%.wav' OR path LIKE '%.ogg' THEN 'Audio'
-			WHEN path NOT LIKE '%.%' OR path LIKE '%/' THEN 'Page'
-			ELSE 'Other'
-		END AS content_type
+		IFNULL(sample_weight, 1) AS weight,
+		` + contentTypeSQL + ` AS content_type
 	FROM requests
 	WHERE ts >= ?`
 
 	args := []any{from}
 	if host != "" {
-		query += " AND host = ?"
+		query += " AND " + hostMatch
 		args = append(args, host)
 	}
 
 	query += `
 ),
 totals AS (
-	SELECT IFNULL(SUM(bytes), 0) AS total_bytes FROM filtered
+	SELECT IFNULL(SUM(bytes * weight), 0) AS total_bytes FROM filtered
 )
 SELECT
 	content_type,
-	IFNULL(SUM(bytes), 0) AS bytes,
-	COUNT(*) AS requests,
-	ROUND(100.0 * IFNULL(SUM(bytes), 0) / NULLIF((SELECT total_bytes FROM totals), 0), 2) AS percent
+	IFNULL(SUM(bytes * weight), 0) AS bytes,
+	SUM(weight) AS requests,
+	ROUND(100.0 * IFNULL(SUM(bytes * weight), 0) / NULLIF((SELECT total_bytes FROM totals), 0), 2) AS percent
 FROM filtered
 GROUP BY content_type
 ORDER BY bytes DESC
@@ -237,14 +416,14 @@ func (s *Storage) bandwidthTimeSeries(ctx context.Context, from time.Time, host
 	query := `
 SELECT
 	strftime('%Y-%m-%dT%H:00:00Z', ts) as bucket,
-	IFNULL(SUM(bytes), 0) as bytes,
-	COUNT(*) as requests
+	IFNULL(SUM(bytes * IFNULL(sample_weight, 1)), 0) as bytes,
+	SUM(IFNULL(sample_weight, 1)) as requests
 FROM requests
 WHERE ts >= ? AND ts IS NOT NULL`
 
 	args := []any{from}
 	if host != "" {
-		query += " AND host = ?"
+		query += " AND " + hostMatch
 		args = append(args, host)
 	}
 
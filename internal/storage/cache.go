@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// CacheStats counts requests by upstream cache status and computes the hit
+// ratio over cacheable (hit or miss) requests.
+func (s *Storage) CacheStats(ctx context.Context, dur time.Duration, host string) (CacheStats, error) {
+	from := time.Now().Add(-dur)
+	query := `
+SELECT
+	IFNULL(SUM(CASE WHEN cache_status = 'HIT' THEN IFNULL(sample_weight, 1) ELSE 0 END), 0),
+	IFNULL(SUM(CASE WHEN cache_status = 'MISS' THEN IFNULL(sample_weight, 1) ELSE 0 END), 0),
+	IFNULL(SUM(CASE WHEN cache_status = 'BYPASS' THEN IFNULL(sample_weight, 1) ELSE 0 END), 0),
+	IFNULL(SUM(CASE WHEN cache_status NOT IN ('', 'HIT', 'MISS', 'BYPASS') THEN IFNULL(sample_weight, 1) ELSE 0 END), 0),
+	IFNULL(SUM(CASE WHEN IFNULL(cache_status, '') = '' THEN IFNULL(sample_weight, 1) ELSE 0 END), 0)
+FROM requests
+WHERE ts >= ?`
+	args := []any{from}
+	if host != "" {
+		query += " AND " + hostMatch
+		args = append(args, host)
+	}
+
+	var out CacheStats
+	if err := s.db.QueryRowContext(ctx, query, args...).Scan(&out.Hits, &out.Misses, &out.Bypasses, &out.Other, &out.Unknown); err != nil {
+		return out, err
+	}
+	if cacheable := out.Hits + out.Misses; cacheable > 0 {
+		out.HitRatio = float64(out.Hits) / float64(cacheable)
+	}
+	return out, nil
+}
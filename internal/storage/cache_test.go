@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestStorage_CacheStats(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	statuses := []string{"HIT", "HIT", "HIT", "MISS", "BYPASS", "ERROR", ""}
+	for _, cs := range statuses {
+		rec := RequestRecord{Timestamp: now, Host: "example.com", Path: "/", Status: 200, CacheStatus: cs}
+		if err := s.InsertRequest(ctx, rec); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+	// Another site's traffic must not leak into a host-filtered report
+	other := RequestRecord{Timestamp: now, Host: "other.com", Path: "/", Status: 200, CacheStatus: "MISS"}
+	if err := s.InsertRequest(ctx, other); err != nil {
+		t.Fatalf("InsertRequest() error = %v", err)
+	}
+
+	stats, err := s.CacheStats(ctx, time.Hour, "example.com")
+	if err != nil {
+		t.Fatalf("CacheStats() error = %v", err)
+	}
+	want := CacheStats{Hits: 3, Misses: 1, Bypasses: 1, Other: 1, Unknown: 1, HitRatio: 0.75}
+	if stats != want {
+		t.Errorf("CacheStats() = %+v, want %+v", stats, want)
+	}
+
+	stats, err = s.CacheStats(ctx, time.Hour, "")
+	if err != nil {
+		t.Fatalf("CacheStats() error = %v", err)
+	}
+	if stats.Misses != 2 || math.Abs(stats.HitRatio-0.6) > 1e-9 {
+		t.Errorf("CacheStats(all) = %+v, want 2 misses and ratio 0.6", stats)
+	}
+}
+
+func TestStorage_CacheStats_Empty(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	stats, err := s.CacheStats(context.Background(), time.Hour, "")
+	if err != nil {
+		t.Fatalf("CacheStats() error = %v", err)
+	}
+	if stats != (CacheStats{}) {
+		t.Errorf("CacheStats() on empty DB = %+v, want zero", stats)
+	}
+}
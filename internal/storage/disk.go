@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"time"
+)
+
+// MonitorDiskSpace checks free space on the database volume immediately and
+// then every interval until ctx is done. While free space is below minFree
+// bytes, DiskSpaceLow reports true so writers can pause. A non-positive
+// minFree disables the check.
+func (s *Storage) MonitorDiskSpace(ctx context.Context, minFree int64, interval time.Duration) {
+	if minFree <= 0 {
+		return
+	}
+	s.diskMinFree.Store(minFree)
+	s.CheckDiskSpace()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.CheckDiskSpace()
+			}
+		}
+	}()
+}
+
+// CheckDiskSpace re-evaluates free space against the configured threshold,
+// logging when the low-space condition starts or ends. It returns the new
+// DiskSpaceLow value and is a no-op when monitoring isn't enabled.
+func (s *Storage) CheckDiskSpace() bool {
+	minFree := s.diskMinFree.Load()
+	if minFree <= 0 {
+		return false
+	}
+	dbPath := s.DBPath()
+	if dbPath == "" || dbPath == ":memory:" {
+		return false
+	}
+
+	free, err := s.diskFree(filepath.Dir(dbPath))
+	if err != nil {
+		slog.Warn("failed to check free disk space", "path", dbPath, "error", err)
+		return s.diskLow.Load()
+	}
+
+	low := free < uint64(minFree)
+	if was := s.diskLow.Swap(low); was != low {
+		if low {
+			slog.Warn("free disk space below threshold, pausing ingest",
+				"free", humanizeBytes(int64(free)), "min_free", humanizeBytes(minFree))
+		} else {
+			slog.Info("free disk space recovered, resuming ingest",
+				"free", humanizeBytes(int64(free)), "min_free", humanizeBytes(minFree))
+		}
+	}
+	return low
+}
+
+// SetDiskFreeFunc replaces the function used to measure free bytes on the
+// database volume (useful for testing).
+func (s *Storage) SetDiskFreeFunc(fn func(path string) (uint64, error)) {
+	s.diskFree = fn
+}
+
+// DiskSpaceLow reports whether the last check found free space below the
+// configured threshold.
+func (s *Storage) DiskSpaceLow() bool {
+	return s.diskLow.Load()
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package storage
+
+import "errors"
+
+// diskFreeBytes is not implemented on this platform.
+func diskFreeBytes(path string) (uint64, error) {
+	return 0, errors.New("free disk space check not supported on this platform")
+}
@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStorage_CheckDiskSpace(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	free := uint64(10 << 20)
+	s.SetDiskFreeFunc(func(string) (uint64, error) { return free, nil })
+
+	// Disabled until monitoring starts
+	if s.CheckDiskSpace() {
+		t.Error("CheckDiskSpace() should report false when monitoring is disabled")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.MonitorDiskSpace(ctx, 50<<20, time.Hour)
+	if !s.DiskSpaceLow() {
+		t.Error("DiskSpaceLow() = false with 10MB free and 50MB threshold")
+	}
+
+	free = 100 << 20
+	if s.CheckDiskSpace() {
+		t.Error("CheckDiskSpace() = true after space recovered")
+	}
+	if s.DiskSpaceLow() {
+		t.Error("DiskSpaceLow() = true after space recovered")
+	}
+}
+
+func TestStorage_CheckDiskSpace_StatErrorKeepsState(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	s.SetDiskFreeFunc(func(string) (uint64, error) { return 0, nil })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.MonitorDiskSpace(ctx, 1, time.Hour)
+	if !s.DiskSpaceLow() {
+		t.Fatal("expected low disk space")
+	}
+
+	s.SetDiskFreeFunc(func(string) (uint64, error) { return 0, errors.New("stat failed") })
+	if !s.CheckDiskSpace() {
+		t.Error("a failed check should keep the previous state")
+	}
+}
+
+func TestDiskFreeBytes(t *testing.T) {
+	free, err := diskFreeBytes(t.TempDir())
+	if err != nil {
+		t.Skipf("free space check unsupported: %v", err)
+	}
+	if free == 0 {
+		t.Error("expected non-zero free space for temp dir")
+	}
+}
@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"time"
+)
+
+// GrowthEstimate returns how fast the requests table is growing, for sizing
+// disk. Counts come from the hourly rollups, so they cover only complete
+// hours and are weighted like every other total (a sampled row counts as
+// the requests it stands for).
+func (s *Storage) GrowthEstimate(ctx context.Context) (GrowthEstimate, error) {
+	return s.growthEstimate(ctx, time.Now())
+}
+
+func (s *Storage) growthEstimate(ctx context.Context, now time.Time) (GrowthEstimate, error) {
+	var out GrowthEstimate
+	end := now.UTC().Truncate(time.Hour)
+	dayStart := end.Add(-24 * time.Hour)
+
+	var first sql.NullString
+	err := s.db.QueryRowContext(ctx, `
+SELECT
+	IFNULL(SUM(CASE WHEN bucket_start >= ? THEN requests ELSE 0 END), 0),
+	IFNULL(SUM(requests), 0),
+	MIN(bucket_start)
+FROM rollups_hourly WHERE bucket_start >= ? AND bucket_start < ?`,
+		end.Add(-time.Hour), dayStart, end).Scan(&out.LastHour, &out.LastDay, &first)
+	if err != nil {
+		return out, err
+	}
+
+	// A database younger than a day is extrapolated from the hours it has
+	// seen so far rather than reported as a partial day.
+	out.HoursObserved = 24
+	if start := parseTimestamp(first.String); first.Valid && start.After(dayStart) {
+		out.HoursObserved = int(end.Sub(start) / time.Hour)
+	}
+	if out.LastDay > 0 && out.HoursObserved > 0 {
+		out.RowsPerDay = float64(out.LastDay) * 24 / float64(out.HoursObserved)
+	}
+
+	// Page count covers indexes and rollups too, which grow along with the
+	// raw rows, so bytes per row reflects the real cost of a request.
+	var pages, pageSize int64
+	if err := s.db.QueryRowContext(ctx, "PRAGMA page_count").Scan(&pages); err != nil {
+		return out, err
+	}
+	if err := s.db.QueryRowContext(ctx, "PRAGMA page_size").Scan(&pageSize); err != nil {
+		return out, err
+	}
+	out.DBSizeBytes = pages * pageSize
+	out.RequestsCount, out.CountEstimated, err = s.requestsCount(ctx)
+	if err != nil {
+		return out, err
+	}
+	if out.RequestsCount > 0 {
+		out.AvgBytesPerRow = math.Round(float64(out.DBSizeBytes)/float64(out.RequestsCount)*100) / 100
+	}
+	out.RowsPerDay = math.Round(out.RowsPerDay*100) / 100
+	out.BytesPerDay = int64(out.RowsPerDay * out.AvgBytesPerRow)
+	return out, nil
+}
@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStorage_GrowthEstimate(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Date(2026, 3, 10, 12, 30, 0, 0, time.UTC)
+
+	// Six complete hours of 10 requests each, plus traffic in the current
+	// hour that isn't counted until the hour closes
+	for h := 1; h <= 6; h++ {
+		for i := 0; i < 10; i++ {
+			ts := now.Truncate(time.Hour).Add(-time.Duration(h)*time.Hour + time.Duration(i)*time.Minute)
+			if err := s.InsertRequest(ctx, RequestRecord{Timestamp: ts, Host: "example.com", Path: "/", Status: 200, IP: "10.0.0.1"}); err != nil {
+				t.Fatalf("InsertRequest() error = %v", err)
+			}
+		}
+	}
+	if err := s.InsertRequest(ctx, RequestRecord{Timestamp: now, Host: "example.com", Path: "/", Status: 200, IP: "10.0.0.1"}); err != nil {
+		t.Fatalf("InsertRequest() error = %v", err)
+	}
+
+	got, err := s.growthEstimate(ctx, now)
+	if err != nil {
+		t.Fatalf("growthEstimate() error = %v", err)
+	}
+	if got.LastHour != 10 || got.LastDay != 60 || got.HoursObserved != 6 {
+		t.Errorf("last hour/day = %d/%d over %d hours, want 10/60 over 6", got.LastHour, got.LastDay, got.HoursObserved)
+	}
+	// 60 requests in 6 hours extrapolates to 240 a day
+	if got.RowsPerDay != 240 {
+		t.Errorf("RowsPerDay = %v, want 240", got.RowsPerDay)
+	}
+	if got.RequestsCount != 61 {
+		t.Errorf("RequestsCount = %d, want 61", got.RequestsCount)
+	}
+	if got.DBSizeBytes <= 0 || got.AvgBytesPerRow <= 0 {
+		t.Fatalf("DBSizeBytes = %d, AvgBytesPerRow = %v; want both positive", got.DBSizeBytes, got.AvgBytesPerRow)
+	}
+	if want := int64(got.RowsPerDay * got.AvgBytesPerRow); got.BytesPerDay != want {
+		t.Errorf("BytesPerDay = %d, want %d", got.BytesPerDay, want)
+	}
+
+	// A day later the window holds all the data and nothing is extrapolated
+	got, err = s.growthEstimate(ctx, now.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("growthEstimate() error = %v", err)
+	}
+	if got.LastHour != 0 || got.LastDay != 1 || got.HoursObserved != 24 || got.RowsPerDay != 1 {
+		t.Errorf("next day: got %+v, want last_day 1 over 24 hours", got)
+	}
+}
+
+func TestStorage_GrowthEstimate_Empty(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	got, err := s.GrowthEstimate(context.Background())
+	if err != nil {
+		t.Fatalf("GrowthEstimate() error = %v", err)
+	}
+	if got.RowsPerDay != 0 || got.AvgBytesPerRow != 0 || got.BytesPerDay != 0 {
+		t.Errorf("empty database: got %+v, want zero growth", got)
+	}
+}
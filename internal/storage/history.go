@@ -8,18 +8,26 @@ import (
 )
 
 // MonthlyHistory returns monthly statistics for the specified number of months.
+// Each month carries its hit growth relative to the previous month and to the
+// same month a year earlier, when those months have data.
 func (s *Storage) MonthlyHistory(ctx context.Context, months int, host string) (MonthlyHistory, error) {
+	return s.monthlyHistory(ctx, time.Now().UTC(), months, host)
+}
+
+func (s *Storage) monthlyHistory(ctx context.Context, now time.Time, months int, host string) (MonthlyHistory, error) {
 	var out MonthlyHistory
 	if months <= 0 || months > 60 {
 		months = 12
 	}
-	now := time.Now().UTC()
+	now = now.UTC()
 	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -months+1, 0)
+	// Look back an extra year so the first months in range have growth baselines.
+	queryStart := start.AddDate(-1, 0, 0)
 
-	args := []any{start}
+	args := []any{queryStart}
 	where := "WHERE ts >= ? AND ts IS NOT NULL"
 	if host != "" {
-		where += " AND host = ?"
+		where += " AND " + hostMatch
 		args = append(args, host)
 	}
 
@@ -31,12 +39,13 @@ WITH filtered AS (
 		path,
 		status,
 		bytes,
+		IFNULL(sample_weight, 1) AS weight,
 		ip,
 		user_agent,
-		CAST(strftime('%%s', ts) AS INTEGER) AS ts_epoch,
-		IFNULL(strftime('%%Y-%%m', ts), '') AS month_key,
+		CAST(strftime('%%s', substr(ts, 1, 19)) AS INTEGER) AS ts_epoch,
+		IFNULL(substr(ts, 1, 7), '') AS month_key,
 		lower(CASE WHEN instr(path, '?') > 0 THEN substr(path, 1, instr(path, '?') - 1) ELSE path END) AS clean_path,
-		lower(user_agent) AS ua
+		is_bot
 	FROM requests
 	%s
 ),
@@ -45,12 +54,12 @@ classified AS (
 		*,
 		CASE
 			WHEN status >= 400 THEN 0
-			WHEN COALESCE(ua, '') LIKE '%%bot%%' OR COALESCE(ua, '') LIKE '%%crawl%%' OR COALESCE(ua, '') LIKE '%%spider%%' OR COALESCE(ua, '') LIKE '%%crawler%%' OR COALESCE(ua, '') LIKE '%%preview%%' OR COALESCE(ua, '') LIKE '%%pingdom%%' OR COALESCE(ua, '') LIKE '%%uptime%%' THEN 0
+			WHEN is_bot = 1 THEN 0
 			ELSE 1
 		END AS is_viewed,
 		CASE
 			WHEN clean_path IS NULL OR clean_path = '' THEN 1
-			WHEN clean_path LIKE '%%.css' OR clean_path LIKE '%%.js' OR clean_path LIKE '%%.png' OR clean_path LIKE '%%.jpg' OR clean_path LIKE '%%.jpeg' OR clean_path LIKE '%%.gif' OR clean_path LIKE '%%.svg' OR clean_path LIKE '%%.ico' OR clean_path LIKE '%%.woff%%' OR clean_path LIKE '%%.ttf' OR clean_path LIKE '%%.eot' OR clean_path LIKE '%%.otf' OR clean_path LIKE '%%.map' OR clean_path LIKE '%%.json' OR clean_path LIKE '%%.xml' OR clean_path LIKE '%%.csv' THEN 0
+			WHEN %s THEN 0
 			ELSE 1
 		END AS is_page
 	FROM filtered
@@ -70,15 +79,15 @@ visits AS (
 )
 SELECT
 	c.month_key,
-	COUNT(*) AS hits,
-	IFNULL(SUM(CASE WHEN is_page = 1 THEN 1 ELSE 0 END), 0) AS pages,
-	IFNULL(SUM(bytes), 0) AS bandwidth_bytes,
+	SUM(weight) AS hits,
+	IFNULL(SUM(CASE WHEN is_page = 1 THEN weight ELSE 0 END), 0) AS pages,
+	IFNULL(SUM(bytes * weight), 0) AS bandwidth_bytes,
 	IFNULL((SELECT SUM(new_visit) FROM visits v WHERE v.month_key = c.month_key), 0) AS visits,
 	IFNULL(COUNT(DISTINCT ip || '|' || COALESCE(user_agent, '')), 0) AS unique_visitors
 FROM classified c
 GROUP BY c.month_key
 ORDER BY c.month_key ASC
-`, where), args...)
+`, where, s.assets.assetSQL("clean_path")), args...)
 	if err != nil {
 		return out, err
 	}
@@ -112,6 +121,9 @@ ORDER BY c.month_key ASC
 		if !ok {
 			stat = MonthlyStat{MonthStart: ms}
 		}
+		stat.GrowthPercent = growthPercent(stat.Hits, byKey[ms.AddDate(0, -1, 0).Format("2006-01")].Hits)
+		stat.YoYGrowthPercent = growthPercent(stat.Hits, byKey[ms.AddDate(-1, 0, 0).Format("2006-01")].Hits)
+		stat.BandwidthHuman = humanizeBytes(stat.BandwidthBytes)
 		out.Months = append(out.Months, stat)
 		out.Totals.Hits += stat.Hits
 		out.Totals.Pages += stat.Pages
@@ -119,9 +131,138 @@ ORDER BY c.month_key ASC
 		out.Totals.Visits += stat.Visits
 		out.Totals.UniqueVisitors += stat.UniqueVisitors
 	}
+	out.Totals.BandwidthHuman = humanizeBytes(out.Totals.BandwidthBytes)
+	return out, nil
+}
+
+// growthPercent returns the percentage change from prev to cur, or nil when
+// there is no prior data to compare against.
+func growthPercent(cur, prev int64) *float64 {
+	if prev <= 0 {
+		return nil
+	}
+	pct := float64(cur-prev) / float64(prev) * 100
+	return &pct
+}
+
+// WeeklyHistory returns statistics for the specified number of ISO weeks,
+// ending with the current week. Weeks start on Monday (UTC).
+func (s *Storage) WeeklyHistory(ctx context.Context, weeks int, host string) (WeeklyHistory, error) {
+	return s.weeklyHistory(ctx, time.Now().UTC(), weeks, host)
+}
+
+func (s *Storage) weeklyHistory(ctx context.Context, now time.Time, weeks int, host string) (WeeklyHistory, error) {
+	var out WeeklyHistory
+	if weeks <= 0 || weeks > 104 {
+		weeks = 12
+	}
+	now = now.UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	start := isoWeekStart(today).AddDate(0, 0, -7*(weeks-1))
+
+	args := []any{start}
+	where := "WHERE ts >= ? AND ts IS NOT NULL"
+	if host != "" {
+		where += " AND " + hostMatch
+		args = append(args, host)
+	}
+
+	// week_key is the Monday of the request's ISO week. Grouping by the Monday
+	// rather than strftime('%%W') keeps weeks that straddle a year boundary
+	// together (e.g. 2025-12-29 through 2026-01-04 is 2026-W01).
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(`
+WITH filtered AS (
+	SELECT
+		ts,
+		host,
+		path,
+		status,
+		bytes,
+		IFNULL(sample_weight, 1) AS weight,
+		ip,
+		user_agent,
+		CAST(strftime('%%s', substr(ts, 1, 19)) AS INTEGER) AS ts_epoch,
+		IFNULL(date(substr(ts, 1, 10), '-' || ((CAST(strftime('%%w', substr(ts, 1, 10)) AS INTEGER) + 6) %% 7) || ' days'), '') AS week_key,
+		lower(CASE WHEN instr(path, '?') > 0 THEN substr(path, 1, instr(path, '?') - 1) ELSE path END) AS clean_path,
+		lower(user_agent) AS ua
+	FROM requests
+	%s
+),
+classified AS (
+	SELECT
+		*,
+		CASE
+			WHEN clean_path IS NULL OR clean_path = '' THEN 1
+			WHEN %s THEN 0
+			ELSE 1
+		END AS is_page
+	FROM filtered
+),
+visits AS (
+	SELECT
+		week_key,
+		CASE
+			WHEN LAG(ts_epoch) OVER (PARTITION BY week_key, ip, user_agent ORDER BY ts_epoch) IS NULL THEN 1
+			WHEN ts_epoch - LAG(ts_epoch) OVER (PARTITION BY week_key, ip, user_agent ORDER BY ts_epoch) > 1800 THEN 1
+			ELSE 0
+		END AS new_visit
+	FROM classified
+)
+SELECT
+	c.week_key,
+	SUM(weight) AS hits,
+	IFNULL(SUM(CASE WHEN is_page = 1 THEN weight ELSE 0 END), 0) AS pages,
+	IFNULL(SUM(bytes * weight), 0) AS bandwidth_bytes,
+	IFNULL((SELECT SUM(new_visit) FROM visits v WHERE v.week_key = c.week_key), 0) AS visits,
+	IFNULL(COUNT(DISTINCT ip || '|' || COALESCE(user_agent, '')), 0) AS unique_visitors
+FROM classified c
+GROUP BY c.week_key
+ORDER BY c.week_key ASC
+`, where, s.assets.assetSQL("clean_path")), args...)
+	if err != nil {
+		return out, err
+	}
+	defer rows.Close()
+
+	byKey := make(map[string]WeeklyStat)
+	for rows.Next() {
+		var key sql.NullString
+		var w WeeklyStat
+		if err := rows.Scan(&key, &w.Hits, &w.Pages, &w.BandwidthBytes, &w.Visits, &w.UniqueVisitors); err != nil {
+			return out, err
+		}
+		if !key.Valid || key.String == "" {
+			continue
+		}
+		byKey[key.String] = w
+	}
+	if err := rows.Err(); err != nil {
+		return out, err
+	}
+
+	for i := 0; i < weeks; i++ {
+		ws := start.AddDate(0, 0, 7*i)
+		stat := byKey[ws.Format("2006-01-02")]
+		stat.WeekStart = ws
+		stat.ISOYear, stat.ISOWeek = ws.ISOWeek()
+		stat.BandwidthHuman = humanizeBytes(stat.BandwidthBytes)
+		out.Weeks = append(out.Weeks, stat)
+		out.Totals.Hits += stat.Hits
+		out.Totals.Pages += stat.Pages
+		out.Totals.BandwidthBytes += stat.BandwidthBytes
+		out.Totals.Visits += stat.Visits
+		out.Totals.UniqueVisitors += stat.UniqueVisitors
+	}
+	out.Totals.BandwidthHuman = humanizeBytes(out.Totals.BandwidthBytes)
 	return out, nil
 }
 
+// isoWeekStart returns the Monday starting the ISO week containing day.
+func isoWeekStart(day time.Time) time.Time {
+	offset := (int(day.Weekday()) + 6) % 7
+	return day.AddDate(0, 0, -offset)
+}
+
 // DailyHistory returns daily statistics for the current month.
 func (s *Storage) DailyHistory(ctx context.Context, host string) (DailyHistory, error) {
 	var out DailyHistory
@@ -132,7 +273,7 @@ func (s *Storage) DailyHistory(ctx context.Context, host string) (DailyHistory,
 	args := []any{start, end}
 	where := "WHERE ts >= ? AND ts < ? AND ts IS NOT NULL"
 	if host != "" {
-		where += " AND host = ?"
+		where += " AND " + hostMatch
 		args = append(args, host)
 	}
 
@@ -144,12 +285,13 @@ WITH filtered AS (
 		path,
 		status,
 		bytes,
+		IFNULL(sample_weight, 1) AS weight,
 		ip,
 		user_agent,
 		CAST(strftime('%%s', ts) AS INTEGER) AS ts_epoch,
 		IFNULL(strftime('%%Y-%%m-%%d', ts), '') AS day_key,
 		lower(CASE WHEN instr(path, '?') > 0 THEN substr(path, 1, instr(path, '?') - 1) ELSE path END) AS clean_path,
-		lower(user_agent) AS ua
+		is_bot
 	FROM requests
 	%s
 ),
@@ -158,12 +300,12 @@ classified AS (
 		*,
 		CASE
 			WHEN status >= 400 THEN 0
-			WHEN COALESCE(ua, '') LIKE '%%bot%%' OR COALESCE(ua, '') LIKE '%%crawl%%' OR COALESCE(ua, '') LIKE '%%spider%%' OR COALESCE(ua, '') LIKE '%%crawler%%' OR COALESCE(ua, '') LIKE '%%preview%%' OR COALESCE(ua, '') LIKE '%%pingdom%%' OR COALESCE(ua, '') LIKE '%%uptime%%' THEN 0
+			WHEN is_bot = 1 THEN 0
 			ELSE 1
 		END AS is_viewed,
 		CASE
 			WHEN clean_path IS NULL OR clean_path = '' THEN 1
-			WHEN clean_path LIKE '%%.css' OR clean_path LIKE '%%.js' OR clean_path LIKE '%%.png' OR clean_path LIKE '%%.jpg' OR clean_path LIKE '%%.jpeg' OR clean_path LIKE '%%.gif' OR clean_path LIKE '%%.svg' OR clean_path LIKE '%%.ico' OR clean_path LIKE '%%.woff%%' OR clean_path LIKE '%%.ttf' OR clean_path LIKE '%%.eot' OR clean_path LIKE '%%.otf' OR clean_path LIKE '%%.map' OR clean_path LIKE '%%.json' OR clean_path LIKE '%%.xml' OR clean_path LIKE '%%.csv' THEN 0
+			WHEN %s THEN 0
 			ELSE 1
 		END AS is_page
 	FROM filtered
@@ -183,14 +325,14 @@ visits AS (
 )
 SELECT
 	c.day_key,
-	COUNT(*) AS hits,
-	IFNULL(SUM(CASE WHEN is_page = 1 THEN 1 ELSE 0 END), 0) AS pages,
-	IFNULL(SUM(bytes), 0) AS bandwidth_bytes,
+	SUM(weight) AS hits,
+	IFNULL(SUM(CASE WHEN is_page = 1 THEN weight ELSE 0 END), 0) AS pages,
+	IFNULL(SUM(bytes * weight), 0) AS bandwidth_bytes,
 	IFNULL((SELECT SUM(new_visit) FROM visits v WHERE v.day_key = c.day_key), 0) AS visits
 FROM classified c
 GROUP BY c.day_key
 ORDER BY c.day_key ASC
-`, where), args...)
+`, where, s.assets.assetSQL("clean_path")), args...)
 	if err != nil {
 		return out, err
 	}
@@ -229,6 +371,7 @@ ORDER BY c.day_key ASC
 		if stat.Hits > 0 || stat.Pages > 0 || stat.BandwidthBytes > 0 || stat.Visits > 0 {
 			daysWithData++
 		}
+		stat.BandwidthHuman = humanizeBytes(stat.BandwidthBytes)
 		out.Days = append(out.Days, stat)
 		out.Totals.Hits += stat.Hits
 		out.Totals.Pages += stat.Pages
@@ -241,5 +384,7 @@ ORDER BY c.day_key ASC
 		out.Average.BandwidthBytes = out.Totals.BandwidthBytes / int64(daysWithData)
 		out.Average.Visits = out.Totals.Visits / int64(daysWithData)
 	}
+	out.Totals.BandwidthHuman = humanizeBytes(out.Totals.BandwidthBytes)
+	out.Average.BandwidthHuman = humanizeBytes(out.Average.BandwidthBytes)
 	return out, nil
 }
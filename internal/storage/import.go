@@ -65,9 +65,9 @@ ON CONFLICT(file_path) DO UPDATE SET
 // GetImportErrors returns import error stats for all files with errors.
 func (s *Storage) GetImportErrors(ctx context.Context) ([]ImportErrorStats, error) {
 	rows, err := s.db.QueryContext(ctx, `
-SELECT file_path, error_count, IFNULL(last_error, ''), last_error_at, updated_at
+SELECT file_path, error_count, IFNULL(last_error, ''), last_error_at, updated_at, quarantined
 FROM import_errors
-WHERE error_count > 0
+WHERE error_count > 0 OR quarantined = 1
 ORDER BY error_count DESC, updated_at DESC
 `)
 	if err != nil {
@@ -79,7 +79,7 @@ ORDER BY error_count DESC, updated_at DESC
 	for rows.Next() {
 		var stat ImportErrorStats
 		var lastErrorAt, updatedAt sql.NullString
-		if err := rows.Scan(&stat.FilePath, &stat.ErrorCount, &stat.LastError, &lastErrorAt, &updatedAt); err != nil {
+		if err := rows.Scan(&stat.FilePath, &stat.ErrorCount, &stat.LastError, &lastErrorAt, &updatedAt, &stat.Quarantined); err != nil {
 			return nil, err
 		}
 		if lastErrorAt.Valid {
@@ -109,7 +109,38 @@ func (s *Storage) GetImportErrorsTotal(ctx context.Context) (int64, error) {
 	return total, nil
 }
 
-// ClearImportErrors clears all error stats for a file (e.g., after successful import).
+// QuarantineFile marks a file as quarantined so the ingestor stops reading
+// it. reason is stored as the file's last error.
+func (s *Storage) QuarantineFile(ctx context.Context, filePath, reason string) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	now := time.Now()
+	_, err := s.db.ExecContext(ctx, `
+INSERT INTO import_errors (file_path, error_count, last_error, last_error_at, updated_at, quarantined)
+VALUES (?, 0, ?, ?, ?, 1)
+ON CONFLICT(file_path) DO UPDATE SET
+	last_error = excluded.last_error,
+	last_error_at = excluded.last_error_at,
+	updated_at = excluded.updated_at,
+	quarantined = 1
+`, filePath, reason, now, now)
+	return err
+}
+
+// IsQuarantined reports whether a file has been quarantined.
+func (s *Storage) IsQuarantined(ctx context.Context, filePath string) (bool, error) {
+	var quarantined bool
+	err := s.db.QueryRowContext(ctx,
+		`SELECT quarantined FROM import_errors WHERE file_path = ?`, filePath).Scan(&quarantined)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return quarantined, err
+}
+
+// ClearImportErrors clears all error stats for a file (e.g., after successful
+// import). This also lifts a quarantine.
 func (s *Storage) ClearImportErrors(ctx context.Context, filePath string) error {
 	s.writeMu.Lock()
 	defer s.writeMu.Unlock()
@@ -0,0 +1,47 @@
+package storage
+
+import "strings"
+
+// Default labels for empty dimension values in reports.
+const (
+	DefaultUnknownLabel = "Unknown"
+	DefaultDirectLabel  = "Direct / Bookmark"
+)
+
+// emptyLabels names empty or NULL dimension values in reports: unknown for
+// browsers, operating systems and locations, direct for referrers.
+type emptyLabels struct {
+	unknown string
+	direct  string
+}
+
+func newEmptyLabels(unknown, direct string) emptyLabels {
+	if unknown == "" {
+		unknown = DefaultUnknownLabel
+	}
+	if direct == "" {
+		direct = DefaultDirectLabel
+	}
+	return emptyLabels{unknown: unknown, direct: direct}
+}
+
+// orUnknown returns SQL for column with empty values reported as the
+// unknown label. column is interpolated and must be trusted.
+func (l emptyLabels) orUnknown(column string) string {
+	return labelSQL(column, l.unknown)
+}
+
+// orDirect returns SQL for a referrer column with empty values reported as
+// the direct label.
+func (l emptyLabels) orDirect(column string) string {
+	return labelSQL(column, l.direct)
+}
+
+func labelSQL(column, label string) string {
+	return "CASE WHEN IFNULL(" + column + ", '') = '' THEN " + sqlString(label) + " ELSE " + column + " END"
+}
+
+// sqlString quotes s as an SQL string literal.
+func sqlString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEmptyLabels_AppliedToEveryReport(t *testing.T) {
+	tests := []struct {
+		name            string
+		unknown, direct string
+	}{
+		{"defaults", "", ""},
+		{"configured", "Inconnu", "Accès direct (l'URL)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewWithOptions(filepath.Join(t.TempDir(), "test.db"), Options{UnknownLabel: tt.unknown, DirectLabel: tt.direct})
+			if err != nil {
+				t.Fatalf("NewWithOptions() error = %v", err)
+			}
+			defer s.Close()
+			wantUnknown, wantDirect := tt.unknown, tt.direct
+			if wantUnknown == "" {
+				wantUnknown, wantDirect = DefaultUnknownLabel, DefaultDirectLabel
+			}
+
+			// One human request with every dimension empty
+			ctx := context.Background()
+			rec := RequestRecord{Timestamp: time.Now().Add(-time.Minute), Host: "example.com", Path: "/", Status: 200, Bytes: 10}
+			if err := s.InsertRequest(ctx, rec); err != nil {
+				t.Fatalf("InsertRequest() error = %v", err)
+			}
+
+			browsers, err := s.Browsers(ctx, time.Hour, "", 10)
+			if err != nil || len(browsers) != 1 || browsers[0].Browser != wantUnknown {
+				t.Errorf("Browsers() = %+v, %v; want one %q row", browsers, err, wantUnknown)
+			}
+			oses, err := s.OperatingSystems(ctx, time.Hour, "", 10)
+			if err != nil || len(oses) != 1 || oses[0].OS != wantUnknown {
+				t.Errorf("OperatingSystems() = %+v, %v; want one %q row", oses, err, wantUnknown)
+			}
+			refs, err := s.Referrers(ctx, time.Hour, "", 10)
+			if err != nil || len(refs) != 1 || refs[0].Referrer != wantDirect || refs[0].Type != "direct" {
+				t.Errorf("Referrers() = %+v, %v; want one direct %q row", refs, err, wantDirect)
+			}
+			geo, err := s.Geo(ctx, time.Hour, "")
+			if err != nil || len(geo) != 1 {
+				t.Fatalf("Geo() = %+v, %v; want one row", geo, err)
+			}
+			if g := geo[0]; g.Country != wantUnknown || g.Region != wantUnknown || g.City != wantUnknown {
+				t.Errorf("Geo() = %+v, want %q for country, region and city", g, wantUnknown)
+			}
+			countries, err := s.BandwidthByCountry(ctx, time.Hour, "", 10)
+			if err != nil || len(countries) != 1 || countries[0].Country != wantUnknown {
+				t.Errorf("BandwidthByCountry() = %+v, %v; want one %q row", countries, err, wantUnknown)
+			}
+			methods, err := s.Methods(ctx, time.Hour, "")
+			if err != nil || len(methods) != 1 || methods[0].Method != wantUnknown {
+				t.Errorf("Methods() = %+v, %v; want one %q row", methods, err, wantUnknown)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/dustin/Caddystat/internal/metrics"
+)
+
+// MetricsTrafficAdapter wraps Storage to satisfy the metrics.TrafficSource interface.
+type MetricsTrafficAdapter struct {
+	store *Storage
+}
+
+// NewMetricsTrafficAdapter creates a new adapter for the metrics package.
+func NewMetricsTrafficAdapter(s *Storage) *MetricsTrafficAdapter {
+	return &MetricsTrafficAdapter{store: s}
+}
+
+// HostTraffic returns rollup totals for the busiest limit hosts, with the
+// rest summed under "other".
+func (a *MetricsTrafficAdapter) HostTraffic(ctx context.Context, limit int) ([]metrics.HostTraffic, error) {
+	totals, err := a.store.RollupTotalsByHost(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]metrics.HostTraffic, len(totals))
+	for i, t := range totals {
+		out[i] = metrics.HostTraffic{
+			Host:      t.Host,
+			Bytes:     t.Bytes,
+			Status2xx: t.Status2xx,
+			Status3xx: t.Status3xx,
+			Status4xx: t.Status4xx,
+			Status5xx: t.Status5xx,
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"math"
+	"strconv"
+	"sync/atomic"
+)
+
+// DefaultMillisDecimals is the number of decimals Millis values keep in
+// JSON unless SetMillisDecimals changes it.
+const DefaultMillisDecimals = 2
+
+var millisDecimals atomic.Int32
+
+func init() {
+	millisDecimals.Store(DefaultMillisDecimals)
+}
+
+// SetMillisDecimals sets how many decimals response times and percentiles
+// keep when serialized to JSON. A negative n keeps full precision.
+func SetMillisDecimals(n int) {
+	millisDecimals.Store(int32(n))
+}
+
+// Millis is a duration in milliseconds, such as an average response time or
+// a percentile. It is a plain float64 in computations; only its JSON form is
+// rounded, so averages don't serialize as 50.50000000001.
+type Millis float64
+
+// MarshalJSON writes m rounded half away from zero to the configured
+// number of decimals, without trailing zeros.
+func (m Millis) MarshalJSON() ([]byte, error) {
+	v := float64(m)
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return []byte("0"), nil
+	}
+	if n := millisDecimals.Load(); n >= 0 {
+		p := math.Pow10(int(n))
+		v = math.Round(v*p) / p
+	}
+	return strconv.AppendFloat(nil, v, 'f', -1, 64), nil
+}
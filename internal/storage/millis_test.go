@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMillis_MarshalJSON(t *testing.T) {
+	defer SetMillisDecimals(DefaultMillisDecimals)
+
+	tests := []struct {
+		decimals int
+		in       Millis
+		want     string
+	}{
+		{1, 50.505, "50.5"},
+		{2, 50.505, "50.51"},
+		{2, 50.50000000001, "50.5"},
+		{2, 12, "12"},
+		{0, 12.5, "13"},
+		{-1, 50.505, "50.505"},
+	}
+	for _, tt := range tests {
+		SetMillisDecimals(tt.decimals)
+		got, err := json.Marshal(tt.in)
+		if err != nil {
+			t.Fatalf("Marshal(%v) error = %v", tt.in, err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("Marshal(%v) at %d decimals = %s, want %s", float64(tt.in), tt.decimals, got, tt.want)
+		}
+	}
+}
+
+func TestMillis_RoundsStructFields(t *testing.T) {
+	defer SetMillisDecimals(DefaultMillisDecimals)
+	SetMillisDecimals(1)
+
+	p95 := Millis(120.04)
+	got, err := json.Marshal(ResponseTimeStats{Avg: 50.505, P95: &p95, Count: 3})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	want := `{"min_ms":0,"max_ms":0,"avg_ms":50.5,"p95_ms":120,"count":3,"std_dev_ms":0}`
+	if string(got) != want {
+		t.Errorf("Marshal() = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,236 @@
+package storage
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// queryStripper normalizes request paths for path rankings so that query
+// variants of one page (e.g. /article?page=1 and /article?page=2) collapse
+// into a single row.
+type queryStripper struct {
+	all    bool
+	params map[string]bool
+}
+
+// newQueryStripper builds a stripper from a list of query parameter names.
+// A "*" entry strips the whole query string. Returns nil when params is empty.
+func newQueryStripper(params []string) *queryStripper {
+	q := &queryStripper{params: make(map[string]bool)}
+	for _, p := range params {
+		p = strings.TrimSpace(p)
+		switch p {
+		case "":
+			continue
+		case "*":
+			q.all = true
+		default:
+			q.params[p] = true
+		}
+	}
+	if !q.all && len(q.params) == 0 {
+		return nil
+	}
+	return q
+}
+
+// normalize removes the configured parameters from p, keeping the order of
+// any remaining ones. The query string is dropped entirely if nothing is left.
+func (q *queryStripper) normalize(p string) string {
+	base, query, ok := strings.Cut(p, "?")
+	if !ok {
+		return p
+	}
+	if q.all {
+		return base
+	}
+	var kept []string
+	for _, pair := range strings.Split(query, "&") {
+		if pair == "" {
+			continue
+		}
+		key, _, _ := strings.Cut(pair, "=")
+		if k, err := url.QueryUnescape(key); err == nil {
+			key = k
+		}
+		if !q.params[key] {
+			kept = append(kept, pair)
+		}
+	}
+	if len(kept) == 0 {
+		return base
+	}
+	return base + "?" + strings.Join(kept, "&")
+}
+
+// topPathsNormalized is topPaths with query stripping applied before
+// aggregation.
+func (s *Storage) topPathsNormalized(ctx context.Context, from time.Time, limit int, f StatsFilter) ([]PathStat, error) {
+	cond, condArgs := f.sql()
+	if s.stripQuery.all {
+		// Whole query string goes; let SQLite do the grouping and limiting.
+		query := `
+SELECT CASE WHEN instr(path, '?') > 0 THEN substr(path, 1, instr(path, '?') - 1) ELSE path END AS clean_path, SUM(IFNULL(sample_weight, 1)) as c
+FROM requests WHERE ts >= ?` + cond
+		args := append([]any{from}, condArgs...)
+		query += " GROUP BY clean_path ORDER BY c DESC LIMIT ?"
+		args = append(args, limit)
+		rows, err := s.db.QueryContext(ctx, query, args...)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		var list []PathStat
+		for rows.Next() {
+			var p PathStat
+			if err := rows.Scan(&p.Path, &p.Count); err != nil {
+				return nil, err
+			}
+			list = append(list, p)
+		}
+		return list, rows.Err()
+	}
+
+	query := `SELECT path, SUM(IFNULL(sample_weight, 1)) FROM requests WHERE ts >= ?` + cond + " GROUP BY path"
+	args := append([]any{from}, condArgs...)
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts := make(map[string]int64)
+	for n := 0; rows.Next(); n++ {
+		if err := scanCanceled(ctx, n); err != nil {
+			return nil, err
+		}
+		var p string
+		var c int64
+		if err := rows.Scan(&p, &c); err != nil {
+			return nil, err
+		}
+		counts[s.stripQuery.normalize(p)] += c
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	list := make([]PathStat, 0, len(counts))
+	for p, c := range counts {
+		list = append(list, PathStat{Path: p, Count: c})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Count != list[j].Count {
+			return list[i].Count > list[j].Count
+		}
+		return list[i].Path < list[j].Path
+	})
+	if limit > 0 && len(list) > limit {
+		list = list[:limit]
+	}
+	return list, nil
+}
+
+// maxPathGroupDepth caps PathGroups depth; deeper groups are barely
+// coarser than individual paths.
+const maxPathGroupDepth = 10
+
+// pathGroup returns the first depth segments of p's path (query string
+// dropped), e.g. "/blog/2024/post" at depth 1 is "/blog". Paths with fewer
+// segments are returned whole.
+func pathGroup(p string, depth int) string {
+	p, _, _ = strings.Cut(p, "?")
+	segments := strings.Split(strings.Trim(p, "/"), "/")
+	if len(segments) == 1 && segments[0] == "" {
+		return "/"
+	}
+	if len(segments) > depth {
+		segments = segments[:depth]
+	}
+	return "/" + strings.Join(segments, "/")
+}
+
+// PathGroups returns traffic grouped by the first depth path segments
+// (virtual directories), ordered by hits. depth defaults to 1.
+func (s *Storage) PathGroups(ctx context.Context, dur time.Duration, host string, depth, limit int) ([]PathGroupStat, error) {
+	from := time.Now().Add(-dur)
+	if depth <= 0 {
+		depth = 1
+	}
+	depth = min(depth, maxPathGroupDepth)
+	if limit <= 0 {
+		limit = 20
+	}
+
+	query := `SELECT path, SUM(IFNULL(sample_weight, 1)), IFNULL(SUM(bytes * IFNULL(sample_weight, 1)), 0) FROM requests WHERE ts >= ?`
+	args := []any{from}
+	if host != "" {
+		query += " AND " + hostMatch
+		args = append(args, host)
+	}
+	query += " GROUP BY path"
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := make(map[string]*PathGroupStat)
+	for n := 0; rows.Next(); n++ {
+		if err := scanCanceled(ctx, n); err != nil {
+			return nil, err
+		}
+		var p string
+		var hits, bytes int64
+		if err := rows.Scan(&p, &hits, &bytes); err != nil {
+			return nil, err
+		}
+		key := pathGroup(p, depth)
+		g, ok := groups[key]
+		if !ok {
+			g = &PathGroupStat{Group: key}
+			groups[key] = g
+		}
+		g.Hits += hits
+		g.Bytes += bytes
+		g.Paths++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	list := make([]PathGroupStat, 0, len(groups))
+	for _, g := range groups {
+		list = append(list, *g)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Hits != list[j].Hits {
+			return list[i].Hits > list[j].Hits
+		}
+		return list[i].Group < list[j].Group
+	})
+	if len(list) > limit {
+		list = list[:limit]
+	}
+	return list, nil
+}
+
+// DistinctPaths counts the distinct pages (query string stripped, static
+// assets excluded) that received at least one request within dur.
+func (s *Storage) DistinctPaths(ctx context.Context, dur time.Duration, host string) (int64, error) {
+	from := time.Now().Add(-dur)
+	query := `
+SELECT COUNT(DISTINCT ` + cleanPathSQL + `)
+FROM requests
+WHERE ts >= ? AND ` + s.assets.pageSQL(cleanPathSQL)
+	args := []any{from}
+	if host != "" {
+		query += " AND " + hostMatch
+		args = append(args, host)
+	}
+	var n int64
+	err := s.db.QueryRowContext(ctx, query, args...).Scan(&n)
+	return n, err
+}
@@ -0,0 +1,177 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestQueryStripper_Normalize(t *testing.T) {
+	tests := []struct {
+		params []string
+		in     string
+		want   string
+	}{
+		{[]string{"*"}, "/article?page=2&sort=new", "/article"},
+		{[]string{"page"}, "/article?page=2", "/article"},
+		{[]string{"page"}, "/article?sort=new&page=2&lang=en", "/article?sort=new&lang=en"},
+		{[]string{"page", "utm_source"}, "/article?utm_source=x&page=3", "/article"},
+		{[]string{"page"}, "/article", "/article"},
+		{[]string{"page"}, "/article?pages=2", "/article?pages=2"},
+	}
+	for _, tt := range tests {
+		q := newQueryStripper(tt.params)
+		if got := q.normalize(tt.in); got != tt.want {
+			t.Errorf("normalize(%v, %q) = %q, want %q", tt.params, tt.in, got, tt.want)
+		}
+	}
+
+	if newQueryStripper(nil) != nil || newQueryStripper([]string{" ", ""}) != nil {
+		t.Error("expected nil stripper when no params are configured")
+	}
+}
+
+func TestStorage_TopPaths_StripQuery(t *testing.T) {
+	paths := []string{"/article?page=1", "/article?page=2", "/about"}
+
+	for _, tt := range []struct {
+		name   string
+		params []string
+		want   int
+	}{
+		{"disabled", nil, 3},
+		{"all", []string{"*"}, 2},
+		{"named param", []string{"page"}, 2},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s, cleanup := setupTestDB(t)
+			defer cleanup()
+			s.stripQuery = newQueryStripper(tt.params)
+
+			ctx := context.Background()
+			now := time.Now().UTC()
+			for _, p := range paths {
+				req := RequestRecord{Timestamp: now.Add(-time.Minute), Host: "example.com", Path: p, Status: 200}
+				if err := s.InsertRequest(ctx, req); err != nil {
+					t.Fatalf("InsertRequest() error = %v", err)
+				}
+			}
+
+			top, err := s.topPaths(ctx, now.Add(-time.Hour), 10, StatsFilter{Host: "example.com"})
+			if err != nil {
+				t.Fatalf("topPaths() error = %v", err)
+			}
+			if len(top) != tt.want {
+				t.Fatalf("expected %d rows, got %d: %+v", tt.want, len(top), top)
+			}
+			if tt.params != nil && (top[0].Path != "/article" || top[0].Count != 2) {
+				t.Errorf("expected /article with 2 hits first, got %+v", top[0])
+			}
+		})
+	}
+}
+
+func TestPathGroup(t *testing.T) {
+	tests := []struct {
+		path  string
+		depth int
+		want  string
+	}{
+		{"/blog/a", 1, "/blog"},
+		{"/blog/a?x=1", 1, "/blog"},
+		{"/blog/2024/post", 2, "/blog/2024"},
+		{"/blog/", 1, "/blog"},
+		{"/", 1, "/"},
+		{"/?q=1", 1, "/"},
+		{"/about", 3, "/about"},
+	}
+	for _, tt := range tests {
+		if got := pathGroup(tt.path, tt.depth); got != tt.want {
+			t.Errorf("pathGroup(%q, %d) = %q, want %q", tt.path, tt.depth, got, tt.want)
+		}
+	}
+}
+
+func TestStorage_PathGroups(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	for _, p := range []string{"/blog/a", "/blog/b", "/blog/b", "/api/users", "/"} {
+		rec := RequestRecord{Timestamp: now, Host: "example.com", Path: p, Status: 200, Bytes: 100}
+		if err := s.InsertRequest(ctx, rec); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	groups, err := s.PathGroups(ctx, time.Hour, "", 1, 10)
+	if err != nil {
+		t.Fatalf("PathGroups() error = %v", err)
+	}
+	want := []PathGroupStat{
+		{Group: "/blog", Hits: 3, Bytes: 300, Paths: 2},
+		{Group: "/", Hits: 1, Bytes: 100, Paths: 1},
+		{Group: "/api", Hits: 1, Bytes: 100, Paths: 1},
+	}
+	if len(groups) != len(want) {
+		t.Fatalf("PathGroups() = %+v, want %+v", groups, want)
+	}
+	for i := range want {
+		if groups[i] != want[i] {
+			t.Errorf("PathGroups()[%d] = %+v, want %+v", i, groups[i], want[i])
+		}
+	}
+
+	groups, err = s.PathGroups(ctx, time.Hour, "", 2, 10)
+	if err != nil {
+		t.Fatalf("PathGroups(depth 2) error = %v", err)
+	}
+	if len(groups) != 4 || groups[0].Group != "/blog/b" || groups[0].Hits != 2 {
+		t.Errorf("PathGroups(depth 2) = %+v, want /blog/b first with 2 hits", groups)
+	}
+}
+
+func TestStorage_DistinctPaths(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	records := []RequestRecord{
+		{Host: "example.com", Path: "/article?page=1"},
+		{Host: "example.com", Path: "/article?page=2"},
+		{Host: "example.com", Path: "/article"},
+		{Host: "example.com", Path: "/about?utm_source=news"},
+		{Host: "example.com", Path: "/about"},
+		{Host: "example.com", Path: "/style.css?v=3"},
+		{Host: "example.com", Path: "/logo.png"},
+		{Host: "other.com", Path: "/contact"},
+		{Host: "example.com", Path: "/old", Timestamp: now.Add(-48 * time.Hour)},
+	}
+	for _, r := range records {
+		if r.Timestamp.IsZero() {
+			r.Timestamp = now.Add(-time.Minute)
+		}
+		r.Status = 200
+		if err := s.InsertRequest(ctx, r); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	got, err := s.DistinctPaths(ctx, 24*time.Hour, "example.com")
+	if err != nil {
+		t.Fatalf("DistinctPaths() error = %v", err)
+	}
+	if got != 2 {
+		t.Errorf("DistinctPaths(example.com) = %d, want 2 (/article and /about)", got)
+	}
+
+	got, err = s.DistinctPaths(ctx, 24*time.Hour, "")
+	if err != nil {
+		t.Fatalf("DistinctPaths() error = %v", err)
+	}
+	if got != 3 {
+		t.Errorf("DistinctPaths(all hosts) = %d, want 3", got)
+	}
+}
@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// tsEpochSQL converts the stored ts column to Unix seconds. Only the leading
+// "YYYY-MM-DD HH:MM:SS" is used because timestamps are stored in UTC in Go's
+// time.Time.String() layout, which SQLite's date functions can't parse whole.
+const tsEpochSQL = "CAST(strftime('%s', substr(ts, 1, 19)) AS INTEGER)"
+
+// PeakTraffic finds the busiest bucket (e.g. one second or one minute) within
+// the range and when it started. Buckets are aligned to whole seconds since
+// the Unix epoch; bucket is rounded down to whole seconds, minimum one.
+func (s *Storage) PeakTraffic(ctx context.Context, dur time.Duration, host string, bucket time.Duration) (PeakTrafficStat, error) {
+	bucketSecs := int64(bucket / time.Second)
+	if bucketSecs < 1 {
+		bucketSecs = 1
+	}
+	stat := PeakTrafficStat{BucketSeconds: bucketSecs}
+	from := time.Now().Add(-dur)
+
+	filter := " WHERE ts >= ?"
+	args := []any{from}
+	if host != "" {
+		filter += " AND " + hostMatch
+		args = append(args, host)
+	}
+
+	if err := s.db.QueryRowContext(ctx, `SELECT IFNULL(SUM(IFNULL(sample_weight, 1)), 0) FROM requests`+filter, args...).Scan(&stat.TotalRequests); err != nil {
+		return stat, err
+	}
+	if dur > 0 {
+		stat.AvgPerSecond = float64(stat.TotalRequests) / dur.Seconds()
+	}
+	if stat.TotalRequests == 0 {
+		return stat, nil
+	}
+
+	query := `
+SELECT (` + tsEpochSQL + ` / ?) * ? AS bucket, SUM(IFNULL(sample_weight, 1)) AS cnt
+FROM requests` + filter + `
+GROUP BY bucket
+HAVING bucket IS NOT NULL
+ORDER BY cnt DESC, bucket ASC
+LIMIT 1`
+	peakArgs := append([]any{bucketSecs, bucketSecs}, args...)
+
+	var bucketStart sql.NullInt64
+	err := s.db.QueryRowContext(ctx, query, peakArgs...).Scan(&bucketStart, &stat.PeakRequests)
+	if err == sql.ErrNoRows {
+		return stat, nil
+	}
+	if err != nil {
+		return stat, err
+	}
+	if bucketStart.Valid {
+		stat.PeakAt = time.Unix(bucketStart.Int64, 0).UTC()
+	}
+	stat.PeakPerSecond = float64(stat.PeakRequests) / float64(bucketSecs)
+	return stat, nil
+}
@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStorage_PeakTraffic(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	burstAt := time.Now().UTC().Add(-10 * time.Minute).Truncate(time.Second)
+
+	var requests []RequestRecord
+	// Burst of 7 requests within a single second
+	for i := 0; i < 7; i++ {
+		requests = append(requests, RequestRecord{Timestamp: burstAt.Add(time.Duration(i) * 100 * time.Millisecond), Host: "example.com", Path: "/", Status: 200})
+	}
+	// Background traffic, one request per second
+	for i := 1; i <= 3; i++ {
+		requests = append(requests, RequestRecord{Timestamp: burstAt.Add(time.Duration(i) * time.Second), Host: "example.com", Path: "/", Status: 200})
+	}
+	// Other host has a bigger burst
+	for i := 0; i < 9; i++ {
+		requests = append(requests, RequestRecord{Timestamp: burstAt.Add(-time.Minute), Host: "other.com", Path: "/", Status: 200})
+	}
+	for _, req := range requests {
+		if err := s.InsertRequest(ctx, req); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	stat, err := s.PeakTraffic(ctx, time.Hour, "example.com", time.Second)
+	if err != nil {
+		t.Fatalf("PeakTraffic() error = %v", err)
+	}
+	if stat.PeakRequests != 7 {
+		t.Errorf("PeakRequests = %d, want 7", stat.PeakRequests)
+	}
+	if !stat.PeakAt.Equal(burstAt) {
+		t.Errorf("PeakAt = %v, want %v", stat.PeakAt, burstAt)
+	}
+	if stat.TotalRequests != 10 {
+		t.Errorf("TotalRequests = %d, want 10", stat.TotalRequests)
+	}
+	if stat.PeakPerSecond != 7 {
+		t.Errorf("PeakPerSecond = %v, want 7", stat.PeakPerSecond)
+	}
+
+	all, err := s.PeakTraffic(ctx, time.Hour, "", time.Second)
+	if err != nil {
+		t.Fatalf("PeakTraffic() error = %v", err)
+	}
+	if all.PeakRequests != 9 {
+		t.Errorf("PeakRequests across hosts = %d, want 9", all.PeakRequests)
+	}
+
+	perMinute, err := s.PeakTraffic(ctx, time.Hour, "example.com", time.Minute)
+	if err != nil {
+		t.Fatalf("PeakTraffic() error = %v", err)
+	}
+	if perMinute.BucketSeconds != 60 {
+		t.Errorf("BucketSeconds = %d, want 60", perMinute.BucketSeconds)
+	}
+	if perMinute.PeakRequests < 7 || perMinute.PeakRequests > 10 {
+		t.Errorf("per-minute PeakRequests = %d, want between 7 and 10", perMinute.PeakRequests)
+	}
+}
+
+func TestStorage_PeakTraffic_Empty(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	stat, err := s.PeakTraffic(context.Background(), time.Hour, "", 0)
+	if err != nil {
+		t.Fatalf("PeakTraffic() error = %v", err)
+	}
+	if stat.PeakRequests != 0 || !stat.PeakAt.IsZero() {
+		t.Errorf("expected empty result, got %+v", stat)
+	}
+	if stat.BucketSeconds != 1 {
+		t.Errorf("BucketSeconds = %d, want 1", stat.BucketSeconds)
+	}
+}
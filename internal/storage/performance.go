@@ -47,7 +47,7 @@ WITH filtered AS (
 
 	args := []any{from}
 	if host != "" {
-		query += " AND host = ?"
+		query += " AND " + hostMatch
 		args = append(args, host)
 	}
 
@@ -90,15 +90,23 @@ SELECT
 	IFNULL(p.std_dev, 0)
 FROM percentiles p, p_values pv`
 
+	var p50, p90, p95, p99 Millis
 	row := s.db.QueryRowContext(ctx, query, args...)
 	if err := row.Scan(
 		&stats.Min, &stats.Max, &stats.Avg,
-		&stats.P50, &stats.P90, &stats.P95, &stats.P99,
+		&p50, &p90, &p95, &p99,
 		&stats.Count, &stats.StdDev,
 	); err != nil {
 		return stats, err
 	}
 
+	// A P99 of a handful of requests is just the slowest one; report none
+	// rather than a falsely precise number.
+	if stats.Count < int64(s.percentileMinSamples) {
+		stats.LowSample = true
+		return stats, nil
+	}
+	stats.P50, stats.P90, stats.P95, stats.P99 = &p50, &p90, &p95, &p99
 	return stats, nil
 }
 
@@ -116,7 +124,7 @@ WITH page_stats AS (
 
 	args := []any{from}
 	if host != "" {
-		query += " AND host = ?"
+		query += " AND " + hostMatch
 		args = append(args, host)
 	}
 
@@ -210,7 +218,7 @@ WITH status_data AS (
 
 	args := []any{from}
 	if host != "" {
-		query += " AND host = ?"
+		query += " AND " + hostMatch
 		args = append(args, host)
 	}
 
@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// maxRealtimeMinutes caps the realtime window; longer ranges belong to the
+// regular time series endpoints.
+const maxRealtimeMinutes = 180
+
+// Realtime returns per-minute request counts and distinct non-bot visitor
+// IPs for the last minutes minutes (default 30), including the current,
+// partial minute. Minutes without traffic are included with zero counts.
+func (s *Storage) Realtime(ctx context.Context, minutes int, host string) (RealtimeStats, error) {
+	return s.realtime(ctx, time.Now(), minutes, host)
+}
+
+func (s *Storage) realtime(ctx context.Context, now time.Time, minutes int, host string) (RealtimeStats, error) {
+	if minutes <= 0 {
+		minutes = 30
+	}
+	minutes = min(minutes, maxRealtimeMinutes)
+	start := now.UTC().Truncate(time.Minute).Add(-time.Duration(minutes-1) * time.Minute)
+
+	out := RealtimeStats{Minutes: minutes, PerMinute: make([]MinuteCount, minutes)}
+	for i := range out.PerMinute {
+		out.PerMinute[i].Minute = start.Add(time.Duration(i) * time.Minute)
+	}
+
+	// The ts index limits the scan to the window's rows
+	end := start.Add(time.Duration(minutes) * time.Minute)
+	filter := " WHERE ts >= ? AND ts < ?"
+	args := []any{start, end}
+	if host != "" {
+		filter += " AND " + hostMatch
+		args = append(args, host)
+	}
+
+	query := `
+SELECT ` + tsEpochSQL + ` / 60 AS minute, SUM(IFNULL(sample_weight, 1))
+FROM requests` + filter + `
+GROUP BY minute
+HAVING minute IS NOT NULL`
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return out, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var minute, count int64
+		if err := rows.Scan(&minute, &count); err != nil {
+			return out, err
+		}
+		if i := int(minute - start.Unix()/60); i >= 0 && i < minutes {
+			out.PerMinute[i].Requests = count
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return out, err
+	}
+	for _, m := range out.PerMinute {
+		out.TotalRequests += m.Requests
+	}
+
+	out.ActiveVisitors, err = s.activeVisitors(ctx, start, end, host)
+	return out, err
+}
+
+// ActiveVisitors counts distinct non-bot IPs seen within window of now
+// ("online now"). window defaults to 5 minutes.
+func (s *Storage) ActiveVisitors(ctx context.Context, window time.Duration, host string) (int64, error) {
+	if window <= 0 {
+		window = 5 * time.Minute
+	}
+	now := time.Now()
+	// A minute of slack covers log timestamps slightly ahead of our clock
+	return s.activeVisitors(ctx, now.Add(-window), now.Add(time.Minute), host)
+}
+
+// activeVisitors counts distinct non-bot IPs with requests in [from, to).
+func (s *Storage) activeVisitors(ctx context.Context, from, to time.Time, host string) (int64, error) {
+	query := `SELECT COUNT(DISTINCT ip) FROM requests WHERE ts >= ? AND ts < ? AND is_bot = 0`
+	args := []any{from, to}
+	if host != "" {
+		query += " AND " + hostMatch
+		args = append(args, host)
+	}
+	var n int64
+	err := s.db.QueryRowContext(ctx, query, args...).Scan(&n)
+	return n, err
+}
@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStorage_Realtime(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Date(2026, 3, 10, 12, 30, 40, 0, time.UTC)
+	records := []struct {
+		ago time.Duration
+		ip  string
+		bot bool
+	}{
+		{10 * time.Second, "10.0.0.1", false}, // 12:30
+		{20 * time.Second, "10.0.0.2", false}, // 12:30
+		{70 * time.Second, "10.0.0.1", false}, // 12:29
+		{3 * time.Minute, "10.0.0.9", true},   // 12:27, bot
+		{10 * time.Minute, "10.0.0.3", false}, // 12:20, outside a 5-minute window
+		{-time.Minute, "10.0.0.4", false},     // after the window, ignored
+	}
+	for _, r := range records {
+		rec := RequestRecord{Timestamp: now.Add(-r.ago), Host: "example.com", Path: "/", Status: 200, IP: r.ip, IsBot: r.bot}
+		if err := s.InsertRequest(ctx, rec); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	stats, err := s.realtime(ctx, now, 5, "")
+	if err != nil {
+		t.Fatalf("realtime() error = %v", err)
+	}
+	if stats.Minutes != 5 || len(stats.PerMinute) != 5 {
+		t.Fatalf("got %d minutes with %d buckets, want 5", stats.Minutes, len(stats.PerMinute))
+	}
+	wantCounts := []int64{0, 1, 0, 1, 2} // 12:26 .. 12:30
+	for i, want := range wantCounts {
+		b := stats.PerMinute[i]
+		if wantMinute := time.Date(2026, 3, 10, 12, 26+i, 0, 0, time.UTC); !b.Minute.Equal(wantMinute) {
+			t.Errorf("bucket %d minute = %v, want %v", i, b.Minute, wantMinute)
+		}
+		if b.Requests != want {
+			t.Errorf("bucket %d (%s) requests = %d, want %d", i, b.Minute.Format("15:04"), b.Requests, want)
+		}
+	}
+	if stats.TotalRequests != 4 {
+		t.Errorf("TotalRequests = %d, want 4", stats.TotalRequests)
+	}
+	// 10.0.0.1 and 10.0.0.2; bots and out-of-window IPs don't count
+	if stats.ActiveVisitors != 2 {
+		t.Errorf("ActiveVisitors = %d, want 2", stats.ActiveVisitors)
+	}
+}
+
+func TestStorage_Realtime_Defaults(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	stats, err := s.Realtime(context.Background(), 0, "")
+	if err != nil {
+		t.Fatalf("Realtime() error = %v", err)
+	}
+	if stats.Minutes != 30 || len(stats.PerMinute) != 30 || stats.TotalRequests != 0 {
+		t.Errorf("Realtime(0) = %d minutes, %d buckets, %d requests; want 30, 30, 0", stats.Minutes, len(stats.PerMinute), stats.TotalRequests)
+	}
+
+	stats, err = s.Realtime(context.Background(), 10_000, "")
+	if err != nil {
+		t.Fatalf("Realtime() error = %v", err)
+	}
+	if stats.Minutes != maxRealtimeMinutes {
+		t.Errorf("Realtime(10000).Minutes = %d, want %d", stats.Minutes, maxRealtimeMinutes)
+	}
+}
+
+func TestStorage_ActiveVisitors(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	records := []RequestRecord{
+		{Timestamp: now.Add(-time.Minute), IP: "10.0.0.1"},
+		{Timestamp: now.Add(-2 * time.Minute), IP: "10.0.0.1"},
+		{Timestamp: now.Add(-3 * time.Minute), IP: "10.0.0.2"},
+		{Timestamp: now.Add(-20 * time.Minute), IP: "10.0.0.3"}, // too old
+		{Timestamp: now.Add(-time.Minute), IP: "10.0.0.4", IsBot: true},
+	}
+	for _, rec := range records {
+		rec.Host = "example.com"
+		rec.Path = "/"
+		rec.Status = 200
+		if err := s.InsertRequest(ctx, rec); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	n, err := s.ActiveVisitors(ctx, 5*time.Minute, "")
+	if err != nil {
+		t.Fatalf("ActiveVisitors() error = %v", err)
+	}
+	if n != 2 {
+		t.Errorf("ActiveVisitors(5m) = %d, want 2", n)
+	}
+
+	n, err = s.ActiveVisitors(ctx, time.Hour, "")
+	if err != nil {
+		t.Fatalf("ActiveVisitors() error = %v", err)
+	}
+	if n != 3 {
+		t.Errorf("ActiveVisitors(1h) = %d, want 3", n)
+	}
+
+	n, err = s.ActiveVisitors(ctx, 5*time.Minute, "other.com")
+	if err != nil {
+		t.Fatalf("ActiveVisitors() error = %v", err)
+	}
+	if n != 0 {
+		t.Errorf("ActiveVisitors(other.com) = %d, want 0", n)
+	}
+}
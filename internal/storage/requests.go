@@ -3,18 +3,49 @@ package storage
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
 	"time"
 )
 
 // InsertRequest inserts a new request record and updates rollup tables.
 func (s *Storage) InsertRequest(ctx context.Context, r RequestRecord) error {
+	_, err := s.InsertRequestWithID(ctx, r)
+	return err
+}
+
+// InsertRequestWithID is InsertRequest that also returns the id of the new
+// row, or 0 when the request was skipped as a duplicate.
+func (s *Storage) InsertRequestWithID(ctx context.Context, r RequestRecord) (int64, error) {
+	ids, err := s.insertRequests(ctx, []RequestRecord{r})
+	if err != nil {
+		return 0, err
+	}
+	return ids[0], nil
+}
+
+// InsertRequestBatch inserts request records and updates rollup tables in a
+// single transaction; either all records are stored or none are.
+func (s *Storage) InsertRequestBatch(ctx context.Context, records []RequestRecord) error {
+	_, err := s.insertRequests(ctx, records)
+	return err
+}
+
+// insertRequests is InsertRequestBatch returning the id of each record's
+// row, 0 for skipped duplicates.
+func (s *Storage) insertRequests(ctx context.Context, records []RequestRecord) ([]int64, error) {
+	if len(records) == 0 {
+		return nil, nil
+	}
 	s.writeMu.Lock()
 	defer s.writeMu.Unlock()
 
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	committed := false
 	defer func() {
@@ -23,16 +54,47 @@ func (s *Storage) InsertRequest(ctx context.Context, r RequestRecord) error {
 		}
 	}()
 
+	// Use prepared statement within the transaction
+	stmt := tx.StmtContext(ctx, s.stmtInsertRequest)
+	ids := make([]int64, len(records))
+	for n, r := range records {
+		if ids[n], err = s.insertRequestTx(ctx, tx, stmt, r); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	committed = true
+	return ids, nil
+}
+
+// insertRequestTx stores r and adds it to the rollups. It returns the new
+// row's id, or 0 when r was skipped as a duplicate.
+func (s *Storage) insertRequestTx(ctx context.Context, tx *sql.Tx, stmt *sql.Stmt, r RequestRecord) (int64, error) {
 	isBot := 0
 	if r.IsBot {
 		isBot = 1
 	}
+	internal := 0
+	if r.Internal {
+		internal = 1
+	}
 
-	// Use prepared statement within the transaction
-	stmt := tx.StmtContext(ctx, s.stmtInsertRequest)
-	_, err = stmt.ExecContext(ctx, r.Timestamp, r.Host, r.Path, r.Status, r.Bytes, r.IP, r.Referrer, r.UserAgent, r.ResponseTime, r.Country, r.Region, r.City, r.Browser, r.BrowserVersion, r.OS, r.OSVersion, r.DeviceType, isBot, r.BotName, r.BotIntent)
+	res, err := stmt.ExecContext(ctx, r.Timestamp, r.Host, r.Path, r.Status, r.Bytes, r.IP, r.Referrer, r.UserAgent, r.ResponseTime, r.Country, r.Region, r.City, r.Browser, r.BrowserVersion, r.OS, r.OSVersion, r.DeviceType, isBot, r.BotName, r.BotIntent, r.DedupHash, r.weight(), r.ContentType, r.Method, r.Protocol, r.TLSVersion, r.CacheStatus, r.BotVerification, r.DeviceBrand, r.DeviceModel, r.Language, internal)
 	if err != nil {
-		return err
+		return 0, err
+	}
+	if r.DedupHash != "" {
+		// A duplicate was skipped; don't count it in the rollups either
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			return 0, nil
+		}
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
 	}
 
 	buckets := []struct {
@@ -43,51 +105,166 @@ func (s *Storage) InsertRequest(ctx context.Context, r RequestRecord) error {
 		{"rollups_daily", r.Timestamp.Truncate(24 * time.Hour)},
 	}
 	for _, b := range buckets {
-		if err = s.updateRollup(ctx, tx, b.table, b.time, r); err != nil {
-			return err
+		if err := s.updateRollup(ctx, tx, b.table, b.time, r); err != nil {
+			return 0, err
 		}
 	}
+	return id, nil
+}
 
-	if err = tx.Commit(); err != nil {
-		return err
+// weight returns how many requests r represents, at least 1.
+func (r RequestRecord) weight() int64 {
+	if r.SampleWeight < 1 {
+		return 1
 	}
-	committed = true
-	return nil
+	return int64(r.SampleWeight)
 }
 
 func (s *Storage) updateRollup(ctx context.Context, tx *sql.Tx, table string, bucket time.Time, r RequestRecord) error {
-	status2xx := 0
-	status3xx := 0
-	status4xx := 0
-	status5xx := 0
+	w := r.weight()
+	c := RollupCounts{Host: r.Host, Path: r.Path, Requests: w, Bytes: r.Bytes * w}
+	c.AddStatus(r.Status, w)
+	return addRollup(ctx, tx, table, bucket, c)
+}
+
+// AddStatus attributes n requests with the given status code to its class.
+// Anything outside 200-599, such as the 0 Caddy logs when the client went
+// away before a response, is counted in StatusOther.
+func (c *RollupCounts) AddStatus(status int, n int64) {
 	switch {
-	case r.Status >= 200 && r.Status < 300:
-		status2xx = 1
-	case r.Status >= 300 && r.Status < 400:
-		status3xx = 1
-	case r.Status >= 400 && r.Status < 500:
-		status4xx = 1
-	case r.Status >= 500:
-		status5xx = 1
+	case status >= 200 && status < 300:
+		c.Status2xx += n
+	case status >= 300 && status < 400:
+		c.Status3xx += n
+	case status >= 400 && status < 500:
+		c.Status4xx += n
+	case status >= 500 && status < 600:
+		c.Status5xx += n
+	default:
+		c.StatusOther += n
 	}
+}
 
+func addRollup(ctx context.Context, tx *sql.Tx, table string, bucket time.Time, c RollupCounts) error {
 	_, err := tx.ExecContext(ctx, fmt.Sprintf(`
-INSERT INTO %s (bucket_start, host, path, requests, bytes, status_2xx, status_3xx, status_4xx, status_5xx)
-VALUES (?, ?, ?, 1, ?, ?, ?, ?, ?)
+INSERT INTO %s (bucket_start, host, path, requests, bytes, status_2xx, status_3xx, status_4xx, status_5xx, status_other)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 ON CONFLICT(bucket_start, host, path) DO UPDATE SET
-	requests = requests + 1,
+	requests = requests + excluded.requests,
 	bytes = bytes + excluded.bytes,
 	status_2xx = status_2xx + excluded.status_2xx,
 	status_3xx = status_3xx + excluded.status_3xx,
 	status_4xx = status_4xx + excluded.status_4xx,
-	status_5xx = status_5xx + excluded.status_5xx
+	status_5xx = status_5xx + excluded.status_5xx,
+	status_other = status_other + excluded.status_other
 `, table),
-		bucket, r.Host, r.Path, r.Bytes, status2xx, status3xx, status4xx, status5xx)
+		bucket, c.Host, c.Path, c.Requests, c.Bytes, c.Status2xx, c.Status3xx, c.Status4xx, c.Status5xx, c.StatusOther)
 	return err
 }
 
+// AddRollupCounts adds pre-aggregated counts to the hourly and daily rollups
+// for the buckets containing ts, without inserting raw requests. It is used
+// by ingest sources that only see totals, such as Caddy's metrics endpoint.
+func (s *Storage) AddRollupCounts(ctx context.Context, ts time.Time, counts []RollupCounts) error {
+	if len(counts) == 0 {
+		return nil
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			_ = tx.Rollback()
+		}
+	}()
+
+	for _, c := range counts {
+		if err = addRollup(ctx, tx, "rollups_hourly", ts.Truncate(time.Hour), c); err != nil {
+			return err
+		}
+		if err = addRollup(ctx, tx, "rollups_daily", ts.Truncate(24*time.Hour), c); err != nil {
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	committed = true
+	return nil
+}
+
+// HourlyRollupTotals sums the hourly rollups for buckets starting in
+// [from, to), optionally for a single host and its aliases. Summary uses it
+// for the compacted part of its range, where only rollups remain; the
+// rollups also hold totals polled from CADDY_METRICS_URL.
+func (s *Storage) HourlyRollupTotals(ctx context.Context, from, to time.Time, host string) (RollupCounts, error) {
+	out := RollupCounts{Host: host}
+	query := `
+SELECT IFNULL(SUM(requests),0), IFNULL(SUM(bytes),0), IFNULL(SUM(status_2xx),0), IFNULL(SUM(status_3xx),0), IFNULL(SUM(status_4xx),0), IFNULL(SUM(status_5xx),0), IFNULL(SUM(status_other),0)
+FROM rollups_hourly WHERE bucket_start >= ? AND bucket_start < ?`
+	args := []any{from.UTC(), to.UTC()}
+	if host != "" {
+		query += " AND " + hostMatch
+		args = append(args, host)
+	}
+	err := s.db.QueryRowContext(ctx, query, args...).Scan(&out.Requests, &out.Bytes, &out.Status2xx, &out.Status3xx, &out.Status4xx, &out.Status5xx, &out.StatusOther)
+	return out, err
+}
+
+// RollupTotalsByHost sums the daily rollups per host for the limit hosts
+// with the most requests. The remaining hosts are summed into one entry
+// with Host "other" so callers can bound label cardinality.
+func (s *Storage) RollupTotalsByHost(ctx context.Context, limit int) ([]RollupCounts, error) {
+	rows, err := s.db.QueryContext(ctx, `
+SELECT IFNULL(host, ''), IFNULL(SUM(requests),0), IFNULL(SUM(bytes),0), IFNULL(SUM(status_2xx),0), IFNULL(SUM(status_3xx),0), IFNULL(SUM(status_4xx),0), IFNULL(SUM(status_5xx),0), IFNULL(SUM(status_other),0)
+FROM rollups_daily
+GROUP BY host
+ORDER BY 2 DESC, 1`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []RollupCounts
+	var other *RollupCounts
+	for rows.Next() {
+		var c RollupCounts
+		if err := rows.Scan(&c.Host, &c.Requests, &c.Bytes, &c.Status2xx, &c.Status3xx, &c.Status4xx, &c.Status5xx, &c.StatusOther); err != nil {
+			return nil, err
+		}
+		if limit <= 0 || len(out) < limit {
+			out = append(out, c)
+			continue
+		}
+		if other == nil {
+			other = &RollupCounts{Host: "other"}
+		}
+		other.Requests += c.Requests
+		other.Bytes += c.Bytes
+		other.Status2xx += c.Status2xx
+		other.Status3xx += c.Status3xx
+		other.Status4xx += c.Status4xx
+		other.Status5xx += c.Status5xx
+		other.StatusOther += c.StatusOther
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if other != nil {
+		out = append(out, *other)
+	}
+	return out, nil
+}
+
 // Cleanup deletes requests older than the retention period.
 func (s *Storage) Cleanup(ctx context.Context, retentionDays int) error {
+	defer s.countBaseline.reset()
 	_, err := s.db.ExecContext(ctx, `
 DELETE FROM requests WHERE ts < datetime('now', ?)
 `, fmt.Sprintf("-%d days", retentionDays))
@@ -96,23 +273,24 @@ DELETE FROM requests WHERE ts < datetime('now', ?)
 
 // CleanupResult holds statistics from a cleanup operation.
 type CleanupResult struct {
-	GlobalDeleted   int64            // Requests deleted using global retention
-	PerSiteDeleted  map[string]int64 // Requests deleted per site with custom retention
-	TotalDeleted    int64            // Total requests deleted
-	SitesProcessed  int              // Number of sites with custom retention processed
+	GlobalDeleted  int64            `json:"global_deleted"`   // Requests deleted using global retention
+	PerSiteDeleted map[string]int64 `json:"per_site_deleted"` // Requests deleted per site with custom retention
+	TotalDeleted   int64            `json:"total_deleted"`    // Total requests deleted
+	SitesProcessed int              `json:"sites_processed"`  // Number of sites with custom retention processed
 }
 
 // CleanupWithPerSiteRetention deletes old requests respecting per-site retention policies.
 // Sites with a custom retention_days > 0 use their configured value.
 // All other requests use the global defaultRetentionDays.
 func (s *Storage) CleanupWithPerSiteRetention(ctx context.Context, defaultRetentionDays int) (*CleanupResult, error) {
+	defer s.countBaseline.reset()
 	result := &CleanupResult{
 		PerSiteDeleted: make(map[string]int64),
 	}
 
 	// Get all sites with custom retention policies
 	rows, err := s.db.QueryContext(ctx, `
-		SELECT host, retention_days
+		SELECT id, host, retention_days
 		FROM sites
 		WHERE retention_days > 0
 	`)
@@ -122,13 +300,14 @@ func (s *Storage) CleanupWithPerSiteRetention(ctx context.Context, defaultRetent
 	defer rows.Close()
 
 	type siteRetention struct {
+		id            int64
 		host          string
 		retentionDays int
 	}
 	var customSites []siteRetention
 	for rows.Next() {
 		var sr siteRetention
-		if err := rows.Scan(&sr.host, &sr.retentionDays); err != nil {
+		if err := rows.Scan(&sr.id, &sr.host, &sr.retentionDays); err != nil {
 			return nil, fmt.Errorf("scan site retention: %w", err)
 		}
 		customSites = append(customSites, sr)
@@ -139,11 +318,12 @@ func (s *Storage) CleanupWithPerSiteRetention(ctx context.Context, defaultRetent
 
 	result.SitesProcessed = len(customSites)
 
-	// Delete requests for sites with custom retention
+	// Delete requests for sites with custom retention, including those
+	// logged under the site's aliases
 	for _, sr := range customSites {
 		res, err := s.db.ExecContext(ctx, `
 			DELETE FROM requests
-			WHERE host = ? AND ts < datetime('now', ?)
+			WHERE `+hostMatch+` AND ts < datetime('now', ?)
 		`, sr.host, fmt.Sprintf("-%d days", sr.retentionDays))
 		if err != nil {
 			return nil, fmt.Errorf("cleanup site %s: %w", sr.host, err)
@@ -155,10 +335,16 @@ func (s *Storage) CleanupWithPerSiteRetention(ctx context.Context, defaultRetent
 		}
 	}
 
-	// Build list of hosts with custom retention to exclude from global cleanup
+	// Build list of hosts with custom retention to exclude from global
+	// cleanup, aliases included
+	aliases, err := s.allSiteAliases(ctx)
+	if err != nil {
+		return nil, err
+	}
 	var excludeHosts []string
 	for _, sr := range customSites {
 		excludeHosts = append(excludeHosts, sr.host)
+		excludeHosts = append(excludeHosts, aliases[sr.id]...)
 	}
 
 	// Delete requests for all other hosts using global retention
@@ -193,6 +379,74 @@ func (s *Storage) CleanupWithPerSiteRetention(ctx context.Context, defaultRetent
 	return result, nil
 }
 
+// compactBatchSize bounds how many raw rows CompactRawData deletes per
+// statement, so ingest isn't blocked behind one long delete.
+const compactBatchSize = 10000
+
+// CompactRawData deletes raw requests older than olderThanDays for every
+// host, leaving the hourly and daily rollups in place so Summary can still
+// report request, byte and status totals for old periods. It returns the
+// rows deleted.
+func (s *Storage) CompactRawData(ctx context.Context, olderThanDays int) (int64, error) {
+	return s.compactRawData(ctx, time.Now(), olderThanDays)
+}
+
+func (s *Storage) compactRawData(ctx context.Context, now time.Time, olderThanDays int) (int64, error) {
+	if olderThanDays <= 0 {
+		return 0, nil
+	}
+	// Align the cutoff to an hourly rollup bucket so a bucket is either
+	// fully compacted or fully raw, never counted twice
+	cutoff := now.UTC().AddDate(0, 0, -olderThanDays).Truncate(time.Hour)
+	defer s.countBaseline.reset()
+	var total int64
+	for {
+		s.writeMu.Lock()
+		res, err := s.db.ExecContext(ctx, `
+DELETE FROM requests WHERE id IN (SELECT id FROM requests WHERE ts < ? LIMIT ?)`, cutoff, compactBatchSize)
+		s.writeMu.Unlock()
+		if err != nil {
+			return total, fmt.Errorf("compact raw data: %w", err)
+		}
+		n, _ := res.RowsAffected()
+		total += n
+		if n < compactBatchSize {
+			break
+		}
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	_, err := s.db.ExecContext(ctx, `
+INSERT INTO compaction_state (id, compacted_before) VALUES (1, ?)
+ON CONFLICT(id) DO UPDATE SET compacted_before = excluded.compacted_before
+WHERE excluded.compacted_before > compacted_before`, cutoff)
+	if err != nil {
+		return total, fmt.Errorf("record compaction cutoff: %w", err)
+	}
+	return total, nil
+}
+
+// compactedBefore returns the time before which raw rows have been
+// compacted away, or the zero time if compaction has never run.
+func (s *Storage) compactedBefore(ctx context.Context) (time.Time, error) {
+	var t time.Time
+	err := s.db.QueryRowContext(ctx, "SELECT compacted_before FROM compaction_state WHERE id = 1").Scan(&t)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	return t, err
+}
+
+// VacuumInto writes a consistent, compacted copy of the database to path
+// with VACUUM INTO. path must not exist or must be an empty file.
+func (s *Storage) VacuumInto(ctx context.Context, path string) error {
+	if _, err := s.db.ExecContext(ctx, "VACUUM INTO ?", path); err != nil {
+		return fmt.Errorf("vacuum into %s: %w", path, err)
+	}
+	return nil
+}
+
 // Vacuum runs SQLite VACUUM to reclaim space and defragment the database.
 // This is useful to run after bulk deletes (like data retention cleanup).
 // Returns the bytes freed (approximate, based on file size before/after).
@@ -220,14 +474,126 @@ func (s *Storage) Vacuum(ctx context.Context) (int64, error) {
 	return bytesFreed, nil
 }
 
+// Optimize runs PRAGMA optimize so SQLite refreshes the query planner
+// statistics for tables that have changed significantly since the last run.
+func (s *Storage) Optimize(ctx context.Context) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	if _, err := s.db.ExecContext(ctx, "PRAGMA optimize"); err != nil {
+		return fmt.Errorf("optimize failed: %w", err)
+	}
+	return nil
+}
+
+// ErrMaintenanceRunning is returned by RunMaintenance when another run is
+// already in progress.
+var ErrMaintenanceRunning = errors.New("maintenance already running")
+
+// MaintenanceResult holds the outcome of a cleanup followed by a vacuum.
+type MaintenanceResult struct {
+	Cleanup    *CleanupResult `json:"cleanup"`
+	BytesFreed int64          `json:"bytes_freed"`
+}
+
+// RunMaintenance applies retention with CleanupWithPerSiteRetention, then
+// runs Vacuum and Optimize. Only one run may be in progress at a time; concurrent callers
+// get ErrMaintenanceRunning instead of waiting.
+func (s *Storage) RunMaintenance(ctx context.Context, defaultRetentionDays int) (*MaintenanceResult, error) {
+	if !s.maintenanceMu.TryLock() {
+		return nil, ErrMaintenanceRunning
+	}
+	defer s.maintenanceMu.Unlock()
+
+	cleanup, err := s.CleanupWithPerSiteRetention(ctx, defaultRetentionDays)
+	if err != nil {
+		return nil, err
+	}
+	result := &MaintenanceResult{Cleanup: cleanup}
+
+	bytesFreed, err := s.Vacuum(ctx)
+	if err != nil {
+		return result, err
+	}
+	result.BytesFreed = bytesFreed
+
+	// Stale planner stats only slow queries down, so this doesn't fail the run
+	if err := s.Optimize(ctx); err != nil {
+		slog.Warn("failed to optimize database", "error", err)
+	}
+
+	// Vacuuming may have freed enough space to resume ingest
+	s.CheckDiskSpace()
+	return result, nil
+}
+
+const (
+	// DefaultMaxRecentRequests is the RecentRequests cap when
+	// Options.MaxRecentRequests is unset.
+	DefaultMaxRecentRequests = 100
+	// MaxRecentRequestsCeiling bounds Options.MaxRecentRequests so a
+	// misconfiguration can't load an unbounded result set into memory.
+	MaxRecentRequestsCeiling = 5000
+)
+
+// StatusFilter restricts requests to status codes in [Min, Max]. The zero
+// value matches every status.
+type StatusFilter struct {
+	Min, Max int
+}
+
+// ParseStatusFilter parses an exact status code ("404") or a class ("5xx").
+// An empty string yields the zero filter.
+func ParseStatusFilter(v string) (StatusFilter, error) {
+	if v == "" {
+		return StatusFilter{}, nil
+	}
+	if len(v) == 3 && strings.EqualFold(v[1:], "xx") && v[0] >= '1' && v[0] <= '5' {
+		base := int(v[0]-'0') * 100
+		return StatusFilter{Min: base, Max: base + 99}, nil
+	}
+	code, err := strconv.Atoi(v)
+	if err != nil || code < 100 || code > 599 {
+		return StatusFilter{}, fmt.Errorf("invalid status %q: want a code like 404 or a class like 5xx", v)
+	}
+	return StatusFilter{Min: code, Max: code}, nil
+}
+
+// MaxPathSearchLength bounds RecentFilter.PathContains.
+const MaxPathSearchLength = 256
+
+// RecentFilter narrows RecentRequestsBefore. The zero value matches every
+// request.
+type RecentFilter struct {
+	Status StatusFilter
+	// PathContains keeps requests whose path contains it, ignoring ASCII
+	// case. % and _ match literally.
+	PathContains string
+}
+
 // RecentRequests returns the most recent N requests, optionally filtered by host.
 // Uses a 24-hour time filter to leverage the ts index and avoid full table scans.
+// N is capped at the configured MaxRecentRequests.
 func (s *Storage) RecentRequests(ctx context.Context, limit int, host string) ([]RecentRequest, error) {
+	return s.RecentRequestsBefore(ctx, limit, host, 0, RecentFilter{})
+}
+
+// likeEscaper escapes LIKE wildcards for use with ESCAPE '\'.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// RecentRequestsBefore is RecentRequests with a cursor: when beforeID is
+// positive only rows that sort after that row are returned. Rows are ordered
+// newest first by timestamp, ties broken by descending id, so passing the
+// last id of one page as the next beforeID pages backwards without skipping
+// or repeating rows while new requests are inserted. A cursor row that has
+// since been deleted yields no rows. filter further restricts the rows; a
+// PathContains longer than MaxPathSearchLength is truncated.
+func (s *Storage) RecentRequestsBefore(ctx context.Context, limit int, host string, beforeID int64, filter RecentFilter) ([]RecentRequest, error) {
 	if limit <= 0 {
 		limit = 20
 	}
-	if limit > 100 {
-		limit = 100
+	if limit > s.maxRecentRequests {
+		limit = s.maxRecentRequests
 	}
 
 	query := `
@@ -240,10 +606,25 @@ WHERE ts >= datetime('now', '-24 hours')`
 
 	args := []any{}
 	if host != "" {
-		query += " AND host = ?"
+		query += " AND " + hostMatch
 		args = append(args, host)
 	}
-	query += " ORDER BY ts DESC LIMIT ?"
+	if beforeID > 0 {
+		query += " AND (ts, id) < (SELECT ts, id FROM requests WHERE id = ?)"
+		args = append(args, beforeID)
+	}
+	if filter.Status != (StatusFilter{}) {
+		query += " AND status BETWEEN ? AND ?"
+		args = append(args, filter.Status.Min, filter.Status.Max)
+	}
+	if p := filter.PathContains; p != "" {
+		if len(p) > MaxPathSearchLength {
+			p = p[:MaxPathSearchLength]
+		}
+		query += ` AND path LIKE ? ESCAPE '\'`
+		args = append(args, "%"+likeEscaper.Replace(p)+"%")
+	}
+	query += " ORDER BY ts DESC, id DESC LIMIT ?"
 	args = append(args, limit)
 
 	rows, err := s.db.QueryContext(ctx, query, args...)
@@ -252,6 +633,60 @@ WHERE ts >= datetime('now', '-24 hours')`
 	}
 	defer rows.Close()
 
+	return scanRecentRequests(rows)
+}
+
+// RequestsByIP returns requests from one client IP in chronological order,
+// for investigating a single visitor. If hosts is non-nil, only requests to
+// those hosts are returned.
+func (s *Storage) RequestsByIP(ctx context.Context, ip string, dur time.Duration, limit, offset int, hosts []string) ([]RecentRequest, error) {
+	from := time.Now().Add(-dur)
+	if limit <= 0 {
+		limit = 100
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	if hosts != nil && len(hosts) == 0 {
+		return []RecentRequest{}, nil
+	}
+
+	query := `
+SELECT
+	id, ts, host, path, status, bytes, ip, referrer, user_agent,
+	resp_time_ms, country, region, city, browser, browser_version,
+	os, os_version, device_type, is_bot, bot_name
+FROM requests
+WHERE ip = ? AND ts >= ?`
+
+	args := []any{ip, from}
+	if len(hosts) > 0 {
+		query += " AND " + hostsMatch(len(hosts))
+		for _, h := range hosts {
+			args = append(args, h)
+		}
+	}
+	query += " ORDER BY ts ASC, id ASC LIMIT ? OFFSET ?"
+	args = append(args, limit, offset)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out, err := scanRecentRequests(rows)
+	if out == nil && err == nil {
+		out = []RecentRequest{}
+	}
+	return out, err
+}
+
+// scanRecentRequests reads rows selected with the RecentRequest column list.
+func scanRecentRequests(rows *sql.Rows) ([]RecentRequest, error) {
 	var out []RecentRequest
 	for rows.Next() {
 		var r RecentRequest
@@ -291,11 +726,42 @@ WHERE ts >= ?`
 
 	args := []any{from}
 	if host != "" {
-		query += " AND host = ?"
+		query += " AND " + hostMatch
 		args = append(args, host)
 	}
 	query += " ORDER BY ts ASC"
+	return s.streamExport(ctx, query, args, batchSize, callback)
+}
+
+// ExportRequestsSince streams requests with an id above sinceID in id order,
+// at most limit of them (0 or less means all), so a sync tool can fetch only
+// what was stored since its last run by passing the last id it saw. Rows are
+// never updated, but retention can delete old ones, so ids may have gaps.
+func (s *Storage) ExportRequestsSince(ctx context.Context, sinceID int64, host string, limit int, callback ExportRequestsCallback) error {
+	query := `
+SELECT
+	id, ts, host, path, status, bytes, ip, referrer, user_agent,
+	resp_time_ms, country, region, city, browser, browser_version,
+	os, os_version, device_type, is_bot, bot_name
+FROM requests
+WHERE id > ?`
+
+	args := []any{sinceID}
+	if host != "" {
+		query += " AND " + hostMatch
+		args = append(args, host)
+	}
+	query += " ORDER BY id ASC"
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+	return s.streamExport(ctx, query, args, 1000, callback)
+}
 
+// streamExport runs an export query selecting the ExportRequest columns and
+// hands the rows to callback in batches of batchSize.
+func (s *Storage) streamExport(ctx context.Context, query string, args []any, batchSize int, callback ExportRequestsCallback) error {
 	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return err
@@ -303,7 +769,10 @@ WHERE ts >= ?`
 	defer rows.Close()
 
 	batch := make([]ExportRequest, 0, batchSize)
-	for rows.Next() {
+	for n := 0; rows.Next(); n++ {
+		if err := scanCanceled(ctx, n); err != nil {
+			return err
+		}
 		var r ExportRequest
 		var tsStr sql.NullString
 		var isBot int
@@ -321,6 +790,10 @@ WHERE ts >= ?`
 		batch = append(batch, r)
 
 		if len(batch) >= batchSize {
+			// Don't hand a batch to a caller whose request is gone
+			if err := ctx.Err(); err != nil {
+				return err
+			}
 			if err := callback(batch); err != nil {
 				return err
 			}
@@ -328,12 +801,18 @@ WHERE ts >= ?`
 		}
 	}
 
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
 	// Process remaining items
 	if len(batch) > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		if err := callback(batch); err != nil {
 			return err
 		}
 	}
-
-	return rows.Err()
+	return nil
 }
@@ -0,0 +1,192 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"strings"
+	"time"
+)
+
+// errorIPTopPaths is how many of each IP's most-hit error paths are returned.
+const errorIPTopPaths = 5
+
+// TopErrorIPs returns client IPs ranked by their number of 4xx/5xx responses,
+// each with a breakdown of the error paths they hit most. Useful for spotting
+// scanners and misbehaving clients.
+func (s *Storage) TopErrorIPs(ctx context.Context, dur time.Duration, host string, limit int, excludeBots bool) ([]ErrorIPStat, error) {
+	from := time.Now().Add(-dur)
+	if limit <= 0 {
+		limit = 20
+	}
+
+	filter := " WHERE ts >= ? AND status >= 400"
+	filterArgs := []any{from}
+	if excludeBots {
+		filter += " AND is_bot = 0"
+	}
+	if host != "" {
+		filter += " AND " + hostMatch
+		filterArgs = append(filterArgs, host)
+	}
+
+	query := `
+SELECT
+	ip,
+	SUM(IFNULL(sample_weight, 1)) AS errors,
+	SUM(CASE WHEN status < 500 THEN IFNULL(sample_weight, 1) ELSE 0 END) AS client_errors,
+	SUM(CASE WHEN status >= 500 THEN IFNULL(sample_weight, 1) ELSE 0 END) AS server_errors,
+	MAX(ts) AS last_seen
+FROM requests` + filter + `
+GROUP BY ip
+ORDER BY errors DESC, ip
+LIMIT ?`
+	args := append(append([]any{}, filterArgs...), limit)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []ErrorIPStat{}
+	index := make(map[string]int)
+	for rows.Next() {
+		var st ErrorIPStat
+		var lastSeen sql.NullString
+		if err := rows.Scan(&st.IP, &st.Errors, &st.ClientErrors, &st.ServerErrors, &lastSeen); err != nil {
+			return nil, err
+		}
+		if lastSeen.Valid {
+			st.LastSeen = parseTimestamp(lastSeen.String)
+		}
+		st.TopPaths = []ErrorPathCount{}
+		index[st.IP] = len(out)
+		out = append(out, st)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(out) == 0 {
+		return out, nil
+	}
+
+	// Break down the error paths for just the ranked IPs
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(out)), ",")
+	pathQuery := `
+SELECT
+	ip,
+	CASE WHEN instr(path, '?') > 0 THEN substr(path, 1, instr(path, '?') - 1) ELSE path END AS clean_path,
+	SUM(IFNULL(sample_weight, 1)) AS cnt
+FROM requests` + filter + ` AND ip IN (` + placeholders + `)
+GROUP BY ip, clean_path`
+	pathArgs := append([]any{}, filterArgs...)
+	for _, st := range out {
+		pathArgs = append(pathArgs, st.IP)
+	}
+
+	pathRows, err := s.db.QueryContext(ctx, pathQuery, pathArgs...)
+	if err != nil {
+		return nil, err
+	}
+	defer pathRows.Close()
+
+	for pathRows.Next() {
+		var ip string
+		var pc ErrorPathCount
+		if err := pathRows.Scan(&ip, &pc.Path, &pc.Count); err != nil {
+			return nil, err
+		}
+		if i, ok := index[ip]; ok {
+			out[i].TopPaths = append(out[i].TopPaths, pc)
+		}
+	}
+	if err := pathRows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range out {
+		paths := out[i].TopPaths
+		sort.Slice(paths, func(a, b int) bool {
+			if paths[a].Count != paths[b].Count {
+				return paths[a].Count > paths[b].Count
+			}
+			return paths[a].Path < paths[b].Path
+		})
+		if len(paths) > errorIPTopPaths {
+			out[i].TopPaths = paths[:errorIPTopPaths]
+		}
+	}
+	return out, nil
+}
+
+// ScannerPaths returns 404 paths containing one of the given patterns (matched
+// case-insensitively as substrings of the path, query string ignored), ranked
+// by hits along with how many distinct IPs probed them.
+func (s *Storage) ScannerPaths(ctx context.Context, dur time.Duration, host string, limit int, patterns []string) ([]ScanPathStat, error) {
+	out := []ScanPathStat{}
+	var matchers []string
+	var matchArgs []any
+	for _, p := range patterns {
+		if p = strings.ToLower(strings.TrimSpace(p)); p != "" {
+			matchers = append(matchers, "instr(lower(clean_path), ?) > 0")
+			matchArgs = append(matchArgs, p)
+		}
+	}
+	if len(matchers) == 0 {
+		return out, nil
+	}
+
+	from := time.Now().Add(-dur)
+	if limit <= 0 {
+		limit = 20
+	}
+
+	query := `
+WITH probes AS (
+	SELECT
+		CASE WHEN instr(path, '?') > 0 THEN substr(path, 1, instr(path, '?') - 1) ELSE path END AS clean_path,
+		ip,
+		ts,
+		IFNULL(sample_weight, 1) AS weight
+	FROM requests
+	WHERE ts >= ? AND status = 404`
+	args := []any{from}
+	if host != "" {
+		query += " AND " + hostMatch
+		args = append(args, host)
+	}
+	query += `
+)
+SELECT
+	clean_path,
+	SUM(weight) AS hits,
+	COUNT(DISTINCT ip) AS distinct_ips,
+	MAX(ts) AS last_seen
+FROM probes
+WHERE ` + strings.Join(matchers, " OR ") + `
+GROUP BY clean_path
+ORDER BY hits DESC, clean_path
+LIMIT ?`
+	args = append(args, matchArgs...)
+	args = append(args, limit)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var st ScanPathStat
+		var lastSeen sql.NullString
+		if err := rows.Scan(&st.Path, &st.Hits, &st.DistinctIPs, &lastSeen); err != nil {
+			return nil, err
+		}
+		if lastSeen.Valid {
+			st.LastSeen = parseTimestamp(lastSeen.String)
+		}
+		out = append(out, st)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStorage_TopErrorIPs(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	var requests []RequestRecord
+	// Scanner: many 404s across a few paths
+	for i := 0; i < 8; i++ {
+		requests = append(requests, RequestRecord{Timestamp: now.Add(-time.Minute), Host: "example.com", Path: "/.env", Status: 404, IP: "6.6.6.6"})
+	}
+	for i := 0; i < 3; i++ {
+		requests = append(requests, RequestRecord{Timestamp: now.Add(-time.Minute), Host: "example.com", Path: "/wp-login.php?x=1", Status: 404, IP: "6.6.6.6"})
+	}
+	requests = append(requests,
+		RequestRecord{Timestamp: now.Add(-time.Minute), Host: "example.com", Path: "/api", Status: 500, IP: "6.6.6.6"},
+		// Regular visitors with the odd error
+		RequestRecord{Timestamp: now.Add(-time.Minute), Host: "example.com", Path: "/missing", Status: 404, IP: "1.1.1.1"},
+		RequestRecord{Timestamp: now.Add(-time.Minute), Host: "example.com", Path: "/", Status: 200, IP: "1.1.1.1"},
+		RequestRecord{Timestamp: now.Add(-time.Minute), Host: "example.com", Path: "/", Status: 200, IP: "2.2.2.2"},
+		// Bot errors
+		RequestRecord{Timestamp: now.Add(-time.Minute), Host: "example.com", Path: "/old", Status: 410, IP: "3.3.3.3", IsBot: true},
+		RequestRecord{Timestamp: now.Add(-time.Minute), Host: "example.com", Path: "/old", Status: 410, IP: "3.3.3.3", IsBot: true},
+		// Outside the window
+		RequestRecord{Timestamp: now.Add(-48 * time.Hour), Host: "example.com", Path: "/.env", Status: 404, IP: "9.9.9.9"},
+	)
+	for _, req := range requests {
+		if err := s.InsertRequest(ctx, req); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	stats, err := s.TopErrorIPs(ctx, 24*time.Hour, "", 10, false)
+	if err != nil {
+		t.Fatalf("TopErrorIPs() error = %v", err)
+	}
+	if len(stats) != 3 {
+		t.Fatalf("expected 3 IPs with errors, got %d: %+v", len(stats), stats)
+	}
+
+	top := stats[0]
+	if top.IP != "6.6.6.6" {
+		t.Errorf("top IP = %q, want 6.6.6.6", top.IP)
+	}
+	if top.Errors != 12 || top.ClientErrors != 11 || top.ServerErrors != 1 {
+		t.Errorf("top counts = %d/%d/%d, want 12/11/1", top.Errors, top.ClientErrors, top.ServerErrors)
+	}
+	if top.LastSeen.IsZero() {
+		t.Error("expected LastSeen to be set")
+	}
+	if len(top.TopPaths) != 3 {
+		t.Fatalf("expected 3 top paths, got %+v", top.TopPaths)
+	}
+	if top.TopPaths[0].Path != "/.env" || top.TopPaths[0].Count != 8 {
+		t.Errorf("top path = %+v, want /.env x8", top.TopPaths[0])
+	}
+	if top.TopPaths[1].Path != "/wp-login.php" || top.TopPaths[1].Count != 3 {
+		t.Errorf("second path = %+v, want /wp-login.php x3 (query stripped)", top.TopPaths[1])
+	}
+
+	// Excluding bots drops the crawler's 410s
+	stats, err = s.TopErrorIPs(ctx, 24*time.Hour, "", 10, true)
+	if err != nil {
+		t.Fatalf("TopErrorIPs(excludeBots) error = %v", err)
+	}
+	for _, st := range stats {
+		if st.IP == "3.3.3.3" {
+			t.Error("bot IP should be excluded")
+		}
+	}
+}
+
+func TestStorage_TopErrorIPs_Empty(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	stats, err := s.TopErrorIPs(context.Background(), time.Hour, "example.com", 10, false)
+	if err != nil {
+		t.Fatalf("TopErrorIPs() error = %v", err)
+	}
+	if stats == nil || len(stats) != 0 {
+		t.Errorf("expected empty non-nil slice, got %#v", stats)
+	}
+}
+
+func TestStorage_ScannerPaths(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	var requests []RequestRecord
+	for _, ip := range []string{"6.6.6.1", "6.6.6.2", "6.6.6.3"} {
+		requests = append(requests,
+			RequestRecord{Timestamp: now, Host: "example.com", Path: "/.env", Status: 404, IP: ip},
+			RequestRecord{Timestamp: now, Host: "example.com", Path: "/.ENV?debug=1", Status: 404, IP: ip},
+		)
+	}
+	requests = append(requests,
+		RequestRecord{Timestamp: now, Host: "example.com", Path: "/wp-login.php", Status: 404, IP: "6.6.6.1"},
+		// Legitimate 404 and a served admin page are not probes
+		RequestRecord{Timestamp: now, Host: "example.com", Path: "/blog/typo", Status: 404, IP: "1.1.1.1"},
+		RequestRecord{Timestamp: now, Host: "example.com", Path: "/wp-login.php", Status: 200, IP: "1.1.1.1"},
+		// Other host
+		RequestRecord{Timestamp: now, Host: "other.com", Path: "/.env", Status: 404, IP: "7.7.7.7"},
+	)
+	for _, req := range requests {
+		if err := s.InsertRequest(ctx, req); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	patterns := []string{"/.env", "/wp-login.php"}
+	stats, err := s.ScannerPaths(ctx, time.Hour, "example.com", 10, patterns)
+	if err != nil {
+		t.Fatalf("ScannerPaths() error = %v", err)
+	}
+	if len(stats) != 3 {
+		t.Fatalf("expected 3 probed paths, got %+v", stats)
+	}
+	if stats[0].Path != "/.ENV" && stats[0].Path != "/.env" {
+		t.Errorf("top path = %q, want /.env", stats[0].Path)
+	}
+	for _, st := range stats {
+		switch st.Path {
+		case "/.env", "/.ENV":
+			if st.Hits != 3 || st.DistinctIPs != 3 {
+				t.Errorf("%s: hits=%d ips=%d, want 3/3", st.Path, st.Hits, st.DistinctIPs)
+			}
+		case "/wp-login.php":
+			if st.Hits != 1 || st.DistinctIPs != 1 {
+				t.Errorf("/wp-login.php: hits=%d ips=%d, want 1/1", st.Hits, st.DistinctIPs)
+			}
+		default:
+			t.Errorf("unexpected path %q", st.Path)
+		}
+		if st.LastSeen.IsZero() {
+			t.Errorf("%s: expected LastSeen to be set", st.Path)
+		}
+	}
+
+	// No patterns means nothing is flagged
+	stats, err = s.ScannerPaths(ctx, time.Hour, "", 10, nil)
+	if err != nil {
+		t.Fatalf("ScannerPaths(nil) error = %v", err)
+	}
+	if len(stats) != 0 {
+		t.Errorf("expected no results without patterns, got %+v", stats)
+	}
+}
@@ -27,7 +27,7 @@ func (s *Storage) VisitorSessions(ctx context.Context, dur time.Duration, host s
 	// Groups by IP + user_agent and detects session boundaries when gap > sessionTimeout
 	hostFilter := ""
 	if host != "" {
-		hostFilter = " AND host = ?"
+		hostFilter = " AND " + hostMatch
 	}
 	query := fmt.Sprintf(`
 WITH filtered AS (
@@ -79,20 +79,7 @@ session_stats AS (
 		MIN(ts) AS start_time,
 		MAX(ts) AS end_time,
 		MAX(ts_epoch) - MIN(ts_epoch) AS duration_seconds,
-		SUM(CASE
-			WHEN clean_path NOT LIKE '%%.css'
-				AND clean_path NOT LIKE '%%.js'
-				AND clean_path NOT LIKE '%%.png'
-				AND clean_path NOT LIKE '%%.jpg'
-				AND clean_path NOT LIKE '%%.jpeg'
-				AND clean_path NOT LIKE '%%.gif'
-				AND clean_path NOT LIKE '%%.svg'
-				AND clean_path NOT LIKE '%%.ico'
-				AND clean_path NOT LIKE '%%.woff%%'
-				AND clean_path NOT LIKE '%%.ttf'
-				AND clean_path NOT LIKE '%%.eot'
-				AND clean_path NOT LIKE '%%.map'
-			THEN 1 ELSE 0 END) AS page_views,
+		SUM(CASE WHEN %s THEN 1 ELSE 0 END) AS page_views,
 		COUNT(*) AS hits,
 		IFNULL(SUM(bytes), 0) AS bandwidth_bytes,
 		MAX(CASE WHEN rn_asc = 1 THEN clean_path END) AS entry_page,
@@ -116,7 +103,7 @@ SELECT
 	exit_page
 FROM session_stats
 ORDER BY start_time DESC
-LIMIT ?`, hostFilter, sessionTimeout)
+LIMIT ?`, hostFilter, sessionTimeout, s.assets.pageSQL("clean_path"))
 
 	args := []any{from}
 	if host != "" {
@@ -187,6 +174,7 @@ LIMIT ?`, hostFilter, sessionTimeout)
 		if bandwidth.Valid {
 			sess.BandwidthBytes = bandwidth.Int64
 		}
+		sess.BandwidthHuman = humanizeBytes(sess.BandwidthBytes)
 
 		// Parse timestamps
 		sess.StartTime = parseTimestamp(startStr.String)
@@ -227,7 +215,7 @@ LIMIT ?`, hostFilter, sessionTimeout)
 func (s *Storage) sessionsByHour(ctx context.Context, from time.Time, host string, sessionTimeout int) ([]HourlyBucket, error) {
 	hostFilter := ""
 	if host != "" {
-		hostFilter = " AND host = ?"
+		hostFilter = " AND " + hostMatch
 	}
 	query := fmt.Sprintf(`
 WITH filtered AS (
@@ -296,7 +284,7 @@ ORDER BY hour`, hostFilter, sessionTimeout)
 func (s *Storage) topEntryPages(ctx context.Context, from time.Time, host string, sessionTimeout int, limit int) ([]PageCount, error) {
 	hostFilter := ""
 	if host != "" {
-		hostFilter = " AND host = ?"
+		hostFilter = " AND " + hostMatch
 	}
 	query := fmt.Sprintf(`
 WITH filtered AS (
@@ -324,20 +312,14 @@ entry_pages AS (
 	SELECT clean_path
 	FROM with_gaps
 	WHERE new_session = 1
-	  AND clean_path NOT LIKE '%%.css'
-	  AND clean_path NOT LIKE '%%.js'
-	  AND clean_path NOT LIKE '%%.png'
-	  AND clean_path NOT LIKE '%%.jpg'
-	  AND clean_path NOT LIKE '%%.gif'
-	  AND clean_path NOT LIKE '%%.svg'
-	  AND clean_path NOT LIKE '%%.ico'
+	  AND %s
 )
 SELECT clean_path, COUNT(*) as cnt
 FROM entry_pages
 GROUP BY clean_path
 ORDER BY cnt DESC
 LIMIT ?`, hostFilter,
-		sessionTimeout,
+		sessionTimeout, s.assets.pageSQL("clean_path"),
 	)
 
 	args := []any{from}
@@ -367,7 +349,7 @@ LIMIT ?`, hostFilter,
 func (s *Storage) topExitPages(ctx context.Context, from time.Time, host string, sessionTimeout int, limit int) ([]PageCount, error) {
 	hostFilter := ""
 	if host != "" {
-		hostFilter = " AND host = ?"
+		hostFilter = " AND " + hostMatch
 	}
 	query := fmt.Sprintf(`
 WITH filtered AS (
@@ -404,13 +386,7 @@ exit_pages AS (
 			clean_path,
 			ROW_NUMBER() OVER (PARTITION BY ip, user_agent, session_id ORDER BY ts_epoch DESC) AS rn
 		FROM with_session_id
-		WHERE clean_path NOT LIKE '%%.css'
-		  AND clean_path NOT LIKE '%%.js'
-		  AND clean_path NOT LIKE '%%.png'
-		  AND clean_path NOT LIKE '%%.jpg'
-		  AND clean_path NOT LIKE '%%.gif'
-		  AND clean_path NOT LIKE '%%.svg'
-		  AND clean_path NOT LIKE '%%.ico'
+		WHERE %s
 	)
 	WHERE rn = 1
 )
@@ -418,7 +394,7 @@ SELECT clean_path, COUNT(*) as cnt
 FROM exit_pages
 GROUP BY clean_path
 ORDER BY cnt DESC
-LIMIT ?`, hostFilter, sessionTimeout)
+LIMIT ?`, hostFilter, sessionTimeout, s.assets.pageSQL("clean_path"))
 
 	args := []any{from}
 	if host != "" {
@@ -507,6 +483,7 @@ func parseTimestamp(s string) time.Time {
 	}
 	formats := []string{
 		"2006-01-02 15:04:05.999999999-07:00",
+		"2006-01-02 15:04:05.999999999 -0700 MST", // time.Time.String(), as returned by MAX(ts)
 		time.RFC3339Nano,
 		"2006-01-02T15:04:05Z",
 		"2006-01-02 15:04:05",
@@ -3,6 +3,7 @@ package storage
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -13,6 +14,7 @@ type Site struct {
 	ID                int64      `json:"id"`
 	Host              string     `json:"host"`
 	DisplayName       string     `json:"display_name,omitempty"`
+	Aliases           []string   `json:"aliases,omitempty"`        // Other hosts reported as this site
 	RetentionDays     int        `json:"retention_days,omitempty"` // 0 means use global default
 	Enabled           bool       `json:"enabled"`
 	CreatedAt         time.Time  `json:"created_at"`
@@ -25,10 +27,39 @@ type Site struct {
 
 // SiteInput represents the input for creating or updating a site.
 type SiteInput struct {
-	Host          string `json:"host"`
-	DisplayName   string `json:"display_name,omitempty"`
-	RetentionDays int    `json:"retention_days,omitempty"`
-	Enabled       *bool  `json:"enabled,omitempty"` // nil means default to true for create
+	Host          string   `json:"host"`
+	DisplayName   string   `json:"display_name,omitempty"`
+	Aliases       []string `json:"aliases,omitempty"` // nil leaves aliases unchanged on update; empty clears them
+	RetentionDays int      `json:"retention_days,omitempty"`
+	Enabled       *bool    `json:"enabled,omitempty"` // nil means default to true for create
+}
+
+// ErrAliasInUse is returned when a site alias is already another site's
+// host or alias, or when a site's host is another site's alias.
+var ErrAliasInUse = errors.New("alias already in use")
+
+// hostMatch replaces "host = ?" in stats queries: it matches the bound host
+// and, when that host is a configured site, the site's aliases, so
+// www.example.com traffic is reported under example.com.
+const hostMatch = `host IN (WITH c(h) AS (VALUES (?)) SELECT h FROM c UNION SELECT a.alias FROM site_aliases a JOIN sites s ON s.id = a.site_id JOIN c ON s.host = c.h)`
+
+// hostsMatch is hostMatch for a list of n bound hosts, such as a session's
+// permitted sites, so each site's aliases are matched along with it.
+func hostsMatch(n int) string {
+	values := strings.TrimSuffix(strings.Repeat("(?),", n), ",")
+	return `host IN (WITH c(h) AS (VALUES ` + values + `) SELECT h FROM c UNION SELECT a.alias FROM site_aliases a JOIN sites s ON s.id = a.site_id JOIN c ON s.host = c.h)`
+}
+
+// SkippedSite is a site from a bulk import that was not created.
+type SkippedSite struct {
+	Host   string `json:"host"`
+	Reason string `json:"reason"`
+}
+
+// BulkSiteResult reports the outcome of CreateSites.
+type BulkSiteResult struct {
+	Created []Site        `json:"created"`
+	Skipped []SkippedSite `json:"skipped"`
 }
 
 // SiteSummary provides a high-level overview of all sites.
@@ -81,6 +112,14 @@ CREATE TABLE IF NOT EXISTS site_permissions (
 CREATE INDEX IF NOT EXISTS idx_site_permissions_token ON site_permissions(session_token);
 CREATE INDEX IF NOT EXISTS idx_site_permissions_host ON site_permissions(site_host);
 CREATE UNIQUE INDEX IF NOT EXISTS idx_site_permissions_unique ON site_permissions(session_token, site_host);
+
+CREATE TABLE IF NOT EXISTS site_aliases (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	site_id INTEGER NOT NULL,
+	alias TEXT NOT NULL UNIQUE,
+	FOREIGN KEY (site_id) REFERENCES sites(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_site_aliases_site ON site_aliases(site_id);
 `
 	_, err := s.db.Exec(schema)
 	return err
@@ -121,18 +160,29 @@ func (s *Storage) ListSites(ctx context.Context) (*SiteSummary, error) {
 		return nil, fmt.Errorf("iterate sites: %w", err)
 	}
 
-	// Get stats for all hosts (including unconfigured ones)
+	aliases, err := s.allSiteAliases(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, site := range configuredSites {
+		site.Aliases = aliases[site.ID]
+	}
+
+	// Get stats for all hosts (including unconfigured ones), counting
+	// aliased hosts under their site
 	from24h := time.Now().Add(-24 * time.Hour)
 	statsRows, err := s.db.QueryContext(ctx, `
 		SELECT
-			host,
-			COUNT(*) as request_count,
-			MAX(ts) as last_request,
-			IFNULL(SUM(bytes), 0) as bandwidth,
-			COUNT(DISTINCT ip || '|' || COALESCE(user_agent, '')) as unique_visitors_24h
-		FROM requests
-		WHERE ts >= ?
-		GROUP BY host
+			COALESCE(s.host, r.host) AS site_host,
+			SUM(IFNULL(r.sample_weight, 1)) as request_count,
+			MAX(r.ts) as last_request,
+			IFNULL(SUM(r.bytes * IFNULL(r.sample_weight, 1)), 0) as bandwidth,
+			COUNT(DISTINCT r.ip || '|' || COALESCE(r.user_agent, '')) as unique_visitors_24h
+		FROM requests r
+		LEFT JOIN site_aliases a ON a.alias = r.host
+		LEFT JOIN sites s ON s.id = a.site_id
+		WHERE r.ts >= ?
+		GROUP BY site_host
 		ORDER BY request_count DESC
 	`, from24h)
 	if err != nil {
@@ -222,21 +272,25 @@ func (s *Storage) GetSite(ctx context.Context, id int64) (*Site, error) {
 
 	// Get stats for this host
 	from24h := time.Now().Add(-24 * time.Hour)
-	var lastRequest sql.NullTime
+	var lastRequest sql.NullString
 	err = s.db.QueryRowContext(ctx, `
 		SELECT
-			COUNT(*),
+			IFNULL(SUM(IFNULL(sample_weight, 1)), 0),
 			MAX(ts),
-			IFNULL(SUM(bytes), 0),
+			IFNULL(SUM(bytes * IFNULL(sample_weight, 1)), 0),
 			COUNT(DISTINCT ip || '|' || COALESCE(user_agent, ''))
 		FROM requests
-		WHERE host = ? AND ts >= ?
+		WHERE `+hostMatch+` AND ts >= ?
 	`, site.Host, from24h).Scan(&site.RequestCount, &lastRequest, &site.BandwidthBytes, &site.UniqueVisitors24h)
 	if err != nil && err != sql.ErrNoRows {
 		return nil, fmt.Errorf("query site stats: %w", err)
 	}
-	if lastRequest.Valid {
-		site.LastRequestAt = &lastRequest.Time
+	if lastRequest.Valid && lastRequest.String != "" {
+		t := parseTimestamp(lastRequest.String)
+		site.LastRequestAt = &t
+	}
+	if site.Aliases, err = siteAliases(ctx, s.db, site.ID); err != nil {
+		return nil, err
 	}
 
 	return &site, nil
@@ -261,6 +315,9 @@ func (s *Storage) GetSiteByHost(ctx context.Context, host string) (*Site, error)
 	if err != nil {
 		return nil, fmt.Errorf("query site by host: %w", err)
 	}
+	if site.Aliases, err = siteAliases(ctx, s.db, site.ID); err != nil {
+		return nil, err
+	}
 	return &site, nil
 }
 
@@ -278,8 +335,21 @@ func (s *Storage) CreateSite(ctx context.Context, input SiteInput) (*Site, error
 		enabled = *input.Enabled
 	}
 
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := checkHostNotAlias(ctx, tx, 0, input.Host); err != nil {
+		return nil, err
+	}
+
 	now := time.Now()
-	result, err := s.db.ExecContext(ctx, `
+	result, err := tx.ExecContext(ctx, `
 		INSERT INTO sites (host, display_name, retention_days, enabled, created_at, updated_at)
 		VALUES (?, ?, ?, ?, ?, ?)
 	`, input.Host, input.DisplayName, input.RetentionDays, enabled, now, now)
@@ -291,11 +361,19 @@ func (s *Storage) CreateSite(ctx context.Context, input SiteInput) (*Site, error
 	if err != nil {
 		return nil, fmt.Errorf("get last insert id: %w", err)
 	}
+	aliases, err := setSiteAliases(ctx, tx, id, input.Host, input.Aliases)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
 
 	return &Site{
 		ID:            id,
 		Host:          input.Host,
 		DisplayName:   input.DisplayName,
+		Aliases:       aliases,
 		RetentionDays: input.RetentionDays,
 		Enabled:       enabled,
 		CreatedAt:     now,
@@ -303,6 +381,78 @@ func (s *Storage) CreateSite(ctx context.Context, input SiteInput) (*Site, error
 	}, nil
 }
 
+// CreateSites creates several sites in one transaction. Hosts that are
+// already configured, or repeated within inputs, are skipped and reported
+// rather than failing the batch; any other error rolls back every insert.
+func (s *Storage) CreateSites(ctx context.Context, inputs []SiteInput) (*BulkSiteResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.queryTimeout)
+	defer cancel()
+
+	for _, input := range inputs {
+		if input.Host == "" {
+			return nil, fmt.Errorf("host is required")
+		}
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	result := &BulkSiteResult{Created: []Site{}, Skipped: []SkippedSite{}}
+	now := time.Now()
+	for _, input := range inputs {
+		enabled := true
+		if input.Enabled != nil {
+			enabled = *input.Enabled
+		}
+		if err := checkHostNotAlias(ctx, tx, 0, input.Host); err != nil {
+			return nil, err
+		}
+		res, err := tx.ExecContext(ctx, `
+			INSERT INTO sites (host, display_name, retention_days, enabled, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?)
+			ON CONFLICT(host) DO NOTHING
+		`, input.Host, input.DisplayName, input.RetentionDays, enabled, now, now)
+		if err != nil {
+			return nil, fmt.Errorf("insert site %q: %w", input.Host, err)
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return nil, fmt.Errorf("rows affected: %w", err)
+		} else if n == 0 {
+			result.Skipped = append(result.Skipped, SkippedSite{Host: input.Host, Reason: "site already exists"})
+			continue
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return nil, fmt.Errorf("get last insert id: %w", err)
+		}
+		aliases, err := setSiteAliases(ctx, tx, id, input.Host, input.Aliases)
+		if err != nil {
+			return nil, fmt.Errorf("site %q: %w", input.Host, err)
+		}
+		result.Created = append(result.Created, Site{
+			ID:            id,
+			Host:          input.Host,
+			DisplayName:   input.DisplayName,
+			Aliases:       aliases,
+			RetentionDays: input.RetentionDays,
+			Enabled:       enabled,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		})
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+	return result, nil
+}
+
 // UpdateSite updates an existing site configuration.
 func (s *Storage) UpdateSite(ctx context.Context, id int64, input SiteInput) (*Site, error) {
 	ctx, cancel := context.WithTimeout(ctx, s.queryTimeout)
@@ -335,8 +485,21 @@ func (s *Storage) UpdateSite(ctx context.Context, id int64, input SiteInput) (*S
 		enabled = *input.Enabled
 	}
 
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := checkHostNotAlias(ctx, tx, id, host); err != nil {
+		return nil, err
+	}
+
 	now := time.Now()
-	_, err = s.db.ExecContext(ctx, `
+	_, err = tx.ExecContext(ctx, `
 		UPDATE sites
 		SET host = ?, display_name = ?, retention_days = ?, enabled = ?, updated_at = ?
 		WHERE id = ?
@@ -344,11 +507,21 @@ func (s *Storage) UpdateSite(ctx context.Context, id int64, input SiteInput) (*S
 	if err != nil {
 		return nil, fmt.Errorf("update site: %w", err)
 	}
+	aliases := existing.Aliases
+	if input.Aliases != nil {
+		if aliases, err = setSiteAliases(ctx, tx, id, host, input.Aliases); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
 
 	return &Site{
 		ID:            id,
 		Host:          host,
 		DisplayName:   displayName,
+		Aliases:       aliases,
 		RetentionDays: retentionDays,
 		Enabled:       enabled,
 		CreatedAt:     existing.CreatedAt,
@@ -356,13 +529,22 @@ func (s *Storage) UpdateSite(ctx context.Context, id int64, input SiteInput) (*S
 	}, nil
 }
 
-// DeleteSite removes a site configuration.
+// DeleteSite removes a site configuration and its aliases.
 // Note: This does not delete the request data for the site.
 func (s *Storage) DeleteSite(ctx context.Context, id int64) error {
 	ctx, cancel := context.WithTimeout(ctx, s.queryTimeout)
 	defer cancel()
 
-	result, err := s.db.ExecContext(ctx, `DELETE FROM sites WHERE id = ?`, id)
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM sites WHERE id = ?`, id)
 	if err != nil {
 		return fmt.Errorf("delete site: %w", err)
 	}
@@ -374,10 +556,124 @@ func (s *Storage) DeleteSite(ctx context.Context, id int64) error {
 	if rows == 0 {
 		return fmt.Errorf("site not found")
 	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM site_aliases WHERE site_id = ?`, id); err != nil {
+		return fmt.Errorf("delete site aliases: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	return nil
+}
+
+// execQuerier is satisfied by both *sql.DB and *sql.Tx.
+type execQuerier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// setSiteAliases replaces the aliases of site id, whose host is host, and
+// returns the stored list. Blank entries, repeats and the site's own host
+// are dropped; an alias that is another site's host or alias fails with
+// ErrAliasInUse.
+func setSiteAliases(ctx context.Context, db execQuerier, id int64, host string, aliases []string) ([]string, error) {
+	if _, err := db.ExecContext(ctx, `DELETE FROM site_aliases WHERE site_id = ?`, id); err != nil {
+		return nil, fmt.Errorf("clear site aliases: %w", err)
+	}
+	stored := []string{}
+	seen := map[string]bool{host: true}
+	for _, alias := range aliases {
+		alias = strings.TrimSpace(alias)
+		if alias == "" || seen[alias] {
+			continue
+		}
+		seen[alias] = true
+		var taken int
+		err := db.QueryRowContext(ctx, `
+			SELECT (SELECT COUNT(*) FROM sites WHERE host = ?) + (SELECT COUNT(*) FROM site_aliases WHERE alias = ?)
+		`, alias, alias).Scan(&taken)
+		if err != nil {
+			return nil, fmt.Errorf("check alias: %w", err)
+		}
+		if taken > 0 {
+			return nil, fmt.Errorf("%w: %s", ErrAliasInUse, alias)
+		}
+		if _, err := db.ExecContext(ctx, `INSERT INTO site_aliases (site_id, alias) VALUES (?, ?)`, id, alias); err != nil {
+			return nil, fmt.Errorf("insert site alias: %w", err)
+		}
+		stored = append(stored, alias)
+	}
+	return stored, nil
+}
 
+// checkHostNotAlias fails with ErrAliasInUse when host is an alias of a
+// site other than id. Pass 0 for a site that does not exist yet.
+func checkHostNotAlias(ctx context.Context, db execQuerier, id int64, host string) error {
+	var taken int
+	err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM site_aliases WHERE alias = ? AND site_id != ?`, host, id).Scan(&taken)
+	if err != nil {
+		return fmt.Errorf("check host: %w", err)
+	}
+	if taken > 0 {
+		return fmt.Errorf("%w: %s is another site's alias", ErrAliasInUse, host)
+	}
 	return nil
 }
 
+// siteAliases returns the aliases of site id in alphabetical order.
+func siteAliases(ctx context.Context, db execQuerier, id int64) ([]string, error) {
+	rows, err := db.QueryContext(ctx, `SELECT alias FROM site_aliases WHERE site_id = ? ORDER BY alias`, id)
+	if err != nil {
+		return nil, fmt.Errorf("query site aliases: %w", err)
+	}
+	defer rows.Close()
+	var aliases []string
+	for rows.Next() {
+		var alias string
+		if err := rows.Scan(&alias); err != nil {
+			return nil, fmt.Errorf("scan site alias: %w", err)
+		}
+		aliases = append(aliases, alias)
+	}
+	return aliases, rows.Err()
+}
+
+// allSiteAliases returns every site's aliases keyed by site ID.
+func (s *Storage) allSiteAliases(ctx context.Context) (map[int64][]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT site_id, alias FROM site_aliases ORDER BY alias`)
+	if err != nil {
+		return nil, fmt.Errorf("query site aliases: %w", err)
+	}
+	defer rows.Close()
+	aliases := make(map[int64][]string)
+	for rows.Next() {
+		var id int64
+		var alias string
+		if err := rows.Scan(&id, &alias); err != nil {
+			return nil, fmt.Errorf("scan site alias: %w", err)
+		}
+		aliases[id] = append(aliases[id], alias)
+	}
+	return aliases, rows.Err()
+}
+
+// canonicalHost returns the host of the site that lists host as an alias,
+// or host itself when it is not an alias.
+func (s *Storage) canonicalHost(ctx context.Context, host string) (string, error) {
+	var canonical string
+	err := s.db.QueryRowContext(ctx, `
+		SELECT s.host FROM site_aliases a JOIN sites s ON s.id = a.site_id WHERE a.alias = ?
+	`, host).Scan(&canonical)
+	if err == sql.ErrNoRows {
+		return host, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("query alias: %w", err)
+	}
+	return canonical, nil
+}
+
 // GetSiteRetention returns the retention days for a specific host.
 // Returns 0 if no specific retention is configured (use global default).
 func (s *Storage) GetSiteRetention(ctx context.Context, host string) (int, error) {
@@ -525,6 +821,17 @@ func (s *Storage) HasSitePermission(ctx context.Context, sessionToken string, ho
 		}
 	}
 
+	// An alias is readable by sessions allowed its site
+	canonical, err := s.canonicalHost(ctx, host)
+	if err != nil || canonical == host {
+		return false, err
+	}
+	for _, allowedHost := range perms.AllowedHosts {
+		if strings.EqualFold(allowedHost, canonical) {
+			return true, nil
+		}
+	}
+
 	return false, nil
 }
 
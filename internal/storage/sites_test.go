@@ -2,6 +2,7 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 )
@@ -86,6 +87,167 @@ func TestStorage_CreateSite_DuplicateHost(t *testing.T) {
 	}
 }
 
+func TestStorage_CreateSites(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	if _, err := s.CreateSite(ctx, SiteInput{Host: "existing.com"}); err != nil {
+		t.Fatalf("CreateSite() error = %v", err)
+	}
+
+	disabled := false
+	result, err := s.CreateSites(ctx, []SiteInput{
+		{Host: "a.com", DisplayName: "A"},
+		{Host: "existing.com"},
+		{Host: "b.com", Enabled: &disabled},
+		{Host: "a.com"},
+	})
+	if err != nil {
+		t.Fatalf("CreateSites() error = %v", err)
+	}
+	if len(result.Created) != 2 || result.Created[0].Host != "a.com" || result.Created[1].Host != "b.com" || result.Created[1].Enabled {
+		t.Errorf("Created = %+v, want a.com and disabled b.com", result.Created)
+	}
+	if len(result.Skipped) != 2 || result.Skipped[0].Host != "existing.com" || result.Skipped[1].Host != "a.com" {
+		t.Errorf("Skipped = %+v, want existing.com and the repeated a.com", result.Skipped)
+	}
+
+	if _, err := s.CreateSites(ctx, []SiteInput{{Host: "c.com"}, {Host: ""}}); err == nil {
+		t.Error("CreateSites() with an empty host succeeded, want error")
+	}
+	if site, _ := s.GetSiteByHost(ctx, "c.com"); site != nil {
+		t.Error("c.com was created by a rejected batch")
+	}
+}
+
+func TestStorage_SiteAliases_PermittedHostLists(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	for _, host := range []string{"example.com", "www.example.com", "other.com"} {
+		if err := s.InsertRequest(ctx, RequestRecord{Timestamp: now.Add(-time.Minute), Host: host, Path: "/", Status: 200, IP: "1.2.3.4"}); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+	if _, err := s.CreateSite(ctx, SiteInput{Host: "example.com", Aliases: []string{"www.example.com"}}); err != nil {
+		t.Fatalf("CreateSite() error = %v", err)
+	}
+	hosts := []string{"example.com"}
+
+	totals, err := s.GrandTotals(ctx, time.Hour, hosts)
+	if err != nil {
+		t.Fatalf("GrandTotals() error = %v", err)
+	}
+	if totals.Requests != 2 {
+		t.Errorf("GrandTotals(example.com).Requests = %d, want 2 (site plus alias)", totals.Requests)
+	}
+
+	byIP, err := s.RequestsByIP(ctx, "1.2.3.4", time.Hour, 100, 0, hosts)
+	if err != nil {
+		t.Fatalf("RequestsByIP() error = %v", err)
+	}
+	if len(byIP) != 2 {
+		t.Errorf("RequestsByIP(example.com) returned %d requests, want 2 (site plus alias)", len(byIP))
+	}
+	for _, r := range byIP {
+		if r.Host == "other.com" {
+			t.Error("RequestsByIP(example.com) returned a request to other.com")
+		}
+	}
+
+	known, err := s.KnownHosts(ctx, time.Hour, hosts)
+	if err != nil || len(known) != 2 {
+		t.Errorf("KnownHosts(example.com) = %v, %v; want example.com and its alias", known, err)
+	}
+}
+
+func TestStorage_SiteAliases(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	for _, host := range []string{"example.com", "www.example.com", "www.example.com", "other.com"} {
+		if err := s.InsertRequest(ctx, RequestRecord{Timestamp: now.Add(-time.Minute), Host: host, Path: "/", Status: 200}); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	site, err := s.CreateSite(ctx, SiteInput{Host: "example.com", Aliases: []string{"www.example.com", " ", "example.com"}})
+	if err != nil {
+		t.Fatalf("CreateSite() error = %v", err)
+	}
+	if len(site.Aliases) != 1 || site.Aliases[0] != "www.example.com" {
+		t.Errorf("Aliases = %v, want [www.example.com]", site.Aliases)
+	}
+
+	summary, err := s.Summary(ctx, time.Hour, "example.com")
+	if err != nil {
+		t.Fatalf("Summary() error = %v", err)
+	}
+	if summary.TotalRequests != 3 {
+		t.Errorf("Summary(example.com).TotalRequests = %d, want 3 (site plus alias)", summary.TotalRequests)
+	}
+	summary, err = s.Summary(ctx, time.Hour, "www.example.com")
+	if err != nil || summary.TotalRequests != 2 {
+		t.Errorf("Summary(www.example.com) = %d, %v; want only the alias' 2 requests", summary.TotalRequests, err)
+	}
+
+	list, err := s.ListSites(ctx)
+	if err != nil {
+		t.Fatalf("ListSites() error = %v", err)
+	}
+	for _, site := range list.Sites {
+		switch site.Host {
+		case "example.com":
+			if site.RequestCount != 3 {
+				t.Errorf("ListSites example.com RequestCount = %d, want 3", site.RequestCount)
+			}
+		case "www.example.com":
+			t.Error("ListSites lists the alias www.example.com as its own site")
+		}
+	}
+
+	if _, err := s.CreateSite(ctx, SiteInput{Host: "other.com", Aliases: []string{"www.example.com"}}); !errors.Is(err, ErrAliasInUse) {
+		t.Errorf("CreateSite() with a taken alias error = %v, want ErrAliasInUse", err)
+	}
+	if _, err := s.CreateSite(ctx, SiteInput{Host: "www.example.com"}); !errors.Is(err, ErrAliasInUse) {
+		t.Errorf("CreateSite() with another site's alias as host error = %v, want ErrAliasInUse", err)
+	}
+	other, err := s.CreateSite(ctx, SiteInput{Host: "other.com"})
+	if err != nil {
+		t.Fatalf("CreateSite(other.com) error = %v", err)
+	}
+	if _, err := s.UpdateSite(ctx, other.ID, SiteInput{Host: "www.example.com"}); !errors.Is(err, ErrAliasInUse) {
+		t.Errorf("UpdateSite() to another site's alias error = %v, want ErrAliasInUse", err)
+	}
+
+	if err := s.CreateSession(ctx, "tok", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("CreateSession() error = %v", err)
+	}
+	if err := s.SetSessionPermissions(ctx, "tok", []string{"example.com"}); err != nil {
+		t.Fatalf("SetSessionPermissions() error = %v", err)
+	}
+	if ok, err := s.HasSitePermission(ctx, "tok", "www.example.com"); err != nil || !ok {
+		t.Errorf("HasSitePermission(alias) = %v, %v; want true", ok, err)
+	}
+	if ok, err := s.HasSitePermission(ctx, "tok", "other.com"); err != nil || ok {
+		t.Errorf("HasSitePermission(other.com) = %v, %v; want false", ok, err)
+	}
+
+	updated, err := s.UpdateSite(ctx, site.ID, SiteInput{Aliases: []string{}})
+	if err != nil || len(updated.Aliases) != 0 {
+		t.Fatalf("UpdateSite(clear aliases) = %+v, %v", updated, err)
+	}
+	summary, err = s.Summary(ctx, time.Hour, "example.com")
+	if err != nil || summary.TotalRequests != 1 {
+		t.Errorf("Summary(example.com) after clearing aliases = %d, %v; want 1", summary.TotalRequests, err)
+	}
+}
+
 func TestStorage_GetSite(t *testing.T) {
 	s, cleanup := setupTestDB(t)
 	defer cleanup()
@@ -235,7 +397,7 @@ func TestStorage_DeleteSite(t *testing.T) {
 	defer cleanup()
 
 	ctx := context.Background()
-	input := SiteInput{Host: "delete-me.com"}
+	input := SiteInput{Host: "delete-me.com", Aliases: []string{"www.delete-me.com"}}
 	created, err := s.CreateSite(ctx, input)
 	if err != nil {
 		t.Fatalf("CreateSite() error = %v", err)
@@ -254,6 +416,11 @@ func TestStorage_DeleteSite(t *testing.T) {
 	if site != nil {
 		t.Error("DeleteSite() did not delete the site")
 	}
+
+	// The alias went with the site, so it can be reused
+	if _, err := s.CreateSite(ctx, SiteInput{Host: "other.com", Aliases: []string{"www.delete-me.com"}}); err != nil {
+		t.Errorf("CreateSite() with the deleted site's alias error = %v", err)
+	}
 }
 
 func TestStorage_DeleteSite_NotFound(t *testing.T) {
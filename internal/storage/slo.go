@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// SLO reports how many requests within dur were good, meaning not a 5xx and
+// answered within latencyTargetMs (a target of 0 or less ignores latency),
+// and how much of the error budget implied by objective (a success
+// percentage such as 99.9) is left. Requests are weighted like the summary,
+// so a sampled row counts as the requests it stands for.
+func (s *Storage) SLO(ctx context.Context, dur time.Duration, host string, latencyTargetMs int, objective float64) (SLOStat, error) {
+	out := SLOStat{ObjectivePercent: objective, LatencyTargetMs: latencyTargetMs}
+	from := time.Now().Add(-dur)
+
+	query := `
+SELECT
+	IFNULL(SUM(IFNULL(sample_weight, 1)), 0),
+	IFNULL(SUM(CASE WHEN status < 500 AND (? <= 0 OR IFNULL(resp_time_ms, 0) <= ?) THEN IFNULL(sample_weight, 1) ELSE 0 END), 0)
+FROM requests
+WHERE ts >= ?`
+	args := []any{latencyTargetMs, latencyTargetMs, from}
+	if host != "" {
+		query += " AND " + hostMatch
+		args = append(args, host)
+	}
+	if err := s.db.QueryRowContext(ctx, query, args...).Scan(&out.Total, &out.Good); err != nil {
+		return out, err
+	}
+	out.Bad = out.Total - out.Good
+	out.computeBudget()
+	return out, nil
+}
+
+// computeBudget fills the percentages from Total, Good, Bad and
+// ObjectivePercent. With no traffic the SLO is met and the budget untouched.
+func (o *SLOStat) computeBudget() {
+	o.SuccessPercent = 100
+	o.ErrorBudgetRemainingPercent = 100
+	o.Met = true
+	if o.Total == 0 {
+		return
+	}
+	o.SuccessPercent = math.Round(float64(o.Good)/float64(o.Total)*100*1000) / 1000
+	o.ErrorBudget = math.Round(float64(o.Total)*(100-o.ObjectivePercent)/100*100) / 100
+	switch {
+	case o.ErrorBudget > 0:
+		remaining := (o.ErrorBudget - float64(o.Bad)) / o.ErrorBudget * 100
+		o.ErrorBudgetRemainingPercent = math.Round(remaining*100) / 100
+	case o.Bad > 0:
+		// A 100% objective has no budget; any bad request exhausts it.
+		o.ErrorBudgetRemainingPercent = 0
+	}
+	// Compare the unrounded rate: SuccessPercent can round up to the
+	// objective when the actual rate falls just short of it. The tolerance
+	// only absorbs float error in objectives such as 99.9.
+	o.Met = float64(o.Good)*100 >= (o.ObjectivePercent-1e-9)*float64(o.Total)
+}
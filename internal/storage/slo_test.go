@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStorage_SLO(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	ts := time.Now().UTC().Add(-time.Minute)
+	// 1000 requests: 995 good, 3 server errors and 2 too slow
+	records := []RequestRecord{
+		{Timestamp: ts, Host: "example.com", Path: "/", Status: 200, ResponseTime: 100, SampleWeight: 990},
+		{Timestamp: ts, Host: "example.com", Path: "/missing", Status: 404, ResponseTime: 20, SampleWeight: 5},
+		{Timestamp: ts, Host: "example.com", Path: "/api", Status: 503, ResponseTime: 10, SampleWeight: 3},
+		{Timestamp: ts, Host: "example.com", Path: "/report", Status: 200, ResponseTime: 800, SampleWeight: 2},
+		{Timestamp: ts, Host: "other.com", Path: "/", Status: 500, ResponseTime: 10},
+		{Timestamp: ts.Add(-48 * time.Hour), Host: "example.com", Path: "/", Status: 500, ResponseTime: 10},
+	}
+	for _, r := range records {
+		if err := s.InsertRequest(ctx, r); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	got, err := s.SLO(ctx, 24*time.Hour, "example.com", 500, 99)
+	if err != nil {
+		t.Fatalf("SLO() error = %v", err)
+	}
+	want := SLOStat{
+		Total: 1000, Good: 995, Bad: 5,
+		SuccessPercent: 99.5, ObjectivePercent: 99, LatencyTargetMs: 500, Met: true,
+		ErrorBudget: 10, ErrorBudgetRemainingPercent: 50,
+	}
+	if got != want {
+		t.Errorf("SLO(99%%, 500ms) = %+v, want %+v", got, want)
+	}
+
+	// A stricter objective allows one bad request; five overspend it 5x
+	got, err = s.SLO(ctx, 24*time.Hour, "example.com", 500, 99.9)
+	if err != nil {
+		t.Fatalf("SLO() error = %v", err)
+	}
+	if got.ErrorBudget != 1 || got.ErrorBudgetRemainingPercent != -400 || got.Met {
+		t.Errorf("SLO(99.9%%) budget = %v, remaining = %v, met = %v; want 1, -400, false",
+			got.ErrorBudget, got.ErrorBudgetRemainingPercent, got.Met)
+	}
+
+	// Without a latency target only the server errors count
+	got, err = s.SLO(ctx, 24*time.Hour, "example.com", 0, 99)
+	if err != nil {
+		t.Fatalf("SLO() error = %v", err)
+	}
+	if got.Good != 997 || got.Bad != 3 || got.ErrorBudgetRemainingPercent != 70 {
+		t.Errorf("SLO(no latency target) = %+v, want 997 good, 3 bad, 70%% budget left", got)
+	}
+
+	got, err = s.SLO(ctx, 24*time.Hour, "empty.example", 500, 99)
+	if err != nil {
+		t.Fatalf("SLO() error = %v", err)
+	}
+	if got.Total != 0 || got.SuccessPercent != 100 || got.ErrorBudgetRemainingPercent != 100 || !got.Met {
+		t.Errorf("SLO(no traffic) = %+v, want an untouched budget", got)
+	}
+}
+
+func TestSLOStat_MetUnrounded(t *testing.T) {
+	tests := []struct {
+		name             string
+		good, total      int64
+		objective        float64
+		wantMet          bool
+		wantSuccessRound float64
+	}{
+		// 99.99996% rounds to 100 but is short of 99.99999%
+		{"rounds up past objective", 2499999, 2500000, 99.99999, false, 100},
+		{"exactly at objective", 999, 1000, 99.9, true, 99.9},
+		{"just below objective", 998, 1000, 99.9, false, 99.8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := SLOStat{Total: tt.total, Good: tt.good, Bad: tt.total - tt.good, ObjectivePercent: tt.objective}
+			o.computeBudget()
+			if o.Met != tt.wantMet || o.SuccessPercent != tt.wantSuccessRound {
+				t.Errorf("met = %v, success = %v; want %v, %v", o.Met, o.SuccessPercent, tt.wantMet, tt.wantSuccessRound)
+			}
+		})
+	}
+}
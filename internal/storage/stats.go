@@ -4,20 +4,67 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 )
 
+// StatsFilter narrows stats queries beyond their time range. The zero
+// value matches every request.
+type StatsFilter struct {
+	Host    string // Site host; requests logged under its aliases match too
+	Country string // ISO 3166-1 alpha-2 code as stored, e.g. "DE"
+	// ExcludeInternal drops requests flagged as coming from INTERNAL_CIDRS.
+	ExcludeInternal bool
+}
+
+// sql returns the filter as " AND ..." predicates and their arguments.
+func (f StatsFilter) sql() (string, []any) {
+	var where string
+	var args []any
+	if f.Host != "" {
+		where += " AND " + hostMatch
+		args = append(args, f.Host)
+	}
+	if f.Country != "" {
+		where += " AND country = ?"
+		args = append(args, f.Country)
+	}
+	if f.ExcludeInternal {
+		where += " AND internal = 0"
+	}
+	return where, args
+}
+
+// ParseCountry validates a two-letter country code and returns it
+// upper-cased, as geo lookups store it. An empty string is returned as is.
+func ParseCountry(v string) (string, error) {
+	if v == "" {
+		return "", nil
+	}
+	if len(v) != 2 || !isASCIILetter(v[0]) || !isASCIILetter(v[1]) {
+		return "", fmt.Errorf("invalid country %q: want a two-letter code like DE", v)
+	}
+	return strings.ToUpper(v), nil
+}
+
+func isASCIILetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
 // Summary returns aggregated statistics for the given time range and optional host filter.
 func (s *Storage) Summary(ctx context.Context, since time.Duration, host string) (Summary, error) {
+	return s.SummaryFiltered(ctx, since, StatsFilter{Host: host})
+}
+
+// SummaryFiltered is Summary narrowed by f. The per-host breakdown in Hosts
+// only applies f's country, so it still lists every host.
+func (s *Storage) SummaryFiltered(ctx context.Context, since time.Duration, f StatsFilter) (Summary, error) {
 	var out Summary
 	from := time.Now().Add(-since)
 
-	args := []any{from}
-	where := "WHERE ts >= ?"
-	if host != "" {
-		where += " AND host = ?"
-		args = append(args, host)
-	}
+	cond, condArgs := f.sql()
+	args := append([]any{from}, condArgs...)
+	where := "WHERE ts >= ?" + cond
 
 	row := s.db.QueryRowContext(ctx, fmt.Sprintf(`
 WITH filtered AS (
@@ -30,9 +77,10 @@ WITH filtered AS (
 		ip,
 		user_agent,
 		resp_time_ms,
+		IFNULL(sample_weight, 1) AS weight,
 		CAST(strftime('%%s', ts) AS INTEGER) AS ts_epoch,
 		lower(CASE WHEN instr(path, '?') > 0 THEN substr(path, 1, instr(path, '?') - 1) ELSE path END) AS clean_path,
-		lower(user_agent) AS ua
+		is_bot
 	FROM requests
 	%s
 ),
@@ -41,12 +89,12 @@ classified AS (
 		*,
 		CASE
 			WHEN status >= 400 THEN 0
-			WHEN COALESCE(ua, '') LIKE '%%bot%%' OR COALESCE(ua, '') LIKE '%%crawl%%' OR COALESCE(ua, '') LIKE '%%spider%%' OR COALESCE(ua, '') LIKE '%%crawler%%' OR COALESCE(ua, '') LIKE '%%preview%%' OR COALESCE(ua, '') LIKE '%%pingdom%%' OR COALESCE(ua, '') LIKE '%%uptime%%' THEN 0
+			WHEN is_bot = 1 THEN 0
 			ELSE 1
 		END AS is_viewed,
 		CASE
 			WHEN clean_path IS NULL OR clean_path = '' THEN 1
-			WHEN clean_path LIKE '%%.css' OR clean_path LIKE '%%.js' OR clean_path LIKE '%%.png' OR clean_path LIKE '%%.jpg' OR clean_path LIKE '%%.jpeg' OR clean_path LIKE '%%.gif' OR clean_path LIKE '%%.svg' OR clean_path LIKE '%%.ico' OR clean_path LIKE '%%.woff%%' OR clean_path LIKE '%%.ttf' OR clean_path LIKE '%%.eot' OR clean_path LIKE '%%.otf' OR clean_path LIKE '%%.map' OR clean_path LIKE '%%.json' OR clean_path LIKE '%%.xml' OR clean_path LIKE '%%.csv' THEN 0
+			WHEN %s THEN 0
 			ELSE 1
 		END AS is_page
 	FROM filtered
@@ -64,29 +112,31 @@ visits AS (
 	FROM classified
 )
 SELECT
-	COUNT(*) AS total_requests,
-	SUM(CASE WHEN status BETWEEN 200 AND 299 THEN 1 ELSE 0 END) AS status_2xx,
-	SUM(CASE WHEN status BETWEEN 300 AND 399 THEN 1 ELSE 0 END) AS status_3xx,
-	SUM(CASE WHEN status BETWEEN 400 AND 499 THEN 1 ELSE 0 END) AS status_4xx,
-	SUM(CASE WHEN status >= 500 THEN 1 ELSE 0 END) AS status_5xx,
-	IFNULL(SUM(bytes), 0) AS bandwidth_bytes,
+	IFNULL(SUM(weight), 0) AS total_requests,
+	SUM(CASE WHEN status BETWEEN 200 AND 299 THEN weight ELSE 0 END) AS status_2xx,
+	SUM(CASE WHEN status BETWEEN 300 AND 399 THEN weight ELSE 0 END) AS status_3xx,
+	SUM(CASE WHEN status BETWEEN 400 AND 499 THEN weight ELSE 0 END) AS status_4xx,
+	SUM(CASE WHEN status BETWEEN 500 AND 599 THEN weight ELSE 0 END) AS status_5xx,
+	SUM(CASE WHEN status < 200 OR status > 599 THEN weight ELSE 0 END) AS status_other,
+	IFNULL(SUM(bytes * weight), 0) AS bandwidth_bytes,
 	IFNULL(AVG(resp_time_ms), 0) AS avg_resp,
-	IFNULL(SUM(CASE WHEN is_viewed = 1 THEN 1 ELSE 0 END), 0) AS viewed_hits,
-	IFNULL(SUM(CASE WHEN is_viewed = 0 THEN 1 ELSE 0 END), 0) AS not_viewed_hits,
-	IFNULL(SUM(CASE WHEN is_viewed = 1 THEN bytes ELSE 0 END), 0) AS viewed_bandwidth,
-	IFNULL(SUM(CASE WHEN is_viewed = 0 THEN bytes ELSE 0 END), 0) AS not_viewed_bandwidth,
-	IFNULL(SUM(CASE WHEN is_viewed = 1 AND is_page = 1 THEN 1 ELSE 0 END), 0) AS viewed_pages,
-	IFNULL(SUM(CASE WHEN is_viewed = 0 AND is_page = 1 THEN 1 ELSE 0 END), 0) AS not_viewed_pages,
+	IFNULL(SUM(CASE WHEN is_viewed = 1 THEN weight ELSE 0 END), 0) AS viewed_hits,
+	IFNULL(SUM(CASE WHEN is_viewed = 0 THEN weight ELSE 0 END), 0) AS not_viewed_hits,
+	IFNULL(SUM(CASE WHEN is_viewed = 1 THEN bytes * weight ELSE 0 END), 0) AS viewed_bandwidth,
+	IFNULL(SUM(CASE WHEN is_viewed = 0 THEN bytes * weight ELSE 0 END), 0) AS not_viewed_bandwidth,
+	IFNULL(SUM(CASE WHEN is_viewed = 1 AND is_page = 1 THEN weight ELSE 0 END), 0) AS viewed_pages,
+	IFNULL(SUM(CASE WHEN is_viewed = 0 AND is_page = 1 THEN weight ELSE 0 END), 0) AS not_viewed_pages,
 	IFNULL((SELECT SUM(new_visit) FROM visits), 0) AS visits,
 	IFNULL((SELECT COUNT(DISTINCT ip || '|' || COALESCE(user_agent, '')) FROM classified), 0) AS unique_visitors
 FROM classified
-`, where), args...)
+`, where, s.assets.assetSQL("clean_path")), args...)
 	if err := row.Scan(
 		&out.TotalRequests,
 		&out.Status2xx,
 		&out.Status3xx,
 		&out.Status4xx,
 		&out.Status5xx,
+		&out.StatusOther,
 		&out.BandwidthBytes,
 		&out.AvgResponseTime,
 		&out.Traffic.Viewed.Hits,
@@ -100,27 +150,59 @@ FROM classified
 	); err != nil {
 		return out, err
 	}
+	// Rollups carry no country or internal flag, so compacted periods only
+	// count toward unfiltered totals
+	if f.Country == "" && !f.ExcludeInternal {
+		if err := s.addCompactedTotals(ctx, &out, from, f.Host); err != nil {
+			return out, err
+		}
+	}
+	out.BandwidthHuman = humanizeBytes(out.BandwidthBytes)
+	out.Traffic.Viewed.BandwidthHuman = humanizeBytes(out.Traffic.Viewed.BandwidthBytes)
+	out.Traffic.NotViewed.BandwidthHuman = humanizeBytes(out.Traffic.NotViewed.BandwidthBytes)
 
-	out.TopPaths, _ = s.topPaths(ctx, from, 5, host)
-	out.Hosts, _ = s.hosts(ctx, from)
-	out.Recent, _ = s.timeSeries(ctx, from, host)
-	out.ErrorPages, _ = s.errorPages(ctx, from, 10, host)
-	out.Bots, _ = s.botStats(ctx, from, host)
+	out.TopPaths, _ = s.topPaths(ctx, from, 5, f)
+	out.Hosts, _ = s.hosts(ctx, from, StatsFilter{Country: f.Country, ExcludeInternal: f.ExcludeInternal})
+	out.Recent, _ = s.timeSeries(ctx, from, f, BucketHour)
+	out.ErrorPages, _ = s.errorPages(ctx, from, 10, f)
+	out.Bots, _ = s.botStats(ctx, from, f)
 	return out, nil
 }
 
-func (s *Storage) topPaths(ctx context.Context, from time.Time, limit int, host string) ([]PathStat, error) {
-	var rows *sql.Rows
-	var err error
-	if host == "" {
-		rows, err = s.db.QueryContext(ctx, `
-SELECT path, COUNT(*) as c FROM requests WHERE ts >= ? GROUP BY path ORDER BY c DESC LIMIT ?
-`, from, limit)
-	} else {
-		rows, err = s.db.QueryContext(ctx, `
-SELECT path, COUNT(*) as c FROM requests WHERE ts >= ? AND host = ? GROUP BY path ORDER BY c DESC LIMIT ?
-`, from, host, limit)
+// addCompactedTotals adds the rollup totals for the part of the range whose
+// raw rows CompactRawData has deleted.
+func (s *Storage) addCompactedTotals(ctx context.Context, out *Summary, from time.Time, host string) error {
+	horizon, err := s.compactedBefore(ctx)
+	if err != nil {
+		return err
+	}
+	if horizon.IsZero() || !from.Before(horizon) {
+		return nil
+	}
+	c, err := s.HourlyRollupTotals(ctx, from.Truncate(time.Hour), horizon, host)
+	if err != nil {
+		return err
+	}
+	out.TotalRequests += c.Requests
+	out.Status2xx += c.Status2xx
+	out.Status3xx += c.Status3xx
+	out.Status4xx += c.Status4xx
+	out.Status5xx += c.Status5xx
+	out.StatusOther += c.StatusOther
+	out.BandwidthBytes += c.Bytes
+	out.Compacted = c.Requests
+	return nil
+}
+
+func (s *Storage) topPaths(ctx context.Context, from time.Time, limit int, f StatsFilter) ([]PathStat, error) {
+	if s.stripQuery != nil {
+		return s.topPathsNormalized(ctx, from, limit, f)
 	}
+	cond, condArgs := f.sql()
+	args := append(append([]any{from}, condArgs...), limit)
+	rows, err := s.db.QueryContext(ctx, `
+SELECT path, SUM(IFNULL(sample_weight, 1)) as c FROM requests WHERE ts >= ?`+cond+` GROUP BY path ORDER BY c DESC LIMIT ?
+`, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -136,10 +218,11 @@ SELECT path, COUNT(*) as c FROM requests WHERE ts >= ? AND host = ? GROUP BY pat
 	return list, rows.Err()
 }
 
-func (s *Storage) hosts(ctx context.Context, from time.Time) ([]HostStat, error) {
+func (s *Storage) hosts(ctx context.Context, from time.Time, f StatsFilter) ([]HostStat, error) {
+	cond, condArgs := f.sql()
 	rows, err := s.db.QueryContext(ctx, `
-SELECT host, COUNT(*) as c FROM requests WHERE ts >= ? GROUP BY host ORDER BY c DESC
-`, from)
+SELECT host, SUM(IFNULL(sample_weight, 1)) as c FROM requests WHERE ts >= ?`+cond+` GROUP BY host ORDER BY c DESC
+`, append([]any{from}, condArgs...)...)
 	if err != nil {
 		return nil, err
 	}
@@ -155,25 +238,114 @@ SELECT host, COUNT(*) as c FROM requests WHERE ts >= ? GROUP BY host ORDER BY c
 	return list, rows.Err()
 }
 
-func (s *Storage) botStats(ctx context.Context, from time.Time, host string) (BotStats, error) {
+// KnownHosts returns every host with requests in the last dur, busiest
+// first, for populating host filters. If hosts is non-nil, only those hosts
+// are returned.
+func (s *Storage) KnownHosts(ctx context.Context, dur time.Duration, hosts []string) ([]HostStat, error) {
+	if hosts != nil && len(hosts) == 0 {
+		return []HostStat{}, nil
+	}
+	query := `SELECT host, SUM(IFNULL(sample_weight, 1)) AS c FROM requests WHERE ts >= ? AND host IS NOT NULL AND host != ''`
+	args := []any{time.Now().Add(-dur)}
+	if len(hosts) > 0 {
+		query += " AND " + hostsMatch(len(hosts))
+		for _, h := range hosts {
+			args = append(args, h)
+		}
+	}
+	query += " GROUP BY host ORDER BY c DESC, host"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []HostStat{}
+	for rows.Next() {
+		var h HostStat
+		if err := rows.Scan(&h.Host, &h.Count); err != nil {
+			return nil, err
+		}
+		list = append(list, h)
+	}
+	return list, rows.Err()
+}
+
+// HostActivity returns, for every host with stored requests, its first and
+// last request time and total request count, most recently active first.
+// Only retained raw requests are considered. If hosts is non-nil, only those
+// hosts are included.
+func (s *Storage) HostActivity(ctx context.Context, hosts []string) ([]HostActivityStat, error) {
+	if hosts != nil && len(hosts) == 0 {
+		return []HostActivityStat{}, nil
+	}
+	query := `SELECT host, MIN(ts), MAX(ts), SUM(IFNULL(sample_weight, 1)) FROM requests WHERE host IS NOT NULL AND host != ''`
+	args := []any{}
+	if len(hosts) > 0 {
+		query += " AND " + hostsMatch(len(hosts))
+		for _, h := range hosts {
+			args = append(args, h)
+		}
+	}
+	query += " GROUP BY host ORDER BY MAX(ts) DESC, host"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []HostActivityStat{}
+	for rows.Next() {
+		var h HostActivityStat
+		var first, last sql.NullString
+		if err := rows.Scan(&h.Host, &first, &last, &h.Requests); err != nil {
+			return nil, err
+		}
+		h.FirstSeen = parseTimestamp(first.String)
+		h.LastSeen = parseTimestamp(last.String)
+		list = append(list, h)
+	}
+	return list, rows.Err()
+}
+
+// GrandTotals returns combined requests, unique visitors, bandwidth and
+// error rate across hosts in one query, so a visitor (IP and user agent, as
+// in Summary) seen on several hosts is only counted once. If hosts is nil
+// every host is included; an empty slice yields zero totals.
+func (s *Storage) GrandTotals(ctx context.Context, dur time.Duration, hosts []string) (GrandTotals, error) {
+	var out GrandTotals
+	if hosts != nil && len(hosts) == 0 {
+		return out, nil
+	}
+	query := `SELECT
+	IFNULL(SUM(IFNULL(sample_weight, 1)), 0),
+	COUNT(DISTINCT ip || '|' || COALESCE(user_agent, '')),
+	IFNULL(SUM(bytes * IFNULL(sample_weight, 1)), 0),
+	IFNULL(ROUND(100.0 * SUM(CASE WHEN status >= 400 THEN IFNULL(sample_weight, 1) ELSE 0 END) / NULLIF(SUM(IFNULL(sample_weight, 1)), 0), 2), 0)
+FROM requests WHERE ts >= ?`
+	args := []any{time.Now().Add(-dur)}
+	if len(hosts) > 0 {
+		query += " AND " + hostsMatch(len(hosts))
+		for _, h := range hosts {
+			args = append(args, h)
+		}
+	}
+	err := s.db.QueryRowContext(ctx, query, args...).Scan(&out.Requests, &out.UniqueVisitors, &out.Bytes, &out.ErrorRate)
+	return out, err
+}
+
+func (s *Storage) botStats(ctx context.Context, from time.Time, f StatsFilter) (BotStats, error) {
 	out := BotStats{
 		ByIntent: make(map[string]BotIntentStats),
 	}
+	cond, condArgs := f.sql()
+	args := append([]any{from}, condArgs...)
 
 	// Get total bot hits and bandwidth
-	var totalRows *sql.Rows
-	var err error
-	if host == "" {
-		totalRows, err = s.db.QueryContext(ctx, `
-SELECT COUNT(*), IFNULL(SUM(bytes), 0)
-FROM requests WHERE ts >= ? AND is_bot = 1
-`, from)
-	} else {
-		totalRows, err = s.db.QueryContext(ctx, `
-SELECT COUNT(*), IFNULL(SUM(bytes), 0)
-FROM requests WHERE ts >= ? AND is_bot = 1 AND host = ?
-`, from, host)
-	}
+	totalRows, err := s.db.QueryContext(ctx, `
+SELECT IFNULL(SUM(IFNULL(sample_weight, 1)), 0), IFNULL(SUM(bytes * IFNULL(sample_weight, 1)), 0)
+FROM requests WHERE ts >= ? AND is_bot = 1`+cond+`
+`, args...)
 	if err != nil {
 		return out, err
 	}
@@ -184,26 +356,16 @@ FROM requests WHERE ts >= ? AND is_bot = 1 AND host = ?
 		}
 	}
 	totalRows.Close() // Close before next query to avoid connection pool deadlock
+	out.BandwidthHuman = humanizeBytes(out.BandwidthBytes)
 
 	// Get breakdown by intent
-	var intentRows *sql.Rows
-	if host == "" {
-		intentRows, err = s.db.QueryContext(ctx, `
-SELECT CASE WHEN bot_intent = '' THEN 'unknown' ELSE bot_intent END AS intent,
-       COUNT(*) AS hits, IFNULL(SUM(bytes), 0) AS bandwidth
-FROM requests WHERE ts >= ? AND is_bot = 1
-GROUP BY intent
-ORDER BY hits DESC
-`, from)
-	} else {
-		intentRows, err = s.db.QueryContext(ctx, `
+	intentRows, err := s.db.QueryContext(ctx, `
 SELECT CASE WHEN bot_intent = '' THEN 'unknown' ELSE bot_intent END AS intent,
-       COUNT(*) AS hits, IFNULL(SUM(bytes), 0) AS bandwidth
-FROM requests WHERE ts >= ? AND is_bot = 1 AND host = ?
+       SUM(IFNULL(sample_weight, 1)) AS hits, IFNULL(SUM(bytes * IFNULL(sample_weight, 1)), 0) AS bandwidth
+FROM requests WHERE ts >= ? AND is_bot = 1`+cond+`
 GROUP BY intent
 ORDER BY hits DESC
-`, from, host)
-	}
+`, args...)
 	if err != nil {
 		return out, err
 	}
@@ -221,24 +383,15 @@ ORDER BY hits DESC
 	return out, intentRows.Err()
 }
 
-func (s *Storage) errorPages(ctx context.Context, from time.Time, limit int, host string) ([]ErrorPageStat, error) {
-	var rows *sql.Rows
-	var err error
-	if host == "" {
-		rows, err = s.db.QueryContext(ctx, `
-SELECT path, status, COUNT(*) as c FROM requests
-WHERE ts >= ? AND status >= 400
-GROUP BY path, status
-ORDER BY c DESC LIMIT ?
-`, from, limit)
-	} else {
-		rows, err = s.db.QueryContext(ctx, `
-SELECT path, status, COUNT(*) as c FROM requests
-WHERE ts >= ? AND status >= 400 AND host = ?
+func (s *Storage) errorPages(ctx context.Context, from time.Time, limit int, f StatsFilter) ([]ErrorPageStat, error) {
+	cond, condArgs := f.sql()
+	args := append(append([]any{from}, condArgs...), limit)
+	rows, err := s.db.QueryContext(ctx, `
+SELECT path, status, SUM(IFNULL(sample_weight, 1)) as c FROM requests
+WHERE ts >= ? AND status >= 400`+cond+`
 GROUP BY path, status
 ORDER BY c DESC LIMIT ?
-`, from, host, limit)
-	}
+`, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -254,42 +407,84 @@ ORDER BY c DESC LIMIT ?
 	return list, rows.Err()
 }
 
-func (s *Storage) timeSeries(ctx context.Context, from time.Time, host string) ([]TimeSeriesStat, error) {
-	var rows *sql.Rows
-	var err error
-	if host == "" {
-		rows, err = s.db.QueryContext(ctx, `
-SELECT
-	strftime('%Y-%m-%dT%H:00:00Z', ts) as bucket,
-	COUNT(*),
-	IFNULL(SUM(bytes),0),
-	SUM(CASE WHEN status BETWEEN 200 AND 299 THEN 1 ELSE 0 END),
-	SUM(CASE WHEN status BETWEEN 400 AND 499 THEN 1 ELSE 0 END),
-	SUM(CASE WHEN status >= 500 THEN 1 ELSE 0 END),
-	IFNULL(AVG(resp_time_ms),0)
-FROM requests
-WHERE ts >= ? AND ts IS NOT NULL
-GROUP BY bucket
-HAVING bucket IS NOT NULL
-ORDER BY bucket ASC
-`, from)
-	} else {
-		rows, err = s.db.QueryContext(ctx, `
+// Bucket is the granularity used to group time series results.
+type Bucket string
+
+const (
+	BucketMinute     Bucket = "minute"
+	BucketFiveMinute Bucket = "5min"
+	BucketHour       Bucket = "hour"
+	BucketDay        Bucket = "day"
+	BucketAuto       Bucket = "auto"
+)
+
+// ParseBucket validates a bucket name. An empty string means hourly buckets.
+func ParseBucket(v string) (Bucket, error) {
+	switch b := Bucket(strings.ToLower(strings.TrimSpace(v))); b {
+	case "":
+		return BucketHour, nil
+	case BucketMinute, BucketFiveMinute, BucketHour, BucketDay, BucketAuto:
+		return b, nil
+	default:
+		return "", fmt.Errorf("invalid bucket %q", v)
+	}
+}
+
+// Resolve turns BucketAuto into a concrete bucket suited to the range,
+// aiming for a few dozen to a few hundred points.
+func (b Bucket) Resolve(dur time.Duration) Bucket {
+	if b != BucketAuto {
+		return b
+	}
+	switch {
+	case dur <= 2*time.Hour:
+		return BucketMinute
+	case dur <= 12*time.Hour:
+		return BucketFiveMinute
+	case dur <= 7*24*time.Hour:
+		return BucketHour
+	default:
+		return BucketDay
+	}
+}
+
+// Seconds returns the bucket width. Unknown buckets fall back to one hour.
+func (b Bucket) Seconds() int64 {
+	switch b {
+	case BucketMinute:
+		return 60
+	case BucketFiveMinute:
+		return 5 * 60
+	case BucketDay:
+		return 24 * 60 * 60
+	default:
+		return 60 * 60
+	}
+}
+
+func (s *Storage) timeSeries(ctx context.Context, from time.Time, f StatsFilter, bucket Bucket) ([]TimeSeriesStat, error) {
+	width := bucket.Resolve(time.Since(from)).Seconds()
+	query := `
 SELECT
-	strftime('%Y-%m-%dT%H:00:00Z', ts) as bucket,
-	COUNT(*),
-	IFNULL(SUM(bytes),0),
-	SUM(CASE WHEN status BETWEEN 200 AND 299 THEN 1 ELSE 0 END),
-	SUM(CASE WHEN status BETWEEN 400 AND 499 THEN 1 ELSE 0 END),
-	SUM(CASE WHEN status >= 500 THEN 1 ELSE 0 END),
+	strftime('%Y-%m-%dT%H:%M:%SZ', (` + tsEpochSQL + ` / ?) * ?, 'unixepoch') as bucket,
+	IFNULL(SUM(IFNULL(sample_weight, 1)),0),
+	IFNULL(SUM(bytes * IFNULL(sample_weight, 1)),0),
+	SUM(CASE WHEN status BETWEEN 200 AND 299 THEN IFNULL(sample_weight, 1) ELSE 0 END),
+	SUM(CASE WHEN status BETWEEN 400 AND 499 THEN IFNULL(sample_weight, 1) ELSE 0 END),
+	SUM(CASE WHEN status BETWEEN 500 AND 599 THEN IFNULL(sample_weight, 1) ELSE 0 END),
+	SUM(CASE WHEN status < 200 OR status > 599 THEN IFNULL(sample_weight, 1) ELSE 0 END),
 	IFNULL(AVG(resp_time_ms),0)
 FROM requests
-WHERE ts >= ? AND ts IS NOT NULL AND host = ?
+WHERE ts >= ? AND ts IS NOT NULL`
+	cond, condArgs := f.sql()
+	query += cond
+	args := append([]any{width, width, from}, condArgs...)
+	query += `
 GROUP BY bucket
 HAVING bucket IS NOT NULL
 ORDER BY bucket ASC
-`, from, host)
-	}
+`
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -298,7 +493,7 @@ ORDER BY bucket ASC
 	for rows.Next() {
 		var tsStr sql.NullString
 		var ts TimeSeriesStat
-		if err := rows.Scan(&tsStr, &ts.Requests, &ts.Bytes, &ts.Status2xx, &ts.Status4xx, &ts.Status5xx, &ts.AvgLatency); err != nil {
+		if err := rows.Scan(&tsStr, &ts.Requests, &ts.Bytes, &ts.Status2xx, &ts.Status4xx, &ts.Status5xx, &ts.StatusOther, &ts.AvgLatency); err != nil {
 			return nil, err
 		}
 		if !tsStr.Valid {
@@ -311,25 +506,32 @@ ORDER BY bucket ASC
 	return list, rows.Err()
 }
 
-// TimeSeriesRange returns time series statistics for the given duration.
-func (s *Storage) TimeSeriesRange(ctx context.Context, dur time.Duration, host string) ([]TimeSeriesStat, error) {
-	return s.timeSeries(ctx, time.Now().Add(-dur), host)
+// TimeSeriesRange returns time series statistics for the given duration,
+// grouped into buckets of the given granularity.
+func (s *Storage) TimeSeriesRange(ctx context.Context, dur time.Duration, host string, bucket Bucket) ([]TimeSeriesStat, error) {
+	return s.TimeSeriesRangeFiltered(ctx, dur, StatsFilter{Host: host}, bucket)
+}
+
+// TimeSeriesRangeFiltered is TimeSeriesRange narrowed by f.
+func (s *Storage) TimeSeriesRangeFiltered(ctx context.Context, dur time.Duration, f StatsFilter, bucket Bucket) ([]TimeSeriesStat, error) {
+	return s.timeSeries(ctx, time.Now().Add(-dur), f, bucket)
 }
 
-// Geo returns geographic statistics for the given duration.
+// Geo returns geographic statistics for the given duration. Requests
+// without a geo lookup report their country, region or city as the
+// unknown label.
 func (s *Storage) Geo(ctx context.Context, dur time.Duration, host string) ([]GeoStat, error) {
-	var rows *sql.Rows
-	var err error
-	from := time.Now().Add(-dur)
-	if host == "" {
-		rows, err = s.db.QueryContext(ctx, `
-SELECT country, region, city, COUNT(*) FROM requests WHERE ts >= ? GROUP BY country, region, city ORDER BY COUNT(*) DESC
-`, from)
-	} else {
-		rows, err = s.db.QueryContext(ctx, `
-SELECT country, region, city, COUNT(*) FROM requests WHERE ts >= ? AND host = ? GROUP BY country, region, city ORDER BY COUNT(*) DESC
-`, from, host)
+	query := `
+SELECT ` + s.labels.orUnknown("country") + `, ` + s.labels.orUnknown("region") + `, ` + s.labels.orUnknown("city") + `, SUM(IFNULL(sample_weight, 1))
+FROM requests WHERE ts >= ?`
+	args := []any{time.Now().Add(-dur)}
+	if host != "" {
+		query += " AND " + hostMatch
+		args = append(args, host)
 	}
+	query += " GROUP BY 1, 2, 3 ORDER BY 4 DESC"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -369,15 +571,15 @@ func (s *Storage) GetAlertStats(ctx context.Context, duration time.Duration, hos
 	args := []any{from}
 	where := "WHERE ts >= ?"
 	if host != "" {
-		where += " AND host = ?"
+		where += " AND " + hostMatch
 		args = append(args, host)
 	}
 
 	query := fmt.Sprintf(`
 SELECT
-	COUNT(*) as total,
-	SUM(CASE WHEN status >= 500 THEN 1 ELSE 0 END) as status_5xx,
-	SUM(CASE WHEN status >= 400 AND status < 500 THEN 1 ELSE 0 END) as status_4xx
+	IFNULL(SUM(IFNULL(sample_weight, 1)), 0) as total,
+	SUM(CASE WHEN status BETWEEN 500 AND 599 THEN IFNULL(sample_weight, 1) ELSE 0 END) as status_5xx,
+	SUM(CASE WHEN status >= 400 AND status < 500 THEN IFNULL(sample_weight, 1) ELSE 0 END) as status_4xx
 FROM requests %s
 `, where)
 
@@ -390,11 +592,11 @@ FROM requests %s
 	prevArgs := []any{prevFrom, from}
 	prevWhere := "WHERE ts >= ? AND ts < ?"
 	if host != "" {
-		prevWhere += " AND host = ?"
+		prevWhere += " AND " + hostMatch
 		prevArgs = append(prevArgs, host)
 	}
 
-	prevQuery := fmt.Sprintf(`SELECT COUNT(*) FROM requests %s`, prevWhere)
+	prevQuery := fmt.Sprintf(`SELECT IFNULL(SUM(IFNULL(sample_weight, 1)), 0) FROM requests %s`, prevWhere)
 	row = s.db.QueryRowContext(ctx, prevQuery, prevArgs...)
 	if err := row.Scan(&stats.PrevRequests); err != nil {
 		return nil, err
@@ -404,12 +606,12 @@ FROM requests %s
 	statusArgs := []any{from}
 	statusWhere := "WHERE ts >= ?"
 	if host != "" {
-		statusWhere += " AND host = ?"
+		statusWhere += " AND " + hostMatch
 		statusArgs = append(statusArgs, host)
 	}
 
 	statusQuery := fmt.Sprintf(`
-SELECT status, COUNT(*) as cnt
+SELECT status, SUM(IFNULL(sample_weight, 1)) as cnt
 FROM requests %s
 GROUP BY status
 `, statusWhere)
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"sync"
 	"time"
 )
 
@@ -32,20 +33,26 @@ func (s *Storage) Ping(ctx context.Context) error {
 	return s.db.PingContext(ctx)
 }
 
-// GetDatabaseStats returns row counts for all tables.
+// GetDatabaseStats returns row counts for all tables. Once the requests
+// table reaches the configured estimate threshold its count is usually
+// estimated instead of taken with a full COUNT(*) scan; see requestsCount.
 func (s *Storage) GetDatabaseStats(ctx context.Context) (DatabaseStats, error) {
 	var stats DatabaseStats
 	queries := []struct {
 		query string
 		dest  *int64
 	}{
-		{"SELECT COUNT(*) FROM requests", &stats.RequestsCount},
 		{"SELECT COUNT(*) FROM sessions", &stats.SessionsCount},
 		{"SELECT COUNT(*) FROM rollups_hourly", &stats.RollupsHourlyCount},
 		{"SELECT COUNT(*) FROM rollups_daily", &stats.RollupsDailyCount},
 		{"SELECT COUNT(*) FROM import_progress", &stats.ImportProgressCount},
 	}
 
+	var err error
+	stats.RequestsCount, stats.RequestsCountEstimated, err = s.requestsCount(ctx)
+	if err != nil {
+		return stats, err
+	}
 	for _, q := range queries {
 		row := s.db.QueryRowContext(ctx, q.query)
 		if err := row.Scan(q.dest); err != nil {
@@ -55,6 +62,89 @@ func (s *Storage) GetDatabaseStats(ctx context.Context) (DatabaseStats, error) {
 	return stats, nil
 }
 
+// countBaseline is the last exact requests count and the largest id
+// allocated at the time. Rows are only appended between deletes, so the
+// baseline plus the ids allocated since estimates the current count.
+type countBaseline struct {
+	mu    sync.Mutex
+	valid bool
+	count int64
+	maxID int64
+	gen   uint64 // bumped by reset so a count racing a delete is not kept
+}
+
+// generation returns the value to pass to set for a count started now.
+func (b *countBaseline) generation() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.gen
+}
+
+// set records an exact count unless reset was called since gen was read.
+func (b *countBaseline) set(gen uint64, count, maxID int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if gen == b.gen {
+		b.valid, b.count, b.maxID = true, count, maxID
+	}
+}
+
+// reset drops the baseline after rows are deleted.
+func (b *countBaseline) reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.valid = false
+	b.gen++
+}
+
+// estimate returns the baseline count plus the ids allocated since.
+func (b *countBaseline) estimate(maxID int64) (int64, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.valid {
+		return 0, false
+	}
+	return b.count + max(maxID-b.maxID, 0), true
+}
+
+// idRangeQuery reads the smallest and largest request ids; each sub-select
+// is a single B-tree seek.
+const idRangeQuery = `SELECT IFNULL((SELECT MIN(id) FROM requests), 0), IFNULL((SELECT MAX(id) FROM requests), 0)`
+
+// requestsCount returns the requests row count and whether it is an
+// estimate. Below the threshold (or with estimates disabled) it is exact.
+// Above it the count is estimated from the last exact count, taken at
+// startup or after the last delete, plus the rows appended since. The id
+// range bounds the estimate: when the estimate exceeds it, or there is no
+// baseline, the table is counted exactly and the baseline refreshed.
+func (s *Storage) requestsCount(ctx context.Context) (int64, bool, error) {
+	gen := s.countBaseline.generation()
+	if s.countEstimateThreshold > 0 {
+		var minID, maxID int64
+		if err := s.db.QueryRowContext(ctx, idRangeQuery).Scan(&minID, &maxID); err != nil {
+			return 0, false, fmt.Errorf("estimate requests count: %w", err)
+		}
+		var span int64
+		if maxID > 0 {
+			span = maxID - minID + 1
+		}
+		if span >= s.countEstimateThreshold {
+			if est, ok := s.countBaseline.estimate(maxID); ok && est <= span && est >= s.countEstimateThreshold {
+				return est, true, nil
+			}
+		}
+	}
+	// requests.id is AUTOINCREMENT, so sqlite_sequence holds the largest id
+	// ever allocated even when that row has been deleted
+	const query = "SELECT COUNT(*), IFNULL((SELECT seq FROM sqlite_sequence WHERE name = 'requests'), 0) FROM requests"
+	var n, maxID int64
+	if err := s.db.QueryRowContext(ctx, query).Scan(&n, &maxID); err != nil {
+		return 0, false, fmt.Errorf("query %q: %w", query, err)
+	}
+	s.countBaseline.set(gen, n, maxID)
+	return n, false, nil
+}
+
 // DBPath returns the database file path.
 func (s *Storage) DBPath() string {
 	// Query the database for its file path
@@ -123,6 +213,7 @@ func (s *Storage) GetSystemStatus(ctx context.Context) (SystemStatus, error) {
 		return status, fmt.Errorf("getting database stats: %w", err)
 	}
 	status.RequestsCount = dbStats.RequestsCount
+	status.CountEstimated = dbStats.RequestsCountEstimated
 	status.HourlyRollups = dbStats.RollupsHourlyCount
 	status.DailyRollups = dbStats.RollupsDailyCount
 	status.ActiveSessions = dbStats.SessionsCount
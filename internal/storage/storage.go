@@ -1,11 +1,16 @@
 package storage
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	_ "modernc.org/sqlite"
@@ -17,6 +22,33 @@ type Storage struct {
 	writeMu      sync.Mutex
 	queryTimeout time.Duration
 
+	// Requests count above which GetDatabaseStats estimates (0 = always exact)
+	countEstimateThreshold int64
+	countBaseline          countBaseline
+
+	// Largest row count RecentRequests returns
+	maxRecentRequests int
+
+	// Response times needed before PerformanceStats reports percentiles
+	percentileMinSamples int
+
+	// Optional query-string normalization for path rankings (see paths.go)
+	stripQuery *queryStripper
+
+	// Extensions counted as assets rather than page views (see assets.go)
+	assets *assetMatcher
+
+	// Labels for empty dimension values in reports (see labels.go)
+	labels emptyLabels
+
+	// maintenanceMu prevents overlapping cleanup/vacuum runs
+	maintenanceMu sync.Mutex
+
+	// Free disk space guard (see disk.go)
+	diskFree    func(path string) (uint64, error)
+	diskMinFree atomic.Int64
+	diskLow     atomic.Bool
+
 	// Prepared statements for frequently-run queries
 	stmtInsertRequest *sql.Stmt
 	stmtInsertSession *sql.Stmt
@@ -28,23 +60,88 @@ type Storage struct {
 type Options struct {
 	MaxConnections int
 	QueryTimeout   time.Duration
+	// StripQueryParams lists query parameters removed from paths before
+	// ranking top paths; "*" removes the whole query string.
+	StripQueryParams []string
+	// AssetExtensions replaces the default list of file extensions that are
+	// not counted as page views; nil keeps the defaults.
+	AssetExtensions []string
+	// BusyTimeout is how long a connection waits on a locked database
+	// (default 30s). JournalMode and Synchronous set the matching PRAGMAs
+	// (defaults WAL and NORMAL).
+	BusyTimeout time.Duration
+	JournalMode string
+	Synchronous string
+	// MaxRecentRequests caps RecentRequests (default 100, at most
+	// MaxRecentRequestsCeiling).
+	MaxRecentRequests int
+	// CountEstimateThreshold is the requests row count from which
+	// GetDatabaseStats reports an estimate instead of running COUNT(*);
+	// 0 always counts exactly.
+	CountEstimateThreshold int64
+	// UnknownLabel and DirectLabel replace empty browser, OS and location
+	// values and empty referrers in reports (defaults "Unknown" and
+	// "Direct / Bookmark").
+	UnknownLabel string
+	DirectLabel  string
+	// PercentileMinSamples is how many timed requests PerformanceStats
+	// needs before it reports percentiles; 0 always reports them.
+	PercentileMinSamples int
+}
+
+// DefaultCountEstimateThreshold is the CountEstimateThreshold used by New.
+const DefaultCountEstimateThreshold = 100_000
+
+var (
+	validJournalModes = []string{"DELETE", "TRUNCATE", "PERSIST", "MEMORY", "WAL", "OFF"}
+	validSynchronous  = []string{"OFF", "NORMAL", "FULL", "EXTRA"}
+)
+
+// dsn builds the driver connection string. The pragmas are applied by the
+// driver to every new connection in the pool.
+func (o Options) dsn(dbPath string) (string, error) {
+	busy := o.BusyTimeout
+	if busy <= 0 {
+		busy = 30 * time.Second
+	}
+	journal := strings.ToUpper(strings.TrimSpace(o.JournalMode))
+	if journal == "" {
+		journal = "WAL"
+	}
+	if !slices.Contains(validJournalModes, journal) {
+		return "", fmt.Errorf("invalid journal mode %q (want one of %s)", o.JournalMode, strings.Join(validJournalModes, ", "))
+	}
+	sync := strings.ToUpper(strings.TrimSpace(o.Synchronous))
+	if sync == "" {
+		sync = "NORMAL"
+	}
+	if !slices.Contains(validSynchronous, sync) {
+		return "", fmt.Errorf("invalid synchronous level %q (want one of %s)", o.Synchronous, strings.Join(validSynchronous, ", "))
+	}
+	return fmt.Sprintf("%s?_pragma=busy_timeout(%d)&_pragma=journal_mode(%s)&_pragma=synchronous(%s)",
+		dbPath, busy.Milliseconds(), journal, sync), nil
 }
 
 // New creates a new Storage instance with default options.
 // For custom options, use NewWithOptions.
 func New(dbPath string) (*Storage, error) {
 	return NewWithOptions(dbPath, Options{
-		MaxConnections: 1,
-		QueryTimeout:   30 * time.Second,
+		MaxConnections:         1,
+		QueryTimeout:           30 * time.Second,
+		CountEstimateThreshold: DefaultCountEstimateThreshold,
 	})
 }
 
 // NewWithOptions creates a new Storage instance with the given options.
 func NewWithOptions(dbPath string, opts Options) (*Storage, error) {
+	dsn, err := opts.dsn(dbPath)
+	if err != nil {
+		return nil, err
+	}
 	if err := os.MkdirAll(filepath.Dir(dbPath), 0o755); err != nil {
 		return nil, fmt.Errorf("create db dir: %w", err)
 	}
-	db, err := sql.Open("sqlite", dbPath+"?_busy_timeout=30000&_journal_mode=WAL&_synchronous=NORMAL")
+	db, err := sql.Open("sqlite", dsn)
 	if err != nil {
 		return nil, err
 	}
@@ -62,14 +159,32 @@ func NewWithOptions(dbPath string, opts Options) (*Storage, error) {
 		queryTimeout = 30 * time.Second
 	}
 
+	maxRecent := opts.MaxRecentRequests
+	if maxRecent <= 0 {
+		maxRecent = DefaultMaxRecentRequests
+	}
+	maxRecent = min(maxRecent, MaxRecentRequestsCeiling)
+
 	s := &Storage{
 		db:           db,
 		queryTimeout: queryTimeout,
+		stripQuery:   newQueryStripper(opts.StripQueryParams),
+		assets:       newAssetMatcher(opts.AssetExtensions),
+		labels:       newEmptyLabels(opts.UnknownLabel, opts.DirectLabel),
+		diskFree:     diskFreeBytes,
+
+		countEstimateThreshold: opts.CountEstimateThreshold,
+		maxRecentRequests:      maxRecent,
+		percentileMinSamples:   opts.PercentileMinSamples,
 	}
 	if err := s.migrate(); err != nil {
 		db.Close()
 		return nil, err
 	}
+	// Refresh planner statistics; a failure only costs query performance
+	if err := s.Optimize(context.Background()); err != nil {
+		slog.Warn("failed to optimize database", "error", err)
+	}
 	if err := s.prepareStatements(); err != nil {
 		db.Close()
 		return nil, fmt.Errorf("prepare statements: %w", err)
@@ -131,6 +246,11 @@ CREATE TABLE IF NOT EXISTS rollups_daily (
 	PRIMARY KEY(bucket_start, host, path)
 );
 
+CREATE TABLE IF NOT EXISTS compaction_state (
+	id INTEGER PRIMARY KEY CHECK (id = 1),
+	compacted_before TIMESTAMP NOT NULL
+);
+
 CREATE TABLE IF NOT EXISTS import_progress (
 	file_path TEXT PRIMARY KEY,
 	byte_offset INTEGER NOT NULL,
@@ -169,6 +289,21 @@ CREATE TABLE IF NOT EXISTS import_errors (
 		"ALTER TABLE requests ADD COLUMN is_bot INTEGER DEFAULT 0",
 		"ALTER TABLE requests ADD COLUMN bot_name TEXT DEFAULT ''",
 		"ALTER TABLE requests ADD COLUMN bot_intent TEXT DEFAULT ''",
+		"ALTER TABLE requests ADD COLUMN dedup_hash TEXT",
+		"ALTER TABLE requests ADD COLUMN sample_weight INTEGER DEFAULT 1",
+		"ALTER TABLE requests ADD COLUMN content_type TEXT DEFAULT ''",
+		"ALTER TABLE requests ADD COLUMN method TEXT DEFAULT ''",
+		"ALTER TABLE requests ADD COLUMN protocol TEXT DEFAULT ''",
+		"ALTER TABLE requests ADD COLUMN tls_version TEXT DEFAULT ''",
+		"ALTER TABLE requests ADD COLUMN cache_status TEXT DEFAULT ''",
+		"ALTER TABLE requests ADD COLUMN bot_verification TEXT DEFAULT ''",
+		"ALTER TABLE requests ADD COLUMN device_brand TEXT DEFAULT ''",
+		"ALTER TABLE requests ADD COLUMN device_model TEXT DEFAULT ''",
+		"ALTER TABLE requests ADD COLUMN language TEXT DEFAULT ''",
+		"ALTER TABLE requests ADD COLUMN internal INTEGER NOT NULL DEFAULT 0",
+		"ALTER TABLE import_errors ADD COLUMN quarantined INTEGER NOT NULL DEFAULT 0",
+		"ALTER TABLE rollups_hourly ADD COLUMN status_other INTEGER NOT NULL DEFAULT 0",
+		"ALTER TABLE rollups_daily ADD COLUMN status_other INTEGER NOT NULL DEFAULT 0",
 	}
 	for _, m := range migrations {
 		// Ignore errors - column may already exist
@@ -178,6 +313,12 @@ CREATE TABLE IF NOT EXISTS import_errors (
 	// Create indexes after columns exist
 	_, _ = s.db.Exec("CREATE INDEX IF NOT EXISTS idx_requests_ip ON requests(ip)")
 	_, _ = s.db.Exec("CREATE INDEX IF NOT EXISTS idx_requests_is_bot ON requests(is_bot)")
+	// Only rows ingested with deduplication enabled carry a hash. The insert
+	// statement's ON CONFLICT clause needs this index, so a failure is fatal
+	// (e.g. existing duplicate hashes).
+	if _, err := s.db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_requests_dedup ON requests(dedup_hash) WHERE dedup_hash IS NOT NULL"); err != nil {
+		return fmt.Errorf("create dedup index: %w", err)
+	}
 
 	return nil
 }
@@ -187,8 +328,9 @@ func (s *Storage) prepareStatements() error {
 
 	// Prepare insert request statement
 	s.stmtInsertRequest, err = s.db.Prepare(`
-INSERT INTO requests (ts, host, path, status, bytes, ip, referrer, user_agent, resp_time_ms, country, region, city, browser, browser_version, os, os_version, device_type, is_bot, bot_name, bot_intent)
-VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+INSERT INTO requests (ts, host, path, status, bytes, ip, referrer, user_agent, resp_time_ms, country, region, city, browser, browser_version, os, os_version, device_type, is_bot, bot_name, bot_intent, dedup_hash, sample_weight, content_type, method, protocol, tls_version, cache_status, bot_verification, device_brand, device_model, language, internal)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NULLIF(?, ''), ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT(dedup_hash) WHERE dedup_hash IS NOT NULL DO NOTHING
 `)
 	if err != nil {
 		return fmt.Errorf("prepare insert request: %w", err)
@@ -235,3 +377,16 @@ func (s *Storage) Close() error {
 func (s *Storage) QueryTimeout() time.Duration {
 	return s.queryTimeout
 }
+
+// scanCheckEvery is how many rows long scans read between context checks.
+const scanCheckEvery = 256
+
+// scanCanceled returns ctx.Err() on every scanCheckEvery-th row, so a scan
+// over a large range stops soon after its request is cancelled (e.g. the
+// client disconnected) instead of holding the connection until the last row.
+func scanCanceled(ctx context.Context, row int) error {
+	if row%scanCheckEvery != 0 {
+		return nil
+	}
+	return ctx.Err()
+}
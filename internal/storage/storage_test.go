@@ -2,9 +2,11 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -244,6 +246,63 @@ func TestStorage_InsertRequest_StatusRollups(t *testing.T) {
 	}
 }
 
+func TestStorage_StatusOther(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC().Add(-time.Minute)
+
+	// 0 is what Caddy logs when the client hung up before a response
+	for _, status := range []int{200, 0, 999} {
+		req := RequestRecord{Timestamp: now, Host: "example.com", Path: "/test", Status: status, Bytes: 100}
+		if err := s.InsertRequest(ctx, req); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	summary, err := s.Summary(ctx, time.Hour, "")
+	if err != nil {
+		t.Fatalf("Summary() error = %v", err)
+	}
+	if summary.TotalRequests != 3 || summary.Status2xx != 1 || summary.StatusOther != 2 {
+		t.Errorf("Summary total/2xx/other = %d/%d/%d, want 3/1/2",
+			summary.TotalRequests, summary.Status2xx, summary.StatusOther)
+	}
+	if summary.Status5xx != 0 {
+		t.Errorf("Summary status_5xx = %d, want 0 (999 is not a server error)", summary.Status5xx)
+	}
+
+	totals, err := s.HourlyRollupTotals(ctx, now.Truncate(time.Hour), now.Add(time.Hour), "")
+	if err != nil {
+		t.Fatalf("HourlyRollupTotals() error = %v", err)
+	}
+	if totals.Requests != 3 || totals.Status2xx != 1 || totals.Status5xx != 0 || totals.StatusOther != 2 {
+		t.Errorf("rollup totals = %+v, want 3 requests, 1 2xx and 2 other", totals)
+	}
+
+	series, err := s.TimeSeriesRange(ctx, time.Hour, "", BucketHour)
+	if err != nil {
+		t.Fatalf("TimeSeriesRange() error = %v", err)
+	}
+	var s5xx, sOther int64
+	for _, p := range series {
+		s5xx += p.Status5xx
+		sOther += p.StatusOther
+	}
+	if s5xx != 0 || sOther != 2 {
+		t.Errorf("time series 5xx/other = %d/%d, want 0/2", s5xx, sOther)
+	}
+
+	alert, err := s.GetAlertStats(ctx, time.Hour, "")
+	if err != nil {
+		t.Fatalf("GetAlertStats() error = %v", err)
+	}
+	if alert.Status5xx != 0 {
+		t.Errorf("alert status_5xx = %d, want 0", alert.Status5xx)
+	}
+}
+
 // Note: TestStorage_Summary_Empty is intentionally omitted because
 // the Summary query currently doesn't wrap all SUM columns with IFNULL,
 // causing SQL scan errors when filtering returns zero rows.
@@ -325,6 +384,114 @@ func TestStorage_Summary_WithHostFilter(t *testing.T) {
 	}
 }
 
+func TestStorage_SummaryFiltered_Country(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	requests := []RequestRecord{
+		{Timestamp: now, Host: "site1.com", Path: "/de", Status: 200, Bytes: 1000, IP: "192.168.1.1", Country: "DE"},
+		{Timestamp: now, Host: "site2.com", Path: "/de", Status: 500, Bytes: 2000, IP: "192.168.1.2", Country: "DE"},
+		{Timestamp: now, Host: "site1.com", Path: "/fr", Status: 200, Bytes: 4000, IP: "192.168.1.3", Country: "FR"},
+		{Timestamp: now, Host: "site1.com", Path: "/unknown", Status: 200, Bytes: 8000, IP: "192.168.1.4"},
+	}
+	for _, req := range requests {
+		if err := s.InsertRequest(ctx, req); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	summary, err := s.SummaryFiltered(ctx, 24*time.Hour, StatsFilter{Country: "DE"})
+	if err != nil {
+		t.Fatalf("SummaryFiltered() error = %v", err)
+	}
+	if summary.TotalRequests != 2 || summary.BandwidthBytes != 3000 || summary.Status5xx != 1 {
+		t.Errorf("DE summary = %d requests, %d bytes, %d 5xx; want 2, 3000, 1", summary.TotalRequests, summary.BandwidthBytes, summary.Status5xx)
+	}
+	if len(summary.TopPaths) != 1 || summary.TopPaths[0].Path != "/de" {
+		t.Errorf("DE top paths = %+v, want only /de", summary.TopPaths)
+	}
+	if len(summary.Hosts) != 2 {
+		t.Errorf("DE hosts = %+v, want both hosts with German traffic", summary.Hosts)
+	}
+
+	// Combined with a host filter
+	summary, err = s.SummaryFiltered(ctx, 24*time.Hour, StatsFilter{Host: "site1.com", Country: "DE"})
+	if err != nil {
+		t.Fatalf("SummaryFiltered() error = %v", err)
+	}
+	if summary.TotalRequests != 1 || summary.BandwidthBytes != 1000 {
+		t.Errorf("site1.com DE summary = %d requests, %d bytes; want 1, 1000", summary.TotalRequests, summary.BandwidthBytes)
+	}
+
+	visitors, err := s.VisitorsFiltered(ctx, 24*time.Hour, StatsFilter{Country: "FR"}, 10, VisitorSortHits)
+	if err != nil {
+		t.Fatalf("VisitorsFiltered() error = %v", err)
+	}
+	if len(visitors) != 1 || visitors[0].IP != "192.168.1.3" {
+		t.Errorf("FR visitors = %+v, want only 192.168.1.3", visitors)
+	}
+}
+
+func TestParseCountry(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"", "", false},
+		{"DE", "DE", false},
+		{"de", "DE", false},
+		{"D", "", true},
+		{"DEU", "", true},
+		{"D1", "", true},
+		{"ü", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseCountry(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseCountry(%q) = %q, %v; want %q, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestStorage_Summary_ViewedUsesBotFlag(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	requests := []RequestRecord{
+		// Flagged at ingest by a signature, although the UA has no "bot" substring
+		{Timestamp: now, Host: "example.com", Path: "/a", Status: 200, Bytes: 100, IP: "10.0.0.1", UserAgent: "python-requests/2.31", IsBot: true, BotName: "python-requests"},
+		// A human browser whose UA happens to contain "bot"
+		{Timestamp: now, Host: "example.com", Path: "/b", Status: 200, Bytes: 200, IP: "10.0.0.2", UserAgent: "Mozilla/5.0 (Linux; Android 12; Cubot P80)"},
+		{Timestamp: now, Host: "example.com", Path: "/c", Status: 200, Bytes: 300, IP: "10.0.0.3", UserAgent: "Mozilla/5.0"},
+	}
+	for _, req := range requests {
+		if err := s.InsertRequest(ctx, req); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	summary, err := s.Summary(ctx, time.Hour, "")
+	if err != nil {
+		t.Fatalf("Summary() error = %v", err)
+	}
+	if got := summary.Traffic.NotViewed.Hits; got != 1 {
+		t.Errorf("NotViewed.Hits = %d, want 1", got)
+	}
+	if got := summary.Traffic.NotViewed.BandwidthBytes; got != 100 {
+		t.Errorf("NotViewed.BandwidthBytes = %d, want 100", got)
+	}
+	if got := summary.Traffic.Viewed.Hits; got != 2 {
+		t.Errorf("Viewed.Hits = %d, want 2", got)
+	}
+}
+
 func TestStorage_Cleanup(t *testing.T) {
 	s, cleanup := setupTestDB(t)
 	defer cleanup()
@@ -459,6 +626,46 @@ func TestStorage_CleanupWithPerSiteRetention(t *testing.T) {
 	}
 }
 
+func TestStorage_CleanupWithPerSiteRetention_Aliases(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	if _, err := s.CreateSite(ctx, SiteInput{Host: "short.com", Aliases: []string{"www.short.com"}, RetentionDays: 3}); err != nil {
+		t.Fatalf("CreateSite() error = %v", err)
+	}
+	if _, err := s.CreateSite(ctx, SiteInput{Host: "long.com", Aliases: []string{"www.long.com"}, RetentionDays: 30}); err != nil {
+		t.Fatalf("CreateSite() error = %v", err)
+	}
+	for _, req := range []RequestRecord{
+		// Past short.com's 3 days, logged under its alias
+		{Timestamp: now.AddDate(0, 0, -5), Host: "www.short.com", Path: "/", Status: 200},
+		// Past the 7-day global default but inside long.com's 30 days
+		{Timestamp: now.AddDate(0, 0, -10), Host: "www.long.com", Path: "/", Status: 200},
+	} {
+		if err := s.InsertRequest(ctx, req); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	result, err := s.CleanupWithPerSiteRetention(ctx, 7)
+	if err != nil {
+		t.Fatalf("CleanupWithPerSiteRetention() error = %v", err)
+	}
+	if result.PerSiteDeleted["short.com"] != 1 || result.GlobalDeleted != 0 {
+		t.Errorf("per-site short.com = %d, global = %d; want 1, 0", result.PerSiteDeleted["short.com"], result.GlobalDeleted)
+	}
+
+	var count int
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM requests WHERE host = ?", "www.long.com").Scan(&count); err != nil {
+		t.Fatalf("count query error = %v", err)
+	}
+	if count != 1 {
+		t.Errorf("www.long.com requests = %d, want 1 (kept by long.com's retention)", count)
+	}
+}
+
 func TestStorage_CleanupWithPerSiteRetention_NoCustomSites(t *testing.T) {
 	s, cleanup := setupTestDB(t)
 	defer cleanup()
@@ -588,6 +795,225 @@ func TestStorage_RecentRequests_MaxLimit(t *testing.T) {
 	}
 }
 
+func TestStorage_RecentRequests_ConfiguredCap(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	s, err := NewWithOptions(dbPath, Options{MaxRecentRequests: 250})
+	if err != nil {
+		t.Fatalf("NewWithOptions() error = %v", err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	for i := 0; i < 200; i++ {
+		req := RequestRecord{
+			Timestamp: now.Add(-time.Duration(i) * time.Second),
+			Host:      "example.com",
+			Path:      "/test",
+			Status:    200,
+			IP:        "192.168.1.1",
+		}
+		if err := s.InsertRequest(ctx, req); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	recent, err := s.RecentRequests(ctx, 200, "")
+	if err != nil {
+		t.Fatalf("RecentRequests() error = %v", err)
+	}
+	if len(recent) != 200 {
+		t.Errorf("expected 200 requests under a cap of 250, got %d", len(recent))
+	}
+
+	// An absurd configured cap is still bounded by the ceiling
+	huge, err := NewWithOptions(filepath.Join(t.TempDir(), "huge.db"), Options{MaxRecentRequests: 10_000_000})
+	if err != nil {
+		t.Fatalf("NewWithOptions() error = %v", err)
+	}
+	defer huge.Close()
+	if huge.maxRecentRequests != MaxRecentRequestsCeiling {
+		t.Errorf("maxRecentRequests = %d, want ceiling %d", huge.maxRecentRequests, MaxRecentRequestsCeiling)
+	}
+	if _, err := huge.RecentRequests(ctx, 1<<30, ""); err != nil {
+		t.Errorf("RecentRequests() with absurd limit error = %v", err)
+	}
+}
+
+func TestStorage_RecentRequestsBefore_Paging(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	insert := func(n int) {
+		for i := 0; i < n; i++ {
+			req := RequestRecord{
+				Timestamp: now.Add(-time.Duration(i) * time.Second),
+				Host:      "example.com",
+				Path:      "/test",
+				Status:    200,
+				IP:        "192.168.1.1",
+			}
+			if err := s.InsertRequest(ctx, req); err != nil {
+				t.Fatalf("InsertRequest() error = %v", err)
+			}
+		}
+	}
+	insert(25)
+
+	seen := make(map[int64]bool)
+	var cursor int64
+	var last *RecentRequest
+	for page := 0; ; page++ {
+		rows, err := s.RecentRequestsBefore(ctx, 10, "", cursor, RecentFilter{})
+		if err != nil {
+			t.Fatalf("RecentRequestsBefore() error = %v", err)
+		}
+		for i := range rows {
+			r := &rows[i]
+			if seen[r.ID] {
+				t.Fatalf("id %d returned twice", r.ID)
+			}
+			if last != nil && (r.Timestamp.After(last.Timestamp) || r.Timestamp.Equal(last.Timestamp) && r.ID >= last.ID) {
+				t.Fatalf("id %d at %v not older than id %d at %v", r.ID, r.Timestamp, last.ID, last.Timestamp)
+			}
+			seen[r.ID] = true
+			last = r
+		}
+		if len(rows) < 10 {
+			break
+		}
+		cursor = rows[len(rows)-1].ID
+		// Rows arriving mid-pagination must not shift later pages
+		if page == 0 {
+			insert(5)
+		}
+	}
+	if len(seen) != 25 {
+		t.Errorf("paged through %d rows, want 25", len(seen))
+	}
+}
+
+func TestStorage_RecentRequestsBefore_StatusFilter(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	for i, rec := range []struct {
+		host   string
+		status int
+	}{
+		{"example.com", 200}, {"example.com", 500}, {"example.com", 404},
+		{"example.com", 503}, {"other.com", 502}, {"example.com", 301},
+	} {
+		req := RequestRecord{Timestamp: now.Add(-time.Duration(i) * time.Minute), Host: rec.host, Path: "/", Status: rec.status, IP: "10.0.0.1"}
+		if err := s.InsertRequest(ctx, req); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	statuses := func(host, status string) []int {
+		t.Helper()
+		filter, err := ParseStatusFilter(status)
+		if err != nil {
+			t.Fatalf("ParseStatusFilter(%q) error = %v", status, err)
+		}
+		rows, err := s.RecentRequestsBefore(ctx, 10, host, 0, RecentFilter{Status: filter})
+		if err != nil {
+			t.Fatalf("RecentRequestsBefore() error = %v", err)
+		}
+		out := make([]int, len(rows))
+		for i, r := range rows {
+			out[i] = r.Status
+		}
+		return out
+	}
+
+	if got, want := statuses("", "5xx"), []int{500, 503, 502}; !reflect.DeepEqual(got, want) {
+		t.Errorf("5xx = %v, want %v", got, want)
+	}
+	if got, want := statuses("example.com", "5XX"), []int{500, 503}; !reflect.DeepEqual(got, want) {
+		t.Errorf("5xx on example.com = %v, want %v", got, want)
+	}
+	if got, want := statuses("", "404"), []int{404}; !reflect.DeepEqual(got, want) {
+		t.Errorf("404 = %v, want %v", got, want)
+	}
+	if got := statuses("", ""); len(got) != 6 {
+		t.Errorf("no filter returned %d rows, want 6", len(got))
+	}
+}
+
+func TestStorage_RecentRequestsBefore_PathFilter(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	for i, rec := range []struct {
+		path   string
+		status int
+	}{
+		{"/admin/login", 200}, {"/Admin/users", 500}, {"/about", 200},
+		{"/blog/sysadmin", 200}, {"/100%_off", 200}, {"/100x_off", 200},
+	} {
+		req := RequestRecord{Timestamp: now.Add(-time.Duration(i) * time.Minute), Host: "example.com", Path: rec.path, Status: rec.status, IP: "10.0.0.1"}
+		if err := s.InsertRequest(ctx, req); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	paths := func(filter RecentFilter) []string {
+		t.Helper()
+		rows, err := s.RecentRequestsBefore(ctx, 10, "example.com", 0, filter)
+		if err != nil {
+			t.Fatalf("RecentRequestsBefore() error = %v", err)
+		}
+		out := make([]string, len(rows))
+		for i, r := range rows {
+			out[i] = r.Path
+		}
+		return out
+	}
+
+	if got, want := paths(RecentFilter{PathContains: "/admin"}), []string{"/admin/login", "/Admin/users"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("/admin = %v, want %v", got, want)
+	}
+	server5xx, _ := ParseStatusFilter("5xx")
+	if got, want := paths(RecentFilter{PathContains: "/admin", Status: server5xx}), []string{"/Admin/users"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("/admin with 5xx = %v, want %v", got, want)
+	}
+	// Wildcards in the search text match literally
+	if got, want := paths(RecentFilter{PathContains: "%_"}), []string{"/100%_off"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("%%_ = %v, want %v", got, want)
+	}
+}
+
+func TestParseStatusFilter(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    StatusFilter
+		wantErr bool
+	}{
+		{"", StatusFilter{}, false},
+		{"404", StatusFilter{404, 404}, false},
+		{"5xx", StatusFilter{500, 599}, false},
+		{"2XX", StatusFilter{200, 299}, false},
+		{"6xx", StatusFilter{}, true},
+		{"0xx", StatusFilter{}, true},
+		{"99", StatusFilter{}, true},
+		{"600", StatusFilter{}, true},
+		{"abc", StatusFilter{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseStatusFilter(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseStatusFilter(%q) = %+v, %v; want %+v, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
 func TestStorage_RecentRequests_TimeFilter(t *testing.T) {
 	s, cleanup := setupTestDB(t)
 	defer cleanup()
@@ -737,7 +1163,7 @@ func TestStorage_Visitors(t *testing.T) {
 		}
 	}
 
-	visitors, err := s.Visitors(ctx, 24*time.Hour, "", 10)
+	visitors, err := s.Visitors(ctx, 24*time.Hour, "", 10, VisitorSortHits)
 	if err != nil {
 		t.Fatalf("Visitors() error = %v", err)
 	}
@@ -753,6 +1179,55 @@ func TestStorage_Visitors(t *testing.T) {
 	}
 }
 
+func TestStorage_Visitors_Sort(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	// .1 has the most hits, .2 the most bytes, .3 the latest visit
+	requests := []RequestRecord{
+		{Timestamp: now.Add(-30 * time.Minute), Host: "example.com", Path: "/a", Status: 200, Bytes: 100, IP: "192.168.1.1"},
+		{Timestamp: now.Add(-29 * time.Minute), Host: "example.com", Path: "/b", Status: 200, Bytes: 100, IP: "192.168.1.1"},
+		{Timestamp: now.Add(-28 * time.Minute), Host: "example.com", Path: "/c", Status: 200, Bytes: 100, IP: "192.168.1.1"},
+		{Timestamp: now.Add(-20 * time.Minute), Host: "example.com", Path: "/video", Status: 200, Bytes: 50000, IP: "192.168.1.2"},
+		{Timestamp: now.Add(-time.Minute), Host: "example.com", Path: "/a", Status: 200, Bytes: 10, IP: "192.168.1.3"},
+	}
+	for _, req := range requests {
+		if err := s.InsertRequest(ctx, req); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	for _, tt := range []struct {
+		sort VisitorSort
+		want []string
+	}{
+		{VisitorSortHits, []string{"192.168.1.1"}},
+		{VisitorSortBandwidth, []string{"192.168.1.2", "192.168.1.1", "192.168.1.3"}},
+		{VisitorSortRecent, []string{"192.168.1.3", "192.168.1.2", "192.168.1.1"}},
+	} {
+		visitors, err := s.Visitors(ctx, time.Hour, "", 10, tt.sort)
+		if err != nil {
+			t.Fatalf("Visitors(%s) error = %v", tt.sort, err)
+		}
+		for i, ip := range tt.want {
+			if i >= len(visitors) || visitors[i].IP != ip {
+				t.Errorf("Visitors(%s)[%d] = %+v, want IP %s", tt.sort, i, visitors, ip)
+				break
+			}
+		}
+	}
+
+	if _, err := ParseVisitorSort("country"); err == nil {
+		t.Error("ParseVisitorSort(country) should fail")
+	}
+	if got, err := ParseVisitorSort(""); err != nil || got != VisitorSortHits {
+		t.Errorf("ParseVisitorSort(\"\") = %q, %v; want hits", got, err)
+	}
+}
+
 func TestStorage_Browsers(t *testing.T) {
 	s, cleanup := setupTestDB(t)
 	defer cleanup()
@@ -819,18 +1294,21 @@ func TestStorage_OperatingSystems(t *testing.T) {
 	}
 }
 
-func TestStorage_Robots(t *testing.T) {
+func TestStorage_TopUserAgents(t *testing.T) {
 	s, cleanup := setupTestDB(t)
 	defer cleanup()
 
 	ctx := context.Background()
 	now := time.Now().UTC()
+	const scraper = "python-requests/2.31.0"
+	const chrome = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) Chrome/120.0"
 
 	requests := []RequestRecord{
-		{Timestamp: now, Host: "example.com", Path: "/robots.txt", Status: 200, Bytes: 100, IP: "1.1.1.1", IsBot: true, BotName: "Googlebot"},
-		{Timestamp: now, Host: "example.com", Path: "/sitemap.xml", Status: 200, Bytes: 200, IP: "1.1.1.1", IsBot: true, BotName: "Googlebot"},
-		{Timestamp: now, Host: "example.com", Path: "/", Status: 200, Bytes: 300, IP: "2.2.2.2", IsBot: true, BotName: "Bingbot"},
-		{Timestamp: now, Host: "example.com", Path: "/", Status: 200, Bytes: 400, IP: "3.3.3.3", IsBot: false}, // Not a bot
+		{Timestamp: now, Host: "example.com", Path: "/", Status: 200, UserAgent: scraper, IsBot: true, BotName: "python-requests"},
+		{Timestamp: now, Host: "example.com", Path: "/a", Status: 200, UserAgent: scraper, IsBot: true, BotName: "python-requests"},
+		{Timestamp: now, Host: "example.com", Path: "/b", Status: 200, UserAgent: scraper, IsBot: true, BotName: "python-requests"},
+		{Timestamp: now, Host: "example.com", Path: "/", Status: 200, UserAgent: chrome, Browser: "Chrome"},
+		{Timestamp: now, Host: "other.com", Path: "/", Status: 200, UserAgent: chrome, Browser: "Chrome"},
 	}
 	for _, req := range requests {
 		if err := s.InsertRequest(ctx, req); err != nil {
@@ -838,15 +1316,210 @@ func TestStorage_Robots(t *testing.T) {
 		}
 	}
 
-	robots, err := s.Robots(ctx, 24*time.Hour, "", 10)
+	agents, err := s.TopUserAgents(ctx, 24*time.Hour, "", 10)
 	if err != nil {
-		t.Fatalf("Robots() error = %v", err)
+		t.Fatalf("TopUserAgents() error = %v", err)
 	}
-	if len(robots) != 2 {
-		t.Fatalf("expected 2 robots, got %d", len(robots))
+	if len(agents) != 2 {
+		t.Fatalf("expected 2 user agents, got %+v", agents)
 	}
-	if robots[0].Name != "Googlebot" {
-		t.Errorf("first robot = %q, want %q", robots[0].Name, "Googlebot")
+	if agents[0].UserAgent != scraper || agents[0].Hits != 3 || !agents[0].IsBot || agents[0].BotName != "python-requests" {
+		t.Errorf("first user agent = %+v, want the scraper with 3 hits flagged as a bot", agents[0])
+	}
+	if agents[0].Percent != 60 {
+		t.Errorf("scraper percent = %v, want 60", agents[0].Percent)
+	}
+	if agents[1].UserAgent != chrome || agents[1].IsBot {
+		t.Errorf("second user agent = %+v, want Chrome not flagged as a bot", agents[1])
+	}
+
+	agents, err = s.TopUserAgents(ctx, 24*time.Hour, "other.com", 10)
+	if err != nil {
+		t.Fatalf("TopUserAgents(host) error = %v", err)
+	}
+	if len(agents) != 1 || agents[0].UserAgent != chrome || agents[0].Hits != 1 {
+		t.Errorf("TopUserAgents(other.com) = %+v, want one Chrome hit", agents)
+	}
+}
+
+func TestStorage_UnclassifiedUserAgents(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	const odd = "SomeEmbeddedClient/1.0"
+	const chrome = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) Chrome/120.0"
+
+	requests := []RequestRecord{
+		{Timestamp: now, Host: "example.com", Path: "/", Status: 200, UserAgent: odd},
+		{Timestamp: now, Host: "example.com", Path: "/a", Status: 200, UserAgent: odd},
+		{Timestamp: now, Host: "example.com", Path: "/", Status: 200, UserAgent: chrome, Browser: "Chrome", OS: "Windows"},
+		{Timestamp: now, Host: "example.com", Path: "/", Status: 200, UserAgent: "Googlebot/2.1", IsBot: true, BotName: "Googlebot"},
+	}
+	for _, req := range requests {
+		if err := s.InsertRequest(ctx, req); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	got, err := s.UnclassifiedUserAgents(ctx, 24*time.Hour, "", 10)
+	if err != nil {
+		t.Fatalf("UnclassifiedUserAgents() error = %v", err)
+	}
+	want := []UnclassifiedUserAgent{{UserAgent: odd, Hits: 2, UnknownBrowser: true, UnknownOS: true}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("UnclassifiedUserAgents() = %+v, want %+v", got, want)
+	}
+}
+
+func TestStorage_Devices(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	requests := []RequestRecord{
+		{Timestamp: now, Host: "example.com", Path: "/", Status: 200, DeviceBrand: "Apple", DeviceModel: "iPhone"},
+		{Timestamp: now, Host: "example.com", Path: "/a", Status: 200, DeviceBrand: "Apple", DeviceModel: "iPhone"},
+		{Timestamp: now, Host: "example.com", Path: "/", Status: 200, DeviceBrand: "Google", DeviceModel: "Pixel 7"},
+		{Timestamp: now, Host: "other.com", Path: "/", Status: 200, DeviceBrand: "Google", DeviceModel: "Pixel 7"},
+		{Timestamp: now, Host: "example.com", Path: "/", Status: 200},                                                             // Unknown device
+		{Timestamp: now, Host: "example.com", Path: "/", Status: 200, IsBot: true, DeviceBrand: "Google", DeviceModel: "Pixel 7"}, // Bot
+	}
+	for _, req := range requests {
+		if err := s.InsertRequest(ctx, req); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	devices, err := s.Devices(ctx, 24*time.Hour, "example.com", 10)
+	if err != nil {
+		t.Fatalf("Devices() error = %v", err)
+	}
+	if len(devices) != 2 {
+		t.Fatalf("expected 2 devices, got %+v", devices)
+	}
+	if got := devices[0]; got.Brand != "Apple" || got.Model != "iPhone" || got.Hits != 2 || got.Percent != 66.7 {
+		t.Errorf("first device = %+v, want Apple iPhone with 2 hits (66.7%%)", got)
+	}
+	if got := devices[1]; got.Model != "Pixel 7" || got.Hits != 1 {
+		t.Errorf("second device = %+v, want Pixel 7 with 1 hit", got)
+	}
+
+	devices, err = s.Devices(ctx, 24*time.Hour, "", 1)
+	if err != nil {
+		t.Fatalf("Devices() error = %v", err)
+	}
+	if len(devices) != 1 {
+		t.Errorf("expected limit to cap results at 1, got %+v", devices)
+	}
+}
+
+func TestStorage_Languages(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	requests := []RequestRecord{
+		{Timestamp: now, Host: "example.com", Path: "/", Status: 200, Language: "en-US"},
+		{Timestamp: now, Host: "example.com", Path: "/a", Status: 200, Language: "en-US"},
+		{Timestamp: now, Host: "example.com", Path: "/b", Status: 200, Language: "en-US"},
+		{Timestamp: now, Host: "example.com", Path: "/", Status: 200, Language: "de-DE"},
+		{Timestamp: now, Host: "example.com", Path: "/", Status: 200},                              // Header not logged
+		{Timestamp: now, Host: "example.com", Path: "/", Status: 200, Language: "fr", IsBot: true}, // Bot
+	}
+	for _, req := range requests {
+		if err := s.InsertRequest(ctx, req); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	langs, err := s.Languages(ctx, 24*time.Hour, "", 10)
+	if err != nil {
+		t.Fatalf("Languages() error = %v", err)
+	}
+	if len(langs) != 2 {
+		t.Fatalf("expected 2 languages, got %+v", langs)
+	}
+	if got := langs[0]; got.Language != "en-US" || got.Hits != 3 || got.Percent != 75 {
+		t.Errorf("first language = %+v, want en-US with 3 hits (75%%)", got)
+	}
+	if got := langs[1]; got.Language != "de-DE" || got.Hits != 1 {
+		t.Errorf("second language = %+v, want de-DE with 1 hit", got)
+	}
+}
+
+func TestStorage_BotVerification(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	const googlebot = "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"
+
+	requests := []RequestRecord{
+		{Timestamp: now, Host: "example.com", Path: "/", Status: 200, IP: "66.249.66.1", UserAgent: googlebot, IsBot: true, BotName: "Googlebot", BotVerification: "verified"},
+		{Timestamp: now, Host: "example.com", Path: "/wp-login.php", Status: 404, IP: "203.0.113.9", UserAgent: googlebot, IsBot: true, BotName: "Googlebot", BotVerification: "unverified"},
+		{Timestamp: now, Host: "example.com", Path: "/", Status: 200, IP: "5.5.5.5", IsBot: true, BotName: "AhrefsBot"}, // No published ranges
+	}
+	for _, req := range requests {
+		if err := s.InsertRequest(ctx, req); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	stats, err := s.BotVerification(ctx, 24*time.Hour, "")
+	if err != nil {
+		t.Fatalf("BotVerification() error = %v", err)
+	}
+	if len(stats) != 1 {
+		t.Fatalf("expected only Googlebot, got %+v", stats)
+	}
+	if got := stats[0]; got.Name != "Googlebot" || got.Verified != 1 || got.Unverified != 1 || got.SpoofedPercent != 50 {
+		t.Errorf("Googlebot = %+v, want 1 verified, 1 unverified, 50%% spoofed", got)
+	}
+
+	stats, err = s.BotVerification(ctx, 24*time.Hour, "other.com")
+	if err != nil {
+		t.Fatalf("BotVerification() error = %v", err)
+	}
+	if len(stats) != 0 {
+		t.Errorf("expected no stats for other.com, got %+v", stats)
+	}
+}
+
+func TestStorage_Robots(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	requests := []RequestRecord{
+		{Timestamp: now, Host: "example.com", Path: "/robots.txt", Status: 200, Bytes: 100, IP: "1.1.1.1", IsBot: true, BotName: "Googlebot"},
+		{Timestamp: now, Host: "example.com", Path: "/sitemap.xml", Status: 200, Bytes: 200, IP: "1.1.1.1", IsBot: true, BotName: "Googlebot"},
+		{Timestamp: now, Host: "example.com", Path: "/", Status: 200, Bytes: 300, IP: "2.2.2.2", IsBot: true, BotName: "Bingbot"},
+		{Timestamp: now, Host: "example.com", Path: "/", Status: 200, Bytes: 400, IP: "3.3.3.3", IsBot: false}, // Not a bot
+	}
+	for _, req := range requests {
+		if err := s.InsertRequest(ctx, req); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	robots, err := s.Robots(ctx, 24*time.Hour, "", 10)
+	if err != nil {
+		t.Fatalf("Robots() error = %v", err)
+	}
+	if len(robots) != 2 {
+		t.Fatalf("expected 2 robots, got %d", len(robots))
+	}
+	if robots[0].Name != "Googlebot" {
+		t.Errorf("first robot = %q, want %q", robots[0].Name, "Googlebot")
 	}
 	if robots[0].Hits != 2 {
 		t.Errorf("Googlebot hits = %d, want 2", robots[0].Hits)
@@ -911,12 +1584,110 @@ func TestStorage_TimeSeriesRange(t *testing.T) {
 		}
 	}
 
-	// TimeSeriesRange returns no error even with empty results
-	// Note: SQLite strftime has issues with Go's time.Time format
-	_, err := s.TimeSeriesRange(ctx, 24*time.Hour, "")
+	series, err := s.TimeSeriesRange(ctx, 24*time.Hour, "", BucketHour)
 	if err != nil {
 		t.Fatalf("TimeSeriesRange() error = %v", err)
 	}
+	var total int64
+	for _, point := range series {
+		total += point.Requests
+	}
+	if total != 3 {
+		t.Errorf("expected 3 requests across buckets, got %d", total)
+	}
+}
+
+func TestStorage_TimeSeriesRange_Buckets(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	base := time.Now().UTC().Truncate(time.Hour).Add(-2 * time.Hour)
+
+	offsets := []time.Duration{1 * time.Minute, 2 * time.Minute, 3 * time.Minute, 3*time.Minute + 30*time.Second, 61 * time.Minute}
+	for _, off := range offsets {
+		req := RequestRecord{Timestamp: base.Add(off), Host: "example.com", Path: "/", Status: 200}
+		if err := s.InsertRequest(ctx, req); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	hourly, err := s.TimeSeriesRange(ctx, 6*time.Hour, "", BucketHour)
+	if err != nil {
+		t.Fatalf("TimeSeriesRange(hour) error = %v", err)
+	}
+	minutely, err := s.TimeSeriesRange(ctx, 6*time.Hour, "", BucketMinute)
+	if err != nil {
+		t.Fatalf("TimeSeriesRange(minute) error = %v", err)
+	}
+	fiveMin, err := s.TimeSeriesRange(ctx, 6*time.Hour, "", BucketFiveMinute)
+	if err != nil {
+		t.Fatalf("TimeSeriesRange(5min) error = %v", err)
+	}
+
+	if len(hourly) != 2 {
+		t.Fatalf("expected 2 hourly buckets, got %d", len(hourly))
+	}
+	if !hourly[0].Bucket.Equal(base) || hourly[0].Requests != 4 {
+		t.Errorf("first hourly bucket = %v with %d requests, want %v with 4", hourly[0].Bucket, hourly[0].Requests, base)
+	}
+	if len(minutely) != 4 {
+		t.Fatalf("expected 4 minute buckets, got %d", len(minutely))
+	}
+	if len(minutely) <= len(hourly) {
+		t.Errorf("minute buckets (%d) should be finer than hourly (%d)", len(minutely), len(hourly))
+	}
+	if !minutely[2].Bucket.Equal(base.Add(3*time.Minute)) || minutely[2].Requests != 2 {
+		t.Errorf("third minute bucket = %v with %d requests, want %v with 2", minutely[2].Bucket, minutely[2].Requests, base.Add(3*time.Minute))
+	}
+	if len(fiveMin) != 2 {
+		t.Errorf("expected 2 five-minute buckets, got %d", len(fiveMin))
+	}
+}
+
+func TestParseBucket(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Bucket
+		wantErr bool
+	}{
+		{"", BucketHour, false},
+		{"minute", BucketMinute, false},
+		{"5MIN", BucketFiveMinute, false},
+		{"day", BucketDay, false},
+		{"auto", BucketAuto, false},
+		{"week", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseBucket(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseBucket(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseBucket(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestBucket_ResolveAuto(t *testing.T) {
+	tests := []struct {
+		dur  time.Duration
+		want Bucket
+	}{
+		{time.Hour, BucketMinute},
+		{6 * time.Hour, BucketFiveMinute},
+		{24 * time.Hour, BucketHour},
+		{30 * 24 * time.Hour, BucketDay},
+	}
+	for _, tt := range tests {
+		if got := BucketAuto.Resolve(tt.dur); got != tt.want {
+			t.Errorf("BucketAuto.Resolve(%v) = %q, want %q", tt.dur, got, tt.want)
+		}
+	}
+	if got := BucketDay.Resolve(time.Hour); got != BucketDay {
+		t.Errorf("explicit bucket should not change, got %q", got)
+	}
 }
 
 func TestStorage_MonthlyHistory(t *testing.T) {
@@ -941,8 +1712,6 @@ func TestStorage_MonthlyHistory(t *testing.T) {
 		}
 	}
 
-	// MonthlyHistory uses strftime which has issues with Go's time.Time format
-	// Test that it returns without error and has the correct structure
 	history, err := s.MonthlyHistory(ctx, 3, "")
 	if err != nil {
 		t.Fatalf("MonthlyHistory() error = %v", err)
@@ -950,6 +1719,115 @@ func TestStorage_MonthlyHistory(t *testing.T) {
 	if len(history.Months) != 3 {
 		t.Errorf("expected 3 months, got %d", len(history.Months))
 	}
+	if history.Totals.Hits == 0 {
+		t.Error("expected current month requests to be counted")
+	}
+}
+
+func TestStorage_MonthlyHistory_Growth(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	insert := func(ts time.Time, n int) {
+		for i := 0; i < n; i++ {
+			req := RequestRecord{Timestamp: ts.Add(time.Duration(i) * time.Minute), Host: "example.com", Path: "/page", Status: 200, IP: "192.168.1.1"}
+			if err := s.InsertRequest(ctx, req); err != nil {
+				t.Fatalf("InsertRequest() error = %v", err)
+			}
+		}
+	}
+	insert(time.Date(2025, time.April, 10, 12, 0, 0, 0, time.UTC), 5) // same month last year
+	insert(time.Date(2026, time.March, 10, 12, 0, 0, 0, time.UTC), 4)
+	insert(time.Date(2026, time.April, 10, 12, 0, 0, 0, time.UTC), 6)
+
+	history, err := s.monthlyHistory(ctx, time.Date(2026, time.May, 15, 0, 0, 0, 0, time.UTC), 3, "")
+	if err != nil {
+		t.Fatalf("monthlyHistory() error = %v", err)
+	}
+	if len(history.Months) != 3 {
+		t.Fatalf("expected 3 months, got %d", len(history.Months))
+	}
+	march, april, may := history.Months[0], history.Months[1], history.Months[2]
+
+	if march.GrowthPercent != nil {
+		t.Errorf("March has no prior month data, got growth %v", *march.GrowthPercent)
+	}
+	if april.GrowthPercent == nil || *april.GrowthPercent != 50 {
+		t.Errorf("April growth = %v, want 50", april.GrowthPercent)
+	}
+	if april.YoYGrowthPercent == nil || *april.YoYGrowthPercent != 20 {
+		t.Errorf("April YoY growth = %v, want 20", april.YoYGrowthPercent)
+	}
+	if may.GrowthPercent == nil || *may.GrowthPercent != -100 {
+		t.Errorf("May growth = %v, want -100", may.GrowthPercent)
+	}
+	if history.Totals.Hits != 10 {
+		t.Errorf("totals hits = %d, want 10 (last year's data is only a baseline)", history.Totals.Hits)
+	}
+}
+
+func TestStorage_WeeklyHistory_YearBoundary(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	at := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, 12, 0, 0, 0, time.UTC)
+	}
+	requests := []struct {
+		ts time.Time
+		ip string
+	}{
+		{at(2025, time.December, 28), "10.0.0.1"}, // Sunday, 2025-W52
+		{at(2025, time.December, 29), "10.0.0.1"}, // Monday, 2026-W01
+		{at(2026, time.January, 1), "10.0.0.2"},   // Thursday, 2026-W01
+		{at(2026, time.January, 4), "10.0.0.3"},   // Sunday, 2026-W01
+		{at(2026, time.January, 5), "10.0.0.1"},   // Monday, 2026-W02
+	}
+	for _, r := range requests {
+		req := RequestRecord{Timestamp: r.ts, Host: "example.com", Path: "/page", Status: 200, Bytes: 100, IP: r.ip, UserAgent: "Mozilla/5.0"}
+		if err := s.InsertRequest(ctx, req); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	history, err := s.weeklyHistory(ctx, at(2026, time.January, 7), 3, "")
+	if err != nil {
+		t.Fatalf("weeklyHistory() error = %v", err)
+	}
+	if len(history.Weeks) != 3 {
+		t.Fatalf("expected 3 weeks, got %d", len(history.Weeks))
+	}
+
+	want := []struct {
+		start   time.Time
+		isoYear int
+		isoWeek int
+		hits    int64
+	}{
+		{time.Date(2025, time.December, 22, 0, 0, 0, 0, time.UTC), 2025, 52, 1},
+		{time.Date(2025, time.December, 29, 0, 0, 0, 0, time.UTC), 2026, 1, 3},
+		{time.Date(2026, time.January, 5, 0, 0, 0, 0, time.UTC), 2026, 2, 1},
+	}
+	for i, w := range want {
+		got := history.Weeks[i]
+		if !got.WeekStart.Equal(w.start) {
+			t.Errorf("week %d start = %v, want %v", i, got.WeekStart, w.start)
+		}
+		if got.ISOYear != w.isoYear || got.ISOWeek != w.isoWeek {
+			t.Errorf("week %d = %d-W%02d, want %d-W%02d", i, got.ISOYear, got.ISOWeek, w.isoYear, w.isoWeek)
+		}
+		if got.Hits != w.hits {
+			t.Errorf("week %d hits = %d, want %d", i, got.Hits, w.hits)
+		}
+	}
+	if history.Weeks[1].UniqueVisitors != 3 || history.Weeks[1].Visits != 3 {
+		t.Errorf("2026-W01 visitors/visits = %d/%d, want 3/3", history.Weeks[1].UniqueVisitors, history.Weeks[1].Visits)
+	}
+	if history.Totals.Hits != 5 || history.Totals.BandwidthBytes != 500 {
+		t.Errorf("totals = %d hits / %d bytes, want 5 / 500", history.Totals.Hits, history.Totals.BandwidthBytes)
+	}
 }
 
 func TestStorage_DailyHistory(t *testing.T) {
@@ -1006,7 +1884,7 @@ func TestStorage_DefaultLimits(t *testing.T) {
 	}
 
 	// Test with negative limit (should use default)
-	visitors, err := s.Visitors(ctx, 24*time.Hour, "", -1)
+	visitors, err := s.Visitors(ctx, 24*time.Hour, "", -1, VisitorSortHits)
 	if err != nil {
 		t.Fatalf("Visitors() error = %v", err)
 	}
@@ -1553,6 +2431,69 @@ func TestNewWithOptions_Defaults(t *testing.T) {
 	}
 }
 
+func TestNewWithOptions_Pragmas(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	s, err := NewWithOptions(dbPath, Options{
+		BusyTimeout: 5 * time.Second,
+		JournalMode: "truncate",
+		Synchronous: "full",
+	})
+	if err != nil {
+		t.Fatalf("NewWithOptions() error = %v", err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+	var busy, sync int
+	var journal string
+	if err := s.db.QueryRowContext(ctx, "PRAGMA busy_timeout").Scan(&busy); err != nil {
+		t.Fatalf("PRAGMA busy_timeout error = %v", err)
+	}
+	if err := s.db.QueryRowContext(ctx, "PRAGMA journal_mode").Scan(&journal); err != nil {
+		t.Fatalf("PRAGMA journal_mode error = %v", err)
+	}
+	if err := s.db.QueryRowContext(ctx, "PRAGMA synchronous").Scan(&sync); err != nil {
+		t.Fatalf("PRAGMA synchronous error = %v", err)
+	}
+	if busy != 5000 {
+		t.Errorf("busy_timeout = %d, want 5000", busy)
+	}
+	if journal != "truncate" {
+		t.Errorf("journal_mode = %q, want truncate", journal)
+	}
+	if sync != 2 { // FULL
+		t.Errorf("synchronous = %d, want 2", sync)
+	}
+}
+
+func TestNewWithOptions_DefaultPragmas(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	var busy int
+	var journal string
+	ctx := context.Background()
+	_ = s.db.QueryRowContext(ctx, "PRAGMA busy_timeout").Scan(&busy)
+	_ = s.db.QueryRowContext(ctx, "PRAGMA journal_mode").Scan(&journal)
+	if busy != 30000 || journal != "wal" {
+		t.Errorf("busy_timeout/journal_mode = %d/%q, want 30000/wal", busy, journal)
+	}
+}
+
+func TestNewWithOptions_InvalidPragmas(t *testing.T) {
+	for _, opts := range []Options{
+		{JournalMode: "sideways"},
+		{Synchronous: "sometimes"},
+		{JournalMode: "WAL); DROP TABLE requests; --"},
+	} {
+		dbPath := filepath.Join(t.TempDir(), "test.db")
+		if s, err := NewWithOptions(dbPath, opts); err == nil {
+			s.Close()
+			t.Errorf("NewWithOptions(%+v) succeeded, want error", opts)
+		}
+	}
+}
+
 func TestStorage_PreparedStatements(t *testing.T) {
 	s, cleanup := setupTestDB(t)
 	defer cleanup()
@@ -1722,29 +2663,165 @@ func TestStorage_Vacuum_AfterDeletes(t *testing.T) {
 	}
 }
 
-func TestStorage_Vacuum_DatabaseStillWorking(t *testing.T) {
+func TestStorage_GetDatabaseStats_Estimate(t *testing.T) {
 	s, cleanup := setupTestDB(t)
 	defer cleanup()
 
 	ctx := context.Background()
 	now := time.Now().UTC()
-
-	// Insert some records
-	for i := 0; i < 10; i++ {
-		req := RequestRecord{
-			Timestamp: now.Add(time.Duration(-i) * time.Second),
-			Host:      "example.com",
-			Path:      "/test",
-			Status:    200,
-			Bytes:     1024,
-		}
-		if err := s.InsertRequest(ctx, req); err != nil {
-			t.Fatalf("InsertRequest() before vacuum error = %v", err)
-		}
+	records := make([]RequestRecord, 500)
+	for i := range records {
+		records[i] = RequestRecord{Timestamp: now.Add(-time.Duration(i) * time.Second), Host: "example.com", Path: "/", Status: 200}
 	}
-
-	// Run vacuum
-	_, err := s.Vacuum(ctx)
+	if err := s.InsertRequestBatch(ctx, records); err != nil {
+		t.Fatalf("InsertRequestBatch() error = %v", err)
+	}
+	// Retention removes the oldest rows, leaving the id range contiguous
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM requests WHERE id <= 100"); err != nil {
+		t.Fatalf("DELETE error = %v", err)
+	}
+	// A few scattered deletes (per-site retention) open gaps in the range
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM requests WHERE id % 50 = 0"); err != nil {
+		t.Fatalf("DELETE error = %v", err)
+	}
+	var exact int64
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM requests").Scan(&exact); err != nil {
+		t.Fatalf("COUNT error = %v", err)
+	}
+
+	// Below the threshold the count stays exact
+	s.countEstimateThreshold = 1000
+	stats, err := s.GetDatabaseStats(ctx)
+	if err != nil {
+		t.Fatalf("GetDatabaseStats() error = %v", err)
+	}
+	if stats.RequestsCount != exact || stats.RequestsCountEstimated {
+		t.Errorf("below threshold: count = %d (estimated %v), want exact %d", stats.RequestsCount, stats.RequestsCountEstimated, exact)
+	}
+
+	s.countEstimateThreshold = 100
+	stats, err = s.GetDatabaseStats(ctx)
+	if err != nil {
+		t.Fatalf("GetDatabaseStats() error = %v", err)
+	}
+	if !stats.RequestsCountEstimated {
+		t.Error("expected an estimated count above the threshold")
+	}
+	if stats.RequestsCount != exact {
+		t.Errorf("estimate = %d, want the exact baseline %d despite gaps in the id range", stats.RequestsCount, exact)
+	}
+
+	// Rows appended since the baseline are added to it
+	if err := s.InsertRequestBatch(ctx, records[:50]); err != nil {
+		t.Fatalf("InsertRequestBatch() error = %v", err)
+	}
+	stats, err = s.GetDatabaseStats(ctx)
+	if err != nil {
+		t.Fatalf("GetDatabaseStats() error = %v", err)
+	}
+	if !stats.RequestsCountEstimated || stats.RequestsCount != exact+50 {
+		t.Errorf("after insert: count = %d (estimated %v), want estimate %d", stats.RequestsCount, stats.RequestsCountEstimated, exact+50)
+	}
+
+	// Deleting rows drops the baseline, so the next count is exact
+	if err := s.Cleanup(ctx, 1); err != nil {
+		t.Fatalf("Cleanup() error = %v", err)
+	}
+	stats, err = s.GetDatabaseStats(ctx)
+	if err != nil {
+		t.Fatalf("GetDatabaseStats() error = %v", err)
+	}
+	if stats.RequestsCountEstimated || stats.RequestsCount != exact+50 {
+		t.Errorf("after cleanup: count = %d (estimated %v), want exact %d", stats.RequestsCount, stats.RequestsCountEstimated, exact+50)
+	}
+}
+
+func BenchmarkRequestsCount(b *testing.B) {
+	dbPath := filepath.Join(b.TempDir(), "bench.db")
+	s, err := New(dbPath)
+	if err != nil {
+		b.Fatalf("New() error = %v", err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+	if _, err := s.db.ExecContext(ctx, `
+WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 200000)
+INSERT INTO requests (ts, host, path, status, bytes, ip, referrer, user_agent, resp_time_ms)
+SELECT datetime('now'), 'example.com', '/', 200, 0, '', '', '', 0 FROM n`); err != nil {
+		b.Fatalf("seed error = %v", err)
+	}
+
+	for _, bc := range []struct {
+		name      string
+		threshold int64
+	}{
+		{"exact", 0},
+		{"estimate", 1},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			s.countEstimateThreshold = bc.threshold
+			for b.Loop() {
+				if _, _, err := s.requestsCount(ctx); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestStorage_Optimize(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	for i := 0; i < 200; i++ {
+		req := RequestRecord{
+			Timestamp: now.Add(time.Duration(-i) * time.Second),
+			Host:      "example.com",
+			Path:      fmt.Sprintf("/page-%d", i%20),
+			Status:    200,
+			Bytes:     1024,
+			IP:        fmt.Sprintf("10.0.0.%d", i%50),
+		}
+		if err := s.InsertRequest(ctx, req); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	if err := s.Optimize(ctx); err != nil {
+		t.Fatalf("Optimize() error = %v", err)
+	}
+	if _, err := s.Summary(ctx, time.Hour, ""); err != nil {
+		t.Errorf("Summary() after optimize error = %v", err)
+	}
+}
+
+func TestStorage_Vacuum_DatabaseStillWorking(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	// Insert some records
+	for i := 0; i < 10; i++ {
+		req := RequestRecord{
+			Timestamp: now.Add(time.Duration(-i) * time.Second),
+			Host:      "example.com",
+			Path:      "/test",
+			Status:    200,
+			Bytes:     1024,
+		}
+		if err := s.InsertRequest(ctx, req); err != nil {
+			t.Fatalf("InsertRequest() before vacuum error = %v", err)
+		}
+	}
+
+	// Run vacuum
+	_, err := s.Vacuum(ctx)
 	if err != nil {
 		t.Fatalf("Vacuum() error = %v", err)
 	}
@@ -2160,17 +3237,60 @@ func TestStorage_ResponseTimePercentiles(t *testing.T) {
 	// P90 should be around 90
 	// P95 should be around 95
 	// P99 should be around 99
-	if stats.ResponseTime.P50 < 45 || stats.ResponseTime.P50 > 55 {
-		t.Errorf("P50 = %f, expected around 50", stats.ResponseTime.P50)
+	if *stats.ResponseTime.P50 < 45 || *stats.ResponseTime.P50 > 55 {
+		t.Errorf("P50 = %f, expected around 50", *stats.ResponseTime.P50)
+	}
+	if *stats.ResponseTime.P90 < 85 || *stats.ResponseTime.P90 > 95 {
+		t.Errorf("P90 = %f, expected around 90", *stats.ResponseTime.P90)
+	}
+	if *stats.ResponseTime.P95 < 92 || *stats.ResponseTime.P95 > 98 {
+		t.Errorf("P95 = %f, expected around 95", *stats.ResponseTime.P95)
+	}
+	if *stats.ResponseTime.P99 < 96 || *stats.ResponseTime.P99 > 100 {
+		t.Errorf("P99 = %f, expected around 99", *stats.ResponseTime.P99)
+	}
+}
+
+func TestStorage_PerformanceStats_MinSamples(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+	s.percentileMinSamples = 20
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	insert := func(n int) {
+		for i := 1; i <= n; i++ {
+			req := RequestRecord{Timestamp: now, Host: "example.com", Path: "/test", Status: 200, ResponseTime: float64(i)}
+			if err := s.InsertRequest(ctx, req); err != nil {
+				t.Fatalf("InsertRequest() error = %v", err)
+			}
+		}
 	}
-	if stats.ResponseTime.P90 < 85 || stats.ResponseTime.P90 > 95 {
-		t.Errorf("P90 = %f, expected around 90", stats.ResponseTime.P90)
+
+	insert(2)
+	stats, err := s.PerformanceStats(ctx, 24*time.Hour, "")
+	if err != nil {
+		t.Fatalf("PerformanceStats() error = %v", err)
 	}
-	if stats.ResponseTime.P95 < 92 || stats.ResponseTime.P95 > 98 {
-		t.Errorf("P95 = %f, expected around 95", stats.ResponseTime.P95)
+	rt := stats.ResponseTime
+	if !rt.LowSample || rt.P50 != nil || rt.P90 != nil || rt.P95 != nil || rt.P99 != nil {
+		t.Errorf("with 2 samples got %+v, want percentiles suppressed", rt)
 	}
-	if stats.ResponseTime.P99 < 96 || stats.ResponseTime.P99 > 100 {
-		t.Errorf("P99 = %f, expected around 99", stats.ResponseTime.P99)
+	if rt.Count != 2 || rt.Min != 1 || rt.Max != 2 || rt.Avg != 1.5 {
+		t.Errorf("with 2 samples min/max/avg/count = %v/%v/%v/%d, want 1/2/1.5/2", rt.Min, rt.Max, rt.Avg, rt.Count)
+	}
+
+	insert(198)
+	stats, err = s.PerformanceStats(ctx, 24*time.Hour, "")
+	if err != nil {
+		t.Fatalf("PerformanceStats() error = %v", err)
+	}
+	rt = stats.ResponseTime
+	if rt.LowSample || rt.P50 == nil || rt.P99 == nil {
+		t.Fatalf("with 200 samples got %+v, want percentiles", rt)
+	}
+	if *rt.P99 < 190 {
+		t.Errorf("P99 = %v, want close to 198", *rt.P99)
 	}
 }
 
@@ -2469,6 +3589,163 @@ func TestStorage_BandwidthStats_ContentTypes(t *testing.T) {
 	}
 }
 
+func TestStorage_TopDownloads(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	// A small page hit often moves more bytes in total than one huge file
+	for i := 0; i < 2000; i++ {
+		rec := RequestRecord{Timestamp: now, Host: "example.com", Path: "/index.html", Status: 200, Bytes: 100_000}
+		if err := s.InsertRequest(ctx, rec); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+	huge := RequestRecord{Timestamp: now, Host: "example.com", Path: "/backup.tar?dl=1", Status: 200, Bytes: 100 << 20}
+	if err := s.InsertRequest(ctx, huge); err != nil {
+		t.Fatalf("InsertRequest() error = %v", err)
+	}
+
+	downloads, err := s.TopDownloads(ctx, time.Hour, "", 10)
+	if err != nil {
+		t.Fatalf("TopDownloads() error = %v", err)
+	}
+	if len(downloads) != 2 {
+		t.Fatalf("TopDownloads() returned %d rows, want 2: %+v", len(downloads), downloads)
+	}
+	want := DownloadStat{Path: "/backup.tar", MaxBytes: 100 << 20, MaxBytesHuman: "100.0 MB", Requests: 1, TotalBytes: 100 << 20}
+	if downloads[0] != want {
+		t.Errorf("TopDownloads()[0] = %+v, want %+v", downloads[0], want)
+	}
+	if downloads[1].Path != "/index.html" || downloads[1].Requests != 2000 || downloads[1].TotalBytes != 200_000_000 {
+		t.Errorf("TopDownloads()[1] = %+v, want /index.html with 2000 requests", downloads[1])
+	}
+}
+
+func TestStorage_BandwidthByCountry(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	records := []RequestRecord{
+		{Timestamp: now, Host: "example.com", Path: "/a", Status: 200, Bytes: 1000, Country: "US"},
+		{Timestamp: now, Host: "example.com", Path: "/b", Status: 200, Bytes: 2000, Country: "US"},
+		{Timestamp: now, Host: "example.com", Path: "/a", Status: 200, Bytes: 1500, Country: "GB"},
+		{Timestamp: now, Host: "example.com", Path: "/a", Status: 200, Bytes: 500},
+		{Timestamp: now, Host: "other.com", Path: "/a", Status: 200, Bytes: 9000, Country: "GB"},
+	}
+	for _, rec := range records {
+		if err := s.InsertRequest(ctx, rec); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	got, err := s.BandwidthByCountry(ctx, time.Hour, "example.com", 10)
+	if err != nil {
+		t.Fatalf("BandwidthByCountry() error = %v", err)
+	}
+	want := []CountryBandwidth{
+		{Country: "US", Bytes: 3000, BytesHuman: "2.9 KB", Requests: 2, Percent: 60},
+		{Country: "GB", Bytes: 1500, BytesHuman: "1.5 KB", Requests: 1, Percent: 30},
+		{Country: "Unknown", Bytes: 500, BytesHuman: "500 B", Requests: 1, Percent: 10},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("BandwidthByCountry() = %+v, want %+v", got, want)
+	}
+
+	all, err := s.BandwidthByCountry(ctx, time.Hour, "", 1)
+	if err != nil {
+		t.Fatalf("BandwidthByCountry() error = %v", err)
+	}
+	if len(all) != 1 || all[0].Country != "GB" || all[0].Bytes != 10500 {
+		t.Errorf("BandwidthByCountry(all, limit 1) = %+v, want GB with 10500 bytes", all)
+	}
+}
+
+func TestStorage_ZeroByteResponses(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	records := []RequestRecord{
+		{Path: "/api/report", Status: 200, Bytes: 0},      // suspicious
+		{Path: "/api/report?id=2", Status: 200, Bytes: 0}, // suspicious, same path
+		{Path: "/api/item", Status: 204, Bytes: 0},        // No Content is legitimate
+		{Path: "/health", Status: 200, Bytes: 0, Method: "HEAD"},
+		{Path: "/missing", Status: 404, Bytes: 0},
+		{Path: "/ok", Status: 200, Bytes: 512},
+	}
+	for _, rec := range records {
+		rec.Timestamp = now
+		rec.Host = "example.com"
+		if err := s.InsertRequest(ctx, rec); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	stats, err := s.ZeroByteResponses(ctx, time.Hour, "", 10)
+	if err != nil {
+		t.Fatalf("ZeroByteResponses() error = %v", err)
+	}
+	if len(stats) != 1 || stats[0] != (ZeroByteStat{Path: "/api/report", Count: 2}) {
+		t.Errorf("ZeroByteResponses() = %+v, want only /api/report with 2", stats)
+	}
+}
+
+func TestStorage_BandwidthStats_StoredContentType(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	records := []struct {
+		path        string
+		contentType string
+	}{
+		{"/api/users", "application/json"}, // extensionless, would be "Page"
+		{"/about", ""},                     // no logged type: extension fallback
+		{"/download.php", "application/pdf"},
+		{"/robots.txt", "text/plain"}, // no label of its own
+	}
+	for _, r := range records {
+		err := s.InsertRequest(ctx, RequestRecord{
+			Timestamp:   now,
+			Host:        "example.com",
+			Path:        r.path,
+			Status:      200,
+			Bytes:       1000,
+			IP:          "1.1.1.1",
+			ContentType: r.contentType,
+		})
+		if err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	stats, err := s.BandwidthStats(ctx, 24*time.Hour, "", 100)
+	if err != nil {
+		t.Fatalf("BandwidthStats() error = %v", err)
+	}
+	got := make(map[string]int64)
+	for _, ct := range stats.ByContentType {
+		got[ct.ContentType] = ct.Requests
+	}
+	want := map[string]int64{"JSON": 1, "Page": 1, "PDF": 1, "text/plain": 1}
+	if len(got) != len(want) {
+		t.Errorf("ByContentType = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s requests = %d, want %d", k, got[k], v)
+		}
+	}
+}
+
 func TestStorage_VisitorSessions(t *testing.T) {
 	s, cleanup := setupTestDB(t)
 	defer cleanup()
@@ -2822,3 +4099,615 @@ func TestStorage_VisitorSessions_SessionsByHour(t *testing.T) {
 		t.Logf("Warning: Sessions exist but none found in hour %d or %d", hour, (hour+23)%24)
 	}
 }
+
+func TestStorage_RunMaintenance_NoOverlap(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	// Simulate a run already in progress
+	s.maintenanceMu.Lock()
+	if _, err := s.RunMaintenance(ctx, 7); !errors.Is(err, ErrMaintenanceRunning) {
+		t.Errorf("RunMaintenance() during run error = %v, want ErrMaintenanceRunning", err)
+	}
+	s.maintenanceMu.Unlock()
+
+	result, err := s.RunMaintenance(ctx, 7)
+	if err != nil {
+		t.Fatalf("RunMaintenance() error = %v", err)
+	}
+	if result.Cleanup == nil {
+		t.Error("expected cleanup result")
+	}
+}
+
+func TestStorage_RequestsByIP(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	requests := []RequestRecord{
+		{Timestamp: now.Add(-10 * time.Minute), Host: "example.com", Path: "/third", Status: 404, IP: "203.0.113.5", UserAgent: "curl/8"},
+		{Timestamp: now.Add(-30 * time.Minute), Host: "example.com", Path: "/first", Status: 200, IP: "203.0.113.5", UserAgent: "curl/8"},
+		{Timestamp: now.Add(-20 * time.Minute), Host: "other.com", Path: "/second", Status: 200, IP: "203.0.113.5", UserAgent: "curl/8"},
+		{Timestamp: now.Add(-15 * time.Minute), Host: "example.com", Path: "/someone-else", Status: 200, IP: "198.51.100.1"},
+		{Timestamp: now.Add(-48 * time.Hour), Host: "example.com", Path: "/too-old", Status: 200, IP: "203.0.113.5"},
+	}
+	for _, req := range requests {
+		if err := s.InsertRequest(ctx, req); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	got, err := s.RequestsByIP(ctx, "203.0.113.5", 24*time.Hour, 10, 0, nil)
+	if err != nil {
+		t.Fatalf("RequestsByIP() error = %v", err)
+	}
+	var paths []string
+	for _, r := range got {
+		paths = append(paths, r.Path)
+	}
+	if fmt.Sprint(paths) != "[/first /second /third]" {
+		t.Errorf("paths = %v, want [/first /second /third] in time order", paths)
+	}
+	if len(got) > 0 && (got[0].UserAgent != "curl/8" || got[0].Timestamp.IsZero()) {
+		t.Errorf("first request missing UA or timestamp: %+v", got[0])
+	}
+
+	// Paging
+	got, err = s.RequestsByIP(ctx, "203.0.113.5", 24*time.Hour, 1, 1, nil)
+	if err != nil {
+		t.Fatalf("RequestsByIP(offset) error = %v", err)
+	}
+	if len(got) != 1 || got[0].Path != "/second" {
+		t.Errorf("page 2 = %+v, want /second", got)
+	}
+
+	// Host restriction
+	got, err = s.RequestsByIP(ctx, "203.0.113.5", 24*time.Hour, 10, 0, []string{"other.com"})
+	if err != nil {
+		t.Fatalf("RequestsByIP(hosts) error = %v", err)
+	}
+	if len(got) != 1 || got[0].Host != "other.com" {
+		t.Errorf("host-restricted results = %+v, want only other.com", got)
+	}
+
+	// Empty (non-nil) host list means no access
+	got, err = s.RequestsByIP(ctx, "203.0.113.5", 24*time.Hour, 10, 0, []string{})
+	if err != nil {
+		t.Fatalf("RequestsByIP(no hosts) error = %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("expected no results with no permitted hosts, got %d", len(got))
+	}
+}
+
+func TestStorage_Methods(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	methods := []string{"GET", "GET", "GET", "POST", "POST", "DELETE", ""}
+	for i, m := range methods {
+		host := "example.com"
+		if i == 0 {
+			host = "other.com"
+		}
+		rec := RequestRecord{Timestamp: now, Host: host, Path: "/", Status: 200, Method: m}
+		if err := s.InsertRequest(ctx, rec); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	stats, err := s.Methods(ctx, time.Hour, "")
+	if err != nil {
+		t.Fatalf("Methods() error = %v", err)
+	}
+	want := []MethodStat{
+		{Method: "GET", Hits: 3, Percent: 42.9},
+		{Method: "POST", Hits: 2, Percent: 28.6},
+		{Method: "DELETE", Hits: 1, Percent: 14.3},
+		{Method: "Unknown", Hits: 1, Percent: 14.3},
+	}
+	if len(stats) != len(want) {
+		t.Fatalf("Methods() = %+v, want %+v", stats, want)
+	}
+	for i := range want {
+		if stats[i] != want[i] {
+			t.Errorf("Methods()[%d] = %+v, want %+v", i, stats[i], want[i])
+		}
+	}
+
+	stats, err = s.Methods(ctx, time.Hour, "example.com")
+	if err != nil {
+		t.Fatalf("Methods() with host error = %v", err)
+	}
+	if len(stats) == 0 || stats[0].Method != "GET" || stats[0].Hits != 2 {
+		t.Errorf("Methods(example.com) = %+v, want GET with 2 hits first", stats)
+	}
+}
+
+func TestStorage_Protocols(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	records := []struct{ proto, tls string }{
+		{"HTTP/2.0", "TLS 1.3"},
+		{"HTTP/2.0", "TLS 1.3"},
+		{"HTTP/3.0", "TLS 1.3"},
+		{"HTTP/1.1", "TLS 1.2"},
+		{"HTTP/1.1", "none"},
+		{"", ""}, // log without the fields
+	}
+	for _, r := range records {
+		rec := RequestRecord{Timestamp: now, Host: "example.com", Path: "/", Status: 200, Protocol: r.proto, TLSVersion: r.tls}
+		if err := s.InsertRequest(ctx, rec); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	stats, err := s.Protocols(ctx, time.Hour, "")
+	if err != nil {
+		t.Fatalf("Protocols() error = %v", err)
+	}
+	counts := func(usage []UsageStat) map[string]int64 {
+		m := make(map[string]int64)
+		for _, u := range usage {
+			m[u.Name] = u.Hits
+		}
+		return m
+	}
+	wantProto := map[string]int64{"HTTP/2.0": 2, "HTTP/1.1": 2, "HTTP/3.0": 1, "Unknown": 1}
+	if got := counts(stats.Protocols); !reflect.DeepEqual(got, wantProto) {
+		t.Errorf("Protocols = %v, want %v", got, wantProto)
+	}
+	wantTLS := map[string]int64{"TLS 1.3": 3, "TLS 1.2": 1, "none": 1, "Unknown": 1}
+	if got := counts(stats.TLSVersions); !reflect.DeepEqual(got, wantTLS) {
+		t.Errorf("TLSVersions = %v, want %v", got, wantTLS)
+	}
+	if stats.TLSVersions[0].Name != "TLS 1.3" || stats.TLSVersions[0].Percent != 50 {
+		t.Errorf("TLSVersions[0] = %+v, want TLS 1.3 at 50%%", stats.TLSVersions[0])
+	}
+}
+
+func TestHumanizeBytes(t *testing.T) {
+	tests := []struct {
+		in   int64
+		want string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KB"},
+		{1536, "1.5 KB"},
+		{5 << 20, "5.0 MB"},
+		{3*(1<<30) + 512<<20, "3.5 GB"},
+		{2 << 40, "2.0 TB"},
+	}
+	for _, tt := range tests {
+		if got := humanizeBytes(tt.in); got != tt.want {
+			t.Errorf("humanizeBytes(%d) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestStorage_BandwidthHumanFields(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	req := RequestRecord{Timestamp: time.Now().UTC(), Host: "example.com", Path: "/", Status: 200, Bytes: 2048, IP: "10.0.0.1"}
+	if err := s.InsertRequest(ctx, req); err != nil {
+		t.Fatalf("InsertRequest() error = %v", err)
+	}
+
+	summary, err := s.Summary(ctx, time.Hour, "")
+	if err != nil {
+		t.Fatalf("Summary() error = %v", err)
+	}
+	if summary.BandwidthBytes != 2048 || summary.BandwidthHuman != "2.0 KB" {
+		t.Errorf("Summary bandwidth = %d/%q, want 2048/\"2.0 KB\"", summary.BandwidthBytes, summary.BandwidthHuman)
+	}
+	if summary.Traffic.Viewed.BandwidthHuman != "2.0 KB" || summary.Traffic.NotViewed.BandwidthHuman != "0 B" {
+		t.Errorf("Traffic bandwidth human = %q/%q", summary.Traffic.Viewed.BandwidthHuman, summary.Traffic.NotViewed.BandwidthHuman)
+	}
+
+	visitors, err := s.Visitors(ctx, time.Hour, "", 10, VisitorSortHits)
+	if err != nil {
+		t.Fatalf("Visitors() error = %v", err)
+	}
+	if len(visitors) != 1 || visitors[0].BandwidthHuman != "2.0 KB" {
+		t.Errorf("Visitors() = %+v, want one visitor with 2.0 KB", visitors)
+	}
+}
+
+func TestStorage_KnownHosts(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	records := []RequestRecord{
+		{Timestamp: now.Add(-time.Minute), Host: "a.com", Path: "/", Status: 200},
+		{Timestamp: now.Add(-time.Minute), Host: "b.com", Path: "/", Status: 200},
+		{Timestamp: now.Add(-2 * time.Minute), Host: "b.com", Path: "/x", Status: 404},
+		{Timestamp: now.Add(-time.Minute), Host: "c.com", Path: "/", Status: 200},
+		{Timestamp: now.Add(-48 * time.Hour), Host: "old.com", Path: "/", Status: 200},
+	}
+	for _, rec := range records {
+		if err := s.InsertRequest(ctx, rec); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	got, err := s.KnownHosts(ctx, 24*time.Hour, nil)
+	if err != nil {
+		t.Fatalf("KnownHosts() error = %v", err)
+	}
+	want := []HostStat{{Host: "b.com", Count: 2}, {Host: "a.com", Count: 1}, {Host: "c.com", Count: 1}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("KnownHosts() = %+v, want %+v", got, want)
+	}
+
+	got, err = s.KnownHosts(ctx, 24*time.Hour, []string{"c.com", "old.com"})
+	if err != nil {
+		t.Fatalf("KnownHosts() error = %v", err)
+	}
+	if len(got) != 1 || got[0].Host != "c.com" {
+		t.Errorf("KnownHosts(filtered) = %+v, want only c.com", got)
+	}
+
+	got, err = s.KnownHosts(ctx, 24*time.Hour, []string{})
+	if err != nil || len(got) != 0 {
+		t.Errorf("KnownHosts(no permitted hosts) = %+v, %v; want empty", got, err)
+	}
+}
+
+func TestStorage_HostActivity(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+	records := []RequestRecord{
+		{Timestamp: now.Add(-72 * time.Hour), Host: "old.com", Path: "/", Status: 200},
+		{Timestamp: now.Add(-48 * time.Hour), Host: "old.com", Path: "/", Status: 200},
+		{Timestamp: now.Add(-5 * time.Hour), Host: "new.com", Path: "/", Status: 200},
+		{Timestamp: now.Add(-time.Hour), Host: "new.com", Path: "/a", Status: 200},
+		{Timestamp: now.Add(-3 * time.Hour), Host: "new.com", Path: "/b", Status: 404},
+	}
+	for _, rec := range records {
+		if err := s.InsertRequest(ctx, rec); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	got, err := s.HostActivity(ctx, nil)
+	if err != nil {
+		t.Fatalf("HostActivity() error = %v", err)
+	}
+	want := []HostActivityStat{
+		{Host: "new.com", FirstSeen: now.Add(-5 * time.Hour), LastSeen: now.Add(-time.Hour), Requests: 3},
+		{Host: "old.com", FirstSeen: now.Add(-72 * time.Hour), LastSeen: now.Add(-48 * time.Hour), Requests: 2},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("HostActivity() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i].Host != want[i].Host || got[i].Requests != want[i].Requests ||
+			!got[i].FirstSeen.Equal(want[i].FirstSeen) || !got[i].LastSeen.Equal(want[i].LastSeen) {
+			t.Errorf("HostActivity()[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	got, err = s.HostActivity(ctx, []string{"old.com"})
+	if err != nil || len(got) != 1 || got[0].Host != "old.com" {
+		t.Errorf("HostActivity(filtered) = %+v, %v; want only old.com", got, err)
+	}
+	got, err = s.HostActivity(ctx, []string{})
+	if err != nil || len(got) != 0 {
+		t.Errorf("HostActivity(no permitted hosts) = %+v, %v; want empty", got, err)
+	}
+}
+
+func TestExportRequests_StopsOnCancel(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	now := time.Now().UTC()
+	records := make([]RequestRecord, 5000)
+	for i := range records {
+		records[i] = RequestRecord{Timestamp: now.Add(-time.Duration(i) * time.Second), Host: "example.com", Path: fmt.Sprintf("/p/%d", i), Status: 200}
+	}
+	if err := s.InsertRequestBatch(context.Background(), records); err != nil {
+		t.Fatalf("InsertRequestBatch() error = %v", err)
+	}
+
+	// Cancel after the first batch, as a client disconnect would
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	exported := 0
+	err := s.ExportRequests(ctx, 24*time.Hour, "", 100, func(batch []ExportRequest) error {
+		exported += len(batch)
+		cancel()
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("ExportRequests() error = %v, want context.Canceled", err)
+	}
+	if exported != 100 {
+		t.Errorf("exported %d rows after cancel, want only the first batch of 100", exported)
+	}
+}
+
+func TestExportRequestsSince(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	// Timestamps run backwards so id order differs from time order
+	records := make([]RequestRecord, 6)
+	for i := range records {
+		records[i] = RequestRecord{Timestamp: now.Add(-time.Duration(i) * time.Hour), Host: "example.com", Path: fmt.Sprintf("/p/%d", i), Status: 200}
+	}
+	records[4].Host = "other.com"
+	if err := s.InsertRequestBatch(ctx, records); err != nil {
+		t.Fatalf("InsertRequestBatch() error = %v", err)
+	}
+
+	var all []ExportRequest
+	if err := s.ExportRequestsSince(ctx, 0, "", 0, func(batch []ExportRequest) error {
+		all = append(all, batch...)
+		return nil
+	}); err != nil {
+		t.Fatalf("ExportRequestsSince(0) error = %v", err)
+	}
+	if len(all) != 6 {
+		t.Fatalf("ExportRequestsSince(0) returned %d rows, want 6", len(all))
+	}
+
+	collect := func(sinceID int64, host string, limit int) []string {
+		t.Helper()
+		var paths []string
+		err := s.ExportRequestsSince(ctx, sinceID, host, limit, func(batch []ExportRequest) error {
+			for _, r := range batch {
+				if r.ID <= sinceID {
+					t.Errorf("row id %d is not after %d", r.ID, sinceID)
+				}
+				paths = append(paths, r.Path)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("ExportRequestsSince(%d) error = %v", sinceID, err)
+		}
+		return paths
+	}
+
+	lastSeen := all[2].ID
+	if got := collect(lastSeen, "", 0); fmt.Sprint(got) != "[/p/3 /p/4 /p/5]" {
+		t.Errorf("rows after id %d = %v, want [/p/3 /p/4 /p/5]", lastSeen, got)
+	}
+	if got := collect(lastSeen, "", 2); fmt.Sprint(got) != "[/p/3 /p/4]" {
+		t.Errorf("rows after id %d with limit 2 = %v, want [/p/3 /p/4]", lastSeen, got)
+	}
+	if got := collect(lastSeen, "example.com", 0); fmt.Sprint(got) != "[/p/3 /p/5]" {
+		t.Errorf("example.com rows after id %d = %v, want [/p/3 /p/5]", lastSeen, got)
+	}
+	if got := collect(all[5].ID, "", 0); len(got) != 0 {
+		t.Errorf("rows after the newest id = %v, want none", got)
+	}
+}
+
+func TestRollupTotalsByHost_FoldsOtherHosts(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	hits := map[string]int{"a.example": 3, "b.example": 2, "c.example": 1, "d.example": 1}
+	for host, n := range hits {
+		for i := 0; i < n; i++ {
+			status := 200
+			if host == "c.example" {
+				status = 500
+			}
+			if err := s.InsertRequest(ctx, RequestRecord{Timestamp: now, Host: host, Path: "/", Status: status, Bytes: 10}); err != nil {
+				t.Fatalf("InsertRequest() error = %v", err)
+			}
+		}
+	}
+
+	totals, err := s.RollupTotalsByHost(ctx, 2)
+	if err != nil {
+		t.Fatalf("RollupTotalsByHost() error = %v", err)
+	}
+	if len(totals) != 3 {
+		t.Fatalf("got %d entries, want 2 hosts + other: %+v", len(totals), totals)
+	}
+	if totals[0].Host != "a.example" || totals[0].Requests != 3 || totals[0].Bytes != 30 {
+		t.Errorf("first = %+v, want a.example with 3 requests, 30 bytes", totals[0])
+	}
+	if totals[1].Host != "b.example" {
+		t.Errorf("second host = %q, want b.example", totals[1].Host)
+	}
+	other := totals[2]
+	if other.Host != "other" || other.Requests != 2 || other.Status5xx != 1 || other.Status2xx != 1 {
+		t.Errorf("other = %+v, want 2 requests (1 2xx, 1 5xx)", other)
+	}
+}
+
+func TestStorage_GrandTotals(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	records := []RequestRecord{
+		{Timestamp: now.Add(-time.Minute), Host: "a.com", Path: "/", Status: 200, Bytes: 100, IP: "1.1.1.1", UserAgent: "ua"},
+		{Timestamp: now.Add(-time.Minute), Host: "b.com", Path: "/", Status: 200, Bytes: 200, IP: "1.1.1.1", UserAgent: "ua"},
+		{Timestamp: now.Add(-time.Minute), Host: "b.com", Path: "/x", Status: 404, Bytes: 50, IP: "2.2.2.2", UserAgent: "ua"},
+		{Timestamp: now.Add(-time.Minute), Host: "c.com", Path: "/", Status: 500, Bytes: 10, IP: "3.3.3.3", UserAgent: "ua"},
+		{Timestamp: now.Add(-48 * time.Hour), Host: "a.com", Path: "/", Status: 200, Bytes: 999, IP: "4.4.4.4", UserAgent: "ua"},
+	}
+	for _, rec := range records {
+		if err := s.InsertRequest(ctx, rec); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	got, err := s.GrandTotals(ctx, 24*time.Hour, []string{"a.com", "b.com"})
+	if err != nil {
+		t.Fatalf("GrandTotals() error = %v", err)
+	}
+	want := GrandTotals{Requests: 3, UniqueVisitors: 2, Bytes: 350, ErrorRate: 33.33}
+	if got != want {
+		t.Errorf("GrandTotals(a.com, b.com) = %+v, want %+v", got, want)
+	}
+
+	got, err = s.GrandTotals(ctx, 24*time.Hour, nil)
+	if err != nil {
+		t.Fatalf("GrandTotals() error = %v", err)
+	}
+	want = GrandTotals{Requests: 4, UniqueVisitors: 3, Bytes: 360, ErrorRate: 50}
+	if got != want {
+		t.Errorf("GrandTotals(all) = %+v, want %+v", got, want)
+	}
+
+	got, err = s.GrandTotals(ctx, 24*time.Hour, []string{})
+	if err != nil || got != (GrandTotals{}) {
+		t.Errorf("GrandTotals(no permitted hosts) = %+v, %v; want zero", got, err)
+	}
+}
+
+func TestStorage_CompactRawData(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	old := now.AddDate(0, 0, -40)
+	for i, status := range []int{200, 200, 500} {
+		if err := s.InsertRequest(ctx, RequestRecord{Timestamp: old.Add(time.Duration(i) * time.Minute), Host: "example.com", Path: "/old", Status: status, Bytes: 100, IP: "10.0.0.1"}); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+	if err := s.InsertRequest(ctx, RequestRecord{Timestamp: now.Add(-time.Hour), Host: "example.com", Path: "/new", Status: 404, Bytes: 50, IP: "10.0.0.2"}); err != nil {
+		t.Fatalf("InsertRequest() error = %v", err)
+	}
+
+	deleted, err := s.compactRawData(ctx, now, 30)
+	if err != nil {
+		t.Fatalf("compactRawData() error = %v", err)
+	}
+	if deleted != 3 {
+		t.Errorf("deleted = %d, want 3", deleted)
+	}
+
+	var raw int64
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM requests").Scan(&raw); err != nil {
+		t.Fatalf("count requests: %v", err)
+	}
+	if raw != 1 {
+		t.Errorf("raw rows = %d, want 1", raw)
+	}
+
+	// The summary still reports the compacted period's totals from the rollups
+	summary, err := s.Summary(ctx, 60*24*time.Hour, "example.com")
+	if err != nil {
+		t.Fatalf("Summary() error = %v", err)
+	}
+	if summary.TotalRequests != 4 || summary.Compacted != 3 {
+		t.Errorf("total/compacted = %d/%d, want 4/3", summary.TotalRequests, summary.Compacted)
+	}
+	if summary.Status2xx != 2 || summary.Status4xx != 1 || summary.Status5xx != 1 {
+		t.Errorf("2xx/4xx/5xx = %d/%d/%d, want 2/1/1", summary.Status2xx, summary.Status4xx, summary.Status5xx)
+	}
+	if summary.BandwidthBytes != 350 {
+		t.Errorf("bandwidth = %d, want 350", summary.BandwidthBytes)
+	}
+
+	// Ranges that end after the compaction cutoff only read raw rows
+	summary, err = s.Summary(ctx, 7*24*time.Hour, "example.com")
+	if err != nil {
+		t.Fatalf("Summary() error = %v", err)
+	}
+	if summary.TotalRequests != 1 || summary.Compacted != 0 {
+		t.Errorf("7d total/compacted = %d/%d, want 1/0", summary.TotalRequests, summary.Compacted)
+	}
+
+	// Rollups carry no internal flag, so filtered summaries skip them
+	summary, err = s.SummaryFiltered(ctx, 60*24*time.Hour, StatsFilter{Host: "example.com", ExcludeInternal: true})
+	if err != nil {
+		t.Fatalf("SummaryFiltered() error = %v", err)
+	}
+	if summary.TotalRequests != 1 || summary.Compacted != 0 {
+		t.Errorf("filtered total/compacted = %d/%d, want 1/0", summary.TotalRequests, summary.Compacted)
+	}
+
+	// Disabled compaction deletes nothing
+	if deleted, err := s.compactRawData(ctx, now.AddDate(1, 0, 0), 0); err != nil || deleted != 0 {
+		t.Errorf("compactRawData(0) = %d, %v; want 0, nil", deleted, err)
+	}
+}
+
+func TestStorage_SampleWeightedReports(t *testing.T) {
+	s, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC().Add(-time.Minute)
+	// Each stored row stands for 3 requests, as with INGEST_SAMPLE_RATE=3
+	for _, path := range []string{"/a", "/a", "/b"} {
+		req := RequestRecord{Timestamp: now, Host: "example.com", Path: path, Status: 200, Bytes: 100, IP: "10.0.0.1", Browser: "Firefox", Country: "DE", SampleWeight: 3}
+		if err := s.InsertRequest(ctx, req); err != nil {
+			t.Fatalf("InsertRequest() error = %v", err)
+		}
+	}
+
+	summary, err := s.Summary(ctx, time.Hour, "")
+	if err != nil {
+		t.Fatalf("Summary() error = %v", err)
+	}
+	if len(summary.TopPaths) == 0 || summary.TopPaths[0].Path != "/a" || summary.TopPaths[0].Count != 6 {
+		t.Errorf("top paths = %+v, want /a with 6", summary.TopPaths)
+	}
+
+	browsers, err := s.Browsers(ctx, time.Hour, "", 10)
+	if err != nil {
+		t.Fatalf("Browsers() error = %v", err)
+	}
+	if len(browsers) != 1 || browsers[0].Hits != 9 {
+		t.Errorf("browsers = %+v, want Firefox with 9 hits", browsers)
+	}
+
+	geo, err := s.Geo(ctx, time.Hour, "")
+	if err != nil {
+		t.Fatalf("Geo() error = %v", err)
+	}
+	if len(geo) != 1 || geo[0].Count != 9 {
+		t.Errorf("geo = %+v, want DE with 9", geo)
+	}
+
+	bw, err := s.BandwidthStats(ctx, time.Hour, "", 10)
+	if err != nil {
+		t.Fatalf("BandwidthStats() error = %v", err)
+	}
+	if bw.TotalBytes != 900 || len(bw.ByHost) != 1 || bw.ByHost[0].Requests != 9 {
+		t.Errorf("bandwidth total = %d, by host = %+v; want 900 bytes over 9 requests", bw.TotalBytes, bw.ByHost)
+	}
+
+	history, err := s.MonthlyHistory(ctx, 1, "")
+	if err != nil {
+		t.Fatalf("MonthlyHistory() error = %v", err)
+	}
+	if history.Totals.Hits != 9 || history.Totals.BandwidthBytes != 900 {
+		t.Errorf("history totals = %d hits, %d bytes; want 9, 900", history.Totals.Hits, history.Totals.BandwidthBytes)
+	}
+}
@@ -21,9 +21,27 @@ type RequestRecord struct {
 	OS             string
 	OSVersion      string
 	DeviceType     string
+	DeviceBrand    string // e.g. "Samsung"; may be empty
+	DeviceModel    string // e.g. "Galaxy S23"; may be empty
 	IsBot          bool
 	BotName        string
 	BotIntent      string
+	ContentType    string // Response media type, e.g. "application/json"; may be empty
+	Method         string // HTTP method, upper-case; may be empty
+	Protocol       string // e.g. "HTTP/2.0"; may be empty
+	TLSVersion     string // e.g. "TLS 1.3", or "none" for plain HTTP; may be empty
+	CacheStatus    string // HIT, MISS or BYPASS from an upstream cache; may be empty
+	Language       string // First Accept-Language tag, e.g. "en-US"; may be empty
+	Internal       bool   // Client IP is inside INTERNAL_CIDRS
+	// DedupHash, when set, identifies the request's content; a second
+	// record with the same hash is silently skipped.
+	DedupHash string
+	// SampleWeight is how many real requests this record stands for when
+	// ingest sampling is enabled. Zero is treated as 1.
+	SampleWeight int
+	// BotVerification is "verified" or "unverified" when a bot's client IP
+	// was checked against its published ranges; empty otherwise.
+	BotVerification string
 }
 
 // Summary represents aggregated statistics for a time period.
@@ -33,10 +51,13 @@ type Summary struct {
 	Status3xx       int64            `json:"status_3xx"`
 	Status4xx       int64            `json:"status_4xx"`
 	Status5xx       int64            `json:"status_5xx"`
+	StatusOther     int64            `json:"status_other"` // 0 (dropped connection), 1xx or codes above 599
 	BandwidthBytes  int64            `json:"bandwidth_bytes"`
+	BandwidthHuman  string           `json:"bandwidth_human"`
+	Compacted       int64            `json:"compacted_requests,omitempty"` // Requests counted from rollups of compacted periods
 	UniqueVisitors  int64            `json:"unique_visitors"`
 	Visits          int64            `json:"visits"`
-	AvgResponseTime float64          `json:"avg_response_time_ms"`
+	AvgResponseTime Millis           `json:"avg_response_time_ms"`
 	Traffic         TrafficSummary   `json:"traffic"`
 	Bots            BotStats         `json:"bots"`
 	TopPaths        []PathStat       `json:"top_paths"`
@@ -53,9 +74,10 @@ type TrafficSummary struct {
 
 // TrafficBreakdown contains page/hit/bandwidth counts.
 type TrafficBreakdown struct {
-	Pages          int64 `json:"pages"`
-	Hits           int64 `json:"hits"`
-	BandwidthBytes int64 `json:"bandwidth_bytes"`
+	Pages          int64  `json:"pages"`
+	Hits           int64  `json:"hits"`
+	BandwidthBytes int64  `json:"bandwidth_bytes"`
+	BandwidthHuman string `json:"bandwidth_human"`
 }
 
 // BotIntentStats holds bot statistics by intent category.
@@ -68,6 +90,7 @@ type BotIntentStats struct {
 type BotStats struct {
 	TotalHits      int64                     `json:"total_hits"`
 	BandwidthBytes int64                     `json:"bandwidth_bytes"`
+	BandwidthHuman string                    `json:"bandwidth_human"`
 	ByIntent       map[string]BotIntentStats `json:"by_intent"`
 }
 
@@ -79,6 +102,11 @@ type MonthlyStat struct {
 	Pages          int64     `json:"pages"`
 	Hits           int64     `json:"hits"`
 	BandwidthBytes int64     `json:"bandwidth_bytes"`
+	BandwidthHuman string    `json:"bandwidth_human"`
+	// Hit growth versus the previous month and the same month last year.
+	// Nil when the comparison month has no data.
+	GrowthPercent    *float64 `json:"growth_percent,omitempty"`
+	YoYGrowthPercent *float64 `json:"yoy_growth_percent,omitempty"`
 }
 
 // MonthlyHistory contains monthly statistics over a time range.
@@ -87,6 +115,25 @@ type MonthlyHistory struct {
 	Totals MonthlyStat   `json:"totals"`
 }
 
+// WeeklyStat represents statistics for a single ISO week.
+type WeeklyStat struct {
+	WeekStart      time.Time `json:"week_start"` // Monday, 00:00 UTC
+	ISOYear        int       `json:"iso_year"`
+	ISOWeek        int       `json:"iso_week"`
+	UniqueVisitors int64     `json:"unique_visitors"`
+	Visits         int64     `json:"visits"`
+	Pages          int64     `json:"pages"`
+	Hits           int64     `json:"hits"`
+	BandwidthBytes int64     `json:"bandwidth_bytes"`
+	BandwidthHuman string    `json:"bandwidth_human"`
+}
+
+// WeeklyHistory contains weekly statistics over a time range.
+type WeeklyHistory struct {
+	Weeks  []WeeklyStat `json:"weeks"`
+	Totals WeeklyStat   `json:"totals"`
+}
+
 // DayStat represents statistics for a single day.
 type DayStat struct {
 	Date           time.Time `json:"date"`
@@ -94,6 +141,7 @@ type DayStat struct {
 	Pages          int64     `json:"pages"`
 	Hits           int64     `json:"hits"`
 	BandwidthBytes int64     `json:"bandwidth_bytes"`
+	BandwidthHuman string    `json:"bandwidth_human"`
 }
 
 // DailyHistory contains daily statistics for a month.
@@ -105,13 +153,14 @@ type DailyHistory struct {
 
 // TimeSeriesStat represents statistics for a time bucket.
 type TimeSeriesStat struct {
-	Bucket     time.Time `json:"bucket"`
-	Requests   int64     `json:"requests"`
-	Bytes      int64     `json:"bytes"`
-	Status2xx  int64     `json:"status_2xx"`
-	Status4xx  int64     `json:"status_4xx"`
-	Status5xx  int64     `json:"status_5xx"`
-	AvgLatency float64   `json:"avg_latency_ms"`
+	Bucket      time.Time `json:"bucket"`
+	Requests    int64     `json:"requests"`
+	Bytes       int64     `json:"bytes"`
+	Status2xx   int64     `json:"status_2xx"`
+	Status4xx   int64     `json:"status_4xx"`
+	Status5xx   int64     `json:"status_5xx"`
+	StatusOther int64     `json:"status_other"` // 0, 1xx or codes above 599, as in Summary
+	AvgLatency  Millis    `json:"avg_latency_ms"`
 }
 
 // PathStat represents request count for a path.
@@ -120,12 +169,37 @@ type PathStat struct {
 	Count int64  `json:"count"`
 }
 
+// PathGroupStat represents traffic under a path prefix such as "/blog".
+type PathGroupStat struct {
+	Group string `json:"group"`
+	Hits  int64  `json:"hits"`
+	Bytes int64  `json:"bytes"`
+	Paths int64  `json:"paths"` // Distinct URLs (including query strings) in the group
+}
+
 // HostStat represents request count for a host.
 type HostStat struct {
 	Host  string `json:"host"`
 	Count int64  `json:"count"`
 }
 
+// HostActivityStat records when a host first and last received traffic.
+type HostActivityStat struct {
+	Host      string    `json:"host"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Requests  int64     `json:"requests"`
+}
+
+// GrandTotals combines traffic across several hosts. ErrorRate is the
+// percentage of requests with a 4xx or 5xx status.
+type GrandTotals struct {
+	Requests       int64   `json:"requests"`
+	UniqueVisitors int64   `json:"unique_visitors"`
+	Bytes          int64   `json:"bytes"`
+	ErrorRate      float64 `json:"error_rate"`
+}
+
 // GeoStat represents request count for a geographic location.
 type GeoStat struct {
 	Country string `json:"country"`
@@ -147,6 +221,7 @@ type VisitorStat struct {
 	Pages          int64     `json:"pages"`
 	Hits           int64     `json:"hits"`
 	BandwidthBytes int64     `json:"bandwidth_bytes"`
+	BandwidthHuman string    `json:"bandwidth_human"`
 	LastVisit      time.Time `json:"last_visit"`
 	Country        string    `json:"country"`
 }
@@ -159,6 +234,21 @@ type BrowserStat struct {
 	Percent float64 `json:"percent"`
 }
 
+// DeviceStat represents usage of one device model.
+type DeviceStat struct {
+	Brand   string  `json:"brand"`
+	Model   string  `json:"model"`
+	Hits    int64   `json:"hits"`
+	Percent float64 `json:"percent"`
+}
+
+// LanguageStat represents how many requests preferred one language tag.
+type LanguageStat struct {
+	Language string  `json:"language"`
+	Hits     int64   `json:"hits"`
+	Percent  float64 `json:"percent"`
+}
+
 // OSStat represents operating system usage statistics.
 type OSStat struct {
 	OS      string  `json:"os"`
@@ -167,15 +257,75 @@ type OSStat struct {
 	Percent float64 `json:"percent"`
 }
 
+// UserAgentStat represents request counts for one raw User-Agent string.
+type UserAgentStat struct {
+	UserAgent string  `json:"user_agent"`
+	Hits      int64   `json:"hits"`
+	Percent   float64 `json:"percent"`
+	IsBot     bool    `json:"is_bot"`
+	BotName   string  `json:"bot_name,omitempty"`
+}
+
+// UnclassifiedUserAgent is a raw User-Agent that the useragent package could
+// not map to a browser and/or OS.
+type UnclassifiedUserAgent struct {
+	UserAgent      string `json:"user_agent"`
+	Hits           int64  `json:"hits"`
+	UnknownBrowser bool   `json:"unknown_browser"`
+	UnknownOS      bool   `json:"unknown_os"`
+}
+
+// MethodStat represents request counts for one HTTP method.
+type MethodStat struct {
+	Method  string  `json:"method"`
+	Hits    int64   `json:"hits"`
+	Percent float64 `json:"percent"`
+}
+
+// UsageStat is the request count for one value of a dimension.
+type UsageStat struct {
+	Name    string  `json:"name"`
+	Hits    int64   `json:"hits"`
+	Percent float64 `json:"percent"`
+}
+
+// ProtocolStats breaks requests down by HTTP protocol and TLS version.
+type ProtocolStats struct {
+	Protocols   []UsageStat `json:"protocols"`
+	TLSVersions []UsageStat `json:"tls_versions"`
+}
+
+// CacheStats summarizes upstream cache effectiveness. HitRatio is
+// Hits / (Hits + Misses); bypassed requests were never cacheable and are
+// left out. Requests without a cache status are counted in Unknown.
+type CacheStats struct {
+	Hits     int64   `json:"hits"`
+	Misses   int64   `json:"misses"`
+	Bypasses int64   `json:"bypasses"`
+	Other    int64   `json:"other"`
+	Unknown  int64   `json:"unknown"`
+	HitRatio float64 `json:"hit_ratio"`
+}
+
 // RobotStat represents bot/spider statistics.
 type RobotStat struct {
 	Name           string    `json:"name"`
 	Intent         string    `json:"intent"`
 	Hits           int64     `json:"hits"`
 	BandwidthBytes int64     `json:"bandwidth_bytes"`
+	BandwidthHuman string    `json:"bandwidth_human"`
 	LastVisit      time.Time `json:"last_visit"`
 }
 
+// BotVerificationStat compares, for one bot, requests whose client IP was
+// inside the bot's published ranges against those that only claimed to be it.
+type BotVerificationStat struct {
+	Name           string  `json:"name"`
+	Verified       int64   `json:"verified"`
+	Unverified     int64   `json:"unverified"`
+	SpoofedPercent float64 `json:"spoofed_percent"`
+}
+
 // ReferrerStat represents referrer statistics.
 type ReferrerStat struct {
 	Referrer string `json:"referrer"`
@@ -184,6 +334,31 @@ type ReferrerStat struct {
 	Hits     int64  `json:"hits"`
 }
 
+// ErrorIPStat represents a client IP ranked by its error responses.
+type ErrorIPStat struct {
+	IP           string           `json:"ip"`
+	Errors       int64            `json:"errors"`
+	ClientErrors int64            `json:"client_errors"` // 4xx responses
+	ServerErrors int64            `json:"server_errors"` // 5xx responses
+	LastSeen     time.Time        `json:"last_seen"`
+	TopPaths     []ErrorPathCount `json:"top_paths"`
+}
+
+// ErrorPathCount represents how often a path returned an error.
+type ErrorPathCount struct {
+	Path  string `json:"path"`
+	Count int64  `json:"count"`
+}
+
+// ScanPathStat represents a suspicious path that returned 404, as probed by
+// vulnerability scanners.
+type ScanPathStat struct {
+	Path        string    `json:"path"`
+	Hits        int64     `json:"hits"`
+	DistinctIPs int64     `json:"distinct_ips"`
+	LastSeen    time.Time `json:"last_seen"`
+}
+
 // VisitorSession represents a reconstructed visitor session.
 // Sessions are grouped by IP + User Agent and separated by 30-minute gaps.
 type VisitorSession struct {
@@ -198,6 +373,7 @@ type VisitorSession struct {
 	PageViews      int64     `json:"page_views"`
 	Hits           int64     `json:"hits"`
 	BandwidthBytes int64     `json:"bandwidth_bytes"`
+	BandwidthHuman string    `json:"bandwidth_human"`
 	EntryPage      string    `json:"entry_page"`
 	ExitPage       string    `json:"exit_page"`
 	IsBounce       bool      `json:"is_bounce"`
@@ -239,7 +415,7 @@ type RecentRequest struct {
 	IP             string    `json:"ip"`
 	Referrer       string    `json:"referrer"`
 	UserAgent      string    `json:"user_agent"`
-	ResponseTime   float64   `json:"response_time_ms"`
+	ResponseTime   Millis    `json:"response_time_ms"`
 	Country        string    `json:"country"`
 	Region         string    `json:"region"`
 	City           string    `json:"city"`
@@ -267,6 +443,7 @@ type ImportErrorStats struct {
 	LastError   string    `json:"last_error"`
 	LastErrorAt time.Time `json:"last_error_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
+	Quarantined bool      `json:"quarantined"` // Ingest stopped after repeated parse failures
 }
 
 // Session represents an authentication session.
@@ -283,6 +460,39 @@ type DatabaseStats struct {
 	RollupsHourlyCount  int64
 	RollupsDailyCount   int64
 	ImportProgressCount int64
+	// RequestsCountEstimated is set when RequestsCount is a rowid-range
+	// estimate rather than an exact count.
+	RequestsCountEstimated bool
+}
+
+// GrowthEstimate describes how fast the database is growing. LastHour and
+// LastDay count requests in the last complete hour and 24 complete hours;
+// RowsPerDay extrapolates LastDay when fewer than 24 hours have data.
+type GrowthEstimate struct {
+	LastHour       int64   `json:"last_hour"`
+	LastDay        int64   `json:"last_day"`
+	HoursObserved  int     `json:"hours_observed"`
+	RowsPerDay     float64 `json:"rows_per_day"`
+	RequestsCount  int64   `json:"requests_count"`
+	CountEstimated bool    `json:"requests_count_estimated,omitempty"`
+	DBSizeBytes    int64   `json:"db_size_bytes"`
+	AvgBytesPerRow float64 `json:"avg_bytes_per_row"`
+	BytesPerDay    int64   `json:"bytes_per_day"`
+}
+
+// SLOStat is a service level report over a time window. ErrorBudget is the
+// number of bad requests the objective allows for Total; the remaining
+// percentage goes negative once more than that were bad.
+type SLOStat struct {
+	Total                       int64   `json:"total"`
+	Good                        int64   `json:"good"`
+	Bad                         int64   `json:"bad"`
+	SuccessPercent              float64 `json:"success_percent"`
+	ObjectivePercent            float64 `json:"objective_percent"`
+	LatencyTargetMs             int     `json:"latency_target_ms"`
+	Met                         bool    `json:"met"`
+	ErrorBudget                 float64 `json:"error_budget"`
+	ErrorBudgetRemainingPercent float64 `json:"error_budget_remaining_percent"`
 }
 
 // SystemStatus represents the overall system status.
@@ -290,6 +500,7 @@ type SystemStatus struct {
 	DBSizeBytes      int64              `json:"db_size_bytes"`
 	DBSizeHuman      string             `json:"db_size_human"`
 	RequestsCount    int64              `json:"requests_count"`
+	CountEstimated   bool               `json:"requests_count_estimated,omitempty"`
 	HourlyRollups    int64              `json:"hourly_rollups"`
 	DailyRollups     int64              `json:"daily_rollups"`
 	LastImportTime   *time.Time         `json:"last_import_time,omitempty"`
@@ -300,25 +511,29 @@ type SystemStatus struct {
 }
 
 // ResponseTimeStats holds response time percentile statistics.
+//
+// Percentiles are nil (and omitted from JSON) when LowSample is set: fewer
+// requests than the storage's PercentileMinSamples had a response time.
 type ResponseTimeStats struct {
-	Min    float64 `json:"min_ms"`
-	Max    float64 `json:"max_ms"`
-	Avg    float64 `json:"avg_ms"`
-	P50    float64 `json:"p50_ms"`
-	P90    float64 `json:"p90_ms"`
-	P95    float64 `json:"p95_ms"`
-	P99    float64 `json:"p99_ms"`
-	Count  int64   `json:"count"`
-	StdDev float64 `json:"std_dev_ms"`
+	Min       Millis  `json:"min_ms"`
+	Max       Millis  `json:"max_ms"`
+	Avg       Millis  `json:"avg_ms"`
+	P50       *Millis `json:"p50_ms,omitempty"`
+	P90       *Millis `json:"p90_ms,omitempty"`
+	P95       *Millis `json:"p95_ms,omitempty"`
+	P99       *Millis `json:"p99_ms,omitempty"`
+	Count     int64   `json:"count"`
+	StdDev    Millis  `json:"std_dev_ms"`
+	LowSample bool    `json:"low_sample,omitempty"`
 }
 
 // SlowPageStat represents a slow page with its response time statistics.
 type SlowPageStat struct {
 	Path          string  `json:"path"`
 	Count         int64   `json:"count"`
-	AvgResponseMs float64 `json:"avg_response_ms"`
-	MaxResponseMs float64 `json:"max_response_ms"`
-	P95ResponseMs float64 `json:"p95_response_ms"`
+	AvgResponseMs Millis  `json:"avg_response_ms"`
+	MaxResponseMs Millis  `json:"max_response_ms"`
+	P95ResponseMs Millis  `json:"p95_response_ms"`
 	TotalBytes    int64   `json:"total_bytes"`
 	ErrorRate     float64 `json:"error_rate"`
 }
@@ -330,12 +545,56 @@ type PerformanceStats struct {
 	ByStatus     []StatusPerfStat  `json:"by_status"`
 }
 
+// RollupCounts is one row's worth of counters in the rollup tables.
+type RollupCounts struct {
+	Host      string `json:"host"`
+	Path      string `json:"path"`
+	Requests  int64  `json:"requests"`
+	Bytes     int64  `json:"bytes"`
+	Status2xx int64  `json:"status_2xx"`
+	Status3xx int64  `json:"status_3xx"`
+	Status4xx int64  `json:"status_4xx"`
+	Status5xx int64  `json:"status_5xx"`
+	// StatusOther counts codes outside 200-599, e.g. 0 for dropped connections
+	StatusOther int64 `json:"status_other"`
+}
+
+// RealtimeStats covers the last few minutes of traffic.
+type RealtimeStats struct {
+	Minutes        int           `json:"minutes"`
+	TotalRequests  int64         `json:"total_requests"`
+	ActiveVisitors int64         `json:"active_visitors"` // Distinct non-bot IPs in the window
+	PerMinute      []MinuteCount `json:"per_minute"`      // Oldest first
+}
+
+// OnlineStats is the "online now" count for a recent window.
+type OnlineStats struct {
+	ActiveVisitors int64 `json:"active_visitors"`
+	WindowSeconds  int64 `json:"window_seconds"`
+}
+
+// MinuteCount is the number of requests in the minute starting at Minute.
+type MinuteCount struct {
+	Minute   time.Time `json:"minute"`
+	Requests int64     `json:"requests"`
+}
+
+// PeakTrafficStat describes the busiest bucket of traffic within a range.
+type PeakTrafficStat struct {
+	BucketSeconds int64     `json:"bucket_seconds"`
+	PeakRequests  int64     `json:"peak_requests"`   // Requests in the busiest bucket
+	PeakAt        time.Time `json:"peak_at"`         // Start of the busiest bucket
+	PeakPerSecond float64   `json:"peak_per_second"` // PeakRequests / BucketSeconds
+	TotalRequests int64     `json:"total_requests"`
+	AvgPerSecond  float64   `json:"avg_per_second"` // Over the whole range
+}
+
 // StatusPerfStat holds performance stats grouped by status code range.
 type StatusPerfStat struct {
-	StatusRange   string  `json:"status_range"`
-	Count         int64   `json:"count"`
-	AvgResponseMs float64 `json:"avg_response_ms"`
-	P95ResponseMs float64 `json:"p95_response_ms"`
+	StatusRange   string `json:"status_range"`
+	Count         int64  `json:"count"`
+	AvgResponseMs Millis `json:"avg_response_ms"`
+	P95ResponseMs Millis `json:"p95_response_ms"`
 }
 
 // BandwidthStats holds comprehensive bandwidth statistics.
@@ -368,6 +627,30 @@ type PathBandwidth struct {
 	Percent    float64 `json:"percent"`
 }
 
+// CountryBandwidth holds bandwidth statistics for a single country.
+type CountryBandwidth struct {
+	Country    string  `json:"country"`
+	Bytes      int64   `json:"bytes"`
+	BytesHuman string  `json:"bytes_human"`
+	Requests   int64   `json:"requests"`
+	Percent    float64 `json:"percent"`
+}
+
+// DownloadStat describes a path by its largest single response.
+type DownloadStat struct {
+	Path          string `json:"path"`
+	MaxBytes      int64  `json:"max_bytes"`
+	MaxBytesHuman string `json:"max_bytes_human"`
+	Requests      int64  `json:"requests"`
+	TotalBytes    int64  `json:"total_bytes"`
+}
+
+// ZeroByteStat counts successful responses with an empty body for a path.
+type ZeroByteStat struct {
+	Path  string `json:"path"`
+	Count int64  `json:"count"`
+}
+
 // ContentBandwidth holds bandwidth statistics grouped by content type (file extension).
 type ContentBandwidth struct {
 	ContentType string  `json:"content_type"`
@@ -0,0 +1,150 @@
+package useragent
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// DeviceRule extracts a device brand and model from a user-agent. Pattern is
+// matched against the raw user-agent; its first capture group is the model
+// unless Model is set, in which case Model is used as-is. Rename maps a
+// captured model prefix (e.g. a Samsung part number) to a marketing name.
+type DeviceRule struct {
+	Brand   string
+	Pattern *regexp.Regexp
+	Model   string
+	Rename  map[string]string
+}
+
+// model returns the model named by ua, or "" when the rule doesn't match.
+func (r DeviceRule) model(ua string) string {
+	m := r.Pattern.FindStringSubmatch(ua)
+	if m == nil {
+		return ""
+	}
+	if r.Model != "" {
+		return r.Model
+	}
+	if len(m) < 2 {
+		return ""
+	}
+	model := strings.TrimSpace(m[1])
+	for prefix, name := range r.Rename {
+		if strings.HasPrefix(model, prefix) {
+			return name
+		}
+	}
+	return model
+}
+
+// samsungModels names popular Galaxy phones by part number; the letter that
+// follows the prefix only marks the regional variant.
+var samsungModels = map[string]string{
+	"SM-S901": "Galaxy S22",
+	"SM-S906": "Galaxy S22+",
+	"SM-S908": "Galaxy S22 Ultra",
+	"SM-S911": "Galaxy S23",
+	"SM-S916": "Galaxy S23+",
+	"SM-S918": "Galaxy S23 Ultra",
+	"SM-S921": "Galaxy S24",
+	"SM-S926": "Galaxy S24+",
+	"SM-S928": "Galaxy S24 Ultra",
+	"SM-A546": "Galaxy A54",
+	"SM-A536": "Galaxy A53",
+	"SM-F946": "Galaxy Z Fold5",
+	"SM-F731": "Galaxy Z Flip5",
+}
+
+// defaultDeviceRules returns the built-in device rules, checked in order.
+func defaultDeviceRules() []DeviceRule {
+	return []DeviceRule{
+		{Brand: "Apple", Pattern: regexp.MustCompile(`\((iPhone|iPad|iPod)\b`)},
+		{Brand: "Google", Pattern: regexp.MustCompile(`; (Pixel[^;)]*?)(?: Build/[^;)]*)?[;)]`)},
+		{Brand: "Samsung", Pattern: regexp.MustCompile(`; (SM-[A-Z0-9]+)`), Rename: samsungModels},
+		{Brand: "Xiaomi", Pattern: regexp.MustCompile(`; ((?:Redmi|POCO|Mi) [^;)]*?)(?: Build/[^;)]*)?[;)]`)},
+		{Brand: "OnePlus", Pattern: regexp.MustCompile(`; ((?:ONEPLUS|OnePlus) ?[^;)]*?)(?: Build/[^;)]*)?[;)]`)},
+		{Brand: "Motorola", Pattern: regexp.MustCompile(`; ((?:moto|Moto|motorola) [^;)]*?)(?: Build/[^;)]*)?[;)]`)},
+		{Brand: "Huawei", Pattern: regexp.MustCompile(`; (HUAWEI [^;)]*?)(?: Build/[^;)]*)?[;)]`)},
+		{Brand: "Nokia", Pattern: regexp.MustCompile(`; (Nokia [^;)]*?)(?: Build/[^;)]*)?[;)]`)},
+		{Brand: "Amazon", Pattern: regexp.MustCompile(`\b(?:Kindle|Silk)/`), Model: "Kindle"},
+	}
+}
+
+var deviceRules = struct {
+	mu    sync.RWMutex
+	rules []DeviceRule
+}{rules: defaultDeviceRules()}
+
+// deviceRulesFile is the JSON format of DEVICE_RULES_PATH.
+type deviceRulesFile struct {
+	Devices []struct {
+		Brand   string            `json:"brand"`
+		Pattern string            `json:"pattern"`
+		Model   string            `json:"model"`
+		Rename  map[string]string `json:"rename"`
+	} `json:"devices"`
+}
+
+// LoadDeviceRules reads extra device rules from a JSON file and checks them,
+// in file order, before the built-in ones, so they can recognize new devices
+// or override a default. An empty path keeps the built-in rules. Nothing is
+// changed if the file fails to load or any rule is invalid.
+func LoadDeviceRules(path string) error {
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var file deviceRulesFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+	rules := make([]DeviceRule, 0, len(file.Devices)+len(defaultDeviceRules()))
+	for n, d := range file.Devices {
+		if d.Brand == "" || d.Pattern == "" {
+			return fmt.Errorf("%s: device %d needs a brand and a pattern", path, n)
+		}
+		re, err := regexp.Compile(d.Pattern)
+		if err != nil {
+			return fmt.Errorf("%s: device %d: %w", path, n, err)
+		}
+		if d.Model == "" && re.NumSubexp() < 1 {
+			return fmt.Errorf("%s: device %d: pattern needs a capture group when model is unset", path, n)
+		}
+		rules = append(rules, DeviceRule{Brand: d.Brand, Pattern: re, Model: d.Model, Rename: d.Rename})
+	}
+	rules = append(rules, defaultDeviceRules()...)
+
+	deviceRules.mu.Lock()
+	deviceRules.rules = rules
+	deviceRules.mu.Unlock()
+	slog.Info("loaded device rules", "path", path, "count", len(file.Devices))
+	return nil
+}
+
+// resetDeviceRules restores the built-in device rules.
+func resetDeviceRules() {
+	deviceRules.mu.Lock()
+	deviceRules.rules = defaultDeviceRules()
+	deviceRules.mu.Unlock()
+}
+
+// parseDevice returns the brand and model named by uaString, or empty
+// strings when no rule matches.
+func parseDevice(uaString string) (string, string) {
+	deviceRules.mu.RLock()
+	defer deviceRules.mu.RUnlock()
+	for _, r := range deviceRules.rules {
+		if model := r.model(uaString); model != "" {
+			return r.Brand, model
+		}
+	}
+	return "", ""
+}
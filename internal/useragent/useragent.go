@@ -4,9 +4,11 @@ import (
 	"encoding/json"
 	"log/slog"
 	"os"
+	"regexp"
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/mssola/useragent"
 )
@@ -30,6 +32,8 @@ type ParsedUA struct {
 	OS             string
 	OSVersion      string
 	DeviceType     string // desktop, mobile, tablet, bot
+	DeviceBrand    string // e.g. Apple, Google, Samsung; empty when unknown
+	DeviceModel    string // e.g. iPhone, Pixel 7, Galaxy S23; empty when unknown
 	IsBot          bool
 	BotName        string    // name of the bot if IsBot is true
 	BotIntent      BotIntent // intent/category of the bot (seo, social, monitoring, ai, archiver, unknown)
@@ -114,13 +118,39 @@ func defaultBotSignatures() []BotSignature {
 	return sigs
 }
 
-// sortSignatures sorts signatures by length (longest first) for priority matching
+// sortSignatures sorts signatures by length (longest first) for priority
+// matching. The sort is stable, so signatures of equal length keep their
+// listed order and the same UA always gets the same name.
 func sortSignatures(sigs []BotSignature) {
-	sort.Slice(sigs, func(i, j int) bool {
+	sort.SliceStable(sigs, func(i, j int) bool {
 		return len(sigs[i].Signature) > len(sigs[j].Signature)
 	})
 }
 
+// signatureSet merges signature lists in order: a repeated signature
+// replaces the earlier entry in place, and new ones are appended.
+type signatureSet struct {
+	sigs  []BotSignature
+	index map[string]int
+}
+
+func newSignatureSet(base []BotSignature) *signatureSet {
+	set := &signatureSet{index: make(map[string]int, len(base))}
+	for _, sig := range base {
+		set.add(sig)
+	}
+	return set
+}
+
+func (set *signatureSet) add(sig BotSignature) {
+	if n, ok := set.index[sig.Signature]; ok {
+		set.sigs[n] = sig
+		return
+	}
+	set.index[sig.Signature] = len(set.sigs)
+	set.sigs = append(set.sigs, sig)
+}
+
 // LoadBotSignatures loads bot signatures from a JSON file.
 // If the file doesn't exist or can't be parsed, it falls back to defaults.
 func LoadBotSignatures(path string) error {
@@ -195,14 +225,11 @@ func LoadBotSignaturesList(paths []string) error {
 	}
 
 	// Start with defaults as base
-	sigMap := make(map[string]BotSignature)
-	for _, sig := range defaultBotSignatures() {
-		sigMap[sig.Signature] = sig
-	}
+	set := newSignatureSet(defaultBotSignatures())
 
 	totalLoaded := 0
 	for _, path := range validPaths {
-		loaded, err := loadAndMergeSignatures(path, sigMap)
+		loaded, err := loadAndMergeSignatures(path, set)
 		if err != nil {
 			slog.Warn("failed to load bot signatures file", "path", path, "error", err)
 			continue
@@ -210,11 +237,7 @@ func LoadBotSignaturesList(paths []string) error {
 		totalLoaded += loaded
 	}
 
-	// Convert map back to slice
-	sigs := make([]BotSignature, 0, len(sigMap))
-	for _, sig := range sigMap {
-		sigs = append(sigs, sig)
-	}
+	sigs := set.sigs
 	sortSignatures(sigs)
 
 	registry.mu.Lock()
@@ -228,9 +251,9 @@ func LoadBotSignaturesList(paths []string) error {
 	return nil
 }
 
-// loadAndMergeSignatures loads signatures from a file and merges them into the map.
+// loadAndMergeSignatures loads signatures from a file and merges them into the set.
 // Returns the number of signatures loaded from this file.
-func loadAndMergeSignatures(path string, sigMap map[string]BotSignature) (int, error) {
+func loadAndMergeSignatures(path string, set *signatureSet) (int, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -262,7 +285,7 @@ func loadAndMergeSignatures(path string, sigMap map[string]BotSignature) (int, e
 			Name:      bot.Name,
 			Intent:    normalizeIntent(bot.Intent),
 		}
-		sigMap[sig.Signature] = sig
+		set.add(sig)
 		loaded++
 	}
 
@@ -289,6 +312,68 @@ func GetBotSignatures() []BotSignature {
 	return sigs
 }
 
+// Strictness controls how eagerly Parse classifies a user-agent as a bot.
+type Strictness int32
+
+const (
+	// StrictnessLoose flags any UA containing a generic token such as "bot"
+	// or "spider". This is the default and can misclassify apps or devices
+	// whose names contain those letters (e.g. "Cubot" phones).
+	StrictnessLoose Strictness = iota
+	// StrictnessBalanced flags signature matches, UAs the parsing library
+	// recognizes as bots, and strong generic tokens ("crawl", "spider",
+	// "bot/").
+	StrictnessBalanced
+	// StrictnessStrict flags only bot signature matches.
+	StrictnessStrict
+)
+
+// weakSignatures are too generic to identify a bot on their own; they only
+// name UAs already detected as bots.
+var weakSignatures = map[string]bool{"bot": true, "ning": true}
+
+// strongBotTokens mark a bot under StrictnessBalanced.
+var strongBotTokens = []string{"crawl", "spider", "slurp", "archiver", "bot/", "bot@"}
+
+var strictness atomic.Int32
+
+// SetStrictness sets the bot detection strictness used by Parse.
+func SetStrictness(s Strictness) {
+	strictness.Store(int32(s))
+}
+
+// CurrentStrictness returns the bot detection strictness used by Parse.
+func CurrentStrictness() Strictness {
+	return Strictness(strictness.Load())
+}
+
+// LookupStrictness converts a name (strict, balanced, loose) to a
+// Strictness, reporting whether the name was recognized. Unknown names
+// return StrictnessLoose and false.
+func LookupStrictness(s string) (Strictness, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "strict":
+		return StrictnessStrict, true
+	case "balanced":
+		return StrictnessBalanced, true
+	case "loose":
+		return StrictnessLoose, true
+	default:
+		return StrictnessLoose, false
+	}
+}
+
+func (s Strictness) String() string {
+	switch s {
+	case StrictnessStrict:
+		return "strict"
+	case StrictnessBalanced:
+		return "balanced"
+	default:
+		return "loose"
+	}
+}
+
 // ResetBotSignatures resets the bot signatures to defaults (useful for testing)
 func ResetBotSignatures() {
 	registry.mu.Lock()
@@ -310,11 +395,10 @@ func Parse(uaString string) ParsedUA {
 	result := ParsedUA{}
 
 	// Check if it's a bot first
-	result.IsBot = ua.Bot()
 	lowerUA := strings.ToLower(uaString)
 
 	// Try to identify specific bots
-	if result.IsBot || containsAny(lowerUA, "bot", "crawler", "spider", "crawl", "slurp", "archiver") {
+	if detectBot(ua, lowerUA) {
 		result.IsBot = true
 		result.DeviceType = "bot"
 		result.BotName, result.BotIntent = identifyBot(lowerUA)
@@ -352,10 +436,36 @@ func Parse(uaString string) ParsedUA {
 	if result.DeviceType == "" {
 		result.DeviceType = "unknown"
 	}
+	result.DeviceBrand, result.DeviceModel = parseDevice(uaString)
 
 	return result
 }
 
+// detectBot reports whether the UA is a bot under the current strictness.
+func detectBot(ua *useragent.UserAgent, lowerUA string) bool {
+	switch CurrentStrictness() {
+	case StrictnessStrict:
+		return matchesSignature(lowerUA)
+	case StrictnessBalanced:
+		return ua.Bot() || matchesSignature(lowerUA) || containsAny(lowerUA, strongBotTokens...)
+	default:
+		return ua.Bot() || containsAny(lowerUA, "bot", "crawler", "spider", "crawl", "slurp", "archiver")
+	}
+}
+
+// matchesSignature reports whether the UA contains a bot signature other
+// than the weak generic ones.
+func matchesSignature(lowerUA string) bool {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	for _, sig := range registry.signatures {
+		if !weakSignatures[sig.Signature] && strings.Contains(lowerUA, sig.Signature) {
+			return true
+		}
+	}
+	return false
+}
+
 func containsAny(s string, substrs ...string) bool {
 	for _, sub := range substrs {
 		if strings.Contains(s, sub) {
@@ -425,7 +535,11 @@ func parseOS(osInfo, lowerUA string) (string, string) {
 
 	// Windows detection
 	if strings.Contains(osLower, "windows") {
-		return "Windows", extractWindowsVersion(osInfo)
+		ver := extractWindowsVersion(osInfo)
+		if ver == "" {
+			ver = windowsNTVersion(lowerUA)
+		}
+		return "Windows", ver
 	}
 
 	// macOS detection
@@ -470,7 +584,36 @@ func extractVersion(osInfo string) string {
 	return ""
 }
 
+// windowsNTVersions maps Windows NT kernel versions to release names.
+// NT 10.0 covers Windows 11 too; user-agents don't tell them apart.
+var windowsNTVersions = map[string]string{
+	"10.0": "10",
+	"6.3":  "8.1",
+	"6.2":  "8",
+	"6.1":  "7",
+	"6.0":  "Vista",
+	"5.2":  "XP", // XP x64
+	"5.1":  "XP",
+}
+
+var windowsNTPattern = regexp.MustCompile(`(?i)windows nt (\d+\.\d+)`)
+
+// windowsNTVersion returns the release name for a "Windows NT x.y" token
+// in s. The version is matched whole, so "10.0" is never read as "1.0".
+func windowsNTVersion(s string) string {
+	m := windowsNTPattern.FindStringSubmatch(s)
+	if m == nil {
+		return ""
+	}
+	return windowsNTVersions[m[1]]
+}
+
+// extractWindowsVersion returns the Windows release from an OS string,
+// either an NT version ("Windows NT 6.1") or a name ("Windows 7").
 func extractWindowsVersion(osInfo string) string {
+	if ver := windowsNTVersion(osInfo); ver != "" {
+		return ver
+	}
 	osLower := strings.ToLower(osInfo)
 	if strings.Contains(osLower, "11") {
 		return "11"
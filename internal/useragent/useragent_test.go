@@ -3,6 +3,7 @@ package useragent
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -198,32 +199,31 @@ func TestParse_DeviceTypes(t *testing.T) {
 }
 
 func TestParse_Bots(t *testing.T) {
-	// Note: The bot detection uses a map with non-deterministic iteration order.
-	// This means specific bot names may or may not be detected depending on which
-	// key is checked first (e.g., "bot" may match before "googlebot").
-	// These tests verify that bots ARE detected, rather than their specific names.
-
+	// Signatures are matched longest first, ties in listed order, so the
+	// most specific name always wins (e.g. "googlebot" before "bot", and
+	// "claudebot" before the equally long "anthropic").
 	botsToDetect := []struct {
-		name string
-		ua   string
+		name     string
+		ua       string
+		wantName string
 	}{
-		{"Googlebot", "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"},
-		{"Bingbot", "Mozilla/5.0 (compatible; bingbot/2.0; +http://www.bing.com/bingbot.htm)"},
-		{"YandexBot", "Mozilla/5.0 (compatible; YandexBot/3.0; +http://yandex.com/bots)"},
-		{"DuckDuckBot", "DuckDuckBot/1.0; (+http://duckduckgo.com/duckduckbot.html)"},
-		{"Baiduspider", "Mozilla/5.0 (compatible; Baiduspider/2.0; +http://www.baidu.com/search/spider.html)"},
-		{"Facebook", "facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)"},
-		{"Twitter", "Twitterbot/1.0"},
-		{"LinkedInBot", "LinkedInBot/1.0 (compatible; Mozilla/5.0; Apache-HttpClient +http://www.linkedin.com)"},
-		{"GPTBot", "Mozilla/5.0 AppleWebKit/537.36 (KHTML, like Gecko; compatible; GPTBot/1.0; +https://openai.com/gptbot)"},
-		{"ClaudeBot", "Mozilla/5.0 AppleWebKit/537.36 (KHTML, like Gecko; compatible; ClaudeBot/1.0; +claudebot@anthropic.com)"},
-		{"AhrefsBot", "Mozilla/5.0 (compatible; AhrefsBot/7.0; +http://ahrefs.com/robot/)"},
-		{"SemrushBot", "Mozilla/5.0 (compatible; SemrushBot/7~bl; +http://www.semrush.com/bot.html)"},
-		{"UptimeRobot", "Mozilla/5.0+(compatible; UptimeRobot/2.0; http://www.uptimerobot.com/)"},
-		{"Applebot", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_5) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/13.1.1 Safari/605.1.15 (Applebot/0.1; +http://www.apple.com/go/applebot)"},
-		{"Generic crawler", "Mozilla/5.0 (compatible; MyCrawler/1.0)"},
-		{"Generic spider", "MySpider/1.0 (+http://example.com/spider)"},
-		{"Generic bot", "SomeBot/1.0"},
+		{"Googlebot", "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", "Googlebot"},
+		{"Bingbot", "Mozilla/5.0 (compatible; bingbot/2.0; +http://www.bing.com/bingbot.htm)", "Bingbot"},
+		{"YandexBot", "Mozilla/5.0 (compatible; YandexBot/3.0; +http://yandex.com/bots)", "YandexBot"},
+		{"DuckDuckBot", "DuckDuckBot/1.0; (+http://duckduckgo.com/duckduckbot.html)", "DuckDuckBot"},
+		{"Baiduspider", "Mozilla/5.0 (compatible; Baiduspider/2.0; +http://www.baidu.com/search/spider.html)", "Baiduspider"},
+		{"Facebook", "facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)", "Facebook"},
+		{"Twitter", "Twitterbot/1.0", "Twitterbot"},
+		{"LinkedInBot", "LinkedInBot/1.0 (compatible; Mozilla/5.0; Apache-HttpClient +http://www.linkedin.com)", "LinkedInBot"},
+		{"GPTBot", "Mozilla/5.0 AppleWebKit/537.36 (KHTML, like Gecko; compatible; GPTBot/1.0; +https://openai.com/gptbot)", "GPTBot"},
+		{"ClaudeBot", "Mozilla/5.0 AppleWebKit/537.36 (KHTML, like Gecko; compatible; ClaudeBot/1.0; +claudebot@anthropic.com)", "ClaudeBot"},
+		{"AhrefsBot", "Mozilla/5.0 (compatible; AhrefsBot/7.0; +http://ahrefs.com/robot/)", "AhrefsBot"},
+		{"SemrushBot", "Mozilla/5.0 (compatible; SemrushBot/7~bl; +http://www.semrush.com/bot.html)", "SemrushBot"},
+		{"UptimeRobot", "Mozilla/5.0+(compatible; UptimeRobot/2.0; http://www.uptimerobot.com/)", "UptimeRobot"},
+		{"Applebot", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_5) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/13.1.1 Safari/605.1.15 (Applebot/0.1; +http://www.apple.com/go/applebot)", "Applebot"},
+		{"Generic crawler", "Mozilla/5.0 (compatible; MyCrawler/1.0)", "Unknown Crawler"},
+		{"Generic spider", "MySpider/1.0 (+http://example.com/spider)", "Unknown Spider"},
+		{"Generic bot", "SomeBot/1.0", "Unknown Bot"},
 	}
 
 	for _, tt := range botsToDetect {
@@ -235,8 +235,8 @@ func TestParse_Bots(t *testing.T) {
 			if result.DeviceType != "bot" {
 				t.Errorf("DeviceType = %q, want %q for bot", result.DeviceType, "bot")
 			}
-			if result.BotName == "" {
-				t.Errorf("BotName should not be empty for detected bot")
+			if result.BotName != tt.wantName {
+				t.Errorf("BotName = %q, want %q", result.BotName, tt.wantName)
 			}
 		})
 	}
@@ -445,16 +445,16 @@ func TestExtractWindowsVersion(t *testing.T) {
 	}{
 		{"Windows NT 11.0", "11"},
 		{"Windows NT 10.0", "10"},
-		// Note: The implementation checks for "8.1", "8", "7" as substrings,
-		// but NT version strings like "6.3", "6.2", "6.1" don't contain these
-		{"Windows NT 6.3", ""}, // Would need to detect as 8.1
-		{"Windows NT 6.2", ""}, // Would need to detect as 8
-		{"Windows NT 6.1", ""}, // Would need to detect as 7
+		// NT kernel versions map to release names
+		{"Windows NT 6.3", "8.1"},
+		{"Windows NT 6.2", "8"},
+		{"Windows NT 6.1", "7"},
+		{"Windows NT 6.0", "Vista"},
+		{"Windows NT 5.1", "XP"},
 		{"Windows Vista", "Vista"},
 		{"Windows XP", "XP"},
-		{"Windows NT 6.0", ""}, // Vista by NT version, but not matched here
 		{"Linux", ""},
-		// These work because they contain the substring directly
+		// Release names are matched directly
 		{"Windows 8.1", "8.1"},
 		{"Windows 8", "8"},
 		{"Windows 7", "7"},
@@ -470,6 +470,29 @@ func TestExtractWindowsVersion(t *testing.T) {
 	}
 }
 
+func TestParse_WindowsNTVersions(t *testing.T) {
+	tests := []struct {
+		nt   string
+		want string
+	}{
+		{"10.0", "10"},
+		{"6.3", "8.1"},
+		{"6.2", "8"},
+		{"6.1", "7"},
+		{"6.0", "Vista"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.nt, func(t *testing.T) {
+			ua := "Mozilla/5.0 (Windows NT " + tt.nt + "; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/109.0.0.0 Safari/537.36"
+			result := Parse(ua)
+			if result.OS != "Windows" || result.OSVersion != tt.want {
+				t.Errorf("Parse(NT %s) = %s %q, want Windows %q", tt.nt, result.OS, result.OSVersion, tt.want)
+			}
+		})
+	}
+}
+
 func TestExtractMacOSVersion(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -875,6 +898,50 @@ func TestSortSignatures(t *testing.T) {
 	}
 }
 
+func TestSortSignatures_TiesKeepListedOrder(t *testing.T) {
+	sigs := []BotSignature{
+		{Signature: "zbot", Name: "Z"},
+		{Signature: "googlebot", Name: "Long"},
+		{Signature: "abot", Name: "A"},
+		{Signature: "mbot", Name: "M"},
+	}
+
+	sortSignatures(sigs)
+
+	var got []string
+	for _, sig := range sigs {
+		got = append(got, sig.Signature)
+	}
+	if want := "googlebot zbot abot mbot"; strings.Join(got, " ") != want {
+		t.Errorf("sorted order = %v, want %s", got, want)
+	}
+}
+
+func TestLoadBotSignaturesList_DeterministicOrder(t *testing.T) {
+	defer ResetBotSignatures()
+
+	// Two equally long signatures that both match the same UA; the one
+	// listed first must win every time, whatever the merge order.
+	path := filepath.Join(t.TempDir(), "bots.json")
+	content := `{"bots": [
+		{"signature": "alphacrawl", "name": "Alpha", "intent": "seo"},
+		{"signature": "crawlomega", "name": "Omega", "intent": "seo"}
+	]}`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	for n := 0; n < 20; n++ {
+		ResetBotSignatures()
+		if err := LoadBotSignaturesList([]string{path}); err != nil {
+			t.Fatalf("LoadBotSignaturesList returned error: %v", err)
+		}
+		if got := Parse("alphacrawlomega/1.0").BotName; got != "Alpha" {
+			t.Fatalf("load %d: BotName = %q, want Alpha", n, got)
+		}
+	}
+}
+
 func TestLoadBotSignaturesList(t *testing.T) {
 	// Reset to defaults after each test
 	defer ResetBotSignatures()
@@ -1094,3 +1161,172 @@ func TestLoadBotSignaturesList(t *testing.T) {
 		}
 	})
 }
+
+func TestParse_Strictness(t *testing.T) {
+	defer SetStrictness(StrictnessLoose)
+
+	const cubot = "Mozilla/5.0 (Linux; Android 12; Cubot P80) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36"
+	tests := []struct {
+		name string
+		ua   string
+		want map[Strictness]bool
+	}{
+		{"Cubot phone", cubot, map[Strictness]bool{StrictnessLoose: true, StrictnessBalanced: false, StrictnessStrict: false}},
+		{"generic crawler", "Mozilla/5.0 (compatible; MyCrawler/1.0)", map[Strictness]bool{StrictnessLoose: true, StrictnessBalanced: true, StrictnessStrict: true}},
+		{"named bot", "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", map[Strictness]bool{StrictnessLoose: true, StrictnessBalanced: true, StrictnessStrict: true}},
+		{"generic bot token", "SomeBot/1.0", map[Strictness]bool{StrictnessLoose: true, StrictnessBalanced: true, StrictnessStrict: false}},
+		{"browser", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.0.0 Safari/537.36", map[Strictness]bool{StrictnessLoose: false, StrictnessBalanced: false, StrictnessStrict: false}},
+	}
+	for _, tt := range tests {
+		for level, want := range tt.want {
+			SetStrictness(level)
+			if got := Parse(tt.ua).IsBot; got != want {
+				t.Errorf("%s: Parse().IsBot = %v in %s mode, want %v", tt.name, got, level, want)
+			}
+		}
+	}
+}
+
+func TestLookupStrictness(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want Strictness
+		ok   bool
+	}{
+		{"strict", StrictnessStrict, true},
+		{" Balanced ", StrictnessBalanced, true},
+		{"LOOSE", StrictnessLoose, true},
+		{"paranoid", StrictnessLoose, false},
+	} {
+		got, ok := LookupStrictness(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("LookupStrictness(%q) = %v, %v; want %v, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParse_Device(t *testing.T) {
+	tests := []struct {
+		name      string
+		ua        string
+		wantBrand string
+		wantModel string
+	}{
+		{"Pixel 7", "Mozilla/5.0 (Linux; Android 14; Pixel 7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.0.0 Mobile Safari/537.36", "Google", "Pixel 7"},
+		{"Pixel 7 Pro with build", "Mozilla/5.0 (Linux; Android 13; Pixel 7 Pro Build/TQ3A.230805.001) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/116.0.0.0 Mobile Safari/537.36", "Google", "Pixel 7 Pro"},
+		{"iPhone", "Mozilla/5.0 (iPhone; CPU iPhone OS 17_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Mobile/15E148 Safari/604.1", "Apple", "iPhone"},
+		{"iPad", "Mozilla/5.0 (iPad; CPU OS 17_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Mobile/15E148 Safari/604.1", "Apple", "iPad"},
+		{"Galaxy S23", "Mozilla/5.0 (Linux; Android 14; SM-S911B) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36", "Samsung", "Galaxy S23"},
+		{"unnamed Samsung", "Mozilla/5.0 (Linux; Android 11; SM-T500) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36", "Samsung", "SM-T500"},
+		{"reduced Android UA", "Mozilla/5.0 (Linux; Android 10; K) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36", "", ""},
+		{"desktop", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.0.0 Safari/537.36", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Parse(tt.ua)
+			if got.DeviceBrand != tt.wantBrand || got.DeviceModel != tt.wantModel {
+				t.Errorf("Parse() device = %q %q, want %q %q", got.DeviceBrand, got.DeviceModel, tt.wantBrand, tt.wantModel)
+			}
+		})
+	}
+}
+
+func TestLoadDeviceRules(t *testing.T) {
+	defer resetDeviceRules()
+	dir := t.TempDir()
+	write := func(name, body string) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	const fairphone = "Mozilla/5.0 (Linux; Android 13; FP5) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36"
+	const pixel = "Mozilla/5.0 (Linux; Android 14; Pixel 7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36"
+
+	for name, body := range map[string]string{
+		"no capture group": `{"devices": [{"brand": "Fairphone", "pattern": "; FP\\d"}]}`,
+		"bad pattern":      `{"devices": [{"brand": "Fairphone", "pattern": "(FP"}]}`,
+		"no brand":         `{"devices": [{"pattern": "; (FP\\d)"}]}`,
+	} {
+		if err := LoadDeviceRules(write("bad.json", body)); err == nil {
+			t.Errorf("%s: LoadDeviceRules() error = nil, want error", name)
+		}
+	}
+	if got := Parse(fairphone); got.DeviceBrand != "" {
+		t.Errorf("failed load changed rules: device brand = %q", got.DeviceBrand)
+	}
+
+	path := write("devices.json", `{"devices": [{"brand": "Fairphone", "pattern": "; (FP\\d)[;)]", "rename": {"FP5": "Fairphone 5"}}]}`)
+	if err := LoadDeviceRules(path); err != nil {
+		t.Fatalf("LoadDeviceRules() error = %v", err)
+	}
+	if got := Parse(fairphone); got.DeviceBrand != "Fairphone" || got.DeviceModel != "Fairphone 5" {
+		t.Errorf("Parse() device = %q %q, want Fairphone Fairphone 5", got.DeviceBrand, got.DeviceModel)
+	}
+	// Built-in rules still apply after the loaded ones
+	if got := Parse(pixel); got.DeviceBrand != "Google" || got.DeviceModel != "Pixel 7" {
+		t.Errorf("Parse() device = %q %q, want Google Pixel 7", got.DeviceBrand, got.DeviceModel)
+	}
+}
+
+func TestVerifyBot(t *testing.T) {
+	tests := []struct {
+		name    string
+		botName string
+		ip      string
+		want    string
+	}{
+		{"real Googlebot", "Googlebot", "66.249.66.1", VerificationVerified},
+		{"real Googlebot IPv6", "Googlebot", "2001:4860:4801:10::1", VerificationVerified},
+		{"spoofed Googlebot", "Googlebot", "203.0.113.9", VerificationUnverified},
+		{"mapped IPv4", "Googlebot", "::ffff:66.249.66.1", VerificationVerified},
+		{"no published ranges", "AhrefsBot", "66.249.66.1", ""},
+		{"unparseable IP", "Googlebot", "not-an-ip", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VerifyBot(tt.botName, tt.ip); got != tt.want {
+				t.Errorf("VerifyBot(%q, %q) = %q, want %q", tt.botName, tt.ip, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadBotRanges(t *testing.T) {
+	defer resetBotRanges()
+	dir := t.TempDir()
+	google := filepath.Join(dir, "googlebot.json")
+	published := `{"creationTime": "2026-01-01T00:00:00", "prefixes": [{"ipv6Prefix": "2001:4860:4801:2::/64"}, {"ipv4Prefix": "192.178.5.0/27"}]}`
+	if err := os.WriteFile(google, []byte(published), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := LoadBotRanges([]string{"Googlebot=" + google, " "}); err != nil {
+		t.Fatalf("LoadBotRanges() error = %v", err)
+	}
+	if got := VerifyBot("Googlebot", "192.178.5.7"); got != VerificationVerified {
+		t.Errorf("VerifyBot(loaded range) = %q, want verified", got)
+	}
+	// The file replaces the built-in Googlebot ranges
+	if got := VerifyBot("Googlebot", "66.249.66.1"); got != VerificationUnverified {
+		t.Errorf("VerifyBot(built-in range) = %q, want unverified", got)
+	}
+	if got := VerifyBot("Bingbot", "40.77.167.1"); got != VerificationVerified {
+		t.Errorf("VerifyBot(Bingbot) = %q, want the built-in ranges kept", got)
+	}
+
+	empty := filepath.Join(dir, "empty.json")
+	if err := os.WriteFile(empty, []byte(`{"prefixes": []}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, specs := range [][]string{{"Googlebot"}, {"Bingbot=" + empty}, {"Bingbot=" + filepath.Join(dir, "missing.json")}} {
+		if err := LoadBotRanges(specs); err == nil {
+			t.Errorf("LoadBotRanges(%q) error = nil, want an error", specs)
+		}
+	}
+	if got := VerifyBot("Bingbot", "40.77.167.1"); got != VerificationVerified {
+		t.Errorf("VerifyBot(Bingbot) after failed load = %q, want unchanged ranges", got)
+	}
+}
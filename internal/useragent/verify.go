@@ -0,0 +1,178 @@
+package useragent
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Bot verification results stored alongside bot requests.
+const (
+	VerificationVerified   = "verified"   // client IP is inside the bot's published ranges
+	VerificationUnverified = "unverified" // UA claims the bot but the IP is outside its ranges
+)
+
+// defaultBotRanges is a snapshot of the published crawler address ranges for
+// bots that can be verified by IP. The operators change these lists over
+// time, so LoadBotRanges can replace them with the current files.
+var defaultBotRanges = map[string][]netip.Prefix{
+	"Googlebot": mustPrefixes(
+		"66.249.64.0/19",
+		"2001:4860:4801::/48",
+	),
+	"Bingbot": mustPrefixes(
+		"40.77.167.0/24",
+		"157.55.39.0/24",
+		"207.46.13.0/24",
+		"13.66.139.0/24",
+		"52.167.144.0/24",
+	),
+	"Applebot": mustPrefixes(
+		"17.0.0.0/8",
+	),
+	"DuckDuckBot": mustPrefixes(
+		"20.191.45.212/32",
+		"40.88.21.235/32",
+		"40.76.173.151/32",
+		"40.76.163.7/32",
+		"20.185.79.47/32",
+		"52.142.26.175/32",
+		"20.185.79.15/32",
+		"52.142.24.149/32",
+		"40.76.162.208/32",
+		"40.76.163.23/32",
+		"40.76.162.191/32",
+		"40.76.162.247/32",
+	),
+}
+
+// botRanges holds the ranges VerifyBot checks. Bots not listed are
+// reported as unverifiable.
+var botRanges = struct {
+	mu     sync.RWMutex
+	ranges map[string][]netip.Prefix
+}{ranges: defaultBotRanges}
+
+func mustPrefixes(cidrs ...string) []netip.Prefix {
+	out := make([]netip.Prefix, len(cidrs))
+	for i, c := range cidrs {
+		out[i] = netip.MustParsePrefix(c)
+	}
+	return out
+}
+
+// VerifyBot checks the client IP of a request whose user-agent claims to be
+// botName against that bot's published ranges. It returns
+// VerificationVerified or VerificationUnverified, or "" when the bot has no
+// known ranges or the IP can't be parsed.
+func VerifyBot(botName, ip string) string {
+	botRanges.mu.RLock()
+	ranges, ok := botRanges.ranges[botName]
+	botRanges.mu.RUnlock()
+	if !ok {
+		return ""
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ""
+	}
+	addr = addr.Unmap()
+	for _, p := range ranges {
+		if p.Contains(addr) {
+			return VerificationVerified
+		}
+	}
+	return VerificationUnverified
+}
+
+// publishedRanges is the JSON format Google, Bing and Apple publish their
+// crawler ranges in, e.g. https://developers.google.com/static/search/apis/ipranges/googlebot.json.
+type publishedRanges struct {
+	Prefixes []struct {
+		IPv4Prefix string `json:"ipv4Prefix"`
+		IPv6Prefix string `json:"ipv6Prefix"`
+	} `json:"prefixes"`
+}
+
+// LoadBotRanges replaces the built-in ranges of the named bots with ones read
+// from files in the published JSON format. Each spec is "BotName=path", where
+// BotName is the name bot detection reports, e.g.
+// "Googlebot=/config/googlebot.json". A bot without a built-in list becomes
+// verifiable. Nothing is changed if any file fails to load.
+func LoadBotRanges(specs []string) error {
+	loaded := make(map[string][]netip.Prefix)
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		name, path, ok := strings.Cut(spec, "=")
+		name, path = strings.TrimSpace(name), strings.TrimSpace(path)
+		if !ok || name == "" || path == "" {
+			return fmt.Errorf("bot ranges %q: want BotName=path", spec)
+		}
+		prefixes, err := readPublishedRanges(path)
+		if err != nil {
+			return fmt.Errorf("bot ranges for %s: %w", name, err)
+		}
+		loaded[name] = prefixes
+	}
+	if len(loaded) == 0 {
+		return nil
+	}
+
+	botRanges.mu.Lock()
+	defer botRanges.mu.Unlock()
+	merged := make(map[string][]netip.Prefix, len(defaultBotRanges)+len(loaded))
+	for name, prefixes := range defaultBotRanges {
+		merged[name] = prefixes
+	}
+	for name, prefixes := range loaded {
+		merged[name] = prefixes
+		slog.Info("loaded bot ranges", "bot", name, "prefixes", len(prefixes))
+	}
+	botRanges.ranges = merged
+	return nil
+}
+
+// resetBotRanges restores the built-in ranges.
+func resetBotRanges() {
+	botRanges.mu.Lock()
+	botRanges.ranges = defaultBotRanges
+	botRanges.mu.Unlock()
+}
+
+// readPublishedRanges parses a published ranges file. A file without any
+// prefix is an error, since it would mark every request from the bot as
+// unverified.
+func readPublishedRanges(path string) ([]netip.Prefix, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file publishedRanges
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	var out []netip.Prefix
+	for _, p := range file.Prefixes {
+		for _, cidr := range []string{p.IPv4Prefix, p.IPv6Prefix} {
+			if cidr == "" {
+				continue
+			}
+			prefix, err := netip.ParsePrefix(cidr)
+			if err != nil {
+				return nil, fmt.Errorf("parse %s: %w", path, err)
+			}
+			out = append(out, prefix.Masked())
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("%s lists no prefixes", path)
+	}
+	return out, nil
+}
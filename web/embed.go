@@ -0,0 +1,24 @@
+// Package web embeds the built dashboard so a single binary can serve it
+// regardless of its working directory.
+package web
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// site holds web/_site as it was when the binary was built. The directory
+// is tracked with only a .gitkeep, so a binary built before `npm run build`
+// embeds an empty site.
+//
+//go:embed all:_site
+var site embed.FS
+
+// Site returns the embedded dashboard rooted at web/_site.
+func Site() fs.FS {
+	sub, err := fs.Sub(site, "_site")
+	if err != nil {
+		panic(err) // "_site" is a valid, embedded path
+	}
+	return sub
+}